@@ -0,0 +1,773 @@
+// Package ast declares the types used to represent the syntax tree of SQL
+// statements, as returned by sqlite3native.Parse.
+//
+// Every node records the position in the query that it was parsed from, and
+// Format renders a node back into canonical SQL. Together, these are intended for
+// tooling that inspects queries without running them, such as linters.
+package ast
+
+import "fmt"
+
+// Pos is a position in the SQL source text.
+type Pos struct {
+	// Offset is the offset in bytes, starting at 0.
+	Offset int
+	// Line is the line number, starting at 1.
+	Line int
+	// Column is the offset in characters within the line, starting at 0.
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Node is implemented by all nodes in the syntax tree.
+type Node interface {
+	// Pos returns the position of the first character of the node.
+	Pos() Pos
+}
+
+// Statement is implemented by all statement nodes.
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expr is implemented by all expression nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// TableRef is implemented by all nodes that can appear in a FROM clause.
+type TableRef interface {
+	Node
+	tableRefNode()
+}
+
+// Statements
+
+// SelectStatement is a SELECT statement:
+//
+//	[<with>] SELECT [DISTINCT] <columns> FROM <from> [WHERE <where>] [GROUP BY <group by>]
+//	  [HAVING <having>] [<compound>]* [ORDER BY <order by>] [LIMIT <limit> [OFFSET <offset>]]
+//
+// The tables in a FROM clause with multiple tables are joined by *Joins. The
+// "LIMIT <offset>, <limit>" form is parsed into the same fields as "LIMIT <limit>
+// OFFSET <offset>".
+type SelectStatement struct {
+	With     *With // or nil; applies to the whole compound, if any
+	Select   Pos   // position of "SELECT"
+	Distinct bool
+	Columns  []*ResultColumn
+	From     TableRef          // or nil
+	Where    Expr              // or nil
+	GroupBy  []Expr            // or nil
+	Having   Expr              // or nil
+	Windows  []*NamedWindow    // the WINDOW clause, or nil
+	Compound []*CompoundSelect // or nil
+	OrderBy  []*OrderingTerm   // or nil; orders the whole compound, if any
+	Limit    Expr              // or nil; limits the whole compound, if any
+	Offset   Expr              // or nil; only set if Limit is
+}
+
+func (s *SelectStatement) Pos() Pos {
+	if s.With != nil {
+		return s.With.With
+	}
+
+	return s.Select
+}
+
+func (*SelectStatement) statementNode() {}
+
+// PragmaStatement is a PRAGMA statement:
+//
+//	PRAGMA [<schema>.]<name> [= <value> | (<value>)]
+//
+// The value is a number, which may be signed, a string, or a name, f.e. ON,
+// which is an *Ident.
+type PragmaStatement struct {
+	Pragma Pos    // position of "PRAGMA"
+	Schema string // unquoted, or ""
+	Name   string // unquoted
+	Value  Expr   // or nil
+}
+
+func (s *PragmaStatement) Pos() Pos { return s.Pragma }
+
+func (*PragmaStatement) statementNode() {}
+
+// With is the WITH clause of a SELECT, which names the common table expressions
+// that the SELECT and its subqueries may read from like tables:
+//
+//	WITH [RECURSIVE] <cte> [, <cte>]*
+//
+// RECURSIVE is optional, like in SQLite: a CTE is recursive if its body refers to
+// itself.
+type With struct {
+	With      Pos // position of "WITH"
+	Recursive bool
+	CTEs      []*CTE
+}
+
+func (w *With) Pos() Pos { return w.With }
+
+// Materialization is whether the rows of a CTE are computed once and stored, or
+// each time that the CTE is read.
+type Materialization int
+
+const (
+	MaterializeDefault Materialization = iota // stored if the CTE is read more than once
+	Materialized                              // "MATERIALIZED"
+	NotMaterialized                           // "NOT MATERIALIZED"
+)
+
+// CTE is a common table expression of a WITH clause, f.e. "c(a, b) AS (SELECT x,
+// y FROM u)":
+//
+//	<name> [(<column> [, <column>]*)] AS [[NOT] MATERIALIZED] (<select>)
+type CTE struct {
+	NamePos      Pos
+	Name         string   // unquoted
+	Columns      []string // unquoted, or nil
+	Materialized Materialization
+	Select       *SelectStatement
+}
+
+func (c *CTE) Pos() Pos { return c.NamePos }
+
+// CompoundOp is the operator of a compound SELECT.
+type CompoundOp int
+
+const (
+	CompoundUnion     CompoundOp = iota // "UNION"
+	CompoundUnionAll                    // "UNION ALL"
+	CompoundIntersect                   // "INTERSECT"
+	CompoundExcept                      // "EXCEPT"
+)
+
+var compoundOps = map[CompoundOp]string{
+	CompoundUnion:     "UNION",
+	CompoundUnionAll:  "UNION ALL",
+	CompoundIntersect: "INTERSECT",
+	CompoundExcept:    "EXCEPT",
+}
+
+func (op CompoundOp) String() string {
+	if s, ok := compoundOps[op]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("CompoundOp(%d)", int(op))
+}
+
+// CompoundSelect is a SELECT that is combined with the ones before it, f.e.
+// "UNION SELECT a FROM u". Compound operators are left-associative and have the
+// same precedence. The SELECT has no ORDER BY or LIMIT clause, nor any compound
+// of its own; those of the first SELECT apply to the whole compound.
+type CompoundSelect struct {
+	OpPos  Pos // position of the operator's first keyword
+	Op     CompoundOp
+	Select *SelectStatement
+}
+
+func (c *CompoundSelect) Pos() Pos { return c.OpPos }
+
+// ResultColumn is a column in the result of a SELECT, either "*", "table.*" or an
+// expression with an optional alias.
+type ResultColumn struct {
+	Star    bool
+	StarPos Pos    // position of "*", or of its table, if Star is set
+	Table   string // the table of "table.*", unquoted, or ""
+	Expr    Expr   // nil if Star is set
+	Alias   string // the name given with "AS", unquoted, or ""
+}
+
+func (c *ResultColumn) Pos() Pos {
+	if c.Star {
+		return c.StarPos
+	}
+
+	return c.Expr.Pos()
+}
+
+// SortOrder is the direction of an ORDER BY term.
+type SortOrder int
+
+const (
+	// SortDefault is used when no direction was given, which sorts in
+	// ascending order.
+	SortDefault SortOrder = iota
+	SortAsc
+	SortDesc
+)
+
+// NullsOrder is where an ORDER BY term sorts NULLs.
+type NullsOrder int
+
+const (
+	// NullsDefault is used when no order was given, which sorts NULLs first
+	// in ascending order and last in descending order.
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+// OrderingTerm is a term in an ORDER BY clause.
+type OrderingTerm struct {
+	Expr  Expr
+	Order SortOrder
+	Nulls NullsOrder
+}
+
+func (t *OrderingTerm) Pos() Pos { return t.Expr.Pos() }
+
+// NamedWindow is a window definition of a WINDOW clause, f.e. "w AS (ORDER BY
+// a)":
+//
+//	<name> AS <window>
+type NamedWindow struct {
+	NamePos Pos
+	Name    string // unquoted
+	Window  *Window
+}
+
+func (w *NamedWindow) Pos() Pos { return w.NamePos }
+
+// Window is the window of a call to a window function, which follows OVER, f.e.
+// "w" or "(PARTITION BY a ORDER BY b ROWS 1 PRECEDING)":
+//
+//	<name> | ([<base>] [PARTITION BY <expr> [, <expr>]*] [ORDER BY <ordering term> [, <ordering term>]*] [<frame>])
+//
+// A window in parentheses may start with the name of a window of the WINDOW
+// clause that it is based on, which it adds its own clauses to.
+type Window struct {
+	NamePos     Pos    // position of the name, if the window is only a name
+	Name        string // the name of a window of the WINDOW clause, unquoted, or ""
+	Lparen      Pos    // position of "(", if Name is ""
+	Base        string // the name of the window that this one is based on, unquoted, or ""
+	PartitionBy []Expr
+	OrderBy     []*OrderingTerm
+	Frame       *Frame // or nil
+}
+
+func (w *Window) Pos() Pos {
+	if w.Name != "" {
+		return w.NamePos
+	}
+
+	return w.Lparen
+}
+
+// FrameUnit is the unit of the bounds of a frame.
+type FrameUnit int
+
+const (
+	FrameRange  FrameUnit = iota // "RANGE"
+	FrameRows                    // "ROWS"
+	FrameGroups                  // "GROUPS"
+)
+
+var frameUnits = map[FrameUnit]string{
+	FrameRange:  "RANGE",
+	FrameRows:   "ROWS",
+	FrameGroups: "GROUPS",
+}
+
+func (u FrameUnit) String() string {
+	if s, ok := frameUnits[u]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("FrameUnit(%d)", int(u))
+}
+
+// FrameBoundKind is the kind of a bound of a frame.
+type FrameBoundKind int
+
+const (
+	UnboundedPreceding FrameBoundKind = iota // "UNBOUNDED PRECEDING"
+	Preceding                                // "<expr> PRECEDING"
+	CurrentRow                               // "CURRENT ROW"
+	Following                                // "<expr> FOLLOWING"
+	UnboundedFollowing                       // "UNBOUNDED FOLLOWING"
+)
+
+// FrameBound is the start or the end of a frame.
+type FrameBound struct {
+	Kind   FrameBoundKind
+	Offset Expr // set if Kind is Preceding or Following
+}
+
+// FrameExclude is the EXCLUDE clause of a frame.
+type FrameExclude int
+
+const (
+	ExcludeNoOthers   FrameExclude = iota // "EXCLUDE NO OTHERS", or no EXCLUDE clause
+	ExcludeCurrentRow                     // "EXCLUDE CURRENT ROW"
+	ExcludeGroup                          // "EXCLUDE GROUP"
+	ExcludeTies                           // "EXCLUDE TIES"
+)
+
+var frameExcludes = map[FrameExclude]string{
+	ExcludeNoOthers:   "NO OTHERS",
+	ExcludeCurrentRow: "CURRENT ROW",
+	ExcludeGroup:      "GROUP",
+	ExcludeTies:       "TIES",
+}
+
+func (e FrameExclude) String() string {
+	if s, ok := frameExcludes[e]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("FrameExclude(%d)", int(e))
+}
+
+// Frame is the frame of a window, which is the set of rows of the partition that
+// a window function is computed from for each row, f.e. "ROWS BETWEEN 1 PRECEDING
+// AND CURRENT ROW":
+//
+//	<unit> {<start> | BETWEEN <start> AND <end>} [EXCLUDE {NO OTHERS | CURRENT ROW | GROUP | TIES}]
+//
+// A frame with only a start ends at the current row.
+type Frame struct {
+	UnitPos Pos // position of the unit
+	Unit    FrameUnit
+	Start   FrameBound
+	End     FrameBound
+	Exclude FrameExclude
+}
+
+func (f *Frame) Pos() Pos { return f.UnitPos }
+
+// Table references
+
+// TableName is a reference to a table by name, f.e. "t", "main.t" or "t AS x".
+type TableName struct {
+	NamePos Pos    // position of the schema, if any, or else of the name
+	Schema  string // unquoted, or ""
+	Name    string // unquoted
+	Alias   string // unquoted, or ""
+}
+
+func (t *TableName) Pos() Pos { return t.NamePos }
+
+func (*TableName) tableRefNode() {}
+
+// TableFunction is a call to a table-valued function, f.e. pragma_table_info(?).
+type TableFunction struct {
+	NamePos Pos
+	Name    string
+	Args    []Expr
+	Alias   string // unquoted, or ""
+}
+
+func (t *TableFunction) Pos() Pos { return t.NamePos }
+
+func (*TableFunction) tableRefNode() {}
+
+// JoinOp is the operator of a join.
+type JoinOp int
+
+const (
+	// JoinComma joins tables that are separated by a comma, f.e. "a, b", which
+	// is the same as JoinInner.
+	JoinComma JoinOp = iota
+	JoinInner        // "JOIN" or "INNER JOIN"
+	JoinCross        // "CROSS JOIN"
+	JoinLeft         // "LEFT [OUTER] JOIN"
+	JoinRight        // "RIGHT [OUTER] JOIN"
+	JoinFull         // "FULL [OUTER] JOIN"
+)
+
+var joinOps = map[JoinOp]string{
+	JoinComma: ",",
+	JoinInner: "JOIN",
+	JoinCross: "CROSS JOIN",
+	JoinLeft:  "LEFT JOIN",
+	JoinRight: "RIGHT JOIN",
+	JoinFull:  "FULL JOIN",
+}
+
+func (op JoinOp) String() string {
+	if s, ok := joinOps[op]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("JoinOp(%d)", int(op))
+}
+
+// Join is a join of two tables, f.e. "a LEFT JOIN b ON a.id = b.a_id". Joins are
+// left-associative, so Y is never a *Join. A NATURAL join, or one with a USING
+// clause, matches the rows whose columns with the same names are equal.
+type Join struct {
+	X       TableRef
+	OpPos   Pos // position of "," or of the first keyword of the operator
+	Op      JoinOp
+	Natural bool
+	Y       TableRef
+	On      Expr     // or nil
+	Using   []string // unquoted, or nil
+}
+
+func (j *Join) Pos() Pos { return j.X.Pos() }
+
+func (*Join) tableRefNode() {}
+
+// SubqueryTable is a subquery in a FROM clause, f.e. "(SELECT a FROM t) AS x".
+type SubqueryTable struct {
+	Lparen Pos // position of "("
+	Select *SelectStatement
+	Alias  string // unquoted, or ""
+}
+
+func (t *SubqueryTable) Pos() Pos { return t.Lparen }
+
+func (*SubqueryTable) tableRefNode() {}
+
+// Expressions
+
+// Ident is a reference to a column by name, f.e. "a", which may be qualified by
+// its table, and that table's schema, f.e. "t.a" or "main.t.a".
+type Ident struct {
+	NamePos Pos    // position of the first part of the name
+	Schema  string // unquoted, or ""
+	Table   string // unquoted, or ""
+	Name    string // unquoted
+}
+
+func (e *Ident) Pos() Pos { return e.NamePos }
+
+func (*Ident) exprNode() {}
+
+// LiteralKind is the type of a literal value.
+type LiteralKind int
+
+const (
+	NumberLiteral LiteralKind = iota + 1
+	StringLiteral
+	BlobLiteral
+	NullLiteral
+)
+
+// Literal is a number, string, blob or NULL literal. Numbers are never negative:
+// "-1" is a *UnaryExpr.
+type Literal struct {
+	ValuePos Pos
+	Kind     LiteralKind
+	// Value is the literal's value: the number as written, f.e. "1.5e3" or
+	// "0x1F", the unescaped contents of a string, or the hex digits of a blob.
+	// It is empty for NULL.
+	Value string
+}
+
+func (e *Literal) Pos() Pos { return e.ValuePos }
+
+func (*Literal) exprNode() {}
+
+// Param is a bound parameter, f.e. "?", "?1" or ":name".
+type Param struct {
+	NamePos Pos
+	Name    string
+}
+
+func (e *Param) Pos() Pos { return e.NamePos }
+
+func (*Param) exprNode() {}
+
+// Operator is a unary or binary operator.
+type Operator int
+
+const (
+	OpEq Operator = iota + 1
+	OpGt
+	OpAnd
+	OpOr
+	OpLike
+	OpGlob
+	OpRegexp
+	OpNe
+	OpLt
+	OpLe
+	OpGe
+	OpIs
+	OpIsNot
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpRem
+	OpConcat
+	OpBitAnd
+	OpBitOr
+	OpShiftLeft
+	OpShiftRight
+
+	// Unary operators.
+	OpNot
+	OpNeg
+	OpPlus
+	OpBitNot
+)
+
+var operators = map[Operator]string{
+	OpEq:         "=",
+	OpGt:         ">",
+	OpAnd:        "AND",
+	OpOr:         "OR",
+	OpLike:       "LIKE",
+	OpGlob:       "GLOB",
+	OpRegexp:     "REGEXP",
+	OpNe:         "!=",
+	OpLt:         "<",
+	OpLe:         "<=",
+	OpGe:         ">=",
+	OpIs:         "IS",
+	OpIsNot:      "IS NOT",
+	OpAdd:        "+",
+	OpSub:        "-",
+	OpMul:        "*",
+	OpDiv:        "/",
+	OpRem:        "%",
+	OpConcat:     "||",
+	OpBitAnd:     "&",
+	OpBitOr:      "|",
+	OpShiftLeft:  "<<",
+	OpShiftRight: ">>",
+	OpNot:        "NOT",
+	OpNeg:        "-",
+	OpPlus:       "+",
+	OpBitNot:     "~",
+}
+
+func (op Operator) String() string {
+	if s, ok := operators[op]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Operator(%d)", int(op))
+}
+
+// Precedence returns the binding strength of op, where operators with a higher
+// precedence bind more tightly. Like in SQLite, from loosest to tightest, they
+// are:
+//
+//	OR
+//	AND
+//	NOT
+//	= != IS IS NOT LIKE GLOB REGEXP BETWEEN IN
+//	< <= > >=
+//	& | << >>
+//	+ -
+//	* / %
+//	||
+//	COLLATE
+//	unary - + ~
+//
+// Binary operators of the same precedence are left-associative.
+func (op Operator) Precedence() int {
+	switch op {
+	case OpOr:
+		return 1
+	case OpAnd:
+		return 2
+	case OpNot:
+		return 3
+	case OpEq, OpNe, OpIs, OpIsNot, OpLike, OpGlob, OpRegexp:
+		return 4
+	case OpLt, OpLe, OpGt, OpGe:
+		return 5
+	case OpBitAnd, OpBitOr, OpShiftLeft, OpShiftRight:
+		return 6
+	case OpAdd, OpSub:
+		return 7
+	case OpMul, OpDiv, OpRem:
+		return 8
+	case OpConcat:
+		return 9
+	default:
+		return UnaryPrecedence
+	}
+}
+
+const (
+	// CollatePrecedence is the precedence of the COLLATE operator.
+	CollatePrecedence = 10
+	// UnaryPrecedence is the precedence of the unary operators, other than NOT.
+	UnaryPrecedence = 11
+)
+
+// UnaryExpr is a unary expression, f.e. "-a", "~a" or "NOT a".
+type UnaryExpr struct {
+	OpPos Pos      // position of Op
+	Op    Operator // OpNot, OpNeg, OpPlus or OpBitNot
+	X     Expr
+}
+
+func (e *UnaryExpr) Pos() Pos { return e.OpPos }
+
+func (*UnaryExpr) exprNode() {}
+
+// BinaryExpr is a binary expression, f.e. "a = 1" or "a + b". "a IS [NOT]
+// DISTINCT FROM b" is parsed as "a IS NOT b", or "a IS b" with NOT, and "a
+// ISNULL", "a NOTNULL" and "a NOT NULL" as "a IS NULL" and "a IS NOT NULL".
+type BinaryExpr struct {
+	X     Expr
+	OpPos Pos // position of Op
+	Op    Operator
+	Y     Expr
+}
+
+func (e *BinaryExpr) Pos() Pos { return e.X.Pos() }
+
+func (*BinaryExpr) exprNode() {}
+
+// LikeExpr is a pattern matching expression, f.e. "a LIKE 'x%'", "a NOT GLOB '*x'"
+// or "a LIKE 'x!%%' ESCAPE '!'".
+type LikeExpr struct {
+	X      Expr
+	Not    bool
+	OpPos  Pos      // position of "NOT", if Not is set, or else of Op
+	Op     Operator // OpLike, OpGlob or OpRegexp
+	Y      Expr     // the pattern
+	Escape Expr     // or nil
+}
+
+func (e *LikeExpr) Pos() Pos { return e.X.Pos() }
+
+func (*LikeExpr) exprNode() {}
+
+// CallExpr is a call to a function, f.e. "lower(a)", "count(*)" or
+// "count(DISTINCT a) FILTER (WHERE b > 1)".
+type CallExpr struct {
+	NamePos  Pos
+	Name     string // unquoted, as written
+	Distinct bool
+	Star     bool // set for "count(*)", in which case Args is empty
+	Args     []Expr
+	Rparen   Pos     // position of ")"
+	Filter   Expr    // the condition of the FILTER clause, or nil
+	Over     *Window // the window of a window function call, or nil
+}
+
+func (e *CallExpr) Pos() Pos { return e.NamePos }
+
+func (*CallExpr) exprNode() {}
+
+// BetweenExpr is a BETWEEN operator, f.e. "a BETWEEN 1 AND 5" or "a NOT BETWEEN ?
+// AND ?".
+type BetweenExpr struct {
+	X     Expr
+	Not   bool
+	OpPos Pos // position of "NOT", if Not is set, or else of "BETWEEN"
+	Low   Expr
+	High  Expr
+}
+
+func (e *BetweenExpr) Pos() Pos { return e.X.Pos() }
+
+func (*BetweenExpr) exprNode() {}
+
+// CaseExpr is a CASE expression, either with an operand, f.e. "CASE a WHEN 1 THEN
+// 'x' ELSE 'y' END", whose result is that of the first WHEN whose value equals
+// the operand, or without, f.e. "CASE WHEN a > 1 THEN 'x' END", whose result is
+// that of the first WHEN whose condition is true:
+//
+//	CASE [<operand>] <when> [<when>]* [ELSE <else>] END
+//
+// If there is no such WHEN, the result is that of the ELSE clause, or NULL.
+type CaseExpr struct {
+	Case  Pos  // position of "CASE"
+	X     Expr // the operand, or nil
+	Whens []*When
+	Else  Expr // or nil
+}
+
+func (e *CaseExpr) Pos() Pos { return e.Case }
+
+func (*CaseExpr) exprNode() {}
+
+// When is a WHEN clause of a CASE expression:
+//
+//	WHEN <cond> THEN <result>
+type When struct {
+	When   Pos // position of "WHEN"
+	Cond   Expr
+	Result Expr
+}
+
+func (w *When) Pos() Pos { return w.When }
+
+// CastExpr is a CAST expression, f.e. "CAST(? AS BLOB)".
+type CastExpr struct {
+	Cast Pos // position of "CAST"
+	X    Expr
+	Type string // the type name, unquoted, f.e. "VARCHAR(10)" or "DOUBLE PRECISION"
+}
+
+func (e *CastExpr) Pos() Pos { return e.Cast }
+
+func (*CastExpr) exprNode() {}
+
+// CollateExpr gives an expression a collating sequence, f.e. "a COLLATE NOCASE".
+type CollateExpr struct {
+	X         Expr
+	Collation string // the name of the collating sequence, unquoted
+}
+
+func (e *CollateExpr) Pos() Pos { return e.X.Pos() }
+
+func (*CollateExpr) exprNode() {}
+
+// RowExpr is a row value, f.e. "(a, b)", which can only be compared with another
+// row value of the same size, f.e. "(a, b) = (1, 2)" or "(a, b) IN (SELECT x, y
+// FROM t)". An expression in parentheses by itself is not a RowExpr.
+type RowExpr struct {
+	Lparen Pos // position of "("
+	List   []Expr
+}
+
+func (e *RowExpr) Pos() Pos { return e.Lparen }
+
+func (*RowExpr) exprNode() {}
+
+// SubqueryExpr is a subquery whose value is the first column of its first row, or
+// NULL if it returns no rows, f.e. "(SELECT max(a) FROM t)". A subquery that
+// returns more than one column is a row value, like a *RowExpr.
+type SubqueryExpr struct {
+	Lparen Pos // position of "("
+	Select *SelectStatement
+}
+
+func (e *SubqueryExpr) Pos() Pos { return e.Lparen }
+
+func (*SubqueryExpr) exprNode() {}
+
+// ExistsExpr is an EXISTS operator, f.e. "EXISTS (SELECT 1 FROM t WHERE a = 1)".
+// "NOT EXISTS (...)" is a NOT operator whose operand is an ExistsExpr.
+type ExistsExpr struct {
+	Exists Pos // position of "EXISTS"
+	Select *SelectStatement
+}
+
+func (e *ExistsExpr) Pos() Pos { return e.Exists }
+
+func (*ExistsExpr) exprNode() {}
+
+// InExpr is an IN operator whose right-hand side is either a subquery, f.e.
+// "a IN (SELECT b FROM t)", or a list of values, f.e. "a NOT IN (1, ?)". Like in
+// SQLite, "a IN t" is parsed as "a IN (SELECT * FROM t)".
+type InExpr struct {
+	X      Expr
+	Not    bool
+	OpPos  Pos              // position of "NOT", if Not is set, or else of "IN"
+	Select *SelectStatement // or nil, if the right-hand side is List
+	List   []Expr
+}
+
+func (e *InExpr) Pos() Pos { return e.X.Pos() }
+
+func (*InExpr) exprNode() {}
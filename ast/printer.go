@@ -0,0 +1,534 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format renders node as canonical SQL: keywords are upper-case, tokens are
+// separated by single spaces, and identifiers are only quoted if required.
+// Queries that differ only in formatting produce the same output.
+func Format(node Node) string {
+	var b strings.Builder
+	p := printer{b: &b}
+	p.node(node)
+
+	return b.String()
+}
+
+type printer struct {
+	b *strings.Builder
+}
+
+func (p printer) node(node Node) {
+	switch n := node.(type) {
+	case *SelectStatement:
+		if n.With != nil {
+			p.node(n.With)
+			p.b.WriteString(" ")
+		}
+		p.b.WriteString("SELECT ")
+		if n.Distinct {
+			p.b.WriteString("DISTINCT ")
+		}
+		for i, c := range n.Columns {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(c)
+		}
+		if n.From != nil {
+			p.b.WriteString(" FROM ")
+			p.node(n.From)
+		}
+		if n.Where != nil {
+			p.b.WriteString(" WHERE ")
+			p.node(n.Where)
+		}
+		if len(n.GroupBy) > 0 {
+			p.b.WriteString(" GROUP BY ")
+			for i, x := range n.GroupBy {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.node(x)
+			}
+		}
+		if n.Having != nil {
+			p.b.WriteString(" HAVING ")
+			p.node(n.Having)
+		}
+		if len(n.Windows) > 0 {
+			p.b.WriteString(" WINDOW ")
+			for i, w := range n.Windows {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.node(w)
+			}
+		}
+		for _, c := range n.Compound {
+			p.b.WriteString(" ")
+			p.node(c)
+		}
+		if len(n.OrderBy) > 0 {
+			p.b.WriteString(" ORDER BY ")
+			for i, t := range n.OrderBy {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.node(t)
+			}
+		}
+		if n.Limit != nil {
+			p.b.WriteString(" LIMIT ")
+			p.node(n.Limit)
+		}
+		if n.Offset != nil {
+			p.b.WriteString(" OFFSET ")
+			p.node(n.Offset)
+		}
+	case *PragmaStatement:
+		p.b.WriteString("PRAGMA " + qualifiedName(n.Schema, n.Name))
+		if n.Value != nil {
+			p.b.WriteString(" = ")
+			p.node(n.Value)
+		}
+	case *ResultColumn:
+		switch {
+		case n.Star && n.Table != "":
+			p.b.WriteString(quoteIdent(n.Table) + ".*")
+		case n.Star:
+			p.b.WriteString("*")
+		default:
+			p.node(n.Expr)
+			p.alias(n.Alias)
+		}
+	case *With:
+		p.b.WriteString("WITH ")
+		if n.Recursive {
+			p.b.WriteString("RECURSIVE ")
+		}
+		for i, c := range n.CTEs {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(c)
+		}
+	case *CTE:
+		p.b.WriteString(quoteIdent(n.Name))
+		if len(n.Columns) > 0 {
+			p.b.WriteString("(")
+			for i, name := range n.Columns {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.b.WriteString(quoteIdent(name))
+			}
+			p.b.WriteString(")")
+		}
+		p.b.WriteString(" AS ")
+		switch n.Materialized {
+		case Materialized:
+			p.b.WriteString("MATERIALIZED ")
+		case NotMaterialized:
+			p.b.WriteString("NOT MATERIALIZED ")
+		}
+		p.subquery(n.Select)
+	case *CompoundSelect:
+		p.b.WriteString(n.Op.String() + " ")
+		p.node(n.Select)
+	case *OrderingTerm:
+		p.node(n.Expr)
+		switch n.Order {
+		case SortAsc:
+			p.b.WriteString(" ASC")
+		case SortDesc:
+			p.b.WriteString(" DESC")
+		}
+		switch n.Nulls {
+		case NullsFirst:
+			p.b.WriteString(" NULLS FIRST")
+		case NullsLast:
+			p.b.WriteString(" NULLS LAST")
+		}
+	case *TableName:
+		p.b.WriteString(qualifiedName(n.Schema, n.Name))
+		p.alias(n.Alias)
+	case *TableFunction:
+		p.b.WriteString(quoteIdent(n.Name))
+		p.b.WriteString("(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(arg)
+		}
+		p.b.WriteString(")")
+		p.alias(n.Alias)
+	case *SubqueryTable:
+		p.subquery(n.Select)
+		p.alias(n.Alias)
+	case *Join:
+		p.node(n.X)
+		if n.Op == JoinComma {
+			p.b.WriteString(", ")
+		} else {
+			p.b.WriteString(" ")
+			if n.Natural {
+				p.b.WriteString("NATURAL ")
+			}
+			p.b.WriteString(n.Op.String() + " ")
+		}
+		p.node(n.Y)
+		if n.On != nil {
+			p.b.WriteString(" ON ")
+			p.node(n.On)
+		}
+		if len(n.Using) > 0 {
+			p.b.WriteString(" USING (")
+			for i, name := range n.Using {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.b.WriteString(quoteIdent(name))
+			}
+			p.b.WriteString(")")
+		}
+	case *Ident:
+		p.b.WriteString(qualifiedName(n.Schema, n.Table, n.Name))
+	case *Literal:
+		switch n.Kind {
+		case StringLiteral:
+			p.b.WriteString("'" + strings.ReplaceAll(n.Value, "'", "''") + "'")
+		case BlobLiteral:
+			p.b.WriteString("X'" + strings.ToUpper(n.Value) + "'")
+		case NullLiteral:
+			p.b.WriteString("NULL")
+		default:
+			p.b.WriteString(n.Value)
+		}
+	case *Param:
+		p.b.WriteString(n.Name)
+	case *UnaryExpr:
+		p.b.WriteString(n.Op.String())
+		if n.Op == OpNot {
+			p.b.WriteString(" ")
+		}
+		if x, ok := n.X.(*UnaryExpr); ok && x.Op != OpNot {
+			// "- -a" must not be printed as "--a", which starts a comment.
+			p.b.WriteString("(")
+			p.node(x)
+			p.b.WriteString(")")
+			break
+		}
+		p.operand(n.X, n.Op.Precedence())
+	case *BinaryExpr:
+		p.operand(n.X, n.Op.Precedence())
+		p.b.WriteString(" " + n.Op.String() + " ")
+		p.operand(n.Y, n.Op.Precedence()+1)
+	case *LikeExpr:
+		p.operand(n.X, n.Op.Precedence())
+		if n.Not {
+			p.b.WriteString(" NOT")
+		}
+		p.b.WriteString(" " + n.Op.String() + " ")
+		p.operand(n.Y, n.Op.Precedence()+1)
+		if n.Escape != nil {
+			p.b.WriteString(" ESCAPE ")
+			p.operand(n.Escape, n.Op.Precedence()+1)
+		}
+	case *BetweenExpr:
+		p.operand(n.X, OpEq.Precedence())
+		if n.Not {
+			p.b.WriteString(" NOT")
+		}
+		p.b.WriteString(" BETWEEN ")
+		p.operand(n.Low, OpNot.Precedence())
+		p.b.WriteString(" AND ")
+		p.operand(n.High, OpEq.Precedence()+1)
+	case *CaseExpr:
+		p.b.WriteString("CASE ")
+		if n.X != nil {
+			p.node(n.X)
+			p.b.WriteString(" ")
+		}
+		for _, w := range n.Whens {
+			p.node(w)
+			p.b.WriteString(" ")
+		}
+		if n.Else != nil {
+			p.b.WriteString("ELSE ")
+			p.node(n.Else)
+			p.b.WriteString(" ")
+		}
+		p.b.WriteString("END")
+	case *When:
+		p.b.WriteString("WHEN ")
+		p.node(n.Cond)
+		p.b.WriteString(" THEN ")
+		p.node(n.Result)
+	case *RowExpr:
+		p.b.WriteString("(")
+		for i, x := range n.List {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(x)
+		}
+		p.b.WriteString(")")
+	case *CallExpr:
+		p.b.WriteString(quoteIdent(n.Name) + "(")
+		if n.Distinct {
+			p.b.WriteString("DISTINCT ")
+		}
+		if n.Star {
+			p.b.WriteString("*")
+		}
+		for i, arg := range n.Args {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(arg)
+		}
+		p.b.WriteString(")")
+		if n.Filter != nil {
+			p.b.WriteString(" FILTER (WHERE ")
+			p.node(n.Filter)
+			p.b.WriteString(")")
+		}
+		if n.Over != nil {
+			p.b.WriteString(" OVER ")
+			p.node(n.Over)
+		}
+	case *NamedWindow:
+		p.b.WriteString(quoteIdent(n.Name) + " AS ")
+		p.node(n.Window)
+	case *Window:
+		if n.Name != "" {
+			p.b.WriteString(quoteIdent(n.Name))
+			break
+		}
+		// sep separates the clauses within the parentheses.
+		sep := ""
+		p.b.WriteString("(")
+		if n.Base != "" {
+			p.b.WriteString(quoteIdent(n.Base))
+			sep = " "
+		}
+		if len(n.PartitionBy) > 0 {
+			p.b.WriteString(sep + "PARTITION BY ")
+			for i, x := range n.PartitionBy {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.node(x)
+			}
+			sep = " "
+		}
+		if len(n.OrderBy) > 0 {
+			p.b.WriteString(sep + "ORDER BY ")
+			for i, t := range n.OrderBy {
+				if i > 0 {
+					p.b.WriteString(", ")
+				}
+				p.node(t)
+			}
+			sep = " "
+		}
+		if n.Frame != nil {
+			p.b.WriteString(sep)
+			p.node(n.Frame)
+		}
+		p.b.WriteString(")")
+	case *Frame:
+		p.b.WriteString(n.Unit.String() + " BETWEEN ")
+		p.frameBound(n.Start)
+		p.b.WriteString(" AND ")
+		p.frameBound(n.End)
+		if n.Exclude != ExcludeNoOthers {
+			p.b.WriteString(" EXCLUDE " + n.Exclude.String())
+		}
+	case *CastExpr:
+		p.b.WriteString("CAST(")
+		p.node(n.X)
+		p.b.WriteString(" AS " + typeName(n.Type) + ")")
+	case *CollateExpr:
+		p.operand(n.X, CollatePrecedence)
+		p.b.WriteString(" COLLATE " + quoteIdent(n.Collation))
+	case *SubqueryExpr:
+		p.subquery(n.Select)
+	case *ExistsExpr:
+		p.b.WriteString("EXISTS ")
+		p.subquery(n.Select)
+	case *InExpr:
+		p.operand(n.X, OpEq.Precedence())
+		if n.Not {
+			p.b.WriteString(" NOT")
+		}
+		p.b.WriteString(" IN ")
+		if n.Select != nil {
+			p.subquery(n.Select)
+			break
+		}
+		p.b.WriteString("(")
+		for i, x := range n.List {
+			if i > 0 {
+				p.b.WriteString(", ")
+			}
+			p.node(x)
+		}
+		p.b.WriteString(")")
+	default:
+		panic(fmt.Sprintf("ast.Format: unexpected node type %T", n))
+	}
+}
+
+// alias prints an "AS" clause, if alias is set.
+func (p printer) alias(alias string) {
+	if alias != "" {
+		p.b.WriteString(" AS " + quoteIdent(alias))
+	}
+}
+
+// frameBound prints the start or the end of a frame.
+func (p printer) frameBound(b FrameBound) {
+	switch b.Kind {
+	case UnboundedPreceding:
+		p.b.WriteString("UNBOUNDED PRECEDING")
+	case Preceding:
+		p.node(b.Offset)
+		p.b.WriteString(" PRECEDING")
+	case CurrentRow:
+		p.b.WriteString("CURRENT ROW")
+	case Following:
+		p.node(b.Offset)
+		p.b.WriteString(" FOLLOWING")
+	case UnboundedFollowing:
+		p.b.WriteString("UNBOUNDED FOLLOWING")
+	}
+}
+
+// subquery prints stmt in parentheses.
+func (p printer) subquery(stmt *SelectStatement) {
+	p.b.WriteString("(")
+	p.node(stmt)
+	p.b.WriteString(")")
+}
+
+// operand prints x, wrapping it in parentheses if it binds less tightly than
+// prec, which is the precedence of the operator that it is an operand of, plus
+// one for the right-hand operand of a left-associative operator.
+func (p printer) operand(x Expr, prec int) {
+	if precedence(x) < prec {
+		p.b.WriteString("(")
+		p.node(x)
+		p.b.WriteString(")")
+		return
+	}
+
+	p.node(x)
+}
+
+// precedence returns the precedence of the operator of x, or one that is higher
+// than that of any operator if x has none, f.e. if it is a column reference.
+func precedence(x Expr) int {
+	switch x := x.(type) {
+	case *UnaryExpr:
+		return x.Op.Precedence()
+	case *BinaryExpr:
+		return x.Op.Precedence()
+	case *LikeExpr:
+		return x.Op.Precedence()
+	case *BetweenExpr, *InExpr:
+		return OpEq.Precedence()
+	case *CollateExpr:
+		return CollatePrecedence
+	default:
+		return UnaryPrecedence + 1
+	}
+}
+
+// typeName returns the type name of a CAST, with each of its names quoted if
+// required.
+func typeName(typ string) string {
+	names, size := typ, ""
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		names, size = typ[:i], typ[i:]
+	}
+	words := strings.Split(names, " ")
+	for i, word := range words {
+		words[i] = quoteIdent(word)
+	}
+
+	return strings.Join(words, " ") + size
+}
+
+// qualifiedName returns the non-empty names joined by dots, each quoted if required.
+func qualifiedName(names ...string) string {
+	s := ""
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if s != "" {
+			s += "."
+		}
+		s += quoteIdent(name)
+	}
+
+	return s
+}
+
+// quoteIdent returns name, double-quoted if it is a keyword or contains
+// characters that are not allowed in a bare identifier.
+func quoteIdent(name string) string {
+	if isBareIdent(name) && !keywords[strings.ToUpper(name)] {
+		return name
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func isBareIdent(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= 0x80:
+		case i > 0 && (c == '$' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// keywords are SQLite's keywords, which must be quoted to be used as identifiers.
+// See: https://www.sqlite.org/lang_keywords.html
+var keywords = map[string]bool{}
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		ABORT ACTION ADD AFTER ALL ALTER ALWAYS ANALYZE AND AS ASC ATTACH
+		AUTOINCREMENT BEFORE BEGIN BETWEEN BY CASCADE CASE CAST CHECK COLLATE
+		COLUMN COMMIT CONFLICT CONSTRAINT CREATE CROSS CURRENT CURRENT_DATE
+		CURRENT_TIME CURRENT_TIMESTAMP DATABASE DEFAULT DEFERRABLE DEFERRED
+		DELETE DESC DETACH DISTINCT DO DROP EACH ELSE END ESCAPE EXCEPT EXCLUDE
+		EXCLUSIVE EXISTS EXPLAIN FAIL FILTER FIRST FOLLOWING FOR FOREIGN FROM
+		FULL GENERATED GLOB GROUP GROUPS HAVING IF IGNORE IMMEDIATE IN INDEX
+		INDEXED INITIALLY INNER INSERT INSTEAD INTERSECT INTO IS ISNULL JOIN KEY
+		LAST LEFT LIKE LIMIT MATCH MATERIALIZED NATURAL NO NOT NOTHING NOTNULL
+		NULL NULLS OF OFFSET ON OR ORDER OTHERS OUTER OVER PARTITION PLAN PRAGMA
+		PRECEDING PRIMARY QUERY RAISE RANGE RECURSIVE REFERENCES REGEXP REINDEX
+		RELEASE RENAME REPLACE RESTRICT RETURNING RIGHT ROLLBACK ROW ROWS
+		SAVEPOINT SELECT SET TABLE TEMP TEMPORARY THEN TIES TO TRANSACTION
+		TRIGGER UNBOUNDED UNION UNIQUE UPDATE USING VACUUM VALUES VIEW VIRTUAL
+		WHEN WHERE WINDOW WITH WITHOUT
+	`) {
+		keywords[keyword] = true
+	}
+}
@@ -0,0 +1,188 @@
+package ast_test
+
+import (
+	"testing"
+
+	sqlite3native "github.com/colinking/go-sqlite3-native"
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(tt *testing.T) {
+	for _, test := range []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{
+			name:     "select star",
+			sql:      "select *\n  from   table1 ;",
+			expected: "SELECT * FROM table1",
+		},
+		{
+			name:     "all clauses",
+			sql:      "select a,b from t where a=1 AND b > ? order by a asc limit 0x10",
+			expected: "SELECT a, b FROM t WHERE a = 1 AND b > ? ORDER BY a ASC LIMIT 0x10",
+		},
+		{
+			name:     "literals",
+			sql:      "select a from t where a == 'it''s' and b = x'0aff' and c = CAST(:c as [blob])",
+			expected: "SELECT a FROM t WHERE a = 'it''s' AND b = X'0AFF' AND c = CAST(:c AS blob)",
+		},
+		{
+			name:     "quoted identifiers",
+			sql:      "select [a b], `select`, \"c\" from \"my \"\"table\"\"\"",
+			expected: `SELECT "a b", "select", c FROM "my ""table"""`,
+		},
+		{
+			name:     "pragma",
+			sql:      "pragma main.cache_size(-2000)",
+			expected: "PRAGMA main.cache_size = -2000",
+		},
+		{
+			name:     "comments",
+			sql:      "select /* columns */ * from t -- all of them",
+			expected: "SELECT * FROM t",
+		},
+		{
+			name:     "qualified names and aliases",
+			sql:      "select t.*, main.t.a x, [b] as \"from\" from main.t as t, u v where u.b = 1 order by x",
+			expected: `SELECT t.*, main.t.a AS x, b AS "from" FROM main.t AS t, u AS v WHERE u.b = 1 ORDER BY x`,
+		},
+		{
+			name:     "select without from",
+			sql:      "select 1+1 where ?",
+			expected: "SELECT 1 + 1 WHERE ?",
+		},
+		{
+			name:     "pattern matching",
+			sql:      "select a from t where a not like 'x\\%' escape '\\' or b glob '*' and c regexp ?",
+			expected: "SELECT a FROM t WHERE a NOT LIKE 'x\\%' ESCAPE '\\' OR b GLOB '*' AND c REGEXP ?",
+		},
+		{
+			name:     "functions and grouping",
+			sql:      "select a, COUNT(*), sum(distinct b) filter (where b > 0), lower(a) from t group by a having count() > 1",
+			expected: "SELECT a, COUNT(*), sum(DISTINCT b) FILTER (WHERE b > 0), lower(a) FROM t GROUP BY a HAVING count() > 1",
+		},
+		{
+			name:     "ordering terms",
+			sql:      "select a from t order by a collate nocase desc nulls first, b, 2 asc nulls last",
+			expected: "SELECT a FROM t ORDER BY a COLLATE nocase DESC NULLS FIRST, b, 2 ASC NULLS LAST",
+		},
+		{
+			name:     "distinct and offset",
+			sql:      "select distinct a, [offset] from t limit 1, :n",
+			expected: `SELECT DISTINCT a, "offset" FROM t LIMIT :n OFFSET 1`,
+		},
+		{
+			name:     "joins",
+			sql:      "select * from a as [left] left outer join b on a.x=b.x, c natural inner join d cross join e using (y, [z])",
+			expected: `SELECT * FROM a AS "left" LEFT JOIN b ON a.x = b.x, c NATURAL JOIN d CROSS JOIN e USING (y, z)`,
+		},
+		{
+			name:     "subqueries",
+			sql:      "select (select max(a) from u), x.* from (select * from v) x where b in (select c from w) and not exists(select 1 from y)",
+			expected: "SELECT (SELECT max(a) FROM u), x.* FROM (SELECT * FROM v) AS x WHERE b IN (SELECT c FROM w) AND NOT EXISTS (SELECT 1 FROM y)",
+		},
+		{
+			name:     "in lists",
+			sql:      "select * from t where a in (1,?, (select max(c) from u)) and b not in ()",
+			expected: "SELECT * FROM t WHERE a IN (1, ?, (SELECT max(c) FROM u)) AND b NOT IN ()",
+		},
+		{
+			name:     "compound selects",
+			sql:      "select a from t union all select b from u where b>1 intersect select c from v except select 1 from w union select d from x order by 1 desc limit 2",
+			expected: "SELECT a FROM t UNION ALL SELECT b FROM u WHERE b > 1 INTERSECT SELECT c FROM v EXCEPT SELECT 1 FROM w UNION SELECT d FROM x ORDER BY 1 DESC LIMIT 2",
+		},
+		{
+			name:     "common table expressions",
+			sql:      "with recursive c(a, \"b c\") as (select x, y from u union all select a, b from c), d as materialized (select 1 from t) select * from c, d",
+			expected: `WITH RECURSIVE c(a, "b c") AS (SELECT x, y FROM u UNION ALL SELECT a, b FROM c), d AS MATERIALIZED (SELECT 1 FROM t) SELECT * FROM c, d`,
+		},
+		{
+			name:     "window functions",
+			sql:      "select rank() over w, sum(a) filter (where b > 1) over (w rows 2 preceding exclude ties), lag(a) OVER (partition by b, c order by a desc groups between current row and unbounded following) from t window w as (order by a), [x] as ()",
+			expected: `SELECT rank() OVER w, sum(a) FILTER (WHERE b > 1) OVER (w ROWS BETWEEN 2 PRECEDING AND CURRENT ROW EXCLUDE TIES), lag(a) OVER (PARTITION BY b, c ORDER BY a DESC GROUPS BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) FROM t WINDOW w AS (ORDER BY a), x AS ()`,
+		},
+		{
+			name:     "operators",
+			sql:      "select -(a+b)*c, a-(b-c), (a-b)-c, - -a, +a||~b, a collate nocase || 'x', (a<b)=(c>=d) from t where not a is not distinct from b and c notnull or d not null and e isnull",
+			expected: "SELECT -(a + b) * c, a - (b - c), a - b - c, -(-a), +a || ~b, a COLLATE nocase || 'x', a < b = c >= d FROM t WHERE NOT a IS b AND c IS NOT NULL OR d IS NOT NULL AND e IS NULL",
+		},
+		{
+			name:     "case, between and row values",
+			sql:      "select case a when 1 then 'x' when 2 then null else cast(a as text) end, case when a then b end from t where (a, b) not between (1, 2) and (3, 4) and (a,b) in ((1,2),(3, (4)))",
+			expected: "SELECT CASE a WHEN 1 THEN 'x' WHEN 2 THEN NULL ELSE CAST(a AS text) END, CASE WHEN a THEN b END FROM t WHERE (a, b) NOT BETWEEN (1, 2) AND (3, 4) AND (a, b) IN ((1, 2), (3, 4))",
+		},
+		{
+			name:     "between bounds",
+			sql:      "select 1 between 2 <> 1 and 2, a between (b and c) and d, a between b and (c = d), a between not b and c from t",
+			expected: "SELECT 1 BETWEEN 2 != 1 AND 2, a BETWEEN (b AND c) AND d, a BETWEEN b AND (c = d), a BETWEEN NOT b AND c FROM t",
+		},
+		{
+			name:     "cast type names",
+			sql:      "select cast(x as varchar(10)), cast(x as DECIMAL(10, 2)), cast(x as double precision), cast(x as UNSIGNED BIG INT), cast(x as numeric(+5, -1)) from t",
+			expected: "SELECT CAST(x AS varchar(10)), CAST(x AS DECIMAL(10,2)), CAST(x AS double precision), CAST(x AS UNSIGNED BIG INT), CAST(x AS numeric(+5,-1)) FROM t",
+		},
+		{
+			name:     "table-valued function",
+			sql:      "SELECT name FROM PRAGMA_TABLE_INFO(?) p",
+			expected: "SELECT name FROM pragma_table_info(?) AS p",
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			statements, err := sqlite3native.Parse(test.sql)
+			require.NoError(t, err)
+			require.Len(t, statements, 1)
+			require.Equal(t, test.expected, ast.Format(statements[0]))
+
+			// Formatting is idempotent:
+			statements, err = sqlite3native.Parse(test.expected)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ast.Format(statements[0]))
+		})
+	}
+}
+
+func TestFormatParenthesizes(t *testing.T) {
+	ident := func(name string) ast.Expr { return &ast.Ident{Name: name} }
+
+	expr := &ast.BinaryExpr{
+		X:  ident("a"),
+		Op: ast.OpEq,
+		Y: &ast.BinaryExpr{
+			X:  ident("b"),
+			Op: ast.OpAnd,
+			Y:  ident("c"),
+		},
+	}
+	require.Equal(t, "a = (b AND c)", ast.Format(expr))
+
+	expr = &ast.BinaryExpr{
+		X: &ast.BinaryExpr{
+			X:  ident("a"),
+			Op: ast.OpOr,
+			Y:  ident("b"),
+		},
+		Op: ast.OpAnd,
+		Y:  &ast.LikeExpr{X: ident("c"), Op: ast.OpLike, Y: ident("d")},
+	}
+	require.Equal(t, "(a OR b) AND c LIKE d", ast.Format(expr))
+}
+
+func TestInspect(t *testing.T) {
+	statements, err := sqlite3native.Parse("SELECT a, b FROM t WHERE c = ? AND d > 1 ORDER BY e")
+	require.NoError(t, err)
+
+	var names []string
+	ast.Inspect(statements[0], func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Ident:
+			names = append(names, n.Name)
+		case *ast.TableName:
+			names = append(names, n.Name)
+		}
+		return true
+	})
+	require.Equal(t, []string{"a", "b", "t", "c", "d", "e"}, names)
+}
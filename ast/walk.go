@@ -0,0 +1,160 @@
+package ast
+
+import "fmt"
+
+// Inspect traverses the tree rooted at node in depth-first order. It calls f(node)
+// and, if that returns true, inspects each of node's children in the order they
+// appear in the source.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *SelectStatement:
+		if n.With != nil {
+			Inspect(n.With, f)
+		}
+		for _, c := range n.Columns {
+			Inspect(c, f)
+		}
+		if n.From != nil {
+			Inspect(n.From, f)
+		}
+		if n.Where != nil {
+			Inspect(n.Where, f)
+		}
+		for _, x := range n.GroupBy {
+			Inspect(x, f)
+		}
+		if n.Having != nil {
+			Inspect(n.Having, f)
+		}
+		for _, w := range n.Windows {
+			Inspect(w, f)
+		}
+		for _, c := range n.Compound {
+			Inspect(c, f)
+		}
+		for _, t := range n.OrderBy {
+			Inspect(t, f)
+		}
+		if n.Limit != nil {
+			Inspect(n.Limit, f)
+		}
+		if n.Offset != nil {
+			Inspect(n.Offset, f)
+		}
+	case *PragmaStatement:
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+	case *ResultColumn:
+		if n.Expr != nil {
+			Inspect(n.Expr, f)
+		}
+	case *With:
+		for _, c := range n.CTEs {
+			Inspect(c, f)
+		}
+	case *CTE:
+		Inspect(n.Select, f)
+	case *CompoundSelect:
+		Inspect(n.Select, f)
+	case *OrderingTerm:
+		Inspect(n.Expr, f)
+	case *TableFunction:
+		for _, arg := range n.Args {
+			Inspect(arg, f)
+		}
+	case *SubqueryTable:
+		Inspect(n.Select, f)
+	case *Join:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+		if n.On != nil {
+			Inspect(n.On, f)
+		}
+	case *UnaryExpr:
+		Inspect(n.X, f)
+	case *BinaryExpr:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *LikeExpr:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+		if n.Escape != nil {
+			Inspect(n.Escape, f)
+		}
+	case *CallExpr:
+		for _, arg := range n.Args {
+			Inspect(arg, f)
+		}
+		if n.Filter != nil {
+			Inspect(n.Filter, f)
+		}
+		if n.Over != nil {
+			Inspect(n.Over, f)
+		}
+	case *NamedWindow:
+		Inspect(n.Window, f)
+	case *Window:
+		for _, x := range n.PartitionBy {
+			Inspect(x, f)
+		}
+		for _, t := range n.OrderBy {
+			Inspect(t, f)
+		}
+		if n.Frame != nil {
+			Inspect(n.Frame, f)
+		}
+	case *Frame:
+		if n.Start.Offset != nil {
+			Inspect(n.Start.Offset, f)
+		}
+		if n.End.Offset != nil {
+			Inspect(n.End.Offset, f)
+		}
+	case *BetweenExpr:
+		Inspect(n.X, f)
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *CaseExpr:
+		if n.X != nil {
+			Inspect(n.X, f)
+		}
+		for _, w := range n.Whens {
+			Inspect(w, f)
+		}
+		if n.Else != nil {
+			Inspect(n.Else, f)
+		}
+	case *When:
+		Inspect(n.Cond, f)
+		Inspect(n.Result, f)
+	case *RowExpr:
+		for _, x := range n.List {
+			Inspect(x, f)
+		}
+	case *CastExpr:
+		Inspect(n.X, f)
+	case *SubqueryExpr:
+		Inspect(n.Select, f)
+	case *ExistsExpr:
+		Inspect(n.Select, f)
+	case *InExpr:
+		Inspect(n.X, f)
+		if n.Select != nil {
+			Inspect(n.Select, f)
+		}
+		for _, x := range n.List {
+			Inspect(x, f)
+		}
+	case *CollateExpr:
+		Inspect(n.X, f)
+	case *TableName, *Ident, *Literal, *Param:
+		// These nodes have no children.
+	default:
+		panic(fmt.Sprintf("ast.Inspect: unexpected node type %T", n))
+	}
+}
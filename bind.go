@@ -0,0 +1,161 @@
+package sqlite3native
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+var _ driver.NamedValueChecker = &Conn{}
+var _ driver.NamedValueChecker = &Stmt{}
+
+// CheckNamedValue converts an argument into a value that can be bound to a
+// parameter. See checkNamedValue.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
+}
+
+// CheckNamedValue converts an argument into a value that can be bound to a
+// parameter. See checkNamedValue.
+func (s *Stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
+}
+
+// checkNamedValue converts an argument into one of the types that a VM register
+// can hold (nil, int64, float64, string or []byte), the same way that
+// mattn/go-sqlite3 binds arguments:
+//
+//   - All integer and float widths are widened to int64 and float64.
+//   - Booleans are bound as the integers 1 and 0.
+//   - Times are bound as text, formatted with SQLiteTimestampFormats[0].
+//   - A nil []byte is bound as NULL.
+//   - driver.Valuer implementations and pointers are resolved to their value.
+//
+// An ErrMismatch error is returned for any other type, or for a uint64 that
+// cannot be represented as an int64.
+func checkNamedValue(nv *driver.NamedValue) error {
+	v, err := convertArg(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+
+	return nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+func convertArg(arg interface{}) (driver.Value, error) {
+	switch v := arg.(type) {
+	case nil, int64, float64, string:
+		return v, nil
+	case []byte:
+		if v == nil {
+			return nil, nil
+		}
+		return v, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		return v.Format(SQLiteTimestampFormats[0]), nil
+	case driver.Valuer:
+		// Like database/sql, a nil pointer whose element type implements Valuer
+		// is treated as NULL rather than calling Value on it.
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+			return nil, nil
+		}
+
+		value, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		if !driver.IsValue(value) {
+			return nil, Error{Code: ErrMismatch, err: fmt.Sprintf("non-Value type %T returned from Value", value)}
+		}
+		return convertArg(value)
+	}
+
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return convertArg(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return nil, Error{Code: ErrMismatch, err: fmt.Sprintf("uint64 value %d overflows int64", u)}
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return convertArg(rv.Bool())
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return convertArg(rv.Bytes())
+		}
+	}
+
+	return nil, Error{Code: ErrMismatch, err: fmt.Sprintf("unsupported type %T, a %s", arg, rv.Kind())}
+}
+
+// bind returns the values of each program's parameters, where params[i][j] is
+// bound to parameter j+1 of the i-th statement. Parameters without an argument
+// are NULL.
+//
+// Like mattn/go-sqlite3, positional arguments are consumed by each statement in
+// turn: the first statement's parameters are bound to the first arguments, the
+// next statement's parameters to the arguments after those, and so on. Named
+// arguments are bound to parameters with the same name and a ":", "@" or "$"
+// prefix in every statement.
+func (s *Stmt) bind(args []driver.NamedValue) ([][]driver.Value, error) {
+	params := make([][]driver.Value, len(s.programs))
+	for i, program := range s.programs {
+		params[i] = make([]driver.Value, program.NumPlaceholders)
+	}
+
+	for _, arg := range args {
+		if arg.Name == "" {
+			index := arg.Ordinal - 1
+			bound := false
+			for i := range params {
+				if index >= 0 && index < len(params[i]) {
+					params[i][index] = arg.Value
+					bound = true
+					break
+				}
+				index -= len(params[i])
+			}
+			if !bound {
+				return nil, Error{Code: ErrRange, err: fmt.Sprintf("bind index out of range: %d", arg.Ordinal)}
+			}
+			continue
+		}
+
+		found := false
+		for i, program := range s.programs {
+			for j, name := range program.Parameters {
+				if name == ":"+arg.Name || name == "@"+arg.Name || name == "$"+arg.Name {
+					params[i][j] = arg.Value
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, Error{Code: ErrRange, err: fmt.Sprintf("no such bind parameter: %s", arg.Name)}
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,138 @@
+package sqlite3native
+
+import (
+	"database/sql/driver"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+	"github.com/stretchr/testify/require"
+)
+
+type myInt int16
+
+type valuerSlice []string
+
+func (v valuerSlice) Value() (driver.Value, error) {
+	return int64(len(v)), nil
+}
+
+type valuerStruct struct{ s string }
+
+func (v valuerStruct) Value() (driver.Value, error) {
+	return v.s, nil
+}
+
+func TestCheckNamedValue(tt *testing.T) {
+	i := 5
+	var nilPtr *int
+	var nilValuer *valuerStruct
+
+	for _, test := range []struct {
+		name     string
+		value    interface{}
+		expected driver.Value
+		code     ErrNo
+	}{
+		{name: "nil", value: nil, expected: nil},
+		{name: "int", value: int(-1), expected: int64(-1)},
+		{name: "int8", value: int8(-8), expected: int64(-8)},
+		{name: "int32", value: int32(32), expected: int64(32)},
+		{name: "named int type", value: myInt(16), expected: int64(16)},
+		{name: "uint8", value: uint8(8), expected: int64(8)},
+		{name: "max int64 as uint64", value: uint64(math.MaxInt64), expected: int64(math.MaxInt64)},
+		{name: "uint64 above max int64", value: uint64(math.MaxInt64) + 1, code: ErrMismatch},
+		{name: "float32", value: float32(1.5), expected: float64(1.5)},
+		{name: "true", value: true, expected: int64(1)},
+		{name: "false", value: false, expected: int64(0)},
+		{name: "string", value: "abc", expected: "abc"},
+		{name: "bytes", value: []byte("abc"), expected: []byte("abc")},
+		{name: "nil bytes are NULL", value: []byte(nil), expected: nil},
+		{
+			name:     "time",
+			value:    time.Date(2020, 9, 13, 14, 26, 40, 500000000, time.FixedZone("", 2*60*60)),
+			expected: "2020-09-13 14:26:40.5+02:00",
+		},
+		{name: "pointer", value: &i, expected: int64(5)},
+		{name: "nil pointer", value: nilPtr, expected: nil},
+		{name: "valuer slice", value: valuerSlice{"a", "b"}, expected: int64(2)},
+		{name: "valuer struct", value: valuerStruct{"x"}, expected: "x"},
+		{name: "nil valuer pointer", value: nilValuer, expected: nil},
+		{name: "unsupported slice", value: []int{1}, code: ErrMismatch},
+		{name: "unsupported struct", value: struct{}{}, code: ErrMismatch},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			nv := &driver.NamedValue{Ordinal: 1, Value: test.value}
+			err := (&Conn{}).CheckNamedValue(nv)
+			if test.code != 0 {
+				require.Error(t, err)
+				require.IsType(t, Error{}, err)
+				require.Equal(t, test.code, err.(Error).Code)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expected, nv.Value)
+		})
+	}
+}
+
+func TestStmtBind(t *testing.T) {
+	require := require.New(t)
+
+	s := &Stmt{programs: []vm.Program{{
+		NumPlaceholders: 4,
+		Parameters:      []string{"", ":a", "@b", "$a"},
+	}}}
+
+	params, err := s.bind([]driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Name: "a", Value: "a"},
+		{Ordinal: 3, Name: "b", Value: 2.5},
+	})
+	require.NoError(err)
+	require.Equal([][]driver.Value{{int64(1), "a", 2.5, "a"}}, params)
+
+	// Parameters without an argument are NULL:
+	params, err = s.bind(nil)
+	require.NoError(err)
+	require.Equal([][]driver.Value{{nil, nil, nil, nil}}, params)
+
+	_, err = s.bind([]driver.NamedValue{{Ordinal: 5, Value: int64(1)}})
+	require.Equal(Error{Code: ErrRange, err: "bind index out of range: 5"}, err)
+
+	_, err = s.bind([]driver.NamedValue{{Ordinal: 1, Name: "c", Value: int64(1)}})
+	require.Equal(Error{Code: ErrRange, err: "no such bind parameter: c"}, err)
+	require.Equal(ErrRange, err.(Error).Code)
+}
+
+func TestStmtBindMultipleStatements(t *testing.T) {
+	require := require.New(t)
+
+	s := &Stmt{programs: []vm.Program{
+		{NumPlaceholders: 2, Parameters: []string{"", ":a"}},
+		{},
+		{NumPlaceholders: 2, Parameters: []string{"", "@a"}},
+	}}
+
+	// Positional arguments are consumed by each statement in turn, while named
+	// arguments are bound in every statement:
+	params, err := s.bind([]driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Value: int64(2)},
+		{Ordinal: 3, Value: int64(3)},
+		{Ordinal: 4, Name: "a", Value: "a"},
+	})
+	require.NoError(err)
+	require.Equal([][]driver.Value{{int64(1), "a"}, {}, {int64(3), "a"}}, params)
+
+	_, err = s.bind([]driver.NamedValue{{Ordinal: 5, Value: int64(1)}})
+	require.Equal(Error{Code: ErrRange, err: "bind index out of range: 5"}, err)
+}
+
+func TestErrorMessages(t *testing.T) {
+	require.Equal(t, "datatype mismatch", Error{Code: ErrMismatch}.Error())
+	require.Equal(t, "unknown error", Error{Code: ErrInternal}.Error())
+	require.Equal(t, "details", Error{Code: ErrRange, err: "details"}.Error())
+}
@@ -0,0 +1,123 @@
+package sqlite3native
+
+import (
+	"container/list"
+
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// StmtCacheStats reports how effective a connection's compiled statement
+// cache has been.
+type StmtCacheStats struct {
+	// Hits is the number of queries that re-used a cached program.
+	Hits uint64
+	// Misses is the number of queries that had to be compiled.
+	Misses uint64
+	// Evictions is the number of queries dropped to stay within Capacity.
+	Evictions uint64
+	// Invalidations is the number of times the cache was purged because
+	// the schema cookie changed.
+	Invalidations uint64
+	// Size is the number of queries whose programs are currently cached.
+	Size int
+	// Capacity is the maximum number of queries whose programs will be cached.
+	Capacity int
+}
+
+// stmtCache is a bounded LRU cache of compiled VM programs keyed by SQL text.
+//
+// Programs embed root page numbers and column offsets, so they are only valid
+// for the schema they were compiled against. Each time the schema cookie
+// changes, every cached program is discarded.
+type stmtCache struct {
+	capacity     int
+	schemaCookie int
+
+	// lru is ordered from most to least recently used.
+	lru     *list.List
+	entries map[string]*list.Element
+
+	stats StmtCacheStats
+}
+
+type stmtCacheEntry struct {
+	query    string
+	programs []vm.Program
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Get returns the programs compiled for each statement in query, if they are
+// cached. schemaCookie is the current schema cookie of the DB.
+func (c *stmtCache) Get(query string, schemaCookie int) ([]vm.Program, bool) {
+	c.validate(schemaCookie)
+
+	el, ok := c.entries[query]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+
+	return el.Value.(*stmtCacheEntry).programs, true
+}
+
+// Put caches the programs compiled for query against the schema identified
+// by schemaCookie.
+func (c *stmtCache) Put(query string, schemaCookie int, programs []vm.Program) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.validate(schemaCookie)
+
+	if el, ok := c.entries[query]; ok {
+		el.Value.(*stmtCacheEntry).programs = programs
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[query] = c.lru.PushFront(&stmtCacheEntry{
+		query:    query,
+		programs: programs,
+	})
+
+	for c.lru.Len() > c.capacity {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*stmtCacheEntry).query)
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns a snapshot of the cache's statistics.
+func (c *stmtCache) Stats() StmtCacheStats {
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	stats.Capacity = c.capacity
+
+	return stats
+}
+
+// validate purges the cache if the schema has changed since the cached
+// programs were compiled.
+func (c *stmtCache) validate(schemaCookie int) {
+	if schemaCookie == c.schemaCookie {
+		return
+	}
+
+	if c.lru.Len() > 0 {
+		c.lru.Init()
+		c.entries = map[string]*list.Element{}
+		c.stats.Invalidations++
+	}
+	c.schemaCookie = schemaCookie
+}
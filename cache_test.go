@@ -0,0 +1,115 @@
+package sqlite3native
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache(t *testing.T) {
+	require := require.New(t)
+
+	programs := func(n int) []vm.Program {
+		return []vm.Program{{NumPlaceholders: n}}
+	}
+
+	c := newStmtCache(2)
+	_, ok := c.Get("a", 1)
+	require.False(ok)
+	c.Put("a", 1, programs(1))
+	c.Put("b", 1, programs(2))
+
+	p, ok := c.Get("a", 1)
+	require.True(ok)
+	require.Equal(programs(1), p)
+
+	// "b" is now the least recently used entry, so it is evicted first:
+	c.Put("c", 1, programs(3))
+	_, ok = c.Get("b", 1)
+	require.False(ok)
+	_, ok = c.Get("c", 1)
+	require.True(ok)
+
+	require.Equal(StmtCacheStats{
+		Hits:      2,
+		Misses:    2,
+		Evictions: 1,
+		Size:      2,
+		Capacity:  2,
+	}, c.Stats())
+
+	// A schema change invalidates every cached program:
+	_, ok = c.Get("a", 2)
+	require.False(ok)
+	stats := c.Stats()
+	require.Equal(uint64(1), stats.Invalidations)
+	require.Equal(0, stats.Size)
+}
+
+func TestStmtCacheDisabled(t *testing.T) {
+	require := require.New(t)
+
+	c := newStmtCache(0)
+	c.Put("a", 1, []vm.Program{{}})
+	_, ok := c.Get("a", 1)
+	require.False(ok)
+	require.Equal(0, c.Stats().Size)
+}
+
+func TestStmtCacheE2E(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE table1 (column1 int);
+		INSERT INTO table1 (column1) VALUES (123);
+	`)
+
+	for _, test := range []struct {
+		dsn   string
+		stats StmtCacheStats
+	}{
+		{
+			dsn:   dbPath,
+			stats: StmtCacheStats{Hits: 2, Misses: 1, Size: 1, Capacity: DefaultStmtCacheSize},
+		},
+		{
+			dsn:   "file:" + dbPath + "?_stmt_cache_size=0",
+			stats: StmtCacheStats{Misses: 3},
+		},
+	} {
+		db, err := sql.Open("sqlite3-native", test.dsn)
+		require.NoError(err)
+
+		conn, err := db.Conn(ctx)
+		require.NoError(err)
+
+		for i := 0; i < 3; i++ {
+			rows, err := conn.QueryContext(ctx, "select * from table1;")
+			require.NoError(err)
+			var values []int64
+			for rows.Next() {
+				var v int64
+				require.NoError(rows.Scan(&v))
+				values = append(values, v)
+			}
+			require.NoError(rows.Err())
+			require.NoError(rows.Close())
+			require.Equal([]int64{123}, values)
+		}
+
+		var stats StmtCacheStats
+		require.NoError(conn.Raw(func(driverConn interface{}) error {
+			stats = driverConn.(*Conn).StmtCacheStats()
+			return nil
+		}))
+		require.Equal(test.stats, stats)
+
+		require.NoError(conn.Close())
+		require.NoError(db.Close())
+	}
+}
@@ -4,14 +4,27 @@ import (
 	"context"
 	"database/sql/driver"
 	"fmt"
+	"time"
 
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/compiler"
 	"github.com/colinking/go-sqlite3-native/internal/parser"
+	"github.com/colinking/go-sqlite3-native/internal/schema"
+	"github.com/colinking/go-sqlite3-native/internal/tree"
 	"github.com/colinking/go-sqlite3-native/internal/vm"
-	"github.com/segmentio/events/v2"
 )
 
 type Conn struct {
-	vm *vm.VM
+	tm    *tree.TreeManager
+	vm    *vm.VM
+	cache *stmtCache
+
+	// schema is the most recently loaded schema catalog. It is reloaded
+	// whenever the schema cookie changes.
+	schema *schema.Schema
+
+	// loc is the location that times are converted into, see the _loc DSN parameter.
+	loc *time.Location
 }
 
 var _ driver.Conn = &Conn{}
@@ -62,131 +75,171 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 }
 
 func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	_, err := parser.Parse(query)
+	programs, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{
+		conn:     c,
+		programs: programs,
+	}, nil
+}
+
+// StmtCacheStats returns statistics on this connection's compiled statement cache.
+//
+// It can be accessed from a *sql.Conn with:
+//
+//	conn.Raw(func(driverConn interface{}) error {
+//	  stats := driverConn.(*sqlite3native.Conn).StmtCacheStats()
+//	  ...
+//	})
+func (c *Conn) StmtCacheStats() StmtCacheStats {
+	return c.cache.Stats()
+}
+
+// prepare returns a program for each statement in query, re-using previously
+// compiled programs if they were compiled against the current schema.
+func (c *Conn) prepare(query string) ([]vm.Program, error) {
+	cookie, err := c.vm.SchemaCookie()
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: Right now, we don't use the parsed output since we haven't implemented the full parser.
-	// Instead, we hardcode a VM program so that we can get the backend working.
-	events.Log("Warning: ignoring query and using hardcoded VM program for testing")
-	var program vm.Program
-	switch query {
-	case "select * from table1;":
-		// This program only works on tmp/simple.db. It is equivalent to:
-		/*
-			sqlite> explain select * from table1;
-			addr  opcode         p1    p2    p3    p4             p5  comment
-			----  -------------  ----  ----  ----  -------------  --  -------------
-			0     Init           0     7     0                    00  Start at 7
-			1     OpenRead       0     2     0     1              00  root=2 iDb=0; table1
-			2     Rewind         0     6     0                    00
-			3       Column         0     0     1                    00  r[1]=table1.column1
-			4       ResultRow      1     1     0                    00  output=r[1]
-			5     Next           0     3     0                    01
-			6     Halt           0     0     0                    00
-			7     Transaction    0     0     1     0              01  usesStmtJournal=0
-			8     Goto           0     1     0                    00
-		*/
-		program = vm.Program{
-			Instructions: []vm.Instruction{
-				vm.NewInstruction(vm.OpcodeInit, 0, 7, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeOpenRead, 0, 2, 0, 1, 0),
-				vm.NewInstruction(vm.OpcodeRewind, 0, 6, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeColumn, 0, 0, 1, 0, 0),
-				vm.NewInstruction(vm.OpcodeResultRow, 1, 1, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeNext, 0, 3, 0, 0, 1),
-				vm.NewInstruction(vm.OpcodeHalt, 0, 0, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeTransaction, 0, 0, 1, 0, 1),
-				vm.NewInstruction(vm.OpcodeGoto, 0, 1, 0, 0, 0),
-			},
-			NumPlaceholders: 0,
-			Columns:         []string{"column1"},
+	if programs, ok := c.cache.Get(query, cookie); ok {
+		return programs, nil
+	}
+
+	sch, err := c.loadSchema(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole query is parsed up front so that syntax errors are reported
+	// before any statement runs, with positions relative to the whole query.
+	statements, err := parser.Parse(query)
+	if err != nil {
+		return nil, Error{Code: ErrError, err: err.Error(), cause: err}
+	}
+
+	texts := parser.Split(query)
+	programs := make([]vm.Program, 0, len(statements))
+	for i, statement := range statements {
+		program, err := compile(texts[i], statement, sch)
+		if err != nil {
+			return nil, err
 		}
-	case "select * from core___source_id_write_key_mapping where write_key=CAST('eE7e8Kpd7Xv6WJ8gzCofFh' AS BLOB);":
-		// This only works on tmp/stage.db
-		/*
-			sqlite> explain select * from core___source_id_write_key_mapping where write_key=CAST('eE7e8Kpd7Xv6WJ8gzCofFh' AS BLOB);
-			addr  opcode         p1    p2    p3    p4             p5  comment
-			----  -------------  ----  ----  ----  -------------  --  -------------
-			0     Init           0     13    0                    00  Start at 13
-			1     OpenRead       0     81    0     2              00  root=81 iDb=0; core___source_id_write_key_mapping
-			2     OpenRead       1     82    0     k(2,,)         02  root=82 iDb=0; sqlite_autoindex_core___source_id_write_key_mapping_1
-			3     String8        0     1     0     eE7e8Kpd7Xv6WJ8gzCofFh  00  r[1]='eE7e8Kpd7Xv6WJ8gzCofFh'
-			4     Cast           1     65    0                    00  affinity(r[1])
-			5     IsNull         1     12    0                    00  if r[1]==NULL goto 12
-			6     SeekGE         1     12    1     1              00  key=r[1]
-			7     IdxGT          1     12    1     1              00  key=r[1]
-			8     DeferredSeek   1     0     0                    00  Move 0 to 1.rowid if needed
-			9     Column         1     0     2                    00  r[2]=core___source_id_write_key_mapping.write_key
-			10    Column         0     1     3                    00  r[3]=core___source_id_write_key_mapping.source_id
-			11    ResultRow      2     2     0                    00  output=r[2..3]
-			12    Halt           0     0     0                    00
-			13    Transaction    0     0     167193  0              01  usesStmtJournal=0
-			14    Goto           0     1     0                    00
-		*/
-		program = vm.Program{
-			Instructions: []vm.Instruction{
-				vm.NewInstruction(vm.OpcodeInit, 0, 13, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeOpenRead, 0, 81, 0, 2, 0),
-				vm.NewInstruction(vm.OpcodeOpenRead, 1, 82, 0, 2, 2),
-				vm.NewInstructionStr(vm.OpcodeString8, 0, 1, 0, "eE7e8Kpd7Xv6WJ8gzCofFh", 0),
-				vm.NewInstruction(vm.OpcodeCast, 1, 65, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeIsNull, 1, 12, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeSeekGE, 1, 12, 1, 1, 0),
-				vm.NewInstruction(vm.OpcodeIdxGT, 1, 12, 1, 1, 0),
-				vm.NewInstruction(vm.OpcodeDeferredSeek, 1, 0, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeColumn, 1, 0, 2, 0, 0),
-				vm.NewInstruction(vm.OpcodeColumn, 0, 1, 3, 0, 0),
-				vm.NewInstruction(vm.OpcodeResultRow, 2, 2, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeHalt, 0, 0, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeTransaction, 0, 0, 167193, 0, 1),
-				vm.NewInstruction(vm.OpcodeGoto, 0, 1, 0, 0, 0),
-			},
-			NumPlaceholders: 0,
-			Columns:         []string{"write_key", "source_id"},
+		programs = append(programs, program)
+	}
+	c.cache.Put(query, cookie, programs)
+
+	return programs, nil
+}
+
+// loadSchema returns the schema catalog, re-reading it from the DB if it has
+// changed since it was last loaded.
+func (c *Conn) loadSchema(cookie int) (*schema.Schema, error) {
+	if c.schema != nil && c.schema.Cookie == cookie {
+		return c.schema, nil
+	}
+
+	sch, err := schema.Load(c.tm)
+	if err != nil {
+		return nil, err
+	}
+	c.schema = sch
+
+	return sch, nil
+}
+
+// compile produces a VM program that executes a single statement against the
+// tables in sch. query is the statement's source text, without its terminating
+// semicolon.
+func compile(query string, stmt ast.Statement, sch *schema.Schema) (vm.Program, error) {
+	if pragma, ok := stmt.(*ast.PragmaStatement); ok {
+		return compiler.CompilePragma(pragma), nil
+	}
+	sel, ok := stmt.(*ast.SelectStatement)
+	if !ok {
+		return vm.Program{}, fmt.Errorf("unsupported statement: '%s'", query)
+	}
+	resolved, err := compiler.Resolve(sel, sch)
+	if err != nil {
+		return vm.Program{}, Error{Code: ErrError, err: err.Error()}
+	}
+
+	program, err := compiler.Compile(resolved, sch.Cookie)
+	if err != nil {
+		return vm.Program{}, Error{Code: ErrError, err: err.Error()}
+	}
+	program.Columns, program.ColumnMetadata = columnMetadata(resolved)
+
+	return program, nil
+}
+
+// columnMetadata returns the name of each of the result columns of sel, along
+// with the declared type and constraints of the table columns that they read.
+func columnMetadata(sel *compiler.Select) ([]string, []vm.ColumnMetadata) {
+	names := make([]string, 0, len(sel.Columns))
+	metadata := make([]vm.ColumnMetadata, 0, len(sel.Columns))
+	for _, c := range sel.Columns {
+		names = append(names, c.Name)
+
+		t, ref, ok := columnOrigin(sel, c.Expr)
+		if !ok {
+			metadata = append(metadata, vm.ColumnMetadata{})
+			continue
 		}
-	case "select * from core___source_id_write_key_mapping;":
-		// This only works on tmp/stage.db
-		/*
-			sqlite> explain select * from core___source_id_write_key_mapping;
-			addr  opcode         p1    p2    p3    p4             p5  comment
-			----  -------------  ----  ----  ----  -------------  --  -------------
-			0     Init           0     8     0                    00  Start at 8
-			1     OpenRead       0     81    0     2              00  root=81 iDb=0; core___source_id_write_key_mapping
-			2     Rewind         0     7     0                    00
-			3       Column         0     0     1                    00  r[1]=core___source_id_write_key_mapping.write_key
-			4       Column         0     1     2                    00  r[2]=core___source_id_write_key_mapping.source_id
-			5       ResultRow      1     2     0                    00  output=r[1..2]
-			6     Next           0     3     0                    01
-			7     Halt           0     0     0                    00
-			8     Transaction    0     0     167193  0              01  usesStmtJournal=0
-			9     Goto           0     1     0                    00
-		*/
-		program = vm.Program{
-			Instructions: []vm.Instruction{
-				vm.NewInstruction(vm.OpcodeInit, 0, 8, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeOpenRead, 0, 81, 0, 2, 0),
-				vm.NewInstruction(vm.OpcodeRewind, 0, 7, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeColumn, 0, 0, 1, 0, 0),
-				vm.NewInstruction(vm.OpcodeColumn, 0, 1, 2, 0, 0),
-				vm.NewInstruction(vm.OpcodeResultRow, 1, 2, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeNext, 0, 3, 0, 0, 1),
-				vm.NewInstruction(vm.OpcodeHalt, 0, 0, 0, 0, 0),
-				vm.NewInstruction(vm.OpcodeTransaction, 0, 0, 167193, 0, 1),
-				vm.NewInstruction(vm.OpcodeGoto, 0, 1, 0, 0, 0),
-			},
-			NumPlaceholders: 0,
-			Columns:         []string{"write_key", "source_id"},
+		rowidAlias, hasRowidAlias := t.RowidAlias()
+
+		idx := ref.Column
+		if idx == compiler.RowidColumn {
+			if !hasRowidAlias {
+				metadata = append(metadata, vm.ColumnMetadata{
+					Table:    t.Name,
+					Column:   "rowid",
+					DeclType: "INTEGER",
+					NotNull:  true,
+				})
+				continue
+			}
+			idx = rowidAlias
 		}
-	default:
-		return nil, fmt.Errorf("unsupported query: '%s'", query)
+		column := t.Columns[idx]
+
+		metadata = append(metadata, vm.ColumnMetadata{
+			Table:    t.Name,
+			Column:   column.Name,
+			DeclType: column.Type,
+			// The rowid can never be NULL, so neither can its alias.
+			NotNull: column.NotNull || (hasRowidAlias && idx == rowidAlias),
+		})
 	}
 
-	return &Stmt{
-		conn:    c,
-		program: program,
-	}, nil
+	return names, metadata
+}
+
+// columnOrigin returns the table column that the result column x of sel reads,
+// following the columns of subqueries in FROM clauses to the result columns that
+// they are. It returns false if x is not a direct reference to a table column,
+// f.e. if it is an expression or a reference to another result column by its
+// alias.
+func columnOrigin(sel *compiler.Select, x ast.Expr) (*schema.Table, compiler.ColumnRef, bool) {
+	for {
+		id, ok := x.(*ast.Ident)
+		if !ok {
+			return nil, compiler.ColumnRef{}, false
+		}
+		ref, ok := sel.Refs[id]
+		if !ok {
+			return nil, compiler.ColumnRef{}, false
+		}
+		src := sel.Sources[ref.Source]
+		if src.Subquery == nil {
+			return src.Table, ref, true
+		}
+		sel, x = src.Subquery, src.Subquery.Columns[ref.Column].Expr
+	}
 }
 
 func (c *Conn) Begin() (driver.Tx, error) {
@@ -29,16 +29,21 @@ func (d *Driver) Open(name string) (driver.Conn, error) {
 }
 
 func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
-	// TODO: URI parsing of name to support file:// notation
+	cfg, err := parseDSN(name)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Connector{
 		name:   name,
+		config: cfg,
 		driver: d,
 	}, nil
 }
 
 type Connector struct {
-	name string
+	name   string
+	config config
 
 	driver driver.Driver
 }
@@ -46,15 +51,19 @@ type Connector struct {
 var _ driver.Connector = &Connector{}
 
 func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
-	pager, err := pager.NewPager(c.name)
+	pager, err := pager.NewPager(c.config.path)
 	if err != nil {
 		return &Conn{}, err
 	}
 	tm := tree.NewManager(pager)
 	m := vm.NewVM(tm)
+	m.SortMemory = c.config.sortMemory
 
 	return &Conn{
-		vm: m,
+		tm:    tm,
+		vm:    m,
+		cache: newStmtCache(c.config.stmtCacheSize),
+		loc:   c.config.loc,
 	}, nil
 }
 
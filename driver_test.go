@@ -4,13 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/colinking/go-sqlite3-native/internal/vm"
 	"github.com/segmentio/events/v2"
 	_ "github.com/segmentio/events/v2/sigevents"
 	"github.com/segmentio/events/v2/text"
@@ -45,30 +49,389 @@ func TestDriverE2E(tt *testing.T) {
 				{int64(456)},
 			},
 		},
+		{
+			name: "rowid lookup",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (id INTEGER PRIMARY KEY, a TEXT);
+				INSERT INTO t VALUES (1, 'x'), (2, 'y'), (3, 'z');
+			`,
+			sql: "SELECT a FROM t WHERE id = '2'",
+			results: [][]driver.Value{
+				{"y"},
+			},
+		},
+		{
+			name: "unique index lookup",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (k BLOB UNIQUE, v INT);
+				INSERT INTO t VALUES ('a', 1), (CAST('b' AS BLOB), 2), ('b', 3);
+			`,
+			sql: "SELECT v FROM t WHERE k = CAST('b' AS BLOB)",
+			results: [][]driver.Value{
+				{int64(2)},
+			},
+		},
+		{
+			name: "like prefix on a NOCASE index",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (name TEXT COLLATE NOCASE);
+				CREATE INDEX t_name ON t (name);
+				INSERT INTO t VALUES ('Alice'), ('bob'), ('ALBERT'), (X'616C6578'), ('al%'), (NULL);
+			`,
+			sql: "SELECT name FROM t WHERE name LIKE 'al%' AND name NOT GLOB '*%*'",
+			results: [][]driver.Value{
+				{"ALBERT"},
+				{"Alice"},
+				{[]byte("alex")},
+			},
+		},
+		{
+			name: "pattern operators",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (a TEXT, b INT);
+				INSERT INTO t VALUES ('apple', 1), ('Banana', 2), ('cherry', 3), (NULL, 4);
+			`,
+			sql: "SELECT b FROM t WHERE a LIKE 'A_P%' OR a GLOB 'B*' AND b > 1 OR a REGEXP 'e{2}|rr'",
+			results: [][]driver.Value{
+				{int64(1)},
+				{int64(2)},
+				{int64(3)},
+			},
+		},
+		{
+			name: "aggregates",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (g TEXT COLLATE NOCASE, v INT);
+				INSERT INTO t VALUES ('a', 1), ('B', 2), ('b', 3), ('A', 4), ('c', 5), ('b', NULL);
+			`,
+			sql: "SELECT count(*), total(v), avg(v), min(g) FROM t WHERE v > 1",
+			results: [][]driver.Value{
+				{int64(4), 14.0, 3.5, "A"},
+			},
+		},
+		{
+			name: "group by with having",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (g TEXT COLLATE NOCASE, v INT);
+				CREATE INDEX t_g ON t (g);
+				INSERT INTO t VALUES ('a', 1), ('B', 2), ('b', 3), ('A', 4), ('c', 5), ('b', NULL);
+			`,
+			sql: "SELECT g, count(*), sum(v), max(v), group_concat(DISTINCT v) FILTER (WHERE v > 1) FROM t GROUP BY g HAVING count(v) > 1",
+			results: [][]driver.Value{
+				{"A", int64(2), int64(5), int64(4), "4"},
+				{"b", int64(3), int64(5), int64(3), "2,3"},
+			},
+		},
+		{
+			name: "order by with limit",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (name TEXT, score INT);
+				INSERT INTO t VALUES ('b', 2), ('A', NULL), ('c', 2), ('a', 1), ('B', 3);
+			`,
+			sql: "SELECT name FROM t ORDER BY score DESC NULLS FIRST, name COLLATE NOCASE, 1 DESC LIMIT 4",
+			results: [][]driver.Value{
+				{"A"},
+				{"B"},
+				{"b"},
+				{"c"},
+			},
+		},
+		{
+			name: "distinct with offset",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (name TEXT COLLATE NOCASE, score INT);
+				INSERT INTO t VALUES ('b', 2), ('A', 1), ('c', 2), ('a', 1), ('B', 2), ('d', 3);
+			`,
+			sql: "SELECT DISTINCT name, score FROM t ORDER BY score DESC, name LIMIT 1, 2",
+			results: [][]driver.Value{
+				{"b", int64(2)},
+				{"c", int64(2)},
+			},
+		},
+		{
+			name: "join with an index",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE families (id INTEGER PRIMARY KEY, name TEXT);
+				CREATE TABLE tables (family_id INT, name TEXT);
+				CREATE INDEX tables_family_id ON tables(family_id);
+				INSERT INTO families VALUES (1, 'billing'), (2, 'users'), (3, 'empty');
+				INSERT INTO tables VALUES (2, 'accounts'), (1, 'invoices'), (2, 'emails'), (4, 'orphan');
+			`,
+			sql: "SELECT f.name, t.name FROM families f JOIN tables t ON t.family_id = f.id ORDER BY 1, 2",
+			results: [][]driver.Value{
+				{"billing", "invoices"},
+				{"users", "accounts"},
+				{"users", "emails"},
+			},
+		},
+		{
+			name: "left join",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE families (family_id INTEGER PRIMARY KEY, name TEXT);
+				CREATE TABLE tables (family_id INT, name TEXT);
+				INSERT INTO families VALUES (1, 'billing'), (2, 'users'), (3, 'empty');
+				INSERT INTO tables VALUES (2, 'accounts'), (1, 'invoices'), (2, 'emails'), (4, 'orphan');
+			`,
+			sql: "SELECT f.name, count(t.name) FROM families f LEFT JOIN tables t USING (family_id) GROUP BY f.name",
+			results: [][]driver.Value{
+				{"billing", int64(1)},
+				{"empty", int64(0)},
+				{"users", int64(2)},
+			},
+		},
+		{
+			name: "natural self-join",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE s (id INTEGER PRIMARY KEY, v TEXT);
+				INSERT INTO s VALUES (1, 'a'), (2, 'b'), (3, NULL);
+			`,
+			sql: "SELECT * FROM s NATURAL JOIN s",
+			results: [][]driver.Value{
+				{int64(1), "a"},
+				{int64(2), "b"},
+			},
+		},
+		{
+			name: "subqueries",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE families (id INTEGER PRIMARY KEY, name TEXT);
+				CREATE TABLE tables (family_id INT, name TEXT);
+				INSERT INTO families VALUES (1, 'billing'), (2, 'users'), (3, 'empty');
+				INSERT INTO tables VALUES (2, 'accounts'), (1, 'invoices'), (2, 'emails'), (4, 'orphan');
+			`,
+			sql: `
+				SELECT f.name, (SELECT count(*) FROM tables WHERE family_id = f.id), (SELECT max(name) FROM tables)
+				FROM (SELECT * FROM families WHERE id IN (SELECT family_id FROM tables)) AS f
+				WHERE NOT EXISTS (SELECT 1 FROM tables t WHERE t.family_id = f.id AND t.name = 'emails')
+			`,
+			results: [][]driver.Value{
+				{"billing", int64(1), "orphan"},
+			},
+		},
+		{
+			name: "compound selects",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE a (name TEXT COLLATE NOCASE, n INT);
+				CREATE TABLE b (name TEXT, n INT);
+				INSERT INTO a VALUES ('x', 1), ('Y', 2), ('z', 3);
+				INSERT INTO b VALUES ('X', 1), ('y', 5), ('w', 3), ('w', 3);
+			`,
+			sql: `
+				SELECT name FROM a UNION ALL SELECT name FROM b WHERE n > 1
+				EXCEPT SELECT name FROM b WHERE n = 5
+				UNION SELECT 'v' FROM a INTERSECT SELECT name FROM b
+				ORDER BY 1 DESC LIMIT 3
+			`,
+			results: [][]driver.Value{
+				{"x"},
+				{"w"},
+			},
+		},
+		{
+			name: "compound ordered by another collation",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (b TEXT);
+				CREATE TABLE p (name TEXT);
+				INSERT INTO t VALUES ('Apple'), ('pear');
+				INSERT INTO p VALUES ('apple'), ('pear');
+			`,
+			sql: "SELECT b FROM t UNION SELECT name FROM p ORDER BY 1 COLLATE NOCASE",
+			results: [][]driver.Value{
+				{"Apple"},
+				{"apple"},
+				{"pear"},
+			},
+		},
+		{
+			name: "recursive cte",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE folders (id INTEGER PRIMARY KEY, parent INT, name TEXT);
+				INSERT INTO folders VALUES (1, NULL, 'root'), (2, 1, 'docs'), (3, 1, 'pics'), (4, 3, '2024'), (5, 5, 'loop'), (6, 5, 'tmp');
+			`,
+			sql: `
+				WITH RECURSIVE tree(id, name) AS (
+					SELECT id, name FROM folders WHERE id = 1
+					UNION ALL SELECT f.id, f.name FROM folders f JOIN tree ON f.parent = tree.id
+				)
+				SELECT name FROM tree
+			`,
+			results: [][]driver.Value{
+				{"root"},
+				{"docs"},
+				{"pics"},
+				{"2024"},
+			},
+		},
+		{
+			name: "recursive cte with cycle",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE folders (id INTEGER PRIMARY KEY, parent INT, name TEXT);
+				INSERT INTO folders VALUES (1, NULL, 'root'), (2, 1, 'docs'), (3, 1, 'pics'), (4, 3, '2024'), (5, 5, 'loop'), (6, 5, 'tmp');
+			`,
+			sql: `
+				WITH RECURSIVE c(id) AS (SELECT id FROM folders WHERE id = 5 UNION SELECT f.id FROM folders f, c WHERE f.parent = c.id),
+				d(id, name) AS (SELECT id, name FROM folders WHERE id = 5 UNION ALL SELECT f.id, f.name FROM folders f, d WHERE f.parent = d.id)
+				SELECT name FROM folders WHERE id IN (SELECT id FROM c)
+				UNION ALL SELECT name FROM (SELECT name FROM d LIMIT 3)
+			`,
+			results: [][]driver.Value{
+				{"loop"},
+				{"tmp"},
+				{"loop"},
+				{"loop"},
+				{"tmp"},
+			},
+		},
+		{
+			name: "recursive cte without from",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (x INT);
+			`,
+			sql: "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c LIMIT 3) SELECT x FROM c",
+			results: [][]driver.Value{
+				{int64(1)},
+				{int64(2)},
+				{int64(3)},
+			},
+		},
+		{
+			name: "in cte",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE folders (id INTEGER PRIMARY KEY, parent INT, name TEXT);
+				INSERT INTO folders VALUES (1, NULL, 'root'), (2, 1, 'docs'), (3, 1, 'pics'), (4, 3, '2024'), (5, 5, 'loop'), (6, 5, 'tmp');
+			`,
+			sql: `
+				WITH RECURSIVE c(id) AS (SELECT 3 UNION SELECT f.id FROM folders f, c WHERE f.parent = c.id)
+				SELECT name FROM folders WHERE id IN c
+			`,
+			results: [][]driver.Value{
+				{"pics"},
+				{"2024"},
+			},
+		},
+		{
+			name: "window functions",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE sales (id INTEGER PRIMARY KEY, region TEXT, amount INT);
+				INSERT INTO sales VALUES (1, 'east', 10), (2, 'west', 20), (3, 'east', 30), (4, 'east', 30), (5, 'west', 5), (6, 'north', 15);
+			`,
+			sql: `
+				SELECT id, row_number() OVER w, rank() OVER (PARTITION BY region ORDER BY amount DESC), sum(amount) OVER w, lag(amount, 1, 0) OVER w
+				FROM sales WINDOW w AS (PARTITION BY region ORDER BY id)
+				ORDER BY id
+			`,
+			results: [][]driver.Value{
+				{int64(1), int64(1), int64(3), int64(10), int64(0)},
+				{int64(2), int64(1), int64(1), int64(20), int64(0)},
+				{int64(3), int64(2), int64(1), int64(40), int64(10)},
+				{int64(4), int64(3), int64(1), int64(70), int64(30)},
+				{int64(5), int64(2), int64(2), int64(25), int64(20)},
+				{int64(6), int64(1), int64(1), int64(15), int64(0)},
+			},
+		},
+		{
+			name: "window frames",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE sales (id INTEGER PRIMARY KEY, region TEXT, amount INT);
+				INSERT INTO sales VALUES (1, 'east', 10), (2, 'west', 20), (3, 'east', 30), (4, 'east', 30), (5, 'west', 5), (6, 'north', 15);
+			`,
+			sql: `
+				SELECT
+					id,
+					sum(amount) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING),
+					count(*) OVER (ORDER BY amount RANGE BETWEEN 10 PRECEDING AND CURRENT ROW),
+					group_concat(id, ',') OVER (ORDER BY amount GROUPS BETWEEN CURRENT ROW AND 1 FOLLOWING EXCLUDE CURRENT ROW)
+				FROM sales ORDER BY id
+			`,
+			results: [][]driver.Value{
+				{int64(1), int64(30), int64(2), "6"},
+				{int64(2), int64(60), int64(3), "3,4"},
+				{int64(3), int64(80), int64(3), "4"},
+				{int64(4), int64(65), int64(3), "3"},
+				{int64(5), int64(50), int64(1), "1"},
+				{int64(6), int64(20), int64(3), "2"},
+			},
+		},
+		{
+			name: "window over groups",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE sales (id INTEGER PRIMARY KEY, region TEXT, amount INT);
+				INSERT INTO sales VALUES (1, 'east', 10), (2, 'west', 20), (3, 'east', 30), (4, 'east', 30), (5, 'west', 5), (6, 'north', 15);
+			`,
+			sql: `SELECT region, sum(amount), dense_rank() OVER (ORDER BY sum(amount) DESC) FROM sales GROUP BY region`,
+			results: [][]driver.Value{
+				{"east", int64(70), int64(1)},
+				{"west", int64(25), int64(2)},
+				{"north", int64(15), int64(3)},
+			},
+		},
+		{
+			name: "expressions",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT COLLATE NOCASE, qty INT);
+				INSERT INTO t VALUES (1, 'apple', 3), (2, 'Banana', NULL), (3, 'cherry', 12), (4, 'date', 7);
+			`,
+			sql: `
+				SELECT
+					id,
+					CASE WHEN qty IS NULL THEN 'none' WHEN qty BETWEEN 1 AND 5 THEN 'few' ELSE 'many' END,
+					CASE name WHEN 'APPLE' THEN 1 ELSE 0 END,
+					-id * 2 + CAST('10' AS INTEGER),
+					(id, name) IN (SELECT id, upper(name) FROM t WHERE id % 2 = 0)
+				FROM t WHERE NOT qty > 10 OR qty IS NULL ORDER BY id
+			`,
+			results: [][]driver.Value{
+				{int64(1), "few", int64(1), int64(8), int64(0)},
+				{int64(2), "none", int64(0), int64(6), int64(1)},
+				{int64(4), "many", int64(0), int64(2), int64(1)},
+			},
+		},
+		{
+			name: "cast type names",
+			setup: `
+				PRAGMA journal_mode=WAL;
+				CREATE TABLE t (id INTEGER PRIMARY KEY, qty INT);
+				INSERT INTO t VALUES (1, 3);
+			`,
+			sql: `
+				SELECT
+					CAST(qty AS VARCHAR(10)),
+					CAST('2.50' AS DECIMAL(10,2)),
+					CAST(qty AS DOUBLE PRECISION),
+					CAST('7.9' AS UNSIGNED BIG INT)
+				FROM t WHERE id = 1
+			`,
+			results: [][]driver.Value{
+				{"3", 2.5, 3.0, int64(7)},
+			},
+		},
 	} {
 		tt.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
 
-			// This test will be run on a sqlite3 DB in a temporary directory.
-			dir, err := ioutil.TempDir("", "go-sqlite3-native-*")
-			require.NoError(err)
-			defer func() {
-				// require.NoError(os.RemoveAll(dir))
-			}()
-
-			// Write the setup SQL to a file so we can pipe it into sqlite3
-			inputPath := filepath.Join(dir, "input.sql")
-			err = ioutil.WriteFile(inputPath, []byte(test.setup), 0644)
-			require.NoError(err)
-
-			// Execute the setup SQL on this temporary SQLite DB:
-			dbPath := filepath.Join(dir, "test.db")
-			events.Log("test path: %s", dbPath)
-			sh := fmt.Sprintf("cat %s | sqlite3 %s", inputPath, dbPath)
-			cmd := exec.Command("bash", "-c", sh)
-			stdout, err := cmd.Output()
-			require.NoError(err)
-			events.Log("%s\nstdout: %s", sh, stdout)
+			dbPath := createTestDB(t, test.setup)
 
 			// Open this SQLite DB with our Go client:
 			db, err := sql.Open("sqlite3-native", dbPath)
@@ -112,3 +475,508 @@ func TestDriverE2E(tt *testing.T) {
 		})
 	}
 }
+
+// createTestDB executes the setup SQL on a new SQLite DB in a temporary directory,
+// using the sqlite3 CLI, and returns the path to that DB.
+func createTestDB(t *testing.T, setup string) string {
+	require := require.New(t)
+
+	// This test will be run on a sqlite3 DB in a temporary directory.
+	dir, err := ioutil.TempDir("", "go-sqlite3-native-*")
+	require.NoError(err)
+
+	// Write the setup SQL to a file so we can pipe it into sqlite3
+	inputPath := filepath.Join(dir, "input.sql")
+	err = ioutil.WriteFile(inputPath, []byte(setup), 0644)
+	require.NoError(err)
+
+	// Execute the setup SQL on this temporary SQLite DB:
+	dbPath := filepath.Join(dir, "test.db")
+	events.Log("test path: %s", dbPath)
+	sh := fmt.Sprintf("cat %s | sqlite3 %s", inputPath, dbPath)
+	cmd := exec.Command("bash", "-c", sh)
+	stdout, err := cmd.Output()
+	require.NoError(err)
+	events.Log("%s\nstdout: %s", sh, stdout)
+
+	return dbPath
+}
+
+func TestSortSpills(t *testing.T) {
+	require := require.New(t)
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (v INT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO t SELECT (i * 7919) % 500 FROM n;
+	`)
+
+	// The rows take up more than 1KB, so they are sorted in temporary files.
+	db, err := sql.Open("sqlite3-native", "file:"+dbPath+"?_sort_memory=1024")
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT v FROM t ORDER BY v DESC")
+	require.NoError(err)
+	defer func() {
+		require.NoError(rows.Close())
+	}()
+
+	want := int64(499)
+	for rows.Next() {
+		var v int64
+		require.NoError(rows.Scan(&v))
+		require.Equal(want, v)
+		want--
+	}
+	require.NoError(rows.Err())
+	require.Equal(int64(-1), want)
+}
+
+func TestCloseEarly(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	// There are more rows than the execution buffers, so the program is still
+	// running when the rows are closed.
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE big (x INT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO big SELECT i FROM n;
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	var x int64
+	require.NoError(db.QueryRowContext(ctx, "SELECT x FROM big WHERE x > 10").Scan(&x))
+	require.Equal(int64(11), x)
+
+	rows, err := db.QueryContext(ctx, "SELECT x FROM big")
+	require.NoError(err)
+	require.True(rows.Next())
+	require.NoError(rows.Scan(&x))
+	require.Equal(int64(1), x)
+	require.NoError(rows.Close())
+
+	// The connection can still be used afterwards.
+	require.NoError(db.QueryRowContext(ctx, "SELECT x FROM big WHERE x > 499").Scan(&x))
+	require.Equal(int64(500), x)
+}
+
+func TestLimitParams(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (v INT);
+		INSERT INTO t VALUES (1), (2), (3), (4), (5);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	query := func(args ...interface{}) ([]int64, error) {
+		rows, err := db.QueryContext(ctx, "SELECT v FROM t LIMIT ? OFFSET ?", args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var values []int64
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, rows.Err()
+	}
+
+	values, err := query(2, 1)
+	require.NoError(err)
+	require.Equal([]int64{2, 3}, values)
+
+	// A negative LIMIT means that there is none, and a negative OFFSET is 0:
+	values, err = query(-1, -5)
+	require.NoError(err)
+	require.Equal([]int64{1, 2, 3, 4, 5}, values)
+
+	values, err = query("2", 4.0)
+	require.NoError(err)
+	require.Equal([]int64{5}, values)
+
+	_, err = query(1.5, 0)
+	require.Equal(Error{Code: ErrMismatch, err: "datatype mismatch"}, err)
+}
+
+func TestInListParams(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (write_key TEXT, version INT, v INT, PRIMARY KEY (write_key, version));
+		INSERT INTO t VALUES ('a', 1, 10), ('a', 2, 11), ('b', 1, 20), ('c', 1, 30), ('d', 1, 40);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	query := func(query string, args ...interface{}) ([]int64, error) {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var values []int64
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, rows.Err()
+	}
+
+	// The leading column of the primary key is looked up once for each
+	// distinct value, in order, and NULLs match nothing:
+	values, err := query("SELECT v FROM t WHERE write_key IN (?, ?, ?, ?)", "d", "a", nil, "d")
+	require.NoError(err)
+	require.Equal([]int64{10, 11, 40}, values)
+
+	values, err = query("SELECT v FROM t WHERE rowid IN (?, ?, ?)", 5, "3", 3.0)
+	require.NoError(err)
+	require.Equal([]int64{20, 40}, values)
+}
+
+func TestPragmaTableInfo(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (a INTEGER DEFAULT -1, b TEXT NOT NULL DEFAULT 'x', c DEFAULT (1 + 2), d, PRIMARY KEY (d, a));
+		CREATE TABLE u (id INTEGER PRIMARY KEY, v);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	query := func(query string, args ...interface{}) [][]interface{} {
+		rows, err := db.QueryContext(ctx, query, args...)
+		require.NoError(err)
+		defer rows.Close()
+
+		cols, err := rows.Columns()
+		require.NoError(err)
+		results := [][]interface{}{}
+		for rows.Next() {
+			row := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range cols {
+				ptrs[i] = &row[i]
+			}
+			require.NoError(rows.Scan(ptrs...))
+			results = append(results, row)
+		}
+		require.NoError(rows.Err())
+		return results
+	}
+
+	require.Equal([][]interface{}{
+		{int64(0), "a", "INTEGER", int64(0), "-1", int64(2)},
+		{int64(1), "b", "TEXT", int64(1), "'x'", int64(0)},
+		{int64(2), "c", "", int64(0), "1 + 2", int64(0)},
+		{int64(3), "d", "", int64(0), nil, int64(1)},
+	}, query("SELECT * FROM pragma_table_info(?)", "t"))
+
+	// The query that the README describes, with a table name in another case:
+	require.Equal([][]interface{}{
+		{"d", ""},
+		{"a", "INTEGER"},
+	}, query("SELECT name, type FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk ASC", "T"))
+	require.Equal([][]interface{}{
+		{"id", "INTEGER"},
+	}, query("SELECT name, type FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk ASC", "u"))
+
+	// Unknown tables have no columns:
+	require.Equal([][]interface{}{}, query("SELECT * FROM pragma_table_info(?)", "unknown"))
+	require.Equal([][]interface{}{}, query("SELECT * FROM pragma_table_info(?)", nil))
+}
+
+func TestSelectWithoutFrom(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (v INT);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	var n int64
+	require.NoError(db.QueryRowContext(ctx, "SELECT 1+1").Scan(&n))
+	require.Equal(int64(2), n)
+
+	var s string
+	require.NoError(db.QueryRowContext(ctx, "SELECT ?", "x").Scan(&s))
+	require.Equal("x", s)
+
+	// The single row is only returned if the WHERE clause is true.
+	err = db.QueryRowContext(ctx, "SELECT 1 WHERE ?", 0).Scan(&n)
+	require.Equal(sql.ErrNoRows, err)
+
+	_, err = db.QueryContext(ctx, "SELECT *")
+	require.EqualError(err, "no tables specified")
+}
+
+func TestOperatorPrecedence(tt *testing.T) {
+	dbPath := createTestDB(tt, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (v INT);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(tt, err)
+	defer func() {
+		require.NoError(tt, db.Close())
+	}()
+
+	for _, test := range []struct {
+		sql      string
+		expected interface{}
+	}{
+		// NOT EXISTS is NOT applied to EXISTS, so it binds less tightly than
+		// the operators that follow it.
+		{sql: "SELECT NOT EXISTS (SELECT 1) + 1", expected: int64(0)},
+		{sql: "SELECT NOT EXISTS (SELECT 1) IS NULL", expected: int64(1)},
+		{sql: "SELECT NOT EXISTS (SELECT 1) || 'x'", expected: int64(0)},
+		{sql: "SELECT (NOT EXISTS (SELECT 1)) || 'x'", expected: "0x"},
+		// The lower bound of BETWEEN extends up to the AND, but the upper
+		// bound ends at the next comparison.
+		{sql: "SELECT 1 BETWEEN 2 <> 1 AND 2", expected: int64(1)},
+		{sql: "SELECT 1 BETWEEN 2 IS NULL AND 3", expected: int64(1)},
+		{sql: "SELECT 1 BETWEEN 0 AND 2 <> 1", expected: int64(0)},
+	} {
+		tt.Run(test.sql, func(t *testing.T) {
+			var v interface{}
+			require.NoError(t, db.QueryRowContext(context.Background(), test.sql).Scan(&v))
+			require.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	require := require.New(t)
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE table1 (column1 int NOT NULL);
+		INSERT INTO table1 (column1) VALUES (123);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	rows, err := db.QueryContext(context.Background(), "select * from table1;")
+	require.NoError(err)
+	defer func() {
+		require.NoError(rows.Close())
+	}()
+
+	types, err := rows.ColumnTypes()
+	require.NoError(err)
+	require.Len(types, 1)
+
+	require.Equal("column1", types[0].Name())
+	require.Equal("INT", types[0].DatabaseTypeName())
+	require.Equal(reflect.TypeOf(sql.NullInt64{}), types[0].ScanType())
+	nullable, ok := types[0].Nullable()
+	require.True(ok)
+	require.False(nullable)
+	_, ok = types[0].Length()
+	require.False(ok)
+
+	for rows.Next() {
+	}
+	require.NoError(rows.Err())
+}
+
+func TestColumnTypeLength(tt *testing.T) {
+	for _, test := range []struct {
+		declType string
+		length   int64
+		ok       bool
+	}{
+		{declType: "INTEGER", length: 0, ok: false},
+		{declType: "REAL", length: 0, ok: false},
+		{declType: "TEXT", length: math.MaxInt64, ok: true},
+		{declType: "BLOB", length: math.MaxInt64, ok: true},
+		{declType: "VARCHAR(255)", length: 255, ok: true},
+		{declType: "nchar ( 16 )", length: 16, ok: true},
+	} {
+		tt.Run(test.declType, func(t *testing.T) {
+			rows := &Rows{
+				program: vm.Program{
+					ColumnMetadata: []vm.ColumnMetadata{{DeclType: test.declType}},
+				},
+			}
+
+			length, ok := rows.ColumnTypeLength(0)
+			require.Equal(t, test.ok, ok)
+			require.Equal(t, test.length, length)
+		})
+	}
+}
+
+func TestMultipleStatements(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE table1 (column1 int);
+		INSERT INTO table1 (column1) VALUES (123);
+		INSERT INTO table1 (column1) VALUES (456);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	query := "select * from table1; ; select * from table1;"
+
+	// Each SELECT's result is exposed as a separate result set:
+	rows, err := db.QueryContext(ctx, query)
+	require.NoError(err)
+	var sets [][]int64
+	for {
+		var values []int64
+		for rows.Next() {
+			var v int64
+			require.NoError(rows.Scan(&v))
+			values = append(values, v)
+		}
+		sets = append(sets, values)
+
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(rows.Err())
+	require.NoError(rows.Close())
+	require.Equal([][]int64{{123, 456}, {123, 456}}, sets)
+
+	// Unread rows are discarded when advancing to the next result set:
+	rows, err = db.QueryContext(ctx, query)
+	require.NoError(err)
+	require.True(rows.NextResultSet())
+	require.True(rows.Next())
+	require.False(rows.NextResultSet())
+	require.NoError(rows.Err())
+	require.NoError(rows.Close())
+
+	// Exec runs every statement:
+	result, err := db.ExecContext(ctx, query)
+	require.NoError(err)
+	affected, err := result.RowsAffected()
+	require.NoError(err)
+	require.Equal(int64(0), affected)
+
+	// Every statement is compiled before any is run:
+	_, err = db.ExecContext(ctx, "select * from table1; select * from unknown;")
+	require.Error(err)
+
+	// PRAGMAs have no effect, and return no result set:
+	rows, err = db.QueryContext(ctx, "PRAGMA foreign_keys=ON; select * from table1; PRAGMA main.cache_size = -2000;")
+	require.NoError(err)
+	var values []int64
+	for rows.Next() {
+		var v int64
+		require.NoError(rows.Scan(&v))
+		values = append(values, v)
+	}
+	require.False(rows.NextResultSet())
+	require.NoError(rows.Err())
+	require.NoError(rows.Close())
+	require.Equal([]int64{123, 456}, values)
+
+	_, err = db.ExecContext(ctx, "select * from table1; PRAGMA foreign_keys=ON;")
+	require.NoError(err)
+}
+
+func TestSyntaxError(t *testing.T) {
+	require := require.New(t)
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE table1 (column1 int);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	_, err = db.QueryContext(context.Background(), "select * form table1;")
+	require.EqualError(err, `near "form": syntax error`)
+	require.Equal(ErrError, err.(Error).Code)
+
+	// The syntax error can be inspected through the Error that wraps it:
+	var synErr *SyntaxError
+	require.True(errors.As(err, &synErr))
+	require.Equal(&SyntaxError{Line: 1, Column: 9, Token: "form", Expected: []string{"<EOF>", ";"}}, synErr)
+}
+
+func TestNameResolutionError(t *testing.T) {
+	require := require.New(t)
+
+	dbPath := createTestDB(t, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE table1 (column1 int);
+	`)
+
+	db, err := sql.Open("sqlite3-native", dbPath)
+	require.NoError(err)
+	defer func() {
+		require.NoError(db.Close())
+	}()
+
+	_, err = db.QueryContext(context.Background(), "select * from nope")
+	require.Equal(Error{Code: ErrError, err: "no such table: nope"}, err)
+
+	_, err = db.QueryContext(context.Background(), "select column2 from table1")
+	require.Equal(Error{Code: ErrError, err: "no such column: column2"}, err)
+}
@@ -0,0 +1,86 @@
+package sqlite3native
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStmtCacheSize is the number of compiled programs that each connection
+// caches, unless overridden with the _stmt_cache_size DSN parameter.
+const DefaultStmtCacheSize = 128
+
+// config stores the settings that were parsed from a DSN.
+type config struct {
+	// path is the location of the SQLite DB file on disk.
+	path string
+	// stmtCacheSize is the maximum number of compiled programs cached per
+	// connection. A size of zero disables the cache.
+	stmtCacheSize int
+	// loc is the location that time.Time values are converted into when read
+	// from DATE, DATETIME and TIMESTAMP columns. If nil, times are returned in
+	// UTC or in the time zone they were stored with.
+	loc *time.Location
+	// sortMemory is the number of bytes that the entries of each sorter, f.e.
+	// for an ORDER BY clause, may take up in memory before they are spilled to
+	// temporary files. Zero uses vm.DefaultSortMemory.
+	sortMemory int
+}
+
+// parseDSN parses a DSN in the format accepted by mattn/go-sqlite3, which is a
+// file path optionally followed by query parameters:
+//
+//	tmp/stage.db?_stmt_cache_size=64
+//	file:tmp/stage.db?_stmt_cache_size=64&_loc=auto&_sort_memory=1048576
+//
+// Unknown parameters are ignored, as they are by mattn/go-sqlite3, so that a DSN
+// can be shared between the two drivers.
+func parseDSN(dsn string) (config, error) {
+	cfg := config{
+		path:          dsn,
+		stmtCacheSize: DefaultStmtCacheSize,
+	}
+
+	if pos := strings.IndexRune(dsn, '?'); pos >= 1 {
+		params, err := url.ParseQuery(dsn[pos+1:])
+		if err != nil {
+			return config{}, err
+		}
+
+		if v := params.Get("_stmt_cache_size"); v != "" {
+			size, err := strconv.Atoi(v)
+			if err != nil || size < 0 {
+				return config{}, fmt.Errorf("invalid _stmt_cache_size: %q", v)
+			}
+			cfg.stmtCacheSize = size
+		}
+
+		if v := params.Get("_loc"); v != "" {
+			if strings.ToLower(v) == "auto" {
+				cfg.loc = time.Local
+			} else {
+				loc, err := time.LoadLocation(v)
+				if err != nil {
+					return config{}, fmt.Errorf("invalid _loc: %v: %v", v, err)
+				}
+				cfg.loc = loc
+			}
+		}
+
+		if v := params.Get("_sort_memory"); v != "" {
+			size, err := strconv.Atoi(v)
+			if err != nil || size < 1 {
+				return config{}, fmt.Errorf("invalid _sort_memory: %q", v)
+			}
+			cfg.sortMemory = size
+		}
+
+		cfg.path = dsn[:pos]
+	}
+
+	cfg.path = strings.TrimPrefix(cfg.path, "file:")
+
+	return cfg, nil
+}
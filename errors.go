@@ -0,0 +1,128 @@
+package sqlite3native
+
+import (
+	"errors"
+
+	"github.com/colinking/go-sqlite3-native/internal/tree"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// ErrNo is a SQLite primary result code: https://www.sqlite.org/rescode.html
+//
+// The codes and their messages match mattn/go-sqlite3's ErrNo.
+type ErrNo int
+
+const (
+	ErrError      = ErrNo(1)  /* SQL error or missing database */
+	ErrInternal   = ErrNo(2)  /* Internal logic error in SQLite */
+	ErrPerm       = ErrNo(3)  /* Access permission denied */
+	ErrAbort      = ErrNo(4)  /* Callback routine requested an abort */
+	ErrBusy       = ErrNo(5)  /* The database file is locked */
+	ErrLocked     = ErrNo(6)  /* A table in the database is locked */
+	ErrNomem      = ErrNo(7)  /* A malloc() failed */
+	ErrReadonly   = ErrNo(8)  /* Attempt to write a readonly database */
+	ErrInterrupt  = ErrNo(9)  /* Operation terminated by sqlite3_interrupt() */
+	ErrIoErr      = ErrNo(10) /* Some kind of disk I/O error occurred */
+	ErrCorrupt    = ErrNo(11) /* The database disk image is malformed */
+	ErrNotFound   = ErrNo(12) /* Unknown opcode in sqlite3_file_control() */
+	ErrFull       = ErrNo(13) /* Insertion failed because database is full */
+	ErrCantOpen   = ErrNo(14) /* Unable to open the database file */
+	ErrProtocol   = ErrNo(15) /* Database lock protocol error */
+	ErrEmpty      = ErrNo(16) /* Database is empty */
+	ErrSchema     = ErrNo(17) /* The database schema changed */
+	ErrTooBig     = ErrNo(18) /* String or BLOB exceeds size limit */
+	ErrConstraint = ErrNo(19) /* Abort due to constraint violation */
+	ErrMismatch   = ErrNo(20) /* Data type mismatch */
+	ErrMisuse     = ErrNo(21) /* Library used incorrectly */
+	ErrNoLFS      = ErrNo(22) /* Uses OS features not supported on host */
+	ErrAuth       = ErrNo(23) /* Authorization denied */
+	ErrFormat     = ErrNo(24) /* Auxiliary database format error */
+	ErrRange      = ErrNo(25) /* 2nd parameter to sqlite3_bind out of range */
+	ErrNotADB     = ErrNo(26) /* File opened that is not a database file */
+	ErrNotice     = ErrNo(27) /* Notifications from sqlite3_log() */
+	ErrWarning    = ErrNo(28) /* Warnings from sqlite3_log() */
+)
+
+// errorMessages are the messages returned by sqlite3_errstr for each result code.
+var errorMessages = map[ErrNo]string{
+	ErrError:      "SQL logic error",
+	ErrPerm:       "access permission denied",
+	ErrAbort:      "query aborted",
+	ErrBusy:       "database is locked",
+	ErrLocked:     "database table is locked",
+	ErrNomem:      "out of memory",
+	ErrReadonly:   "attempt to write a readonly database",
+	ErrInterrupt:  "interrupted",
+	ErrIoErr:      "disk I/O error",
+	ErrCorrupt:    "database disk image is malformed",
+	ErrNotFound:   "unknown operation",
+	ErrFull:       "database or disk is full",
+	ErrCantOpen:   "unable to open database file",
+	ErrProtocol:   "locking protocol",
+	ErrSchema:     "database schema has changed",
+	ErrTooBig:     "string or blob too big",
+	ErrConstraint: "constraint failed",
+	ErrMismatch:   "datatype mismatch",
+	ErrMisuse:     "bad parameter or other API misuse",
+	ErrAuth:       "authorization denied",
+	ErrRange:      "column index out of range",
+	ErrNotADB:     "file is not a database",
+	ErrNotice:     "notification message",
+	ErrWarning:    "warning message",
+}
+
+// Error returns the generic message for this result code.
+func (err ErrNo) Error() string {
+	if msg, ok := errorMessages[err]; ok {
+		return msg
+	}
+
+	return "unknown error"
+}
+
+// Error is an error with a SQLite result code. Like mattn/go-sqlite3, callers can
+// check for a specific code with:
+//
+//	if serr, ok := err.(sqlite3native.Error); ok && serr.Code == sqlite3native.ErrRange {
+//	  ...
+//	}
+//
+// The error that caused it, if any, can be checked with errors.As, f.e. the
+// *SyntaxError of a query that is not valid SQL.
+type Error struct {
+	Code ErrNo
+
+	// err is a detailed message. If empty, the generic message for Code is used.
+	err string
+	// cause is the error that this one was caused by, or nil.
+	cause error
+}
+
+func (err Error) Error() string {
+	if err.err != "" {
+		return err.err
+	}
+
+	return err.Code.Error()
+}
+
+// Unwrap returns the error that caused this one, or nil.
+func (err Error) Unwrap() error {
+	return err.cause
+}
+
+// executionError converts an error from executing a program into an Error with the
+// matching result code, if there is one.
+func executionError(err error) error {
+	if errors.Is(err, tree.ErrCorrupt) {
+		return Error{Code: ErrCorrupt, err: err.Error()}
+	}
+	if errors.Is(err, vm.ErrTooBig) {
+		return Error{Code: ErrTooBig, err: err.Error()}
+	}
+	if errors.Is(err, vm.ErrMismatch) {
+		return Error{Code: ErrMismatch, err: err.Error()}
+	}
+
+	return err
+}
@@ -3,7 +3,6 @@ module github.com/colinking/go-sqlite3-native
 go 1.14
 
 require (
-	github.com/antlr/antlr4 v0.0.0-20200801005519-2ba38605b949
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/pkg/errors v0.9.1
@@ -0,0 +1,379 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// aggregator holds the registers and cursors of an aggregate query.
+type aggregator struct {
+	// first is the first of the accumulators of the calls to aggregate
+	// functions, which are in g.accumulators.
+	first int
+	// distinct holds the ephemeral index of each call with DISTINCT, which
+	// holds the arguments that it was called with in the current group.
+	distinct map[*ast.CallExpr]int
+
+	// columns are the column references outside of the arguments of aggregate
+	// functions, f.e. "a" in "SELECT a, count(*)", which are read from a single
+	// row of each group into the registers starting at bare. Like in SQLite,
+	// that is the row of a min or max, if there is one, or else the first row.
+	columns []ColumnRef
+	bare    int
+	// hit is 1 if the columns are not read from the current row. If the query
+	// calls min or max, OpcodeCollSeq sets it to 0 and OpcodeAggStep to 1 if
+	// the row is not the minimum or maximum. Otherwise, it is set once the
+	// columns are read from the first row. It is 0 if there are no columns.
+	hit    int
+	minMax bool
+
+	// used is 1 once a row was added to the current group, for queries with a
+	// GROUP BY clause.
+	used int
+}
+
+// newAggregator allocates the registers and cursors of an aggregate query.
+func (g *generator) newAggregator() *aggregator {
+	a := &aggregator{distinct: map[*ast.CallExpr]int{}}
+
+	g.accumulators = map[*ast.CallExpr]int{}
+	a.first = g.allocRegisters(len(g.sel.Aggregates))
+	for i, call := range g.sel.Aggregates {
+		g.accumulators[call] = a.first + i
+		if call.Distinct {
+			a.distinct[call] = g.allocCursor()
+		}
+		if f, err := vm.LookupAggregate(call.Name, len(call.Args)); err == nil && f.Collation {
+			a.minMax = true
+		}
+	}
+
+	a.columns = g.columnRefs(g.outputExprs(), g.accumulators)
+	if len(a.columns) > 0 {
+		a.bare = g.allocRegisters(len(a.columns))
+		a.hit = g.allocRegister()
+	}
+
+	return a
+}
+
+// outputExprs returns the expressions that the result rows of an aggregate query
+// are computed from: the result columns, the HAVING clause, the ORDER BY terms and
+// the PARTITION BY and ORDER BY terms of the windows.
+func (g *generator) outputExprs() []ast.Expr {
+	exprs := []ast.Expr{}
+	for _, c := range g.sel.Columns {
+		exprs = append(exprs, c.Expr)
+	}
+	if g.sel.Stmt.Having != nil {
+		exprs = append(exprs, g.sel.Stmt.Having)
+	}
+	for _, t := range g.sel.OrderBy {
+		exprs = append(exprs, t.Expr)
+	}
+	for _, w := range g.sel.Windows {
+		exprs = append(exprs, w.Def.PartitionBy...)
+		for _, t := range w.Def.OrderBy {
+			exprs = append(exprs, t.Expr)
+		}
+	}
+
+	return exprs
+}
+
+// columnRefs returns the columns that exprs refer to, in the order that they are
+// first referred to, except in the arguments of the calls in skip, whose results
+// are known.
+func (g *generator) columnRefs(exprs []ast.Expr, skip map[*ast.CallExpr]int) []ColumnRef {
+	var refs []ColumnRef
+	seen := map[ColumnRef]bool{}
+	for _, x := range exprs {
+		ast.Inspect(x, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				_, ok := skip[n]
+				return !ok
+			case *ast.Ident:
+				if ref, ok := g.sel.Refs[n]; ok && !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+			return true
+		})
+	}
+
+	return refs
+}
+
+// aggregateSelect generates the body of the program for an aggregate query, whose
+// result rows are output by the code that row generates. Like
+// in SQLite, the rows of each group are visited one after the other, either
+// because the loop over the table visits them in that order, f.e. when it scans
+// an index on the GROUP BY columns, or by sorting them first. Each row is added
+// to the accumulators of the aggregate functions, and the result row of a group
+// is output when the next group starts:
+//
+//	     Gosub    reset
+//	     ...      loop over the rows of the groups
+//	     Compare  the GROUP BY terms of the row with those of the last one
+//	     Jump     to step, if they are equal
+//	     Gosub    output
+//	     Gosub    reset
+//	step:
+//	     AggStep  for each aggregate function
+//	     ...      end of the loop
+//	     Gosub    output
+//	     Goto     done
+//	output:
+//	     AggFinal for each aggregate function, HAVING and ResultRow
+//	     Return
+//	reset:
+//	     Null     the accumulators
+//	     Return
+//	done:
+//
+// Queries without a GROUP BY clause have a single group, which is output after
+// the loop even if it has no rows.
+func (g *generator) aggregateSelect(terms []*term, plans []*plan, row func() error) error {
+	a := g.newAggregator()
+	groupBy := g.sel.GroupBy
+
+	if len(groupBy) == 0 {
+		g.resetAggregates(a)
+		loops, err := g.beginLoops(terms, plans)
+		if err != nil {
+			return err
+		}
+		if err := g.stepAggregates(a); err != nil {
+			return err
+		}
+		g.endLoops(loops)
+
+		done := g.newLabel()
+		if err := g.outputAggregates(a, done, row); err != nil {
+			return err
+		}
+		g.resolve(done)
+		return nil
+	}
+
+	n := len(groupBy)
+	keyInfo := &vm.KeyInfo{}
+	for _, x := range groupBy {
+		keyInfo.Collations = append(keyInfo.Collations, g.collation(x))
+		keyInfo.Desc = append(keyInfo.Desc, false)
+	}
+
+	// prev holds the GROUP BY terms of the last row, and output and reset the
+	// return addresses of the subroutines.
+	prev := g.allocRegisters(n)
+	a.used = g.allocRegister()
+	output, reset := g.allocRegister(), g.allocRegister()
+	outputLabel, resetLabel, done := g.newLabel(), g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeNull, 0, prev, prev+n-1, 0, 0)
+	g.emit(vm.OpcodeGosub, reset, resetLabel, 0, 0, 0)
+
+	// group generates the code that is run for each row, whose GROUP BY terms
+	// are in the registers starting at keys.
+	group := func(keys int) error {
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeCompare, prev, keys, n, keyInfo, 0))
+		step := g.newLabel()
+		next := len(g.instructions) + 1
+		g.emit(vm.OpcodeJump, next, step, next, 0, 0)
+		g.emit(vm.OpcodeCopy, keys, prev, n-1, 0, 0)
+		g.emit(vm.OpcodeGosub, output, outputLabel, 0, 0, 0)
+		g.emit(vm.OpcodeGosub, reset, resetLabel, 0, 0, 0)
+		g.resolve(step)
+		if err := g.stepAggregates(a); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeInteger, 1, a.used, 0, 0, 0)
+		return nil
+	}
+
+	if plans[0].grouped {
+		loops, err := g.beginLoops(terms, plans)
+		if err != nil {
+			return err
+		}
+		keys := g.allocRegisters(n)
+		for i, x := range groupBy {
+			if err := g.expr(x, keys+i); err != nil {
+				return err
+			}
+		}
+		if err := group(keys); err != nil {
+			return err
+		}
+		g.endLoops(loops)
+	} else if err := g.sortedGroups(terms, plans, keyInfo, group); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeGosub, output, outputLabel, 0, 0, 0)
+	g.emit(vm.OpcodeGoto, 0, done, 0, 0, 0)
+
+	// The output subroutine returns without a result row if the group is
+	// empty, which it only is if there are no rows at all.
+	g.resolve(outputLabel)
+	g.emit(vm.OpcodeIfPos, a.used, len(g.instructions)+2, 0, 0, 0)
+	g.emit(vm.OpcodeReturn, output, 0, 0, 0, 0)
+	ret := g.newLabel()
+	if err := g.outputAggregates(a, ret, row); err != nil {
+		return err
+	}
+	g.resolve(ret)
+	g.emit(vm.OpcodeReturn, output, 0, 0, 0, 0)
+
+	g.resolve(resetLabel)
+	g.resetAggregates(a)
+	g.emit(vm.OpcodeReturn, reset, 0, 0, 0, 0)
+	g.resolve(done)
+
+	return nil
+}
+
+// sortedGroups generates a loop over the rows of the table that sorts them by
+// their GROUP BY terms, followed by a loop over the sorted rows that runs the code
+// that group generates for each of them. The sorter holds the GROUP BY terms of
+// each row, followed by the columns that the result columns, HAVING clause and
+// ORDER BY terms refer to, which are read from the sorter instead of the table.
+func (g *generator) sortedGroups(terms []*term, plans []*plan, keyInfo *vm.KeyInfo, group func(keys int) error) error {
+	groupBy := g.sel.GroupBy
+	columns := g.columnRefs(g.outputExprs(), nil)
+
+	n := len(groupBy) + len(columns)
+	sorter := g.allocCursor()
+	entry := g.allocRegisters(n)
+	g.append(vm.NewInstructionKeyInfo(vm.OpcodeSorterOpen, sorter, n, 0, keyInfo, 0))
+
+	loops, err := g.beginLoops(terms, plans)
+	if err != nil {
+		return err
+	}
+	for i, x := range groupBy {
+		if err := g.expr(x, entry+i); err != nil {
+			return err
+		}
+	}
+	for i, ref := range columns {
+		g.column(ref, entry+len(groupBy)+i)
+	}
+	g.emit(vm.OpcodeSorterInsert, sorter, entry, n, 0, 0)
+	g.endLoops(loops)
+
+	done := g.newLabel()
+	g.emit(vm.OpcodeSorterSort, sorter, done, 0, 0, 0)
+	top := len(g.instructions)
+	g.emit(vm.OpcodeSorterData, sorter, entry, n, 0, 0)
+	g.columnRegs = map[ColumnRef]int{}
+	for i, ref := range columns {
+		g.columnRegs[ref] = entry + len(groupBy) + i
+	}
+	if err := group(entry); err != nil {
+		return err
+	}
+	g.columnRegs = nil
+	g.emit(vm.OpcodeSorterNext, sorter, top, 0, 0, 0)
+	g.resolve(done)
+
+	return nil
+}
+
+// resetAggregates generates code that starts a new group.
+func (g *generator) resetAggregates(a *aggregator) {
+	if a.used != 0 {
+		g.emit(vm.OpcodeInteger, 0, a.used, 0, 0, 0)
+	}
+	if n := len(g.sel.Aggregates); n > 0 {
+		g.emit(vm.OpcodeNull, 0, a.first, a.first+n-1, 0, 0)
+	}
+	if n := len(a.columns); n > 0 {
+		g.emit(vm.OpcodeNull, 0, a.bare, a.bare+n-1, 0, 0)
+		g.emit(vm.OpcodeInteger, 0, a.hit, 0, 0, 0)
+	}
+	for _, call := range g.sel.Aggregates {
+		if cursor, ok := a.distinct[call]; ok {
+			keyInfo := &vm.KeyInfo{Collations: []string{g.collation(call.Args[0])}, Desc: []bool{false}}
+			g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenEphemeral, cursor, 1, 0, keyInfo, 0))
+		}
+	}
+}
+
+// stepAggregates generates code that adds the current row to the accumulators of
+// the aggregate functions, and reads the bare columns from it if it is the row
+// that they are read from.
+func (g *generator) stepAggregates(a *aggregator) error {
+	for _, call := range g.sel.Aggregates {
+		skip := g.newLabel()
+		if call.Filter != nil {
+			if err := g.jumpIfFalse(call.Filter, skip, true); err != nil {
+				return err
+			}
+		}
+
+		args := g.allocRegisters(len(call.Args))
+		for i, arg := range call.Args {
+			if err := g.expr(arg, args+i); err != nil {
+				return err
+			}
+		}
+		if cursor, ok := a.distinct[call]; ok {
+			g.emit(vm.OpcodeFound, cursor, skip, args, 1, 0)
+			g.emit(vm.OpcodeIdxInsert, cursor, args, 1, 0, 0)
+		}
+
+		name := strings.ToLower(call.Name)
+		f, err := vm.LookupAggregate(name, len(call.Args))
+		if err != nil {
+			return err
+		}
+		if f.Collation {
+			g.emitStr(vm.OpcodeCollSeq, a.hit, 0, 0, g.collation(call.Args[0]), 0)
+		}
+		g.emitStr(vm.OpcodeAggStep, 0, args, g.accumulators[call], name, len(call.Args))
+		g.resolve(skip)
+	}
+
+	if len(a.columns) == 0 {
+		return nil
+	}
+	skip := g.newLabel()
+	g.emit(vm.OpcodeIf, a.hit, skip, 0, 0, 0)
+	for i, ref := range a.columns {
+		g.column(ref, a.bare+i)
+	}
+	if !a.minMax {
+		g.emit(vm.OpcodeInteger, 1, a.hit, 0, 0, 0)
+	}
+	g.resolve(skip)
+
+	return nil
+}
+
+// outputAggregates generates code that computes the results of the aggregate
+// functions for the current group and outputs its result row with row, unless the
+// HAVING clause is not true, in which case it jumps to skip.
+func (g *generator) outputAggregates(a *aggregator, skip int, row func() error) error {
+	for _, call := range g.sel.Aggregates {
+		g.emitStr(vm.OpcodeAggFinal, g.accumulators[call], len(call.Args), 0, strings.ToLower(call.Name), 0)
+	}
+
+	// The bare columns are read from the registers that hold the values of
+	// the group's row.
+	g.columnRegs = map[ColumnRef]int{}
+	for i, ref := range a.columns {
+		g.columnRegs[ref] = a.bare + i
+	}
+	defer func() { g.columnRegs = nil }()
+
+	if having := g.sel.Stmt.Having; having != nil {
+		if err := g.jumpIfFalse(having, skip, true); err != nil {
+			return err
+		}
+	}
+
+	return row()
+}
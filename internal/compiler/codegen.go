@@ -0,0 +1,304 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// maxVariableNumber is the largest N in a "?N" parameter, which is SQLite's
+// default SQLITE_MAX_VARIABLE_NUMBER.
+const maxVariableNumber = 32766
+
+// Compile generates a program that runs the resolved SELECT statement sel against
+// a DB whose schema is at the given schema cookie. Like SQLite's programs, it
+// starts by jumping to the end, where it begins a read transaction, before jumping
+// back to run the statement:
+//
+//	0  Init         to the Transaction
+//	1  ...          the statement
+//	   Halt
+//	   Transaction  checks the schema cookie
+//	   Goto 1
+func Compile(sel *Select, cookie int) (vm.Program, error) {
+	g := &generator{
+		query:        newQuery(sel, nil),
+		params:       map[*ast.Param]int{},
+		materialized: map[*CTE]*materialization{},
+		current:      map[*CTE]int{},
+	}
+	if err := g.assignParams(); err != nil {
+		return vm.Program{}, err
+	}
+
+	start := g.newLabel()
+	g.emit(vm.OpcodeInit, 0, start, 0, 0, 0)
+	if err := g.selectStatement(destination{}); err != nil {
+		return vm.Program{}, err
+	}
+	g.emit(vm.OpcodeHalt, 0, 0, 0, 0, 0)
+	g.resolve(start)
+	g.emit(vm.OpcodeTransaction, 0, 0, cookie, 0, 1)
+	g.emit(vm.OpcodeGoto, 0, 1, 0, 0, 0)
+
+	// Jumps to labels are patched with the labels' addresses.
+	for i, inst := range g.instructions {
+		if inst.P2 < 0 {
+			g.instructions[i].P2 = g.labels[-inst.P2-1]
+		}
+	}
+
+	program := vm.Program{
+		Instructions:    g.instructions,
+		NumPlaceholders: len(g.paramNames),
+	}
+	if len(g.paramNames) > 0 {
+		program.Parameters = g.paramNames
+	}
+
+	return program, nil
+}
+
+// CompilePragma generates a program that runs the PRAGMA statement stmt. No
+// pragmas are supported, so like the ones that SQLite does not know, it does
+// nothing, and returns no rows:
+//
+//	0  Init
+//	1  Halt
+func CompilePragma(stmt *ast.PragmaStatement) vm.Program {
+	return vm.Program{Instructions: []vm.Instruction{
+		vm.NewInstruction(vm.OpcodeInit, 0, 1, 0, 0, 0),
+		vm.NewInstruction(vm.OpcodeHalt, 0, 0, 0, 0, 0),
+	}}
+}
+
+// generator holds the state of a program while its instructions are generated.
+type generator struct {
+	// query is the statement whose code is being generated, which is a
+	// subquery while the code of one is generated.
+	*query
+	instructions []vm.Instruction
+
+	// labels holds the address of each label, or -1 if it has not been
+	// resolved yet. Label i is referred to by the jump address -(i+1).
+	labels []int
+
+	// numRegisters and numCursors are the number of registers and cursors
+	// allocated so far. Register 0 is not used, like in SQLite.
+	numRegisters int
+	numCursors   int
+
+	// params holds the number of each parameter in the statement, and
+	// paramNames the name of each parameter number, see assignParams.
+	params     map[*ast.Param]int
+	paramNames []string
+
+	// materialized holds the code that computes the rows of each
+	// materialized CTE, once its first source has generated it.
+	materialized map[*CTE]*materialization
+	// current holds the cursor of the current row of each recursive CTE
+	// whose code is being generated, which its recursive SELECTs read.
+	current map[*CTE]int
+}
+
+// query holds the state of the code of a SELECT statement.
+type query struct {
+	sel *Select
+	// outer is the query that this one is a subquery of, or nil.
+	outer *query
+
+	// cursors holds the table cursor of each source that is being scanned,
+	// by the source's index.
+	cursors map[int]int
+	// results holds the first of the registers that the coroutine of each
+	// subquery in the FROM clause yields its rows in, by the source's index.
+	results map[int]int
+
+	// accumulators holds the register of each call to an aggregate function,
+	// which its result is read from.
+	accumulators map[*ast.CallExpr]int
+	// columnRegs holds the registers that column references are read from,
+	// instead of from the cursors of their sources, f.e. when the rows have
+	// been sorted.
+	columnRegs map[ColumnRef]int
+	// out holds the state of the output of the result rows.
+	out *output
+}
+
+func newQuery(sel *Select, outer *query) *query {
+	return &query{sel: sel, outer: outer, cursors: map[int]int{}, results: map[int]int{}}
+}
+
+// scope returns the query whose sources the column reference id refers to, which
+// is one of the queries around the current one if id is a correlated reference.
+func (g *generator) scope(id *ast.Ident) *query {
+	for q := g.query; q != nil; q = q.outer {
+		if _, ok := q.sel.Refs[id]; ok {
+			return q
+		}
+		if _, ok := q.sel.Aliases[id]; ok {
+			return q
+		}
+	}
+
+	return g.query
+}
+
+// enter makes q the current query, returning a function that makes the previous
+// one current again.
+func (g *generator) enter(q *query) func() {
+	prev := g.query
+	g.query = q
+
+	return func() { g.query = prev }
+}
+
+// emit appends an instruction to the program, returning its address.
+func (g *generator) emit(op vm.Opcode, p1, p2, p3, p4, p5 int) int {
+	return g.append(vm.NewInstruction(op, p1, p2, p3, p4, p5))
+}
+
+// emitStr appends an instruction whose P4 operand is a string.
+func (g *generator) emitStr(op vm.Opcode, p1, p2, p3 int, p4 string, p5 int) int {
+	return g.append(vm.NewInstructionStr(op, p1, p2, p3, p4, p5))
+}
+
+func (g *generator) append(inst vm.Instruction) int {
+	g.instructions = append(g.instructions, inst)
+
+	return len(g.instructions) - 1
+}
+
+// newLabel returns a label for an address that is not known yet, which can be
+// used as the P2 operand of jumps until it is resolved.
+func (g *generator) newLabel() int {
+	g.labels = append(g.labels, -1)
+
+	return -len(g.labels)
+}
+
+// resolve sets the address of label to that of the next instruction.
+func (g *generator) resolve(label int) {
+	g.labels[-label-1] = len(g.instructions)
+}
+
+// allocRegisters returns the first of n consecutive, unused registers.
+func (g *generator) allocRegisters(n int) int {
+	first := g.numRegisters + 1
+	g.numRegisters += n
+
+	return first
+}
+
+func (g *generator) allocRegister() int {
+	return g.allocRegisters(1)
+}
+
+func (g *generator) allocCursor() int {
+	g.numCursors++
+
+	return g.numCursors - 1
+}
+
+// assignParams numbers the parameters in the statement, in the order that they
+// appear, like SQLite does: "?" is numbered one more than the largest number so
+// far, "?N" is number N, and each use of a named parameter, f.e. ":id", shares the
+// number that it was first given.
+func (g *generator) assignParams() error {
+	named := map[string]int{}
+
+	var err error
+	ast.Inspect(g.sel.Stmt, func(n ast.Node) bool {
+		param, ok := n.(*ast.Param)
+		if !ok || err != nil {
+			return err == nil
+		}
+
+		switch name := param.Name; {
+		case name == "?":
+			g.paramNames = append(g.paramNames, "")
+			g.params[param] = len(g.paramNames)
+		case name[0] == '?':
+			i, perr := strconv.Atoi(name[1:])
+			if perr != nil || i < 1 || i > maxVariableNumber {
+				err = fmt.Errorf("variable number must be between ?1 and ?%d", maxVariableNumber)
+				return false
+			}
+			for len(g.paramNames) < i {
+				g.paramNames = append(g.paramNames, "")
+			}
+			if g.paramNames[i-1] == "" {
+				g.paramNames[i-1] = name
+			}
+			g.params[param] = i
+		default:
+			i, ok := named[name]
+			if !ok {
+				g.paramNames = append(g.paramNames, name)
+				i = len(g.paramNames)
+				named[name] = i
+			}
+			g.params[param] = i
+		}
+		return true
+	})
+
+	return err
+}
+
+// selectStatement generates the code of the current query, whose result rows go to
+// dest.
+func (g *generator) selectStatement(dest destination) error {
+	if g.sel.CTE != nil {
+		return g.recursiveSelect(dest)
+	}
+	if len(g.sel.Compound) > 0 {
+		return g.compoundSelect(dest)
+	}
+
+	for _, src := range g.sel.Sources {
+		if src.Table.WithoutRowid && src.Subquery == nil && src.CTE == nil && src.Tables == nil {
+			return fmt.Errorf("WITHOUT ROWID tables are not supported: %s", src.Name)
+		}
+		if src.Join == ast.JoinRight || src.Join == ast.JoinFull {
+			return errors.New("RIGHT and FULL OUTER JOINs are not supported")
+		}
+	}
+
+	terms := g.terms()
+	if len(g.sel.Windows) > 0 {
+		return g.windowSelect(terms, dest)
+	}
+	if g.sel.Aggregate() {
+		// Queries without a GROUP BY clause return a single row, which
+		// needs no sorting.
+		plans := g.planLoops(terms, g.sel.GroupBy, nil)
+		if err := g.beginOutput(len(g.sel.GroupBy) == 0, dest); err != nil {
+			return err
+		}
+		if err := g.aggregateSelect(terms, plans, g.resultRow); err != nil {
+			return err
+		}
+		g.endOutput()
+		return nil
+	}
+
+	plans := g.planLoops(terms, nil, g.sel.OrderBy)
+	if err := g.beginOutput(plans[0].ordered, dest); err != nil {
+		return err
+	}
+	loops, err := g.beginLoops(terms, plans)
+	if err != nil {
+		return err
+	}
+	if err := g.resultRow(); err != nil {
+		return err
+	}
+	g.endLoops(loops)
+	g.endOutput()
+
+	return nil
+}
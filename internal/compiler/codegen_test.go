@@ -0,0 +1,479 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/parser"
+	"github.com/colinking/go-sqlite3-native/internal/schema"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+	"github.com/stretchr/testify/require"
+)
+
+// indexSchema is equivalent to:
+//
+//	CREATE TABLE w (id INTEGER PRIMARY KEY, a TEXT COLLATE NOCASE, b INT, c);
+//	CREATE INDEX w_a ON w(a);
+//	CREATE INDEX w_bc ON w(b, c);
+//	CREATE INDEX w_partial ON w(c) WHERE c > 0;
+var indexSchema = schema.New(0,
+	&schema.Table{
+		Name:     "w",
+		RootPage: 2,
+		Columns: []schema.Column{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "a", Type: "TEXT", Collation: "NOCASE"},
+			{Name: "b", Type: "INT"},
+			{Name: "c"},
+		},
+		Indexes: []*schema.Index{
+			{Name: "w_a", RootPage: 3, Columns: []schema.IndexColumn{{Column: 1, Collation: "NOCASE"}}},
+			{Name: "w_bc", RootPage: 4, Columns: []schema.IndexColumn{{Column: 2}, {Column: 3}}},
+			{Name: "w_partial", RootPage: 5, Columns: []schema.IndexColumn{{Column: 3}}, Partial: true},
+		},
+	},
+)
+
+func TestCompilePlans(tt *testing.T) {
+	for _, test := range []struct {
+		name  string
+		query string
+		// opcodes are the opcodes of the statement, from the one after the
+		// Init to the Halt.
+		opcodes []vm.Opcode
+	}{
+		{
+			name:  "partial indexes are not used",
+			query: `SELECT c FROM w WHERE c = 1`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeInteger, vm.OpcodeNe,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "select without from",
+			query: `SELECT 1 + 1 WHERE ?`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeVariable, vm.OpcodeIfNot,
+				vm.OpcodeInteger, vm.OpcodeInteger, vm.OpcodeAdd, vm.OpcodeResultRow,
+			},
+		},
+		{
+			name:  "rowid lookup",
+			query: `SELECT c FROM w WHERE id = 5`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeInteger, vm.OpcodeSeekRowid,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+			},
+		},
+		{
+			name:  "rowid range",
+			query: `SELECT c FROM w WHERE rowid > 5`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeInteger, vm.OpcodeIsNull, vm.OpcodeSeekGT,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "rowid upper bound",
+			query: `SELECT c FROM w WHERE 5 > rowid AND c IS NOT NULL`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeRewind, vm.OpcodeInteger, vm.OpcodeRowid, vm.OpcodeGe,
+				vm.OpcodeColumn, vm.OpcodeIsNull,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "index equality",
+			query: `SELECT c FROM w WHERE b = 5 AND c = 'x'`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeInteger, vm.OpcodeIsNull, vm.OpcodeString8, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeSeekGE, vm.OpcodeIdxGT, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "index range",
+			query: `SELECT c FROM w WHERE b = 5 AND c > 'x'`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeInteger, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeString8, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeSeekGT, vm.OpcodeIdxGT, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "like prefix",
+			query: `SELECT c FROM w WHERE a LIKE 'ab%'`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead, vm.OpcodeInteger,
+				vm.OpcodeString8, vm.OpcodeSeekGE, vm.OpcodeString8,
+				vm.OpcodeIdxGE, vm.OpcodeDeferredSeek,
+				vm.OpcodeString8, vm.OpcodeColumn, vm.OpcodeFunction, vm.OpcodeIfNot,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext, vm.OpcodeIfPos,
+			},
+		},
+		{
+			name:  "glob needs a binary index",
+			query: `SELECT c FROM w WHERE a GLOB 'ab*'`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeString8, vm.OpcodeColumn, vm.OpcodeFunction, vm.OpcodeIfNot,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "aggregate",
+			query: `SELECT count(*) FROM w`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeNull, vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeAggStep,
+				vm.OpcodeNext,
+				vm.OpcodeAggFinal, vm.OpcodeSCopy, vm.OpcodeResultRow,
+			},
+		},
+		{
+			name:  "group by index",
+			query: `SELECT count(*) FROM w GROUP BY b`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeNull, vm.OpcodeGosub,
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead, vm.OpcodeSeekGE, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeCompare, vm.OpcodeJump,
+				vm.OpcodeCopy, vm.OpcodeGosub, vm.OpcodeGosub,
+				vm.OpcodeAggStep, vm.OpcodeInteger,
+				vm.OpcodeNext,
+				vm.OpcodeGosub, vm.OpcodeGoto,
+				vm.OpcodeIfPos, vm.OpcodeReturn, vm.OpcodeAggFinal, vm.OpcodeSCopy, vm.OpcodeResultRow, vm.OpcodeReturn,
+				vm.OpcodeInteger, vm.OpcodeNull, vm.OpcodeReturn,
+			},
+		},
+		{
+			name:  "group by sorter",
+			query: `SELECT count(*) FROM w GROUP BY c`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeNull, vm.OpcodeGosub, vm.OpcodeSorterOpen,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeSorterInsert,
+				vm.OpcodeNext,
+				vm.OpcodeSorterSort, vm.OpcodeSorterData, vm.OpcodeCompare, vm.OpcodeJump,
+				vm.OpcodeCopy, vm.OpcodeGosub, vm.OpcodeGosub,
+				vm.OpcodeAggStep, vm.OpcodeInteger,
+				vm.OpcodeSorterNext,
+				vm.OpcodeGosub, vm.OpcodeGoto,
+				vm.OpcodeIfPos, vm.OpcodeReturn, vm.OpcodeAggFinal, vm.OpcodeSCopy, vm.OpcodeResultRow, vm.OpcodeReturn,
+				vm.OpcodeInteger, vm.OpcodeNull, vm.OpcodeReturn,
+			},
+		},
+		{
+			name:  "order by index",
+			query: `SELECT c FROM w WHERE b = 5 ORDER BY c, id LIMIT 10`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInteger,
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeInteger, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeSeekGE, vm.OpcodeIdxGT, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeResultRow, vm.OpcodeDecrJumpZero,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "order by sorter",
+			query: `SELECT c FROM w ORDER BY b DESC, c LIMIT 10`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInteger, vm.OpcodeSorterOpen,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeSorterInsert,
+				vm.OpcodeNext,
+				vm.OpcodeSorterSort, vm.OpcodeSorterData, vm.OpcodeResultRow, vm.OpcodeDecrJumpZero, vm.OpcodeSorterNext,
+			},
+		},
+		{
+			name:  "limit and offset parameters",
+			query: `SELECT c FROM w ORDER BY c LIMIT ? OFFSET ?`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeVariable, vm.OpcodeMustBeInt, vm.OpcodeIfNot,
+				vm.OpcodeVariable, vm.OpcodeMustBeInt, vm.OpcodeOffsetLimit,
+				vm.OpcodeSorterOpen,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeSorterInsert,
+				vm.OpcodeNext,
+				vm.OpcodeSorterSort, vm.OpcodeSorterData,
+				vm.OpcodeIfPos, vm.OpcodeResultRow, vm.OpcodeDecrJumpZero, vm.OpcodeSorterNext,
+			},
+		},
+		{
+			name:  "distinct",
+			query: `SELECT DISTINCT a, b FROM w LIMIT 5, 10`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInteger, vm.OpcodeInteger, vm.OpcodeMustBeInt, vm.OpcodeOffsetLimit,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeFound, vm.OpcodeIdxInsert,
+				vm.OpcodeIfPos, vm.OpcodeResultRow, vm.OpcodeDecrJumpZero,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "group by order by",
+			query: `SELECT b FROM w GROUP BY b ORDER BY count(*)`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeSorterOpen, vm.OpcodeNull, vm.OpcodeGosub,
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead, vm.OpcodeSeekGE, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeCompare, vm.OpcodeJump,
+				vm.OpcodeCopy, vm.OpcodeGosub, vm.OpcodeGosub,
+				vm.OpcodeAggStep, vm.OpcodeIf, vm.OpcodeColumn, vm.OpcodeInteger, vm.OpcodeInteger,
+				vm.OpcodeNext,
+				vm.OpcodeGosub, vm.OpcodeGoto,
+				vm.OpcodeIfPos, vm.OpcodeReturn, vm.OpcodeAggFinal, vm.OpcodeSCopy, vm.OpcodeSCopy, vm.OpcodeSorterInsert, vm.OpcodeReturn,
+				vm.OpcodeInteger, vm.OpcodeNull, vm.OpcodeNull, vm.OpcodeInteger, vm.OpcodeReturn,
+				vm.OpcodeSorterSort, vm.OpcodeSorterData, vm.OpcodeResultRow, vm.OpcodeSorterNext,
+			},
+		},
+		{
+			name:  "join seeks an index",
+			query: `SELECT x.c, y.c FROM w x JOIN w y ON y.b = x.id`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeRewind,
+				vm.OpcodeRowid, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeSeekGE, vm.OpcodeIdxGT, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "left join",
+			query: `SELECT x.c, y.c FROM w x LEFT JOIN w y ON y.id = x.b AND y.c = 1`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeRewind,
+				vm.OpcodeInteger, vm.OpcodeColumn, vm.OpcodeSeekRowid,
+				vm.OpcodeColumn, vm.OpcodeInteger, vm.OpcodeNe,
+				vm.OpcodeInteger,
+				vm.OpcodeColumn, vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeIfPos, vm.OpcodeNullRow, vm.OpcodeGoto,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "in subquery runs once",
+			query: `SELECT c FROM w WHERE c IN (SELECT id FROM w)`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeOnce, vm.OpcodeInteger, vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeRowid, vm.OpcodeAffinity, vm.OpcodeIdxInsert, vm.OpcodeInteger,
+				vm.OpcodeNext,
+				vm.OpcodeInteger, vm.OpcodeIfNot, vm.OpcodeColumn,
+				vm.OpcodeNull, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeInteger, vm.OpcodeFound,
+				vm.OpcodeNull, vm.OpcodeNull, vm.OpcodeFound,
+				vm.OpcodeInteger, vm.OpcodeIfNot,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "in list seeks rowids",
+			query: `SELECT c FROM w WHERE id IN (?, 1, ?)`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead,
+				vm.OpcodeOnce, vm.OpcodeInteger, vm.OpcodeOpenEphemeral,
+				vm.OpcodeVariable, vm.OpcodeAffinity, vm.OpcodeIdxInsert,
+				vm.OpcodeInteger, vm.OpcodeAffinity, vm.OpcodeIdxInsert,
+				vm.OpcodeVariable, vm.OpcodeAffinity, vm.OpcodeIdxInsert,
+				vm.OpcodeInteger,
+				vm.OpcodeRewind, vm.OpcodeColumn, vm.OpcodeSeekRowid,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "in list seeks index",
+			query: `SELECT c FROM w WHERE b IN (1, 2) AND c = ?`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeOpenRead,
+				vm.OpcodeOnce, vm.OpcodeInteger, vm.OpcodeOpenEphemeral,
+				vm.OpcodeInteger, vm.OpcodeAffinity, vm.OpcodeIdxInsert,
+				vm.OpcodeInteger, vm.OpcodeAffinity, vm.OpcodeIdxInsert,
+				vm.OpcodeInteger,
+				vm.OpcodeRewind, vm.OpcodeColumn, vm.OpcodeIsNull,
+				vm.OpcodeVariable, vm.OpcodeIsNull, vm.OpcodeAffinity,
+				vm.OpcodeSeekGE, vm.OpcodeIdxGT, vm.OpcodeDeferredSeek,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext, vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "correlated exists",
+			query: `SELECT c FROM w x WHERE EXISTS (SELECT 1 FROM w y WHERE y.id = x.b)`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeInteger,
+				vm.OpcodeOpenRead, vm.OpcodeColumn, vm.OpcodeSeekRowid,
+				vm.OpcodeInteger, vm.OpcodeInteger, vm.OpcodeGoto,
+				vm.OpcodeSCopy, vm.OpcodeIfNot,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "from subquery",
+			query: `SELECT z.c FROM (SELECT c FROM w) z`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInitCoroutine,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeCopy, vm.OpcodeYield,
+				vm.OpcodeNext,
+				vm.OpcodeEndCoroutine,
+				vm.OpcodeYield, vm.OpcodeSCopy, vm.OpcodeResultRow,
+				vm.OpcodeGoto,
+			},
+		},
+		{
+			name:  "union",
+			query: `SELECT b FROM w UNION SELECT id FROM w`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeGoto, vm.OpcodeResultRow, vm.OpcodeReturn,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeIdxInsert,
+				vm.OpcodeNext,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeRowid, vm.OpcodeIdxInsert,
+				vm.OpcodeNext,
+				vm.OpcodeRewind, vm.OpcodeColumn, vm.OpcodeGosub, vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "intersect order by",
+			query: `SELECT b FROM w INTERSECT SELECT c FROM w ORDER BY 1 LIMIT 2`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInteger, vm.OpcodeSorterOpen,
+				vm.OpcodeGoto, vm.OpcodeSCopy, vm.OpcodeSorterInsert, vm.OpcodeReturn,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeIdxInsert,
+				vm.OpcodeNext,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeIdxInsert,
+				vm.OpcodeNext,
+				vm.OpcodeRewind, vm.OpcodeColumn, vm.OpcodeNotFound, vm.OpcodeGosub, vm.OpcodeNext,
+				vm.OpcodeSorterSort, vm.OpcodeSorterData, vm.OpcodeResultRow, vm.OpcodeDecrJumpZero,
+				vm.OpcodeSorterNext,
+			},
+		},
+		{
+			name:  "except",
+			query: `SELECT b FROM w EXCEPT SELECT c FROM w`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeGoto, vm.OpcodeResultRow, vm.OpcodeReturn,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeIdxInsert,
+				vm.OpcodeNext,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeIdxDelete,
+				vm.OpcodeNext,
+				vm.OpcodeRewind, vm.OpcodeColumn, vm.OpcodeGosub, vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "materialized cte",
+			query: `WITH x AS MATERIALIZED (SELECT c FROM w) SELECT c FROM x`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeGoto, vm.OpcodeOnce, vm.OpcodeOpenEphemeral,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeInsert,
+				vm.OpcodeNext,
+				vm.OpcodeReturn,
+				vm.OpcodeGosub, vm.OpcodeOpenDup, vm.OpcodeRewind,
+				vm.OpcodeColumn, vm.OpcodeResultRow,
+				vm.OpcodeNext,
+			},
+		},
+		{
+			name:  "recursive cte",
+			query: `WITH RECURSIVE r(n) AS (SELECT id FROM w UNION ALL SELECT b FROM w, r WHERE w.id = r.n) SELECT n FROM r`,
+			opcodes: []vm.Opcode{
+				vm.OpcodeInitCoroutine,
+				vm.OpcodeOpenEphemeral,
+				vm.OpcodeGoto, vm.OpcodeInsert, vm.OpcodeReturn,
+				vm.OpcodeOpenRead, vm.OpcodeRewind,
+				vm.OpcodeRowid, vm.OpcodeCopy, vm.OpcodeGosub,
+				vm.OpcodeNext,
+				vm.OpcodeRewind, vm.OpcodeOpenEphemeral,
+				vm.OpcodeColumn, vm.OpcodeInsert, vm.OpcodeDelete,
+				vm.OpcodeCopy, vm.OpcodeYield,
+				vm.OpcodeOpenRead, vm.OpcodeRewind, vm.OpcodeRewind,
+				vm.OpcodeRowid, vm.OpcodeColumn, vm.OpcodeNe,
+				vm.OpcodeColumn, vm.OpcodeCopy, vm.OpcodeGosub,
+				vm.OpcodeNext, vm.OpcodeNext,
+				vm.OpcodeGoto,
+				vm.OpcodeEndCoroutine,
+				vm.OpcodeYield, vm.OpcodeSCopy, vm.OpcodeResultRow,
+				vm.OpcodeGoto,
+			},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			program := compile(t, test.query)
+
+			var opcodes []vm.Opcode
+			for _, inst := range program.Instructions[1:] {
+				if inst.Op == vm.OpcodeHalt {
+					break
+				}
+				opcodes = append(opcodes, inst.Op)
+			}
+			require.Equal(t, test.opcodes, opcodes)
+		})
+	}
+}
+
+func TestCompileLikeBounds(t *testing.T) {
+	program := compile(t, `SELECT c FROM w WHERE a LIKE 'aB\_%' ESCAPE '\'`)
+
+	var bounds []string
+	for _, inst := range program.Instructions {
+		// The bounds are stored as blobs in the second pass, when the pass
+		// register in P3 is 0.
+		if inst.Op == vm.OpcodeString8 && inst.P3 != 0 {
+			bounds = append(bounds, inst.String())
+		}
+	}
+	require.Len(t, bounds, 2)
+	require.Contains(t, bounds[0], "P4: AB_,")
+	require.Contains(t, bounds[1], "P4: ab`,")
+}
+
+func TestCompileParams(t *testing.T) {
+	program := compile(t, `SELECT c FROM w WHERE b = :x AND c = ?5 AND a = ? OR a = :x`)
+	require.Equal(t, 6, program.NumPlaceholders)
+	require.Equal(t, []string{":x", "", "", "", "?5", ""}, program.Parameters)
+}
+
+func compile(t *testing.T, query string) vm.Program {
+	statements, err := parser.Parse(query)
+	require.NoError(t, err)
+
+	sel, err := Resolve(statements[0].(*ast.SelectStatement), indexSchema)
+	require.NoError(t, err)
+
+	program, err := Compile(sel, 0)
+	require.NoError(t, err)
+
+	return program
+}
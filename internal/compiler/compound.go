@@ -0,0 +1,241 @@
+package compiler
+
+import (
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// compoundSelect generates the code of the current query, which is a compound
+// SELECT, whose result rows go to dest. Each of its SELECTs is a query of its own,
+// whose rows are output by a subroutine that applies the ORDER BY and LIMIT
+// clauses of the compound, like the output of a simple SELECT:
+//
+//	     Goto    start
+//	sub: ...     output the row in the result registers
+//	     Return
+//	start:
+//	     ...     each row of the compound, in the result registers
+//	     Gosub   sub
+//	     ...
+//
+// The sorter of an ORDER BY clause is stable, so rows that are equal in its order
+// keep that of the compound.
+func (g *generator) compoundSelect(dest destination) error {
+	if err := g.beginOutput(false, dest); err != nil {
+		return err
+	}
+
+	out, n := g.out, len(g.sel.Columns)
+	keys := 0
+	if out.sorter >= 0 {
+		keys = len(g.sel.OrderBy)
+	}
+	ret, entry := g.allocRegister(), g.allocRegisters(keys+n)
+	result := entry + keys
+	sub, start, skip := g.newLabel(), g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeGoto, 0, start, 0, 0, 0)
+	g.resolve(sub)
+	if out.sorter < 0 {
+		g.emitRow(result, skip)
+	} else {
+		for i, t := range g.sel.OrderBy {
+			g.emit(vm.OpcodeSCopy, result+g.resultColumn(t.Expr), entry+i, 0, 0, 0)
+		}
+		g.emit(vm.OpcodeSorterInsert, out.sorter, entry, keys+n, 0, 0)
+	}
+	g.resolve(skip)
+	g.emit(vm.OpcodeReturn, ret, 0, 0, 0, 0)
+	g.resolve(start)
+
+	rows := destination{kind: destCompound, reg: ret, results: result, label: sub}
+	if err := g.compound(len(g.sel.Compound)-1, rows); err != nil {
+		return err
+	}
+	g.endOutput()
+
+	return nil
+}
+
+// compound generates the code of the compound of the SELECTs of the current query
+// up to the i-th one, whose result rows go to dest. Compound operators are
+// left-associative, so the SELECTs before the i-th one are its left-hand side.
+// Like in SQLite, the rows of a UNION or EXCEPT are added to an ephemeral index,
+// from which those of its right-hand side are removed for an EXCEPT, and which is
+// then read in order. Those of an INTERSECT are added to two indexes, and the
+// rows of the left-hand one that the right-hand one has are read. Operands that
+// are UNIONs or EXCEPTs themselves add their rows to the same index.
+func (g *generator) compound(i int, dest destination) error {
+	if i == 0 {
+		return g.compoundArm(0, dest)
+	}
+
+	switch op := g.sel.Compound[i].Op; op {
+	case ast.CompoundUnionAll:
+		if err := g.compound(i-1, dest); err != nil {
+			return err
+		}
+		return g.compoundArm(i, dest)
+	case ast.CompoundUnion, ast.CompoundExcept:
+		index := dest
+		if dest.kind != destUnion {
+			index = destination{kind: destUnion, cursor: g.compoundIndex()}
+		}
+		if err := g.compound(i-1, index); err != nil {
+			return err
+		}
+		right := index
+		if op == ast.CompoundExcept {
+			right.kind = destExcept
+		}
+		if err := g.compoundArm(i, right); err != nil {
+			return err
+		}
+		if dest.kind != destUnion {
+			g.compoundRows(index.cursor, -1, dest)
+		}
+	case ast.CompoundIntersect:
+		left := destination{kind: destUnion, cursor: g.compoundIndex()}
+		if err := g.compound(i-1, left); err != nil {
+			return err
+		}
+		right := destination{kind: destUnion, cursor: g.compoundIndex()}
+		if err := g.compoundArm(i, right); err != nil {
+			return err
+		}
+		g.compoundRows(left.cursor, right.cursor, dest)
+	}
+
+	return nil
+}
+
+// compoundArm generates the code of the i-th SELECT of the current query, whose
+// result rows go to dest. Its correlated column references refer to the queries
+// around the compound.
+func (g *generator) compoundArm(i int, dest destination) error {
+	defer g.enter(newQuery(g.sel.Compound[i].Select, g.outer))()
+
+	return g.selectStatement(dest)
+}
+
+// compoundIndex generates code that opens a new ephemeral index for the result
+// rows of the current query, and returns its cursor. Rows are equal if each of
+// their columns is, as compared by the collating sequence of the column. Like in
+// SQLite, the COLLATE operators of the ORDER BY terms only apply to the sorter,
+// so f.e. 'Apple' and 'apple' are both kept by a UNION that is ordered by
+// "1 COLLATE NOCASE".
+func (g *generator) compoundIndex() int {
+	n := len(g.sel.Columns)
+	keyInfo := &vm.KeyInfo{Collations: make([]string, n), Desc: make([]bool, n)}
+	for i := range keyInfo.Collations {
+		keyInfo.Collations[i] = g.compoundCollation(i)
+	}
+	cursor := g.allocCursor()
+	g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenEphemeral, cursor, n, 0, keyInfo, 0))
+
+	return cursor
+}
+
+// compoundRows generates code that reads the rows of the ephemeral index cursor,
+// which go to dest, skipping those that the ephemeral index filter does not have,
+// unless it is -1:
+//
+//	     Rewind   cursor, to done
+//	top: Column   each column of the row
+//	     NotFound filter, to next
+//	     ...      output the row
+//	next:
+//	     Next     cursor, to top
+//	done:
+func (g *generator) compoundRows(cursor, filter int, dest destination) {
+	n := len(g.sel.Columns)
+	row := dest.results
+	if dest.kind != destCompound {
+		row = g.allocRegisters(n)
+	}
+	next, done := g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeRewind, cursor, done, 0, 0, 0)
+	top := len(g.instructions)
+	for i := 0; i < n; i++ {
+		g.emit(vm.OpcodeColumn, cursor, i, row+i, 0, 0)
+	}
+	if filter >= 0 {
+		g.emit(vm.OpcodeNotFound, filter, next, row, n, 0)
+	}
+	if dest.kind == destCompound {
+		g.emit(vm.OpcodeGosub, dest.reg, dest.label, 0, 0, 0)
+	} else {
+		g.emit(vm.OpcodeIdxInsert, dest.cursor, row, n, 0, 0)
+	}
+	g.resolve(next)
+	g.emit(vm.OpcodeNext, cursor, top, 0, 0, 0)
+	g.resolve(done)
+}
+
+// resultColumn returns the index of the result column of the current query whose
+// expression is x, which may have a COLLATE operator.
+func (g *generator) resultColumn(x ast.Expr) int {
+	if c, ok := x.(*ast.CollateExpr); ok {
+		x = c.X
+	}
+	for i, c := range g.sel.Columns {
+		if c.Expr == x {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sortCollation returns the collating sequence that the ORDER BY term t sorts the
+// rows by: that of its expression, or, for a compound SELECT, that of its COLLATE
+// operator, if it has one, or else that of its result column.
+func (g *generator) sortCollation(t *ast.OrderingTerm) string {
+	if _, ok := t.Expr.(*ast.CollateExpr); ok || len(g.sel.Compound) == 0 {
+		return g.collation(t.Expr)
+	}
+
+	return g.compoundCollation(g.resultColumn(t.Expr))
+}
+
+// compoundCollation returns the collating sequence that the i-th result columns of
+// the SELECTs of the current query, which is a compound SELECT, are compared with.
+// Like in SQLite, it is that of the first of them that has one, even if it is the
+// default one, which column references do.
+func (g *generator) compoundCollation(i int) string {
+	for _, c := range g.sel.Compound {
+		restore := g.enter(newQuery(c.Select, g.outer))
+		x := c.Select.Columns[i].Expr
+		coll, ok := g.collation(x), g.hasCollation(x)
+		restore()
+		if ok {
+			return coll
+		}
+	}
+
+	return ""
+}
+
+// hasCollation returns true if x has a collating sequence, even if it is the
+// default one: if it is a COLLATE operator, or a column reference, other than
+// one to the rowid or the column that is an alias for it, or a CAST of either.
+func (g *generator) hasCollation(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		defer g.enter(g.scope(x))()
+		if i, ok := g.sel.Aliases[x]; ok {
+			return g.hasCollation(g.sel.Columns[i].Expr)
+		}
+		ref, ok := g.sel.Refs[x]
+		if !ok || ref.Column == RowidColumn {
+			return false
+		}
+		alias, ok := g.sel.Sources[ref.Source].Table.RowidAlias()
+		return !ok || ref.Column != alias
+	case *ast.CastExpr:
+		return g.hasCollation(x.X)
+	case *ast.CollateExpr:
+		return true
+	default:
+		return false
+	}
+}
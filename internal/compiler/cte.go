@@ -0,0 +1,156 @@
+package compiler
+
+import (
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// materialization is the code that computes the rows of a materialized CTE into
+// the ephemeral table cursor: a subroutine at the address of label, which is
+// called with Gosub reg.
+type materialization struct {
+	cursor, reg, label int
+}
+
+// materialize generates the start of a loop over the rows of the materialized CTE
+// that the source of l reads, with a cursor of its own on the ephemeral table that
+// holds them. Like in SQLite, the first such source generates a subroutine that
+// computes the rows, which each of them calls, and which only does so the first
+// time, unless the CTE is correlated:
+//
+//	     Goto          start
+//	sub: Once          to skip
+//	     OpenEphemeral table
+//	     ...           the body of the CTE, which appends each row to the table
+//	skip:
+//	     Return
+//	start:
+//	     Gosub         sub
+//	     OpenDup       cursor, table
+//	     Rewind        cursor, to done
+//	top:
+func (g *generator) materialize(l *loop) error {
+	src := g.sel.Sources[l.source]
+	m, ok := g.materialized[src.CTE]
+	if !ok {
+		m = &materialization{cursor: g.allocCursor(), reg: g.allocRegister(), label: g.newLabel()}
+		g.materialized[src.CTE] = m
+		start := g.newLabel()
+		g.emit(vm.OpcodeGoto, 0, start, 0, 0, 0)
+		g.resolve(m.label)
+		skip := g.once(src.Subquery)
+		g.emit(vm.OpcodeOpenEphemeral, m.cursor, len(src.Table.Columns), 0, 0, 0)
+		if err := g.subquery(src.Subquery, destination{kind: destTable, cursor: m.cursor}); err != nil {
+			return err
+		}
+		g.resolve(skip)
+		g.emit(vm.OpcodeReturn, m.reg, 0, 0, 0, 0)
+		g.resolve(start)
+	}
+
+	g.emit(vm.OpcodeGosub, m.reg, m.label, 0, 0, 0)
+	g.emit(vm.OpcodeOpenDup, l.table, m.cursor, 0, 0, 0)
+	g.emit(vm.OpcodeRewind, l.table, l.done, 0, 0, 0)
+	l.top = len(g.instructions)
+
+	return nil
+}
+
+// recursiveSelect generates the code of the current query, which is the body of a
+// recursive CTE, whose result rows go to dest. Like in SQLite, the rows of the
+// SELECTs before the recursive ones are added to a queue. Each row that is taken
+// from the queue is output, and put in an ephemeral table of its own, which the
+// recursive SELECTs read, whose rows are added to the queue too. That goes on
+// until the queue is empty, or the LIMIT is reached:
+//
+//	     ...           add the rows of the SELECTs before the recursive ones
+//	top: Rewind        queue, to done
+//	     OpenEphemeral current
+//	     ...           move the first row of the queue to the current one
+//	     ...           output the current row
+//	     ...           add the rows of the recursive SELECTs
+//	     Goto          top
+//	done:
+//
+// The queue is an ephemeral table, which returns the rows in the order that they
+// were added. If the compound has an ORDER BY clause, it is an ephemeral index
+// instead, whose entries start with the ORDER BY terms, followed by a sequence
+// number, which returns the first row in that order, and then the one that was
+// added first. For a UNION, the rows are only added to the queue if they are not
+// in another ephemeral index of the rows that were added before.
+func (g *generator) recursiveSelect(dest destination) error {
+	cte := g.sel.CTE
+	if err := g.beginOutput(true, dest); err != nil {
+		return err
+	}
+
+	n, keys := len(g.sel.Columns), len(g.sel.OrderBy)
+	width := n
+	queue := g.allocCursor()
+	if keys == 0 {
+		g.emit(vm.OpcodeOpenEphemeral, queue, n, 0, 0, 0)
+	} else {
+		width = keys + 1 + n
+		keyInfo := g.orderByKeyInfo()
+		keyInfo.Collations = append(keyInfo.Collations, "")
+		keyInfo.Desc = append(keyInfo.Desc, false)
+		if keyInfo.BigNull != nil {
+			keyInfo.BigNull = append(keyInfo.BigNull, false)
+		}
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenEphemeral, queue, width, 0, keyInfo, 0))
+	}
+	distinct := -1
+	if g.sel.Compound[len(g.sel.Compound)-1].Op == ast.CompoundUnion {
+		distinct = g.compoundIndex()
+	}
+
+	// The rows are added to the queue by a subroutine.
+	ret, entry := g.allocRegister(), g.allocRegisters(width)
+	row := entry + width - n
+	sub, start, skip := g.newLabel(), g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeGoto, 0, start, 0, 0, 0)
+	g.resolve(sub)
+	if distinct >= 0 {
+		g.emit(vm.OpcodeFound, distinct, skip, row, n, 0)
+		g.emit(vm.OpcodeIdxInsert, distinct, row, n, 0, 0)
+	}
+	if keys == 0 {
+		g.emit(vm.OpcodeInsert, queue, row, n, 0, 0)
+	} else {
+		for i, t := range g.sel.OrderBy {
+			g.emit(vm.OpcodeSCopy, row+g.resultColumn(t.Expr), entry+i, 0, 0, 0)
+		}
+		g.emit(vm.OpcodeSequence, queue, entry+keys, 0, 0, 0)
+		g.emit(vm.OpcodeIdxInsert, queue, entry, width, 0, 0)
+	}
+	g.resolve(skip)
+	g.emit(vm.OpcodeReturn, ret, 0, 0, 0, 0)
+	g.resolve(start)
+
+	rows := destination{kind: destCompound, reg: ret, results: row, label: sub}
+	if err := g.compound(cte.Recursive-1, rows); err != nil {
+		return err
+	}
+
+	current, result := g.allocCursor(), g.allocRegisters(n)
+	g.current[cte] = current
+	top := g.emit(vm.OpcodeRewind, queue, g.out.done, 0, 0, 0)
+	g.emit(vm.OpcodeOpenEphemeral, current, n, 0, 0, 0)
+	for i := 0; i < n; i++ {
+		g.emit(vm.OpcodeColumn, queue, width-n+i, result+i, 0, 0)
+	}
+	g.emit(vm.OpcodeInsert, current, result, n, 0, 0)
+	g.emit(vm.OpcodeDelete, queue, 0, 0, 0, 0)
+	next := g.newLabel()
+	g.emitRow(result, next)
+	g.resolve(next)
+	for i := cte.Recursive; i < len(g.sel.Compound); i++ {
+		if err := g.compoundArm(i, rows); err != nil {
+			return err
+		}
+	}
+	g.emit(vm.OpcodeGoto, 0, top, 0, 0, 0)
+	g.endOutput()
+
+	return nil
+}
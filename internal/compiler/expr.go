@@ -0,0 +1,814 @@
+package compiler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// expr generates code that stores the value of x in the register target.
+func (g *generator) expr(x ast.Expr, target int) error {
+	switch x := x.(type) {
+	case *ast.Ident:
+		defer g.enter(g.scope(x))()
+		if i, ok := g.sel.Aliases[x]; ok {
+			return g.expr(g.sel.Columns[i].Expr, target)
+		}
+		g.column(g.sel.Refs[x], target)
+	case *ast.Literal:
+		return g.literal(x, target)
+	case *ast.Param:
+		g.emit(vm.OpcodeVariable, g.params[x], target, 0, 0, 0)
+	case *ast.CastExpr:
+		if err := g.expr(x.X, target); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeCast, target, vm.TypeAffinity(x.Type), 0, 0, 0)
+	case *ast.UnaryExpr:
+		return g.unary(x, target)
+	case *ast.BinaryExpr:
+		if op, ok := arithmeticOpcodes[x.Op]; ok {
+			lhs, rhs := g.allocRegister(), g.allocRegister()
+			if err := g.expr(x.X, lhs); err != nil {
+				return err
+			}
+			if err := g.expr(x.Y, rhs); err != nil {
+				return err
+			}
+			g.emit(op, rhs, lhs, target, 0, 0)
+			return nil
+		}
+		if x.Op == ast.OpAnd || x.Op == ast.OpOr {
+			a, b := g.allocRegister(), g.allocRegister()
+			if err := g.expr(x.X, a); err != nil {
+				return err
+			}
+			if err := g.expr(x.Y, b); err != nil {
+				return err
+			}
+			op := vm.OpcodeAnd
+			if x.Op == ast.OpOr {
+				op = vm.OpcodeOr
+			}
+			g.emit(op, a, b, target, 0, 0)
+			return nil
+		}
+
+		lhs, rhs, err := g.operands(x)
+		if err != nil {
+			return err
+		}
+		g.compareVectors(x.Op, lhs, rhs, target)
+	case *ast.BetweenExpr:
+		return g.between(x, target)
+	case *ast.CaseExpr:
+		return g.caseExpr(x, target)
+	case *ast.CollateExpr:
+		return g.expr(x.X, target)
+	case *ast.LikeExpr:
+		return g.like(x, target)
+	case *ast.CallExpr:
+		if acc, ok := g.accumulators[x]; ok {
+			g.emit(vm.OpcodeSCopy, acc, target, 0, 0, 0)
+			return nil
+		}
+		return g.call(x, target)
+	case *ast.SubqueryExpr:
+		return g.scalarSubquery(x, target)
+	case *ast.ExistsExpr:
+		return g.exists(x, target)
+	case *ast.InExpr:
+		return g.in(x, target)
+	default:
+		panic(fmt.Sprintf("compiler: unexpected expression type %T", x))
+	}
+
+	return nil
+}
+
+// column generates code that reads the column ref of the row that its source's
+// cursor is at into the register target, or copies it from the register that
+// columnRegs holds it in, or that the row of a subquery was yielded in.
+func (g *generator) column(ref ColumnRef, target int) {
+	if r, ok := g.columnRegs[ref]; ok {
+		g.emit(vm.OpcodeSCopy, r, target, 0, 0, 0)
+		return
+	}
+	if r, ok := g.results[ref.Source]; ok {
+		g.emit(vm.OpcodeSCopy, r+ref.Column, target, 0, 0, 0)
+		return
+	}
+
+	cursor := g.cursors[ref.Source]
+	t := g.sel.Sources[ref.Source].Table
+
+	// The column that is an alias for the rowid is stored as a NULL.
+	if alias, ok := t.RowidAlias(); ref.Column == RowidColumn || (ok && ref.Column == alias) {
+		g.emit(vm.OpcodeRowid, cursor, target, 0, 0, 0)
+		return
+	}
+
+	g.emit(vm.OpcodeColumn, cursor, ref.Column, target, 0, 0)
+	if vm.TypeAffinity(t.Columns[ref.Column].Type) == vm.AffinityReal {
+		g.emit(vm.OpcodeRealAffinity, target, 0, 0, 0, 0)
+	}
+}
+
+// literal generates code that stores the value of lit in the register target.
+func (g *generator) literal(lit *ast.Literal, target int) error {
+	switch lit.Kind {
+	case ast.StringLiteral:
+		g.emitStr(vm.OpcodeString8, 0, target, 0, lit.Value, 0)
+	case ast.BlobLiteral:
+		b, err := hex.DecodeString(lit.Value)
+		if err != nil {
+			return fmt.Errorf("malformed blob literal: X'%s'", lit.Value)
+		}
+		g.emitStr(vm.OpcodeBlob, len(b), target, 0, string(b), 0)
+	case ast.NullLiteral:
+		g.emit(vm.OpcodeNull, 0, target, 0, 0, 0)
+	default:
+		return g.number(lit.Value, false, target)
+	}
+
+	return nil
+}
+
+// number generates code that stores the number text, or its negation if neg is
+// set, in the register target.
+func (g *generator) number(text string, neg bool, target int) error {
+	if len(text) > 2 && text[0] == '0' && (text[1] == 'x' || text[1] == 'X') {
+		// Hexadecimal integers are two's complement, so 0xFFFFFFFFFFFFFFFF
+		// is -1.
+		u, err := strconv.ParseUint(text[2:], 16, 64)
+		if err != nil {
+			return fmt.Errorf("hex literal too big: %s", text)
+		}
+		i := int64(u)
+		if neg {
+			i = -i
+		}
+		g.emit(vm.OpcodeInteger, int(i), target, 0, 0, 0)
+		return nil
+	}
+
+	// Integers that do not fit into an int64 are reals, except for the
+	// smallest one, which can only be written negated.
+	if !strings.ContainsAny(text, ".eE") {
+		if neg {
+			text = "-" + text
+		}
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			g.emit(vm.OpcodeInteger, int(i), target, 0, 0, 0)
+			return nil
+		}
+		text = strings.TrimPrefix(text, "-")
+	}
+	f := vm.ParseReal(text)
+	if neg {
+		f = -f
+	}
+	g.append(vm.NewInstructionReal(vm.OpcodeReal, 0, target, 0, f, 0))
+
+	return nil
+}
+
+// unary generates code that stores the result of the unary operator x in the
+// register target.
+func (g *generator) unary(x *ast.UnaryExpr, target int) error {
+	if lit, ok := x.X.(*ast.Literal); ok && x.Op == ast.OpNeg && lit.Kind == ast.NumberLiteral {
+		return g.number(lit.Value, true, target)
+	}
+	if err := g.expr(x.X, target); err != nil {
+		return err
+	}
+
+	switch x.Op {
+	case ast.OpNeg:
+		zero := g.allocRegister()
+		g.emit(vm.OpcodeInteger, 0, zero, 0, 0, 0)
+		g.emit(vm.OpcodeSubtract, target, zero, target, 0, 0)
+	case ast.OpBitNot:
+		g.emit(vm.OpcodeBitNot, target, target, 0, 0, 0)
+	case ast.OpNot:
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// between generates code that stores the result of the BETWEEN operator x in the
+// register target, which is that of "x.X >= x.Low AND x.X <= x.High", except
+// that x.X is only evaluated once.
+func (g *generator) between(x *ast.BetweenExpr, target int) error {
+	var v [3]vector
+	for i, y := range []ast.Expr{x.X, x.Low, x.High} {
+		var err error
+		if v[i], err = g.vector(y); err != nil {
+			return err
+		}
+	}
+
+	r := g.allocRegister()
+	g.compareVectors(ast.OpGe, v[0], v[1], target)
+	g.compareVectors(ast.OpLe, v[0], v[2], r)
+	g.emit(vm.OpcodeAnd, target, r, target, 0, 0)
+	if x.Not {
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// caseExpr generates code that stores the result of the CASE expression x in the
+// register target. Its operand, if it has one, is evaluated once, and compared
+// with the condition of each WHEN clause in turn:
+//
+//	     ...       the operand
+//	     ...       jump to next unless the condition is true, or equals the operand
+//	     ...       the result
+//	     Goto      done
+//	next:
+//	     ...       the next WHEN clauses
+//	     ...       the ELSE clause, or Null target
+//	done:
+func (g *generator) caseExpr(x *ast.CaseExpr, target int) error {
+	var operand vector
+	if x.X != nil {
+		var err error
+		if operand, err = g.vector(x.X); err != nil {
+			return err
+		}
+	}
+
+	done := g.newLabel()
+	for _, w := range x.Whens {
+		next := g.newLabel()
+		if x.X == nil {
+			if err := g.jumpIfFalse(w.Cond, next, true); err != nil {
+				return err
+			}
+		} else {
+			v, err := g.vector(w.Cond)
+			if err != nil {
+				return err
+			}
+			if len(v.affinities) == 1 {
+				g.compare(vm.OpcodeNe, operand, v, 0, next, vm.JumpIfNull)
+			} else {
+				r := g.allocRegister()
+				g.compareVectors(ast.OpEq, operand, v, r)
+				g.emit(vm.OpcodeIfNot, r, next, 1, 0, 0)
+			}
+		}
+		if err := g.expr(w.Result, target); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeGoto, 0, done, 0, 0, 0)
+		g.resolve(next)
+	}
+	if x.Else != nil {
+		if err := g.expr(x.Else, target); err != nil {
+			return err
+		}
+	} else {
+		g.emit(vm.OpcodeNull, 0, target, 0, 0, 0)
+	}
+	g.resolve(done)
+
+	return nil
+}
+
+// like generates code that stores the result of a LIKE, GLOB or REGEXP operator
+// in the register target. "x LIKE y ESCAPE z" is a call to like(y, x, z), and
+// similarly for glob() and regexp().
+func (g *generator) like(x *ast.LikeExpr, target int) error {
+	args := []ast.Expr{x.Y, x.X}
+	if x.Escape != nil {
+		args = append(args, x.Escape)
+	}
+
+	name := strings.ToLower(x.Op.String())
+	if _, err := vm.LookupFunction(name, len(args)); err != nil {
+		return err
+	}
+
+	first := g.allocRegisters(len(args))
+	for i, arg := range args {
+		if err := g.expr(arg, first+i); err != nil {
+			return err
+		}
+	}
+	g.emitStr(vm.OpcodeFunction, 0, first, target, name, len(args))
+	if x.Not {
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// call generates code that stores the result of calling the scalar function x in
+// the register target.
+func (g *generator) call(x *ast.CallExpr, target int) error {
+	first := g.allocRegisters(len(x.Args))
+	for i, arg := range x.Args {
+		if err := g.expr(arg, first+i); err != nil {
+			return err
+		}
+	}
+	g.emitStr(vm.OpcodeFunction, 0, first, target, strings.ToLower(x.Name), len(x.Args))
+
+	return nil
+}
+
+// arithmeticOpcodes are the opcodes that implement each binary operator that is
+// not a comparison or AND and OR, which compute r[P2] op r[P1].
+var arithmeticOpcodes = map[ast.Operator]vm.Opcode{
+	ast.OpAdd:        vm.OpcodeAdd,
+	ast.OpSub:        vm.OpcodeSubtract,
+	ast.OpMul:        vm.OpcodeMultiply,
+	ast.OpDiv:        vm.OpcodeDivide,
+	ast.OpRem:        vm.OpcodeRemainder,
+	ast.OpConcat:     vm.OpcodeConcat,
+	ast.OpBitAnd:     vm.OpcodeBitAnd,
+	ast.OpBitOr:      vm.OpcodeBitOr,
+	ast.OpShiftLeft:  vm.OpcodeShiftLeft,
+	ast.OpShiftRight: vm.OpcodeShiftRight,
+}
+
+// comparisonOpcodes are the opcodes that implement each comparison operator. IS
+// and IS NOT are = and != with the vm.NullEq flag.
+var comparisonOpcodes = map[ast.Operator]vm.Opcode{
+	ast.OpEq:    vm.OpcodeEq,
+	ast.OpNe:    vm.OpcodeNe,
+	ast.OpLt:    vm.OpcodeLt,
+	ast.OpLe:    vm.OpcodeLe,
+	ast.OpGt:    vm.OpcodeGt,
+	ast.OpGe:    vm.OpcodeGe,
+	ast.OpIs:    vm.OpcodeEq,
+	ast.OpIsNot: vm.OpcodeNe,
+}
+
+// comparisonFlags returns the flags that are added to the P5 operand of the
+// opcode of the comparison operator op.
+func comparisonFlags(op ast.Operator) int {
+	if op == ast.OpIs || op == ast.OpIsNot {
+		return vm.NullEq
+	}
+
+	return 0
+}
+
+// negations maps each comparison opcode to the one that jumps when it does not,
+// except when an operand is NULL.
+var negations = map[vm.Opcode]vm.Opcode{
+	vm.OpcodeEq:      vm.OpcodeNe,
+	vm.OpcodeNe:      vm.OpcodeEq,
+	vm.OpcodeLt:      vm.OpcodeGe,
+	vm.OpcodeGe:      vm.OpcodeLt,
+	vm.OpcodeGt:      vm.OpcodeLe,
+	vm.OpcodeLe:      vm.OpcodeGt,
+	vm.OpcodeIsNull:  vm.OpcodeNotNull,
+	vm.OpcodeNotNull: vm.OpcodeIsNull,
+}
+
+// nullTest returns the opcode that jumps if x is true, if it is "y IS NULL" or
+// "y IS NOT NULL".
+func nullTest(x *ast.BinaryExpr) (vm.Opcode, bool) {
+	if lit, ok := x.Y.(*ast.Literal); !ok || lit.Kind != ast.NullLiteral {
+		return 0, false
+	}
+	switch x.Op {
+	case ast.OpIs:
+		return vm.OpcodeIsNull, true
+	case ast.OpIsNot:
+		return vm.OpcodeNotNull, true
+	default:
+		return 0, false
+	}
+}
+
+// vector holds the values of an operand of a comparison in the registers starting
+// at reg: one for each value of a row value, or a single one for a scalar, along
+// with the affinity and collating sequence of each.
+type vector struct {
+	reg        int
+	affinities []int
+	collations []string
+}
+
+// vector generates code that stores the values of the operand x of a comparison
+// in new registers. Those of a subquery with several columns are the columns of
+// its first row.
+func (g *generator) vector(x ast.Expr) (vector, error) {
+	v := vector{reg: g.allocRegisters(g.width(x))}
+	v.affinities, v.collations = g.comparand(x)
+	switch x := x.(type) {
+	case *ast.RowExpr:
+		for i, y := range x.List {
+			if err := g.expr(y, v.reg+i); err != nil {
+				return vector{}, err
+			}
+		}
+		return v, nil
+	case *ast.SubqueryExpr:
+		if len(v.affinities) > 1 {
+			return v, g.scalarSubquery(x, v.reg)
+		}
+	}
+
+	return v, g.expr(x, v.reg)
+}
+
+// comparand returns the affinities and collating sequences of the values of the
+// operand x of a comparison.
+func (g *generator) comparand(x ast.Expr) (affinities []int, collations []string) {
+	switch x := x.(type) {
+	case *ast.RowExpr:
+		for _, y := range x.List {
+			affinities = append(affinities, g.affinity(y))
+			collations = append(collations, g.collation(y))
+		}
+		return affinities, collations
+	case *ast.SubqueryExpr:
+		if sel := g.sel.Subqueries[x.Select]; len(sel.Columns) > 1 {
+			return g.subqueryColumns(sel)
+		}
+	}
+
+	return []int{g.affinity(x)}, []string{g.collation(x)}
+}
+
+// width returns the number of values of the operand x of a comparison.
+func (g *generator) width(x ast.Expr) int {
+	switch x := x.(type) {
+	case *ast.RowExpr:
+		return len(x.List)
+	case *ast.SubqueryExpr:
+		return len(g.sel.Subqueries[x.Select].Columns)
+	default:
+		return 1
+	}
+}
+
+// operands generates code that stores the operands of the comparison x in new
+// registers.
+func (g *generator) operands(x *ast.BinaryExpr) (lhs, rhs vector, err error) {
+	if lhs, err = g.vector(x.X); err != nil {
+		return vector{}, vector{}, err
+	}
+	if rhs, err = g.vector(x.Y); err != nil {
+		return vector{}, vector{}, err
+	}
+
+	return lhs, rhs, nil
+}
+
+// compare emits the comparison opcode op, which jumps to label if the i-th value
+// of lhs op that of rhs is true, with the affinity and collating sequence of
+// their comparison. flags are added to its P5 operand, f.e. vm.JumpIfNull.
+func (g *generator) compare(op vm.Opcode, lhs, rhs vector, i, label, flags int) {
+	coll := lhs.collations[i]
+	if coll == "" {
+		coll = rhs.collations[i]
+	}
+	affinity := compareAffinities(lhs.affinities[i], rhs.affinities[i])
+	g.emitStr(op, rhs.reg+i, label, lhs.reg+i, coll, affinity|flags)
+}
+
+// compareValues generates code that stores the result of comparing the i-th
+// values of lhs and rhs with the comparison opcode op in the register target.
+// The result is 1 if the comparison jumps, and otherwise 0, or NULL if either
+// value is NULL, unless flags has vm.NullEq.
+func (g *generator) compareValues(op vm.Opcode, lhs, rhs vector, i, flags, target int) {
+	done := g.newLabel()
+	g.emit(vm.OpcodeInteger, 1, target, 0, 0, 0)
+	g.compare(op, lhs, rhs, i, done, flags)
+	if flags&vm.NullEq != 0 {
+		g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	} else {
+		g.emit(vm.OpcodeZeroOrNull, lhs.reg+i, target, rhs.reg+i, 0, 0)
+	}
+	g.resolve(done)
+}
+
+// compareVectors generates code that stores the result of the comparison
+// "lhs op rhs" in the register target. Row values are equal if each of their
+// values are, and are otherwise ordered by the first of their values that are
+// not equal, which is NULL if either of those is:
+//
+//	     Eq        the first values, to next
+//	     ...       store whether the first values are less or greater
+//	     Goto      done
+//	next:
+//	     ...       the same for the next values, or compare the last ones
+//	done:
+func (g *generator) compareVectors(op ast.Operator, lhs, rhs vector, target int) {
+	opcode, flags, n := comparisonOpcodes[op], comparisonFlags(op), len(lhs.affinities)
+	if opcode == vm.OpcodeEq || opcode == vm.OpcodeNe {
+		// The results of comparing each pair of values are combined with
+		// AND, or with OR for != and IS NOT.
+		g.compareValues(opcode, lhs, rhs, 0, flags, target)
+		if n > 1 {
+			combine, r := vm.OpcodeAnd, g.allocRegister()
+			if opcode == vm.OpcodeNe {
+				combine = vm.OpcodeOr
+			}
+			for i := 1; i < n; i++ {
+				g.compareValues(opcode, lhs, rhs, i, flags, r)
+				g.emit(combine, target, r, target, 0, 0)
+			}
+		}
+		return
+	}
+
+	strict := opcode
+	switch opcode {
+	case vm.OpcodeLe:
+		strict = vm.OpcodeLt
+	case vm.OpcodeGe:
+		strict = vm.OpcodeGt
+	}
+	done := g.newLabel()
+	for i := 0; i < n-1; i++ {
+		next := g.newLabel()
+		g.compare(vm.OpcodeEq, lhs, rhs, i, next, 0)
+		g.compareValues(strict, lhs, rhs, i, 0, target)
+		g.emit(vm.OpcodeGoto, 0, done, 0, 0, 0)
+		g.resolve(next)
+	}
+	g.compareValues(opcode, lhs, rhs, n-1, flags, target)
+	g.resolve(done)
+}
+
+// jumpIfTrue generates code that jumps to label if x is true. If x is NULL, the
+// jump is only taken if jumpIfNull is set.
+func (g *generator) jumpIfTrue(x ast.Expr, label int, jumpIfNull bool) error {
+	switch x := x.(type) {
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case ast.OpAnd:
+			skip := g.newLabel()
+			if err := g.jumpIfFalse(x.X, skip, !jumpIfNull); err != nil {
+				return err
+			}
+			if err := g.jumpIfTrue(x.Y, label, jumpIfNull); err != nil {
+				return err
+			}
+			g.resolve(skip)
+			return nil
+		case ast.OpOr:
+			if err := g.jumpIfTrue(x.X, label, jumpIfNull); err != nil {
+				return err
+			}
+			return g.jumpIfTrue(x.Y, label, jumpIfNull)
+		}
+		if op, ok := nullTest(x); ok {
+			r := g.allocRegister()
+			if err := g.expr(x.X, r); err != nil {
+				return err
+			}
+			g.emit(op, r, label, 0, 0, 0)
+			return nil
+		}
+		if op, ok := comparisonOpcodes[x.Op]; ok && g.width(x.X) == 1 {
+			lhs, rhs, err := g.operands(x)
+			if err != nil {
+				return err
+			}
+			g.compare(op, lhs, rhs, 0, label, comparisonFlags(x.Op)|jumpFlags(jumpIfNull))
+			return nil
+		}
+	case *ast.UnaryExpr:
+		if x.Op == ast.OpNot {
+			return g.jumpIfFalse(x.X, label, jumpIfNull)
+		}
+	}
+
+	r := g.allocRegister()
+	if err := g.expr(x, r); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeIf, r, label, boolInt(jumpIfNull), 0, 0)
+
+	return nil
+}
+
+// jumpIfFalse generates code that jumps to label if x is false. If x is NULL, the
+// jump is only taken if jumpIfNull is set.
+func (g *generator) jumpIfFalse(x ast.Expr, label int, jumpIfNull bool) error {
+	switch x := x.(type) {
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case ast.OpAnd:
+			if err := g.jumpIfFalse(x.X, label, jumpIfNull); err != nil {
+				return err
+			}
+			return g.jumpIfFalse(x.Y, label, jumpIfNull)
+		case ast.OpOr:
+			skip := g.newLabel()
+			if err := g.jumpIfTrue(x.X, skip, !jumpIfNull); err != nil {
+				return err
+			}
+			if err := g.jumpIfFalse(x.Y, label, jumpIfNull); err != nil {
+				return err
+			}
+			g.resolve(skip)
+			return nil
+		}
+		if op, ok := nullTest(x); ok {
+			r := g.allocRegister()
+			if err := g.expr(x.X, r); err != nil {
+				return err
+			}
+			g.emit(negations[op], r, label, 0, 0, 0)
+			return nil
+		}
+		if op, ok := comparisonOpcodes[x.Op]; ok && g.width(x.X) == 1 {
+			lhs, rhs, err := g.operands(x)
+			if err != nil {
+				return err
+			}
+			g.compare(negations[op], lhs, rhs, 0, label, comparisonFlags(x.Op)|jumpFlags(jumpIfNull))
+			return nil
+		}
+	case *ast.UnaryExpr:
+		if x.Op == ast.OpNot {
+			return g.jumpIfTrue(x.X, label, jumpIfNull)
+		}
+	}
+
+	r := g.allocRegister()
+	if err := g.expr(x, r); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeIfNot, r, label, boolInt(jumpIfNull), 0, 0)
+
+	return nil
+}
+
+func jumpFlags(jumpIfNull bool) int {
+	if jumpIfNull {
+		return vm.JumpIfNull
+	}
+
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// affinity returns the affinity of x, or 0 if it has none. Only column references
+// and CASTs have an affinity, which COLLATE operators keep.
+//
+// See: https://www.sqlite.org/datatype3.html#affinity_of_expressions
+func (g *generator) affinity(x ast.Expr) int {
+	switch x := x.(type) {
+	case *ast.Ident:
+		defer g.enter(g.scope(x))()
+		if i, ok := g.sel.Aliases[x]; ok {
+			return g.affinity(g.sel.Columns[i].Expr)
+		}
+		ref := g.sel.Refs[x]
+		if ref.Column == RowidColumn {
+			return vm.AffinityInteger
+		}
+		return vm.TypeAffinity(g.sel.Sources[ref.Source].Table.Columns[ref.Column].Type)
+	case *ast.CastExpr:
+		return vm.TypeAffinity(x.Type)
+	case *ast.CollateExpr:
+		return g.affinity(x.X)
+	default:
+		return 0
+	}
+}
+
+// comparisonAffinity returns the affinity that is applied to the operands of a
+// comparison of x and y: NUMERIC if either has a numeric affinity, and otherwise
+// the affinity of the operand that has one, if only one of them does.
+//
+// See: https://www.sqlite.org/datatype3.html#type_conversions_prior_to_comparison
+func (g *generator) comparisonAffinity(x, y ast.Expr) int {
+	return compareAffinities(g.affinity(x), g.affinity(y))
+}
+
+func compareAffinities(a, b int) int {
+	switch {
+	case a != 0 && b != 0:
+		if a >= vm.AffinityNumeric || b >= vm.AffinityNumeric {
+			return vm.AffinityNumeric
+		}
+		return vm.AffinityBlob
+	case a == 0 && b == 0:
+		return vm.AffinityBlob
+	default:
+		return a + b
+	}
+}
+
+// collation returns the name of the collating sequence of x, or "" if it has none.
+// Column references and COLLATE operators have one, which CASTs and unary plus
+// keep. Like in SQLite, other expressions have that of the first of their
+// operands that has a COLLATE operator in it.
+func (g *generator) collation(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.Ident:
+		defer g.enter(g.scope(x))()
+		if i, ok := g.sel.Aliases[x]; ok {
+			return g.collation(g.sel.Columns[i].Expr)
+		}
+		ref := g.sel.Refs[x]
+		if ref.Column == RowidColumn {
+			return ""
+		}
+		return g.sel.Sources[ref.Source].Table.Columns[ref.Column].Collation
+	case *ast.CastExpr:
+		return g.collation(x.X)
+	case *ast.CollateExpr:
+		return x.Collation
+	case *ast.UnaryExpr:
+		if x.Op == ast.OpPlus {
+			return g.collation(x.X)
+		}
+	}
+
+	for _, y := range subexprs(x) {
+		if hasCollate(y) {
+			return g.collation(y)
+		}
+	}
+
+	return ""
+}
+
+// subexprs returns the operands of x, in the order that SQLite looks for a
+// COLLATE operator in them.
+func subexprs(x ast.Expr) []ast.Expr {
+	switch x := x.(type) {
+	case *ast.UnaryExpr:
+		return []ast.Expr{x.X}
+	case *ast.BinaryExpr:
+		return []ast.Expr{x.X, x.Y}
+	case *ast.LikeExpr:
+		// The operands are the arguments of a call to like().
+		return []ast.Expr{x.Y, x.X, x.Escape}
+	case *ast.BetweenExpr:
+		return []ast.Expr{x.X, x.Low, x.High}
+	case *ast.InExpr:
+		return append([]ast.Expr{x.X}, x.List...)
+	case *ast.CaseExpr:
+		xs := []ast.Expr{x.X}
+		for _, w := range x.Whens {
+			xs = append(xs, w.Cond, w.Result)
+		}
+		return append(xs, x.Else)
+	case *ast.CallExpr:
+		return x.Args
+	case *ast.RowExpr:
+		return x.List
+	default:
+		return nil
+	}
+}
+
+// hasCollate returns true if x has a COLLATE operator in it, outside of
+// subqueries.
+func hasCollate(x ast.Expr) bool {
+	if x == nil {
+		return false
+	}
+
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.CollateExpr:
+			found = true
+		case *ast.SelectStatement:
+			return false
+		}
+		return !found
+	})
+
+	return found
+}
+
+// comparisonCollation returns the collating sequence that a comparison of x and y
+// uses: that of x, if it has one, or else that of y.
+//
+// See: https://www.sqlite.org/datatype3.html#assigning_collating_sequences_from_sql
+func (g *generator) comparisonCollation(x, y ast.Expr) string {
+	if coll := g.collation(x); coll != "" {
+		return coll
+	}
+
+	return g.collation(y)
+}
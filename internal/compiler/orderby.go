@@ -0,0 +1,283 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// output holds the state of the code that outputs the result rows of a SELECT,
+// which either outputs each row as soon as it is computed, or adds it to a sorter
+// that outputs the rows in the order of the ORDER BY clause once all of them have
+// been computed. Like in SQLite, each entry of the sorter holds the ORDER BY terms
+// of a row, followed by its result columns:
+//
+//	     SorterOpen
+//	     ...         compute the rows
+//	     SorterInsert the ORDER BY terms and result columns of each row
+//	     ...
+//	     SorterSort  to done, if there are no rows
+//	top: SorterData
+//	     ResultRow   the result columns
+//	     SorterNext  to top
+//	done:
+//
+// A LIMIT is a counter that is decremented after each row that is output, which
+// ends the query once it reaches zero, so that the rest of the rows are never
+// read. An OFFSET is a counter of the rows that are skipped before that. With a
+// sorter, the sorter itself only keeps the rows that come first. The rows of a
+// DISTINCT query are looked up in an ephemeral index of the rows that were
+// already output before they are output or sorted.
+//
+// The rows of subqueries are not returned by the program, but go to the
+// destination of the output.
+type output struct {
+	dest destination
+	// sorter is the cursor of the sorter, or -1 if the rows are output as they
+	// are computed.
+	sorter  int
+	keyInfo *vm.KeyInfo
+	// distinct is the cursor of the ephemeral index of a DISTINCT query, or -1.
+	distinct int
+	// limit is the register that holds the number of rows that are left to
+	// output, or 0 if there is no LIMIT. offset is the register that holds the
+	// number of rows that are left to skip, followed by the one that holds the
+	// number of rows that the sorter keeps, or 0 if there is no OFFSET.
+	limit, offset int
+	// done is the label of the end of the output.
+	done int
+}
+
+// destKind is the kind of destination that the result rows go to.
+type destKind int
+
+const (
+	// destResult returns the rows from the program.
+	destResult destKind = iota
+	// destCoroutine yields each row from the coroutine in r[reg], after it is
+	// copied into the registers starting at results.
+	destCoroutine
+	// destValue stores the columns of the first row in the registers starting
+	// at reg, and ends the query.
+	destValue
+	// destExists sets r[reg] to 1 once there is a row, and ends the query.
+	destExists
+	// destSet adds each row to the ephemeral index cursor, with the affinities
+	// of its columns, and sets r[reg] to 1.
+	destSet
+	// destCompound copies each row into the registers starting at results,
+	// and calls the subroutine at label, which outputs the rows of a compound
+	// SELECT, with Gosub reg.
+	destCompound
+	// destUnion adds each row to the ephemeral index cursor.
+	destUnion
+	// destExcept removes each row from the ephemeral index cursor.
+	destExcept
+	// destTable appends each row to the ephemeral table cursor.
+	destTable
+)
+
+// destination is where the result rows of a query go, like SQLite's SelectDest.
+type destination struct {
+	kind       destKind
+	reg        int
+	results    int
+	cursor     int
+	affinities string
+	label      int
+}
+
+// beginOutput generates the code that prepares the output of the result rows to
+// dest, which are sorted unless there is no ORDER BY clause, or the rows are
+// computed in its order already.
+func (g *generator) beginOutput(ordered bool, dest destination) error {
+	out := &output{dest: dest, sorter: -1, distinct: -1, done: g.newLabel()}
+	g.out = out
+
+	if err := g.limit(); err != nil {
+		return err
+	}
+
+	// The DISTINCT of the first SELECT of a compound only applies to it.
+	if g.sel.Stmt.Distinct && len(g.sel.Compound) == 0 {
+		keyInfo := &vm.KeyInfo{}
+		for _, c := range g.sel.Columns {
+			keyInfo.Collations = append(keyInfo.Collations, g.collation(c.Expr))
+			keyInfo.Desc = append(keyInfo.Desc, false)
+		}
+		out.distinct = g.allocCursor()
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenEphemeral, out.distinct, len(g.sel.Columns), 0, keyInfo, 0))
+	}
+
+	if ordered || len(g.sel.OrderBy) == 0 {
+		return nil
+	}
+
+	out.keyInfo = g.orderByKeyInfo()
+	// The sorter keeps the rows of the OFFSET, along with those of the LIMIT.
+	keep := out.limit
+	if out.offset != 0 {
+		keep = out.offset + 1
+	}
+	out.sorter = g.allocCursor()
+	n := len(g.sel.OrderBy) + len(g.sel.Columns)
+	g.append(vm.NewInstructionKeyInfo(vm.OpcodeSorterOpen, out.sorter, n, keep, out.keyInfo, 0))
+
+	return nil
+}
+
+// orderByKeyInfo returns the KeyInfo of the entries of a sorter that sorts the
+// rows by the terms of the ORDER BY clause, which start with those terms.
+func (g *generator) orderByKeyInfo() *vm.KeyInfo {
+	keyInfo := &vm.KeyInfo{}
+	for i, t := range g.sel.OrderBy {
+		keyInfo.Collations = append(keyInfo.Collations, g.sortCollation(t))
+		keyInfo.Desc = append(keyInfo.Desc, t.Order == ast.SortDesc)
+		if bigNull(t) {
+			if keyInfo.BigNull == nil {
+				keyInfo.BigNull = make([]bool, len(g.sel.OrderBy))
+			}
+			keyInfo.BigNull[i] = true
+		}
+	}
+
+	return keyInfo
+}
+
+// limit generates the code that computes the LIMIT and OFFSET clauses, which must
+// be integers. Like in SQLite, a LIMIT that is negative means that there is none,
+// an OFFSET that is negative is 0, and the query ends immediately if the LIMIT is
+// 0.
+func (g *generator) limit() error {
+	out, stmt := g.out, g.sel.Stmt
+	if stmt.Limit == nil {
+		return nil
+	}
+
+	out.limit = g.allocRegister()
+	if n, ok := intLiteral(stmt.Limit); ok {
+		g.emit(vm.OpcodeInteger, n, out.limit, 0, 0, 0)
+		if n == 0 {
+			g.emit(vm.OpcodeGoto, 0, out.done, 0, 0, 0)
+		}
+	} else {
+		if err := g.expr(stmt.Limit, out.limit); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeMustBeInt, out.limit, 0, 0, 0, 0)
+		g.emit(vm.OpcodeIfNot, out.limit, out.done, 0, 0, 0)
+	}
+
+	if stmt.Offset != nil {
+		out.offset = g.allocRegisters(2)
+		if err := g.expr(stmt.Offset, out.offset); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeMustBeInt, out.offset, 0, 0, 0, 0)
+		g.emit(vm.OpcodeOffsetLimit, out.limit, out.offset+1, out.offset, 0, 0)
+	}
+
+	return nil
+}
+
+// resultRow generates code that computes the result columns of the current row
+// and outputs them, or adds them to the sorter along with the ORDER BY terms.
+func (g *generator) resultRow() error {
+	out := g.out
+	keys := 0
+	if out.sorter >= 0 {
+		keys = len(g.sel.OrderBy)
+	}
+	n := len(g.sel.Columns)
+	entry := g.allocRegisters(keys + n)
+	result := entry + keys
+	for i, c := range g.sel.Columns {
+		if err := g.expr(c.Expr, result+i); err != nil {
+			return err
+		}
+	}
+
+	skip := g.newLabel()
+	if out.distinct >= 0 {
+		g.emit(vm.OpcodeFound, out.distinct, skip, result, n, 0)
+		g.emit(vm.OpcodeIdxInsert, out.distinct, result, n, 0, 0)
+	}
+
+	if out.sorter < 0 {
+		g.emitRow(result, skip)
+		g.resolve(skip)
+		return nil
+	}
+
+	for i, t := range g.sel.OrderBy {
+		if err := g.expr(t.Expr, entry+i); err != nil {
+			return err
+		}
+	}
+	g.emit(vm.OpcodeSorterInsert, out.sorter, entry, keys+n, 0, 0)
+	g.resolve(skip)
+
+	return nil
+}
+
+// emitRow generates code that outputs the result row in the registers starting at
+// result to the destination, unless it is one of the rows of the OFFSET, in which
+// case it jumps to skip, and that ends the output once the LIMIT is reached.
+func (g *generator) emitRow(result, skip int) {
+	out, n := g.out, len(g.sel.Columns)
+	if out.offset != 0 {
+		g.emit(vm.OpcodeIfPos, out.offset, skip, 1, 0, 0)
+	}
+	switch dest := out.dest; dest.kind {
+	case destResult:
+		g.emit(vm.OpcodeResultRow, result, n, 0, 0, 0)
+	case destCoroutine:
+		g.emit(vm.OpcodeCopy, result, dest.results, n-1, 0, 0)
+		g.emit(vm.OpcodeYield, dest.reg, 0, 0, 0, 0)
+	case destValue:
+		g.emit(vm.OpcodeCopy, result, dest.reg, n-1, 0, 0)
+		g.emit(vm.OpcodeGoto, 0, out.done, 0, 0, 0)
+		return
+	case destExists:
+		g.emit(vm.OpcodeInteger, 1, dest.reg, 0, 0, 0)
+		g.emit(vm.OpcodeGoto, 0, out.done, 0, 0, 0)
+		return
+	case destSet:
+		if strings.Trim(dest.affinities, string(rune(vm.AffinityBlob))) != "" {
+			g.emitStr(vm.OpcodeAffinity, result, n, 0, dest.affinities, 0)
+		}
+		g.emit(vm.OpcodeIdxInsert, dest.cursor, result, n, 0, 0)
+		g.emit(vm.OpcodeInteger, 1, dest.reg, 0, 0, 0)
+	case destCompound:
+		g.emit(vm.OpcodeCopy, result, dest.results, n-1, 0, 0)
+		g.emit(vm.OpcodeGosub, dest.reg, dest.label, 0, 0, 0)
+	case destUnion:
+		g.emit(vm.OpcodeIdxInsert, dest.cursor, result, n, 0, 0)
+	case destExcept:
+		g.emit(vm.OpcodeIdxDelete, dest.cursor, result, n, 0, 0)
+	case destTable:
+		g.emit(vm.OpcodeInsert, dest.cursor, result, n, 0, 0)
+	}
+	if out.limit != 0 {
+		g.emit(vm.OpcodeDecrJumpZero, out.limit, out.done, 0, 0, 0)
+	}
+}
+
+// endOutput generates the code that outputs the sorted rows, if they are sorted.
+func (g *generator) endOutput() {
+	out := g.out
+	if out.sorter >= 0 {
+		keys := len(g.sel.OrderBy)
+		n := keys + len(g.sel.Columns)
+		entry := g.allocRegisters(n)
+		g.emit(vm.OpcodeSorterSort, out.sorter, out.done, 0, 0, 0)
+		top := len(g.instructions)
+		g.emit(vm.OpcodeSorterData, out.sorter, entry, n, 0, 0)
+		next := g.newLabel()
+		g.emitRow(entry+keys, next)
+		g.resolve(next)
+		g.emit(vm.OpcodeSorterNext, out.sorter, top, 0, 0, 0)
+	}
+	g.resolve(out.done)
+}
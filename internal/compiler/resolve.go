@@ -0,0 +1,1815 @@
+// Package compiler implements the SQLite compiler module, which turns the parse
+// trees of statements into programs for the vm. So far, it resolves the names in
+// a statement against the schema catalog.
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/schema"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// RowidColumn is the column index of a reference to a table's rowid.
+const RowidColumn = -1
+
+// Select is a SELECT statement whose names have been resolved.
+type Select struct {
+	Stmt *ast.SelectStatement
+
+	// Sources are the tables in the FROM clause, in the order that they appear.
+	Sources []Source
+	// Columns are the result columns, with each "*" expanded into a reference to
+	// each of the columns that it stands for.
+	Columns []ResultColumn
+	// Refs maps each column reference in the statement, including those that "*"
+	// was expanded into, to the column that it refers to.
+	Refs map[*ast.Ident]ColumnRef
+	// Aliases maps each column reference that refers to a result column by its
+	// alias to the index of that result column.
+	Aliases map[*ast.Ident]int
+	// GroupBy are the terms of the GROUP BY clause, where each term that is a
+	// result column number, f.e. the 1 in "GROUP BY 1", is replaced by the
+	// expression of that result column.
+	GroupBy []ast.Expr
+	// OrderBy are the terms of the ORDER BY clause, where each term whose
+	// expression is a result column number, f.e. the 2 in "ORDER BY 2 DESC", is
+	// replaced by a term with the expression of that result column.
+	OrderBy []*ast.OrderingTerm
+	// Aggregates are the calls to aggregate functions in the result columns, the
+	// HAVING clause and the ORDER BY clause, in the order that they appear.
+	Aggregates []*ast.CallExpr
+	// Windows are the calls to window functions in the result columns and the
+	// ORDER BY clause, in the order that they appear.
+	Windows []*Window
+	// Subqueries holds the subqueries in the expressions of the statement, by
+	// their statements. Their column references may refer to the sources of
+	// this statement, or of those around it, which are in the Refs of the
+	// statement whose sources they refer to.
+	Subqueries map[*ast.SelectStatement]*Select
+	// Correlated is set for subqueries that refer to the sources of the
+	// statements around them, either directly or through their own subqueries,
+	// and which must be run again for each of their rows.
+	Correlated bool
+	// Compound holds the SELECTs of a compound SELECT, starting with the first
+	// one, or is nil. The result columns of the compound are those of its
+	// first SELECT, along with the sources and column references that they
+	// refer to, and the expressions of its ORDER BY terms are those result
+	// columns.
+	Compound []CompoundSelect
+	// CTE is the recursive CTE that the compound is the body of, or nil.
+	CTE *CTE
+}
+
+// CompoundSelect is one of the SELECTs of a compound SELECT.
+type CompoundSelect struct {
+	// Op is the operator that combines the SELECT with those before it. It
+	// is not used for the first one.
+	Op     ast.CompoundOp
+	Select *Select
+}
+
+// Aggregate returns true if the statement is an aggregate query, which returns a
+// row for each group of rows, or a single row if it has no GROUP BY clause.
+func (s *Select) Aggregate() bool {
+	return len(s.GroupBy) > 0 || len(s.Aggregates) > 0
+}
+
+// lastSource returns the index of the last of the sources that x refers to, either
+// directly or through the aliases of result columns, or -1 if it refers to none.
+func (s *Select) lastSource(x ast.Expr) int {
+	last := -1
+	ast.Inspect(x, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if i, ok := s.Aliases[id]; ok {
+			if l := s.lastSource(s.Columns[i].Expr); l > last {
+				last = l
+			}
+		} else if ref, ok := s.Refs[id]; ok && ref.Source > last {
+			last = ref.Source
+		}
+		return true
+	})
+
+	return last
+}
+
+// Source is a table in the FROM clause of a SELECT.
+type Source struct {
+	// Ref is the *ast.TableName, *ast.TableFunction or *ast.SubqueryTable that
+	// the table is read from.
+	Ref ast.TableRef
+	// Name is the name that column references are qualified with: the table's
+	// alias, if it has one, or else its name.
+	Name  string
+	Table *schema.Table
+	// Subquery is the resolved subquery of a *ast.SubqueryTable, or the body
+	// of the CTE that a *ast.TableName refers to, whose result columns are
+	// the columns of Table, or nil.
+	Subquery *Select
+	// CTE is the CTE that the table is, or nil. The sources of its own
+	// recursive SELECTs have no Subquery, and read its rows one at a time.
+	CTE *CTE
+	// Tables are the tables of the schema, if the source is a call to
+	// pragma_table_info, which returns the columns of the one that its
+	// argument names, or nil.
+	Tables []*schema.Table
+
+	// Join is the operator of the join that the source is the right-hand table
+	// of. It is ast.JoinComma for the first source.
+	Join ast.JoinOp
+	// On is the constraint of that join, or nil: its ON clause, or the
+	// comparisons of the columns of a USING clause or NATURAL join.
+	On ast.Expr
+	// using holds the columns of the table that a USING clause or NATURAL join
+	// compares with those of the tables before it, which "*" and unqualified
+	// column references do not refer to.
+	using map[int]bool
+}
+
+// CTE is a common table expression of a WITH clause, which the sources of the
+// statement that it belongs to, and of the statements in it, may read the rows of.
+type CTE struct {
+	Def *ast.CTE
+	// Recursive is the index of the first of the SELECTs of the compound that
+	// is the body of the CTE that refers to the CTE, if it is a recursive CTE,
+	// or else 0. That SELECT and those after it are the recursive SELECTs,
+	// which are run for each row that the CTE returns, starting with those of
+	// the SELECTs before them, and return more of them.
+	Recursive int
+	// Uses is the number of sources that read the rows of the CTE, other than
+	// those of its recursive SELECTs.
+	Uses int
+
+	// scope is the scope of the CTE's WITH clause, which its body is resolved
+	// in.
+	scope *cteScope
+	// self holds the references to the CTE in the FROM clauses of its
+	// recursive SELECTs, which read the table.
+	self  map[*ast.TableName]bool
+	table *schema.Table
+	// err is the format of the error of the other references to the CTE,
+	// while its body is being resolved, or "".
+	err string
+}
+
+// Materialized returns true if the rows of the CTE are computed once, into an
+// ephemeral table that each of the sources that read them scans, rather than by
+// a coroutine for each of them. Like in SQLite, that is the case if the CTE is
+// MATERIALIZED, or if it is read more than once and not NOT MATERIALIZED.
+func (c *CTE) Materialized() bool {
+	switch c.Def.Materialized {
+	case ast.Materialized:
+		return true
+	case ast.NotMaterialized:
+		return false
+	default:
+		return c.Uses > 1
+	}
+}
+
+// recursiveSelects finds the recursive SELECTs of the body of the CTE. Like in
+// SQLite, those are the last SELECTs of a compound, as long as each of them has
+// the same operator as the last one, which must be UNION or UNION ALL, and refers
+// to the CTE in its FROM clause, which it may only do once.
+func (c *CTE) recursiveSelects() error {
+	stmt := c.Def.Select
+	c.Recursive, c.self = 0, map[*ast.TableName]bool{}
+	n := len(stmt.Compound)
+	if n == 0 {
+		return nil
+	}
+	op := stmt.Compound[n-1].Op
+	if op != ast.CompoundUnion && op != ast.CompoundUnionAll {
+		return nil
+	}
+
+	for i := n - 1; i >= 0 && stmt.Compound[i].Op == op; i-- {
+		var refs []*ast.TableName
+		var find func(ref ast.TableRef)
+		find = func(ref ast.TableRef) {
+			switch ref := ref.(type) {
+			case *ast.Join:
+				find(ref.X)
+				find(ref.Y)
+			case *ast.TableName:
+				if ref.Schema == "" && strings.EqualFold(ref.Name, c.Def.Name) {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		find(stmt.Compound[i].Select.From)
+		if len(refs) == 0 {
+			break
+		}
+		if len(refs) > 1 {
+			return fmt.Errorf("multiple references to recursive table: %s", c.Def.Name)
+		}
+		c.self[refs[0]] = true
+		c.Recursive = i + 1
+	}
+
+	return nil
+}
+
+// cteScope holds the CTEs of a WITH clause, along with the scope of the WITH
+// clauses around it, whose CTEs they hide if they have the same name.
+type cteScope struct {
+	ctes  []*CTE
+	outer *cteScope
+}
+
+// withScope returns the scope of the CTEs of the WITH clause with, which is inside
+// the scope outer.
+func withScope(with *ast.With, outer *cteScope) (*cteScope, error) {
+	s := &cteScope{outer: outer}
+	for _, def := range with.CTEs {
+		for _, c := range s.ctes {
+			if strings.EqualFold(c.Def.Name, def.Name) {
+				return nil, fmt.Errorf("duplicate WITH table name: %s", def.Name)
+			}
+		}
+		s.ctes = append(s.ctes, &CTE{Def: def, scope: s})
+	}
+
+	return s, nil
+}
+
+// lookup returns the CTE with the given name in the scope, or in those around it.
+func (s *cteScope) lookup(name string) (*CTE, bool) {
+	for ; s != nil; s = s.outer {
+		for _, c := range s.ctes {
+			if strings.EqualFold(c.Def.Name, name) {
+				return c, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// ColumnRef identifies a column of a source.
+type ColumnRef struct {
+	// Source is the index of the source in Select.Sources.
+	Source int
+	// Column is the index of the column in the source's table, or RowidColumn.
+	Column int
+}
+
+// ResultColumn is a column in the result of a SELECT.
+type ResultColumn struct {
+	// Name is the name of the column, as reported to clients.
+	Name string
+	Expr ast.Expr
+}
+
+// Window is a call to a window function.
+type Window struct {
+	Call *ast.CallExpr
+	// Def is the window that the function is computed over: that of the
+	// call, along with the clauses of the window of the WINDOW clause that it
+	// names or is based on. Its frame is the default one if it has none,
+	// f.e. for "OVER ()", or that of the built-in window function, see
+	// windowFrames.
+	Def *ast.Window
+}
+
+// tableFunctions are the table-valued functions that can be used in a FROM clause,
+// described by the table that they return.
+var tableFunctions = map[string]*schema.Table{
+	// https://www.sqlite.org/pragma.html#pragma_table_info
+	"pragma_table_info": {
+		Name:         "pragma_table_info",
+		WithoutRowid: true,
+		Columns: []schema.Column{
+			{Name: "cid"},
+			{Name: "name"},
+			{Name: "type"},
+			{Name: "notnull"},
+			{Name: "dflt_value"},
+			{Name: "pk"},
+		},
+	},
+}
+
+// defaultFrame is the frame of windows that have none, which ends with the last
+// peer of the current row.
+var defaultFrame = &ast.Frame{
+	Unit:  ast.FrameRange,
+	Start: ast.FrameBound{Kind: ast.UnboundedPreceding},
+	End:   ast.FrameBound{Kind: ast.CurrentRow},
+}
+
+// windowFrames are the frames that the built-in window functions are computed
+// from, like in SQLite, regardless of the frame of their window. Their states in
+// the vm rely on the rows that the frames add and remove.
+var windowFrames = map[string]*ast.Frame{
+	"row_number": {
+		Unit:  ast.FrameRows,
+		Start: ast.FrameBound{Kind: ast.UnboundedPreceding},
+		End:   ast.FrameBound{Kind: ast.CurrentRow},
+	},
+	"rank":       defaultFrame,
+	"dense_rank": defaultFrame,
+	"percent_rank": {
+		Unit:  ast.FrameGroups,
+		Start: ast.FrameBound{Kind: ast.CurrentRow},
+		End:   ast.FrameBound{Kind: ast.UnboundedFollowing},
+	},
+	"cume_dist": {
+		Unit:  ast.FrameGroups,
+		Start: ast.FrameBound{Kind: ast.Following, Offset: &ast.Literal{Kind: ast.NumberLiteral, Value: "1"}},
+		End:   ast.FrameBound{Kind: ast.UnboundedFollowing},
+	},
+	"ntile": {
+		Unit:  ast.FrameRows,
+		Start: ast.FrameBound{Kind: ast.CurrentRow},
+		End:   ast.FrameBound{Kind: ast.UnboundedFollowing},
+	},
+	"lag":  wholePartition,
+	"lead": wholePartition,
+}
+
+var wholePartition = &ast.Frame{
+	Unit:  ast.FrameRows,
+	Start: ast.FrameBound{Kind: ast.UnboundedPreceding},
+	End:   ast.FrameBound{Kind: ast.UnboundedFollowing},
+}
+
+// Resolve binds the table and column names in stmt to the tables in sch, following
+// SQLite's rules:
+//
+//   - Tables may be qualified by the "main" schema, and given an alias.
+//   - Columns may be qualified by their table's alias, or by its name if it has
+//     none, and by the "main" schema. An unqualified column may be in any of the
+//     tables, but must only be in one of them.
+//   - rowid, oid and _rowid_ refer to a table's rowid, unless one of the tables
+//     has a column with that name.
+//   - The columns that a USING clause or NATURAL join compares are those of the
+//     right-hand table, and the first of the tables before it that has them. "*"
+//     and unqualified column references only refer to the latter, and so do
+//     qualified ones that both tables match, f.e. in a self-join.
+//   - The ON clause of a LEFT JOIN cannot refer to the tables after it.
+//   - In WHERE, GROUP BY and HAVING clauses, a name that is not a column of any
+//     table may refer to a result column by its alias. In ORDER BY clauses,
+//     aliases take precedence.
+//   - A GROUP BY or ORDER BY term that is an integer refers to the result column
+//     with that number, starting at 1.
+//   - Aggregate functions may only be called in the result columns and HAVING
+//     clause, and in the ORDER BY clause of an aggregate query, and not in the
+//     arguments of other aggregate functions.
+//   - Window functions may only be called in the result columns and ORDER BY
+//     clause, and not in the arguments of aggregate and window functions. The
+//     windows that they are called over may name, or be based on, the windows
+//     of the WINDOW clause, which may be based on those before them, and
+//     cannot refer to result columns by their aliases.
+//   - The collating sequences of COLLATE operators must exist.
+//   - LIMIT and OFFSET clauses cannot refer to columns, or call aggregate
+//     functions.
+//   - Subqueries may refer to the columns of the tables of the statements
+//     around them, if none of their own tables has a column with the name.
+//     Subqueries in FROM clauses cannot refer to the other tables of the FROM
+//     clause that they are in.
+//   - Scalar subqueries, and those of IN operators, must return a single
+//     column.
+//   - The SELECTs of a compound SELECT must return the same number of columns,
+//     and its ORDER BY terms must each be one of them: its number, or else, in
+//     the first of the SELECTs where it is one, its alias or the same
+//     expression.
+//   - Tables that are not qualified by a schema may be the CTEs of the WITH
+//     clauses of the statement and of those around it, the closest first, before
+//     the tables of the schema. The body of a CTE is resolved for each table
+//     that is one, like a subquery in the FROM clause, and may refer to the
+//     other CTEs of its WITH clause. It may only refer to the CTE itself if it
+//     is a compound whose last SELECTs are recursive, see CTE.Recursive, which
+//     cannot be aggregate queries.
+//   - The column list of a CTE must have as many columns as its body returns.
+//
+// Errors are formatted like SQLite's, f.e. "no such table: x".
+func Resolve(stmt *ast.SelectStatement, sch *schema.Schema) (*Select, error) {
+	return resolveSelect(stmt, sch, nil, nil)
+}
+
+// resolveSelect resolves stmt, which is a subquery of the statement that outer
+// resolves, if outer is not nil, and may refer to the CTEs in the scope ctes.
+func resolveSelect(stmt *ast.SelectStatement, sch *schema.Schema, outer *resolver, ctes *cteScope) (*Select, error) {
+	if stmt.With != nil {
+		var err error
+		if ctes, err = withScope(stmt.With, ctes); err != nil {
+			return nil, err
+		}
+	}
+	if len(stmt.Compound) > 0 {
+		return resolveCompound(stmt, sch, outer, ctes, nil)
+	}
+
+	r := resolver{
+		schema:    sch,
+		outer:     outer,
+		ctes:      ctes,
+		onClauses: map[int]ast.Expr{},
+		sel: &Select{
+			Stmt:       stmt,
+			Refs:       map[*ast.Ident]ColumnRef{},
+			Aliases:    map[*ast.Ident]int{},
+			Subqueries: map[*ast.SelectStatement]*Select{},
+		},
+	}
+
+	if err := r.from(stmt.From); err != nil {
+		return nil, err
+	}
+	for i, def := range stmt.Windows {
+		if _, err := windowDefn(def.Window, stmt.Windows[:i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range stmt.Columns {
+		if err := r.resultColumn(c); err != nil {
+			return nil, err
+		}
+	}
+	for i := range r.sel.Sources {
+		if x, ok := r.onClauses[i]; ok {
+			if err := r.onClause(i, x); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if stmt.Where != nil {
+		if err := r.filter(stmt.Where); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.groupBy(stmt.GroupBy); err != nil {
+		return nil, err
+	}
+	if stmt.Having != nil {
+		calls, err := r.expr(stmt.Having, aliasesLast)
+		if err != nil {
+			return nil, err
+		}
+		r.sel.Aggregates = append(r.sel.Aggregates, calls...)
+		if !r.sel.Aggregate() {
+			return nil, fmt.Errorf("HAVING clause on a non-aggregate query")
+		}
+		if id := r.aliasedWindow(stmt.Having); id != nil {
+			return nil, fmt.Errorf("misuse of aliased window function %s", id.Name)
+		}
+	}
+	if err := r.orderBy(stmt.OrderBy); err != nil {
+		return nil, err
+	}
+	for _, x := range []ast.Expr{stmt.Limit, stmt.Offset} {
+		if x != nil {
+			if err := r.limit(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r.sel, nil
+}
+
+// resolveCompound resolves the compound SELECT stmt, each of whose SELECTs is
+// resolved on its own. Its ORDER BY and LIMIT clauses apply to the whole
+// compound. If it is the body of the recursive CTE cte, rather than nil, its
+// recursive SELECTs read the table of the CTE, whose columns are those of the
+// first SELECT.
+func resolveCompound(stmt *ast.SelectStatement, sch *schema.Schema, outer *resolver, ctes *cteScope, cte *CTE) (*Select, error) {
+	core := *stmt
+	core.With, core.Compound, core.OrderBy, core.Limit, core.Offset = nil, nil, nil, nil, nil
+	first, err := resolveSelect(&core, sch, outer, ctes)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resolver{
+		schema: sch,
+		outer:  outer,
+		ctes:   ctes,
+		sel: &Select{
+			Stmt:       stmt,
+			Sources:    first.Sources,
+			Columns:    first.Columns,
+			Refs:       first.Refs,
+			Aliases:    first.Aliases,
+			Subqueries: first.Subqueries,
+			Correlated: first.Correlated,
+			Compound:   []CompoundSelect{{Select: first}},
+		},
+	}
+	if cte != nil {
+		if cte.table, err = r.cteTable(cte.Def, first); err != nil {
+			return nil, err
+		}
+		r.sel.CTE = cte
+	}
+	for i, c := range stmt.Compound {
+		recursive := cte != nil && i+1 >= cte.Recursive
+		if recursive && i == len(stmt.Compound)-1 {
+			// Like in SQLite, the other references to the CTE in the last
+			// SELECT are reported as such.
+			cte.err = "multiple recursive references: %s"
+		}
+		sel, err := resolveSelect(c.Select, sch, outer, ctes)
+		if err != nil {
+			return nil, err
+		}
+		if len(sel.Columns) != len(first.Columns) {
+			return nil, fmt.Errorf("SELECTs to the left and right of %s do not have the same number of result columns", c.Op)
+		}
+		if recursive && sel.Aggregate() {
+			return nil, fmt.Errorf("recursive aggregate queries not supported")
+		}
+		r.sel.Compound = append(r.sel.Compound, CompoundSelect{Op: c.Op, Select: sel})
+		if sel.Correlated {
+			r.sel.Correlated = true
+		}
+	}
+	if err := r.compoundOrderBy(stmt.OrderBy); err != nil {
+		return nil, err
+	}
+	for _, x := range []ast.Expr{stmt.Limit, stmt.Offset} {
+		if x != nil {
+			if err := r.limit(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r.sel, nil
+}
+
+// aliasLookup is whether, and when, names are looked up in the aliases of result
+// columns.
+type aliasLookup int
+
+const (
+	noAliases aliasLookup = iota
+	// aliasesLast looks up aliases if no table has a column with the name.
+	aliasesLast
+	// aliasesFirst looks up aliases before the columns of the tables.
+	aliasesFirst
+)
+
+type resolver struct {
+	schema *schema.Schema
+	sel    *Select
+	// outer is the resolver of the statement that this one is a subquery of,
+	// or nil.
+	outer *resolver
+	// ctes is the scope of the CTEs that the statement may refer to, or nil.
+	ctes *cteScope
+	// onClauses holds the ON clause of each join by the index of its
+	// right-hand source. They are resolved after the result columns, since
+	// they may refer to them by their aliases.
+	onClauses map[int]ast.Expr
+	// windows is set while the expressions that may call window functions
+	// are resolved.
+	windows bool
+}
+
+// allowWindows sets whether the expressions that are resolved may call window
+// functions, returning a function that restores the previous setting.
+func (r *resolver) allowWindows(allow bool) func() {
+	prev := r.windows
+	r.windows = allow
+
+	return func() { r.windows = prev }
+}
+
+// from adds each of the tables in ref to the sources.
+func (r *resolver) from(ref ast.TableRef) error {
+	switch ref := ref.(type) {
+	case nil:
+		// A SELECT without a FROM clause has no sources, and a single row.
+	case *ast.Join:
+		if err := r.from(ref.X); err != nil {
+			return err
+		}
+		if err := r.from(ref.Y); err != nil {
+			return err
+		}
+		return r.join(ref)
+	case *ast.TableName:
+		if cte, ok := r.ctes.lookup(ref.Name); ok && ref.Schema == "" {
+			return r.cteSource(ref, cte)
+		}
+		var t *schema.Table
+		ok := false
+		// Only the main schema is supported, since DBs cannot be attached.
+		if ref.Schema == "" || strings.EqualFold(ref.Schema, "main") {
+			t, ok = r.schema.Table(ref.Name)
+		}
+		if !ok {
+			return fmt.Errorf("no such table: %s", qualifiedName(ref.Schema, ref.Name))
+		}
+		r.addSource(ref, t, ref.Alias)
+	case *ast.TableFunction:
+		t, ok := tableFunctions[strings.ToLower(ref.Name)]
+		if !ok {
+			return fmt.Errorf("no such table: %s", ref.Name)
+		}
+		r.addSource(ref, t, ref.Alias)
+		r.sel.Sources[len(r.sel.Sources)-1].Tables = r.schema.Tables()
+	case *ast.SubqueryTable:
+		// The subquery is run before the rows of the other tables are read, so
+		// it can only refer to those of the statements around this one.
+		sel, err := resolveSelect(ref.Select, r.schema, r.outer, r.ctes)
+		if err != nil {
+			return err
+		}
+		if sel.Correlated {
+			r.sel.Correlated = true
+		}
+		r.addSource(ref, r.subqueryTable(sel, ref.Alias), ref.Alias)
+		r.sel.Sources[len(r.sel.Sources)-1].Subquery = sel
+	default:
+		panic(fmt.Sprintf("compiler: unexpected table type %T", ref))
+	}
+
+	return nil
+}
+
+// cteSource adds the CTE that ref refers to to the sources.
+func (r *resolver) cteSource(ref *ast.TableName, cte *CTE) error {
+	if cte.self[ref] {
+		r.addSource(ref, cte.table, ref.Alias)
+		r.sel.Sources[len(r.sel.Sources)-1].CTE = cte
+		return nil
+	}
+	if cte.err != "" {
+		return fmt.Errorf(cte.err, cte.Def.Name)
+	}
+
+	sel, err := r.cteSelect(cte)
+	if err != nil {
+		return err
+	}
+	t, err := r.cteTable(cte.Def, sel)
+	if err != nil {
+		return err
+	}
+	if sel.Correlated {
+		r.sel.Correlated = true
+	}
+	cte.Uses++
+	r.addSource(ref, t, ref.Alias)
+	src := &r.sel.Sources[len(r.sel.Sources)-1]
+	src.Subquery, src.CTE = sel, cte
+
+	return nil
+}
+
+// cteSelect resolves the body of cte for a source of the statement, which, like
+// a subquery in the FROM clause, may refer to the statements around this one.
+// Other references to the CTE in its body are circular, unless they are those of
+// its recursive SELECTs.
+func (r *resolver) cteSelect(cte *CTE) (*Select, error) {
+	if err := cte.recursiveSelects(); err != nil {
+		return nil, err
+	}
+	cte.err = "circular reference: %s"
+	defer func() { cte.err = "" }()
+
+	stmt := cte.Def.Select
+	if cte.Recursive == 0 {
+		return resolveSelect(stmt, r.schema, r.outer, cte.scope)
+	}
+	ctes := cte.scope
+	if stmt.With != nil {
+		var err error
+		if ctes, err = withScope(stmt.With, ctes); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolveCompound(stmt, r.schema, r.outer, ctes, cte)
+}
+
+// cteTable returns the table whose rows the body sel of the CTE def returns,
+// which is named after the CTE, and whose columns are named by its column list,
+// if it has one.
+func (r *resolver) cteTable(def *ast.CTE, sel *Select) (*schema.Table, error) {
+	t := r.subqueryTable(sel, def.Name)
+	if len(def.Columns) == 0 {
+		return t, nil
+	}
+	if len(def.Columns) != len(t.Columns) {
+		return nil, fmt.Errorf("table %s has %d values for %d columns", def.Name, len(t.Columns), len(def.Columns))
+	}
+	for i, name := range def.Columns {
+		t.Columns[i].Name = name
+	}
+
+	return t, nil
+}
+
+// join sets the operator and constraint of the join j on its right-hand source,
+// which is the last source.
+func (r *resolver) join(j *ast.Join) error {
+	i := len(r.sel.Sources) - 1
+	src := &r.sel.Sources[i]
+	src.Join = j.Op
+
+	using := j.Using
+	switch {
+	case j.Natural && (j.On != nil || len(j.Using) > 0):
+		return fmt.Errorf("a NATURAL join may not have an ON or USING clause")
+	case j.Natural:
+		// A NATURAL join compares the columns of the right-hand table that
+		// any of the tables before it has.
+		for _, column := range src.Table.Columns {
+			if _, _, ok := r.usingColumn(i, column.Name); ok {
+				using = append(using, column.Name)
+			}
+		}
+	case j.On != nil:
+		r.onClauses[i] = j.On
+		return nil
+	}
+
+	src.using = map[int]bool{}
+	for _, name := range using {
+		column, ok := src.Table.Column(name)
+		left, leftColumn, leftOk := r.usingColumn(i, name)
+		if !ok || !leftOk {
+			return fmt.Errorf("cannot join using column %s - column not present in both tables", name)
+		}
+		src.using[column] = true
+
+		x := &ast.Ident{NamePos: j.OpPos, Table: r.sel.Sources[left].Name, Name: name}
+		y := &ast.Ident{NamePos: j.OpPos, Table: src.Name, Name: name}
+		r.sel.Refs[x] = ColumnRef{Source: left, Column: leftColumn}
+		r.sel.Refs[y] = ColumnRef{Source: i, Column: column}
+		var eq ast.Expr = &ast.BinaryExpr{X: x, OpPos: j.OpPos, Op: ast.OpEq, Y: y}
+		if src.On != nil {
+			eq = &ast.BinaryExpr{X: src.On, OpPos: j.OpPos, Op: ast.OpAnd, Y: eq}
+		}
+		src.On = eq
+	}
+
+	return nil
+}
+
+// usingColumn returns the first of the sources before source i that has a column
+// with the given name, which it can be joined with by a USING clause or NATURAL
+// join, along with the index of that column.
+func (r *resolver) usingColumn(i int, name string) (int, int, bool) {
+	for left, src := range r.sel.Sources[:i] {
+		if column, ok := src.Table.Column(name); ok && !src.using[column] {
+			return left, column, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// onClause resolves the ON clause x of the join whose right-hand table is the
+// source i. Like in SQLite, the ON clause of an inner join may refer to any of the
+// tables, but that of a LEFT JOIN only to those up to its right-hand table.
+func (r *resolver) onClause(i int, x ast.Expr) error {
+	if err := r.filter(x); err != nil {
+		return err
+	}
+	src := &r.sel.Sources[i]
+	if src.Join == ast.JoinLeft && r.sel.lastSource(x) > i {
+		return fmt.Errorf("ON clause references tables to its right")
+	}
+	src.On = x
+
+	return nil
+}
+
+// filter resolves a WHERE or ON clause x, which cannot call aggregate functions.
+func (r *resolver) filter(x ast.Expr) error {
+	calls, err := r.expr(x, aliasesLast)
+	if err != nil {
+		return err
+	}
+	if len(calls) > 0 {
+		return fmt.Errorf("misuse of aggregate function %s()", calls[0].Name)
+	}
+	if call := r.aliasedAggregate(x); call != nil {
+		return fmt.Errorf("misuse of aggregate: %s()", call.Name)
+	}
+	if id := r.aliasedWindow(x); id != nil {
+		return fmt.Errorf("misuse of aliased window function %s", id.Name)
+	}
+
+	return nil
+}
+
+// subqueryTable returns the table whose rows the subquery sel in a FROM clause
+// returns. Its columns are named after the result columns, made unique like in
+// SQLite by adding ":N" to the names that are already taken, and have the
+// declared type and collating sequence of the table columns that they read, or
+// of the CAST or COLLATE operators that they are wrapped in. Like in SQLite,
+// such tables have no rowid.
+func (r *resolver) subqueryTable(sel *Select, alias string) *schema.Table {
+	t := &schema.Table{Name: alias, WithoutRowid: true}
+	for _, c := range sel.Columns {
+		name := c.Name
+		for i := 1; ; i++ {
+			if _, ok := t.Column(name); !ok {
+				break
+			}
+			name = fmt.Sprintf("%s:%d", c.Name, i)
+		}
+		typ, coll := r.origin(sel, c.Expr)
+		t.Columns = append(t.Columns, schema.Column{Name: name, Type: typ, Collation: coll})
+	}
+
+	return t
+}
+
+// origin returns the declared type and collating sequence of the result column x
+// of the subquery sel.
+func (r *resolver) origin(sel *Select, x ast.Expr) (string, string) {
+	switch x := x.(type) {
+	case *ast.Ident:
+		ref, ok := sel.Refs[x]
+		for o := r.outer; !ok && o != nil; o = o.outer {
+			sel = o.sel
+			ref, ok = sel.Refs[x]
+		}
+		if !ok {
+			return "", ""
+		}
+		if ref.Column == RowidColumn {
+			return "INTEGER", ""
+		}
+		column := sel.Sources[ref.Source].Table.Columns[ref.Column]
+		return column.Type, column.Collation
+	case *ast.CastExpr:
+		_, coll := r.origin(sel, x.X)
+		return x.Type, coll
+	case *ast.CollateExpr:
+		typ, _ := r.origin(sel, x.X)
+		return typ, x.Collation
+	default:
+		return "", ""
+	}
+}
+
+func (r *resolver) addSource(ref ast.TableRef, t *schema.Table, alias string) {
+	name := alias
+	if name == "" {
+		name = t.Name
+	}
+
+	r.sel.Sources = append(r.sel.Sources, Source{Ref: ref, Name: name, Table: t})
+}
+
+// resultColumn resolves c, expanding it if it is a "*", and adds it to the result
+// columns.
+func (r *resolver) resultColumn(c *ast.ResultColumn) error {
+	if !c.Star {
+		restore := r.allowWindows(true)
+		calls, err := r.expr(c.Expr, noAliases)
+		restore()
+		if err != nil {
+			return err
+		}
+		r.sel.Aggregates = append(r.sel.Aggregates, calls...)
+		r.sel.Columns = append(r.sel.Columns, ResultColumn{Name: r.columnName(c), Expr: c.Expr})
+		return nil
+	}
+
+	if c.Table == "" && len(r.sel.Sources) == 0 {
+		return fmt.Errorf("no tables specified")
+	}
+	found := false
+	for i, src := range r.sel.Sources {
+		if c.Table != "" && !src.matches(c.Table) {
+			continue
+		}
+		found = true
+
+		for j, column := range src.Table.Columns {
+			if c.Table == "" && src.using[j] {
+				continue
+			}
+			id := &ast.Ident{NamePos: c.StarPos, Name: column.Name}
+			if len(r.sel.Sources) == 1 || src.Name == "" {
+				// Subqueries without an alias have no name to qualify their
+				// columns with.
+				r.sel.Refs[id] = ColumnRef{Source: i, Column: j}
+			} else {
+				// Like SQLite, if there are multiple tables, each column is
+				// looked up by its qualified name, which is ambiguous if
+				// multiple tables have the same name.
+				id.Schema, id.Table = "main", src.Name
+				if err := r.ident(id, noAliases); err != nil {
+					return err
+				}
+			}
+			r.sel.Columns = append(r.sel.Columns, ResultColumn{Name: column.Name, Expr: id})
+		}
+	}
+	if !found {
+		return fmt.Errorf("no such table: %s", c.Table)
+	}
+
+	return nil
+}
+
+// columnName returns the name of the non-"*" result column c.
+func (r *resolver) columnName(c *ast.ResultColumn) string {
+	if c.Alias != "" {
+		return c.Alias
+	}
+
+	id, ok := c.Expr.(*ast.Ident)
+	if !ok {
+		return ast.Format(c.Expr)
+	}
+
+	// Columns are named as they were declared, rather than as they were
+	// referenced, and the rowid is named after its alias, if it has one. The
+	// column may be one of the statements around this one.
+	sel := r.sel
+	ref, ok := sel.Refs[id]
+	for o := r.outer; !ok && o != nil; o = o.outer {
+		sel = o.sel
+		ref, ok = sel.Refs[id]
+	}
+	t := sel.Sources[ref.Source].Table
+	column := ref.Column
+	if column == RowidColumn {
+		alias, ok := t.RowidAlias()
+		if !ok {
+			return "rowid"
+		}
+		column = alias
+	}
+
+	return t.Columns[column].Name
+}
+
+// groupBy resolves the terms of a GROUP BY clause, which must not call aggregate
+// functions.
+func (r *resolver) groupBy(terms []ast.Expr) error {
+	for i, term := range terms {
+		if n, ok := intLiteral(term); ok {
+			if n < 1 || n > len(r.sel.Columns) {
+				return fmt.Errorf("%s GROUP BY term out of range - should be between 1 and %d", ordinal(i+1), len(r.sel.Columns))
+			}
+			term = r.sel.Columns[n-1].Expr
+			if r.firstAggregate(term) != nil {
+				return fmt.Errorf("aggregate functions are not allowed in the GROUP BY clause")
+			}
+			if call := firstWindow(term); call != nil {
+				return fmt.Errorf("misuse of window function %s()", call.Name)
+			}
+			r.sel.GroupBy = append(r.sel.GroupBy, term)
+			continue
+		}
+
+		calls, err := r.expr(term, aliasesLast)
+		if err != nil {
+			return err
+		}
+		if len(calls) > 0 || r.aliasedAggregate(term) != nil {
+			return fmt.Errorf("aggregate functions are not allowed in the GROUP BY clause")
+		}
+		if id := r.aliasedWindow(term); id != nil {
+			return fmt.Errorf("misuse of aliased window function %s", id.Name)
+		}
+		r.sel.GroupBy = append(r.sel.GroupBy, term)
+	}
+
+	return nil
+}
+
+// orderBy resolves the terms of an ORDER BY clause, which may only call aggregate
+// functions in an aggregate query, and may call window functions. The calls are added to the aggregates, since
+// they are computed along with those of the result columns.
+func (r *resolver) orderBy(terms []*ast.OrderingTerm) error {
+	aggregate := r.sel.Aggregate()
+	for i, term := range terms {
+		// A result column number may be followed by a COLLATE operator, which
+		// applies to the result column.
+		x, collate := term.Expr, (*ast.CollateExpr)(nil)
+		if c, ok := x.(*ast.CollateExpr); ok {
+			x, collate = c.X, c
+		}
+		if n, ok := intLiteral(x); ok {
+			if n < 1 || n > len(r.sel.Columns) {
+				return fmt.Errorf("%s ORDER BY term out of range - should be between 1 and %d", ordinal(i+1), len(r.sel.Columns))
+			}
+			x = r.sel.Columns[n-1].Expr
+			if collate != nil {
+				if err := vm.CheckCollation(collate.Collation); err != nil {
+					return err
+				}
+				x = &ast.CollateExpr{X: x, Collation: collate.Collation}
+			}
+			r.sel.OrderBy = append(r.sel.OrderBy, &ast.OrderingTerm{Expr: x, Order: term.Order, Nulls: term.Nulls})
+			continue
+		}
+
+		restore := r.allowWindows(true)
+		calls, err := r.expr(term.Expr, aliasesFirst)
+		restore()
+		if err != nil {
+			return err
+		}
+		if len(calls) > 0 && !aggregate {
+			return fmt.Errorf("misuse of aggregate: %s()", calls[0].Name)
+		}
+		r.sel.Aggregates = append(r.sel.Aggregates, calls...)
+		r.sel.OrderBy = append(r.sel.OrderBy, term)
+	}
+
+	return nil
+}
+
+// compoundOrderBy resolves the terms of the ORDER BY clause of a compound SELECT.
+// Like in SQLite, the SELECTs are tried in order, and a term refers to a result
+// column of the first one where it is either the number of that column, its
+// alias, or the same expression. Each term is replaced by one whose expression is
+// the result column of the compound, along with the term's COLLATE operator, if
+// it has one.
+func (r *resolver) compoundOrderBy(terms []*ast.OrderingTerm) error {
+	columns := make([]int, len(terms))
+	for i := range columns {
+		columns[i] = -1
+	}
+	for _, c := range r.sel.Compound {
+		for i, term := range terms {
+			if columns[i] >= 0 {
+				continue
+			}
+			x := term.Expr
+			if collate, ok := x.(*ast.CollateExpr); ok {
+				x = collate.X
+			}
+			if n, ok := intLiteral(x); ok {
+				if n < 1 || n > len(r.sel.Columns) {
+					return fmt.Errorf("%s ORDER BY term out of range - should be between 1 and %d", ordinal(i+1), len(r.sel.Columns))
+				}
+				columns[i] = n - 1
+				continue
+			}
+			columns[i] = r.compoundColumn(c.Select, x)
+		}
+	}
+
+	for i, term := range terms {
+		if columns[i] < 0 {
+			return fmt.Errorf("%s ORDER BY term does not match any column in the result set", ordinal(i+1))
+		}
+		x := r.sel.Columns[columns[i]].Expr
+		if collate, ok := term.Expr.(*ast.CollateExpr); ok {
+			if err := vm.CheckCollation(collate.Collation); err != nil {
+				return err
+			}
+			x = &ast.CollateExpr{X: x, Collation: collate.Collation}
+		}
+		r.sel.OrderBy = append(r.sel.OrderBy, &ast.OrderingTerm{Expr: x, Order: term.Order, Nulls: term.Nulls})
+	}
+
+	return nil
+}
+
+// compoundColumn returns the index of the result column of sel, one of the
+// SELECTs of a compound SELECT, that the expression x of an ORDER BY term of the
+// compound refers to, or -1 if it refers to none. x is resolved against the
+// sources of sel, but only to compare it with the result columns, so its column
+// references are not added to sel's, and it cannot refer to the statements
+// around the compound.
+func (r *resolver) compoundColumn(sel *Select, x ast.Expr) int {
+	if id, ok := x.(*ast.Ident); ok {
+		tr := resolver{sel: sel}
+		if i, ok := tr.alias(id); ok {
+			return i
+		}
+	}
+
+	tr := resolver{
+		schema: r.schema,
+		ctes:   r.ctes,
+		sel: &Select{
+			Stmt:       sel.Stmt,
+			Sources:    sel.Sources,
+			Columns:    sel.Columns,
+			Refs:       map[*ast.Ident]ColumnRef{},
+			Aliases:    map[*ast.Ident]int{},
+			Subqueries: map[*ast.SelectStatement]*Select{},
+		},
+	}
+	if _, err := tr.expr(x, aliasesLast); err != nil {
+		return -1
+	}
+	for i, c := range sel.Columns {
+		if sameExpr(c.Expr, sel.Refs, x, tr.sel.Refs) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// limit resolves the expression of a LIMIT or OFFSET clause, which is computed
+// before any rows are read, so it cannot refer to the columns of the sources, nor
+// to the aliases of result columns.
+func (r *resolver) limit(x ast.Expr) error {
+	nr := &resolver{
+		schema: r.schema,
+		outer:  r.outer,
+		ctes:   r.ctes,
+		sel:    &Select{Stmt: r.sel.Stmt, Refs: r.sel.Refs, Aliases: r.sel.Aliases, Subqueries: r.sel.Subqueries},
+	}
+	calls, err := nr.expr(x, noAliases)
+	if err != nil {
+		return err
+	}
+	if len(calls) > 0 {
+		return fmt.Errorf("misuse of aggregate function %s()", calls[0].Name)
+	}
+
+	return nil
+}
+
+// intLiteral returns the value of x if it is an integer literal, f.e. the result
+// column number of a GROUP BY or ORDER BY term.
+func intLiteral(x ast.Expr) (int, bool) {
+	lit, ok := x.(*ast.Literal)
+	if !ok || lit.Kind != ast.NumberLiteral {
+		return 0, false
+	}
+	n, err := strconv.Atoi(lit.Value)
+
+	return n, err == nil
+}
+
+// sameExpr returns true if x and y are the same expression, like SQLite's
+// sqlite3ExprCompare: their column references, which are in xRefs and yRefs,
+// refer to the same columns, however they are qualified. Subqueries, and "?"
+// parameters, are never the same.
+func sameExpr(x ast.Expr, xRefs map[*ast.Ident]ColumnRef, y ast.Expr, yRefs map[*ast.Ident]ColumnRef) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	same := func(x, y ast.Expr) bool { return sameExpr(x, xRefs, y, yRefs) }
+
+	switch x := x.(type) {
+	case *ast.Ident:
+		y, ok := y.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		xRef, xOk := xRefs[x]
+		yRef, yOk := yRefs[y]
+		return xOk && yOk && xRef == yRef
+	case *ast.Literal:
+		y, ok := y.(*ast.Literal)
+		return ok && x.Kind == y.Kind && x.Value == y.Value
+	case *ast.Param:
+		y, ok := y.(*ast.Param)
+		return ok && x.Name == y.Name && x.Name != "?"
+	case *ast.UnaryExpr:
+		y, ok := y.(*ast.UnaryExpr)
+		return ok && x.Op == y.Op && same(x.X, y.X)
+	case *ast.BinaryExpr:
+		y, ok := y.(*ast.BinaryExpr)
+		return ok && x.Op == y.Op && same(x.X, y.X) && same(x.Y, y.Y)
+	case *ast.BetweenExpr:
+		y, ok := y.(*ast.BetweenExpr)
+		return ok && x.Not == y.Not && same(x.X, y.X) && same(x.Low, y.Low) && same(x.High, y.High)
+	case *ast.CaseExpr:
+		y, ok := y.(*ast.CaseExpr)
+		if !ok || len(x.Whens) != len(y.Whens) || !same(x.X, y.X) || !same(x.Else, y.Else) {
+			return false
+		}
+		for i, w := range x.Whens {
+			if !same(w.Cond, y.Whens[i].Cond) || !same(w.Result, y.Whens[i].Result) {
+				return false
+			}
+		}
+		return true
+	case *ast.RowExpr:
+		y, ok := y.(*ast.RowExpr)
+		if !ok || len(x.List) != len(y.List) {
+			return false
+		}
+		for i := range x.List {
+			if !same(x.List[i], y.List[i]) {
+				return false
+			}
+		}
+		return true
+	case *ast.LikeExpr:
+		y, ok := y.(*ast.LikeExpr)
+		return ok && x.Not == y.Not && x.Op == y.Op && same(x.X, y.X) && same(x.Y, y.Y) && same(x.Escape, y.Escape)
+	case *ast.CallExpr:
+		y, ok := y.(*ast.CallExpr)
+		if !ok || !strings.EqualFold(x.Name, y.Name) || x.Distinct != y.Distinct || x.Star != y.Star || len(x.Args) != len(y.Args) {
+			return false
+		}
+		for i := range x.Args {
+			if !same(x.Args[i], y.Args[i]) {
+				return false
+			}
+		}
+		return same(x.Filter, y.Filter) && sameWindow(x.Over, xRefs, y.Over, yRefs)
+	case *ast.CastExpr:
+		y, ok := y.(*ast.CastExpr)
+		return ok && strings.EqualFold(x.Type, y.Type) && same(x.X, y.X)
+	case *ast.CollateExpr:
+		y, ok := y.(*ast.CollateExpr)
+		return ok && strings.EqualFold(x.Collation, y.Collation) && same(x.X, y.X)
+	default:
+		return false
+	}
+}
+
+// sameWindow returns true if x and y are the same window, like sameExpr, or are
+// both nil.
+func sameWindow(x *ast.Window, xRefs map[*ast.Ident]ColumnRef, y *ast.Window, yRefs map[*ast.Ident]ColumnRef) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	same := func(x, y ast.Expr) bool { return sameExpr(x, xRefs, y, yRefs) }
+
+	if !strings.EqualFold(x.Name, y.Name) || !strings.EqualFold(x.Base, y.Base) || len(x.PartitionBy) != len(y.PartitionBy) || len(x.OrderBy) != len(y.OrderBy) {
+		return false
+	}
+	for i := range x.PartitionBy {
+		if !same(x.PartitionBy[i], y.PartitionBy[i]) {
+			return false
+		}
+	}
+	for i, t := range x.OrderBy {
+		u := y.OrderBy[i]
+		if (t.Order == ast.SortDesc) != (u.Order == ast.SortDesc) || bigNull(t) != bigNull(u) || !same(t.Expr, u.Expr) {
+			return false
+		}
+	}
+
+	xf, yf := x.Frame, y.Frame
+	if xf == nil || yf == nil {
+		return xf == nil && yf == nil
+	}
+	return xf.Unit == yf.Unit && xf.Exclude == yf.Exclude &&
+		xf.Start.Kind == yf.Start.Kind && same(xf.Start.Offset, yf.Start.Offset) &&
+		xf.End.Kind == yf.End.Kind && same(xf.End.Offset, yf.End.Offset)
+}
+
+// ordinal returns n with its English ordinal suffix, f.e. "1st" or "12th".
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+
+	return strconv.Itoa(n) + suffix
+}
+
+// expr resolves each of the column references, COLLATE operators, function calls
+// and subqueries in x, returning the calls to aggregate functions that are not in
+// the arguments of others, nor in subqueries. Those in the arguments and windows
+// of window functions are included. The row values in x are checked by
+// rowValues.
+func (r *resolver) expr(x ast.Expr, aliases aliasLookup) ([]*ast.CallExpr, error) {
+	var calls []*ast.CallExpr
+	var err error
+	ast.Inspect(x, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectStatement:
+			// Subqueries are resolved by the expressions that they are in.
+			return false
+		case *ast.SubqueryExpr:
+			err = r.subquery(n.Select, 0)
+		case *ast.InExpr:
+			if n.Select != nil {
+				err = r.subquery(n.Select, 0)
+			}
+		case *ast.ExistsExpr:
+			err = r.subquery(n.Select, 0)
+		case *ast.Ident:
+			err = r.ident(n, aliases)
+		case *ast.CollateExpr:
+			err = vm.CheckCollation(n.Collation)
+		case *ast.CallExpr:
+			if n.Over != nil {
+				var aggregates []*ast.CallExpr
+				aggregates, err = r.window(n, aliases)
+				calls = append(calls, aggregates...)
+				return false
+			}
+			var aggregate bool
+			aggregate, err = r.call(n, aliases)
+			if aggregate {
+				calls = append(calls, n)
+				// The arguments were resolved by call.
+				return false
+			}
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = r.rowValues(x)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return calls, nil
+}
+
+// rowValues checks that the row values in x, which are *ast.RowExprs and
+// subqueries that return more than one column, are only compared with row values
+// of the same size, like in SQLite: by a comparison, BETWEEN, IN, or a CASE with
+// an operand. Those of a subquery on the right-hand side of IN are compared with
+// its left-hand operand. Row values cannot be used in any other way.
+func (r *resolver) rowValues(x ast.Expr) error {
+	// compared holds the operands of the comparisons seen so far, which are
+	// visited after them.
+	compared := map[ast.Expr]bool{}
+	// compare checks that xs are all the same size.
+	compare := func(xs ...ast.Expr) error {
+		for _, y := range xs {
+			compared[y] = true
+			if r.width(y) != r.width(xs[0]) {
+				return fmt.Errorf("row value misused")
+			}
+		}
+		return nil
+	}
+
+	var err error
+	ast.Inspect(x, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectStatement:
+			// Subqueries are checked when they are resolved.
+			return false
+		case *ast.BinaryExpr:
+			if _, ok := comparisonOpcodes[n.Op]; ok {
+				err = compare(n.X, n.Y)
+			}
+		case *ast.BetweenExpr:
+			err = compare(n.X, n.Low, n.High)
+		case *ast.CaseExpr:
+			for _, w := range n.Whens {
+				if n.X != nil && err == nil {
+					err = compare(n.X, w.Cond)
+				}
+			}
+		case *ast.InExpr:
+			compared[n.X] = true
+			if n.Select != nil {
+				if want, got := r.width(n.X), len(r.sel.Subqueries[n.Select].Columns); got != want {
+					err = fmt.Errorf("sub-select returns %d columns - expected %d", got, want)
+				}
+			}
+			for _, y := range n.List {
+				if err == nil {
+					err = compare(n.X, y)
+				}
+			}
+		case *ast.RowExpr:
+			if !compared[n] {
+				err = fmt.Errorf("row value misused")
+			}
+		case *ast.SubqueryExpr:
+			if w := r.width(n); w != 1 && !compared[n] {
+				err = fmt.Errorf("sub-select returns %d columns - expected 1", w)
+			}
+		}
+		return err == nil
+	})
+
+	return err
+}
+
+// width returns the number of values of x, which is more than 1 if x is a row
+// value.
+func (r *resolver) width(x ast.Expr) int {
+	switch x := x.(type) {
+	case *ast.RowExpr:
+		return len(x.List)
+	case *ast.SubqueryExpr:
+		return len(r.sel.Subqueries[x.Select].Columns)
+	default:
+		return 1
+	}
+}
+
+// subquery resolves the subquery stmt of an expression, which must return the
+// given number of columns, unless it is 0.
+func (r *resolver) subquery(stmt *ast.SelectStatement, columns int) error {
+	sel, err := resolveSelect(stmt, r.schema, r, r.ctes)
+	if err != nil {
+		return err
+	}
+	if columns > 0 && len(sel.Columns) != columns {
+		return fmt.Errorf("sub-select returns %d columns - expected %d", len(sel.Columns), columns)
+	}
+	r.sel.Subqueries[stmt] = sel
+
+	return nil
+}
+
+// call checks that the function that x calls exists, and accepts its arguments,
+// returning whether it is an aggregate function. Functions that can be called
+// with the same number of arguments as either, like min and max, are aggregate
+// functions. The arguments of aggregate functions are resolved.
+func (r *resolver) call(x *ast.CallExpr, aliases aliasLookup) (bool, error) {
+	f, err := vm.LookupAggregate(x.Name, len(x.Args))
+	if err != nil {
+		if _, ferr := vm.LookupFunction(x.Name, len(x.Args)); ferr != nil {
+			if vm.IsAggregate(x.Name) {
+				return false, err
+			}
+			return false, ferr
+		}
+		if x.Star {
+			return false, fmt.Errorf("wrong number of arguments to function %s()", x.Name)
+		}
+		if x.Filter != nil {
+			return false, fmt.Errorf("FILTER may not be used with non-aggregate %s()", x.Name)
+		}
+		return false, nil
+	}
+
+	if f.Window {
+		return true, fmt.Errorf("misuse of window function %s()", x.Name)
+	}
+	if x.Distinct && len(x.Args) != 1 {
+		return true, fmt.Errorf("DISTINCT aggregates must have exactly one argument")
+	}
+
+	defer r.allowWindows(false)()
+	args := append([]ast.Expr{}, x.Args...)
+	if x.Filter != nil {
+		args = append(args, x.Filter)
+	}
+	for _, arg := range args {
+		calls, err := r.expr(arg, aliases)
+		if err != nil {
+			return true, err
+		}
+		if len(calls) > 0 {
+			return true, fmt.Errorf("misuse of aggregate function %s()", calls[0].Name)
+		}
+	}
+
+	return true, nil
+}
+
+// window checks that the function that x calls is an aggregate or window function,
+// which accepts its arguments, and resolves its arguments and window, which is
+// added to the windows. It returns the calls to aggregate functions in them.
+func (r *resolver) window(x *ast.CallExpr, aliases aliasLookup) ([]*ast.CallExpr, error) {
+	f, err := vm.LookupAggregate(x.Name, len(x.Args))
+	if err != nil {
+		if _, ferr := vm.LookupFunction(x.Name, len(x.Args)); ferr == nil {
+			return nil, fmt.Errorf("%s() may not be used as a window function", x.Name)
+		} else if !vm.IsAggregate(x.Name) {
+			return nil, ferr
+		}
+		return nil, err
+	}
+	switch {
+	case !r.windows:
+		return nil, fmt.Errorf("misuse of window function %s()", x.Name)
+	case x.Distinct:
+		return nil, fmt.Errorf("DISTINCT is not supported for window functions")
+	case x.Filter != nil && f.Window:
+		return nil, fmt.Errorf("FILTER clause may only be used with aggregate window functions")
+	}
+
+	def, err := windowDefn(x.Over, r.sel.Stmt.Windows)
+	if err != nil {
+		return nil, err
+	}
+	frame := def.Frame
+	if frame == nil {
+		frame = defaultFrame
+	}
+	if err := checkFrame(frame, len(def.OrderBy)); err != nil {
+		return nil, err
+	}
+	if forced, ok := windowFrames[f.Name]; ok {
+		frame = forced
+	}
+	for _, b := range []ast.FrameBound{frame.Start, frame.End} {
+		if b.Offset != nil {
+			if err := r.limit(b.Offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The arguments and FILTER clause may refer to the aliases of result
+	// columns, unlike the window.
+	defer r.allowWindows(false)()
+	var calls []*ast.CallExpr
+	args := append([]ast.Expr{}, x.Args...)
+	if x.Filter != nil {
+		args = append(args, x.Filter)
+	}
+	for _, arg := range args {
+		c, err := r.expr(arg, aliases)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, c...)
+	}
+	exprs := append([]ast.Expr{}, def.PartitionBy...)
+	for _, t := range def.OrderBy {
+		exprs = append(exprs, t.Expr)
+	}
+	for _, x := range exprs {
+		c, err := r.expr(x, noAliases)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, c...)
+	}
+
+	r.sel.Windows = append(r.sel.Windows, &Window{
+		Call: x,
+		Def:  &ast.Window{PartitionBy: def.PartitionBy, OrderBy: def.OrderBy, Frame: frame},
+	})
+
+	return calls, nil
+}
+
+// windowDefn returns the window w, along with the clauses of the window that it
+// names or is based on, which is looked up in defs, the first one first.
+func windowDefn(w *ast.Window, defs []*ast.NamedWindow) (*ast.Window, error) {
+	name := w.Name
+	if name == "" {
+		name = w.Base
+	}
+	if name == "" {
+		return w, nil
+	}
+
+	i := 0
+	for i < len(defs) && !strings.EqualFold(defs[i].Name, name) {
+		i++
+	}
+	if i == len(defs) {
+		return nil, fmt.Errorf("no such window: %s", name)
+	}
+	base, err := windowDefn(defs[i].Window, defs[:i])
+	if err != nil || w.Name != "" {
+		return base, err
+	}
+
+	switch {
+	case len(w.PartitionBy) > 0:
+		return nil, fmt.Errorf("cannot override PARTITION clause of window: %s", name)
+	case len(w.OrderBy) > 0 && len(base.OrderBy) > 0:
+		return nil, fmt.Errorf("cannot override ORDER BY clause of window: %s", name)
+	case base.Frame != nil:
+		return nil, fmt.Errorf("cannot override frame specification of window: %s", name)
+	}
+	def := &ast.Window{PartitionBy: base.PartitionBy, OrderBy: w.OrderBy, Frame: w.Frame}
+	if len(def.OrderBy) == 0 {
+		def.OrderBy = base.OrderBy
+	}
+
+	return def, nil
+}
+
+// checkFrame checks that the frame f of a window with the given number of ORDER BY
+// terms is supported. Like in SQLite, it cannot start after its end's kind of
+// bound, and RANGE offsets are added to the single ORDER BY term.
+func checkFrame(f *ast.Frame, orderBy int) error {
+	start, end := f.Start.Kind, f.End.Kind
+	if (start == ast.CurrentRow && end == ast.Preceding) || (start == ast.Following && end <= ast.CurrentRow) {
+		return fmt.Errorf("unsupported frame specification")
+	}
+	if f.Unit == ast.FrameRange && (f.Start.Offset != nil || f.End.Offset != nil) && orderBy != 1 {
+		return fmt.Errorf("RANGE with offset PRECEDING/FOLLOWING requires one ORDER BY expression")
+	}
+
+	return nil
+}
+
+// firstAggregate returns the first call to an aggregate function in x, if any. x
+// must have been resolved.
+func (r *resolver) firstAggregate(x ast.Expr) *ast.CallExpr {
+	var call *ast.CallExpr
+	ast.Inspect(x, func(n ast.Node) bool {
+		for _, c := range r.sel.Aggregates {
+			if n == c && call == nil {
+				call = c
+			}
+		}
+		return call == nil
+	})
+
+	return call
+}
+
+// aliasedAggregate returns the first call to an aggregate function in the result
+// columns that x refers to by their aliases, if any.
+func (r *resolver) aliasedAggregate(x ast.Expr) *ast.CallExpr {
+	var call *ast.CallExpr
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && call == nil {
+			if i, ok := r.sel.Aliases[id]; ok {
+				call = r.firstAggregate(r.sel.Columns[i].Expr)
+			}
+		}
+		return call == nil
+	})
+
+	return call
+}
+
+// firstWindow returns the first call to a window function in x, outside of its
+// subqueries, if any.
+func firstWindow(x ast.Expr) *ast.CallExpr {
+	var call *ast.CallExpr
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectStatement:
+			return false
+		case *ast.CallExpr:
+			if n.Over != nil && call == nil {
+				call = n
+			}
+		}
+		return call == nil
+	})
+
+	return call
+}
+
+// aliasedWindow returns the first column reference in x that refers to a result
+// column that calls a window function by its alias, if any.
+func (r *resolver) aliasedWindow(x ast.Expr) *ast.Ident {
+	var alias *ast.Ident
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && alias == nil {
+			if i, ok := r.sel.Aliases[id]; ok && firstWindow(r.sel.Columns[i].Expr) != nil {
+				alias = id
+			}
+		}
+		return alias == nil
+	})
+
+	return alias
+}
+
+// ident resolves the column reference id.
+func (r *resolver) ident(id *ast.Ident, aliases aliasLookup) error {
+	if aliases == aliasesFirst {
+		if i, ok := r.alias(id); ok {
+			r.sel.Aliases[id] = i
+			return nil
+		}
+	}
+
+	ref, n := r.lookup(id)
+	switch {
+	case n == 1:
+		r.sel.Refs[id] = ref
+		return nil
+	case n > 1:
+		return fmt.Errorf("ambiguous column name: %s", qualifiedName(id.Schema, id.Table, id.Name))
+	}
+
+	if aliases == aliasesLast {
+		if i, ok := r.alias(id); ok {
+			r.sel.Aliases[id] = i
+			return nil
+		}
+	}
+
+	// A subquery may refer to the columns of the statements around it, which
+	// makes it, and those between it and that statement, correlated.
+	for o := r.outer; o != nil; o = o.outer {
+		ref, n := o.lookup(id)
+		switch {
+		case n == 1:
+			o.sel.Refs[id] = ref
+			for s := r; s != o; s = s.outer {
+				s.sel.Correlated = true
+			}
+			return nil
+		case n > 1:
+			return fmt.Errorf("ambiguous column name: %s", qualifiedName(id.Schema, id.Table, id.Name))
+		}
+	}
+
+	return fmt.Errorf("no such column: %s", qualifiedName(id.Schema, id.Table, id.Name))
+}
+
+// lookup returns the column of the sources that id refers to, along with the
+// number of columns that it could refer to.
+func (r *resolver) lookup(id *ast.Ident) (ColumnRef, int) {
+	if id.Schema != "" && !strings.EqualFold(id.Schema, "main") {
+		return ColumnRef{}, 0
+	}
+
+	var ref, rowid ColumnRef
+	n, rowids := 0, 0
+	for i, src := range r.sel.Sources {
+		if id.Table != "" && !src.matches(id.Table) {
+			continue
+		}
+
+		if j, ok := src.Table.Column(id.Name); ok {
+			// A column that a USING clause or NATURAL join compares is
+			// that of the first table that has it, which is not ambiguous.
+			if n > 0 && src.using[j] {
+				continue
+			}
+			ref = ColumnRef{Source: i, Column: j}
+			n++
+		} else if !src.Table.WithoutRowid {
+			rowid = ColumnRef{Source: i, Column: RowidColumn}
+			rowids++
+		}
+	}
+
+	if n == 0 && isRowid(id.Name) {
+		return rowid, rowids
+	}
+
+	return ref, n
+}
+
+// alias returns the index of the result column whose alias is id, if it is an
+// unqualified name.
+func (r *resolver) alias(id *ast.Ident) (int, bool) {
+	if id.Schema != "" || id.Table != "" {
+		return -1, false
+	}
+
+	for i, c := range r.sel.Stmt.Columns {
+		if c.Alias != "" && strings.EqualFold(c.Alias, id.Name) {
+			// Result columns before this one may have been expanded from "*"s.
+			return r.resultIndex(i), true
+		}
+	}
+
+	return -1, false
+}
+
+// resultIndex returns the index in Select.Columns of the i-th result column in the
+// statement, which must not be a "*".
+func (r *resolver) resultIndex(i int) int {
+	c := r.sel.Stmt.Columns[i]
+	for j, column := range r.sel.Columns {
+		if column.Expr == c.Expr {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// matches returns true if column references qualified by table refer to s.
+func (s Source) matches(table string) bool {
+	if strings.EqualFold(s.Name, table) {
+		return true
+	}
+
+	// The schema table may also be referred to by its legacy name.
+	return s.Name == schema.SchemaTableName && strings.EqualFold(table, "sqlite_master")
+}
+
+func isRowid(name string) bool {
+	return strings.EqualFold(name, "rowid") || strings.EqualFold(name, "oid") || strings.EqualFold(name, "_rowid_")
+}
+
+// qualifiedName returns the non-empty names joined by dots.
+func qualifiedName(names ...string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != "" {
+			parts = append(parts, name)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
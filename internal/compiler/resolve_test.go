@@ -0,0 +1,433 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/parser"
+	"github.com/colinking/go-sqlite3-native/internal/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// testSchema is equivalent to:
+//
+//	CREATE TABLE t (id INTEGER PRIMARY KEY, a, B text);
+//	CREATE TABLE u (a, c);
+//	CREATE TABLE v (x PRIMARY KEY, y) WITHOUT ROWID;
+var testSchema = schema.New(0,
+	&schema.Table{
+		Name: "t",
+		Columns: []schema.Column{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "a"},
+			{Name: "B", Type: "text"},
+		},
+	},
+	&schema.Table{
+		Name: "u",
+		Columns: []schema.Column{
+			{Name: "a"},
+			{Name: "c"},
+		},
+	},
+	&schema.Table{
+		Name: "v",
+		Columns: []schema.Column{
+			{Name: "x", PrimaryKey: true},
+			{Name: "y"},
+		},
+		WithoutRowid: true,
+	},
+)
+
+func TestResolve(tt *testing.T) {
+	for _, test := range []struct {
+		name  string
+		query string
+		// columns are the names of the result columns.
+		columns []string
+		// refs are the columns that each result column refers to.
+		refs []ColumnRef
+		// where and orderBy are what the first column reference in the WHERE
+		// and ORDER BY clauses refer to: a ColumnRef or the index of a result
+		// column, by its alias.
+		where, orderBy interface{}
+	}{
+		{
+			name:    "star",
+			query:   `SELECT * FROM t`,
+			columns: []string{"id", "a", "B"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}},
+		},
+		{
+			name:    "star with multiple tables",
+			query:   `SELECT * FROM t, u`,
+			columns: []string{"id", "a", "B", "a", "c"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}},
+		},
+		{
+			name:    "qualified star",
+			query:   `SELECT x.* FROM t x, t`,
+			columns: []string{"id", "a", "B"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}},
+		},
+		{
+			name:    "columns are named as declared",
+			query:   `SELECT ID, b, C FROM t, u`,
+			columns: []string{"id", "B", "c"},
+			refs:    []ColumnRef{{0, 0}, {0, 2}, {1, 1}},
+		},
+		{
+			name:    "qualified columns",
+			query:   `SELECT t.a, MAIN.U.A, main.x.a FROM main.t, u, t AS x`,
+			columns: []string{"a", "a", "a"},
+			refs:    []ColumnRef{{0, 1}, {1, 0}, {2, 1}},
+		},
+		{
+			name:    "rowid",
+			query:   `SELECT rowid, OID, u._rowid_ FROM u`,
+			columns: []string{"rowid", "rowid", "rowid"},
+			refs:    []ColumnRef{{0, RowidColumn}, {0, RowidColumn}, {0, RowidColumn}},
+		},
+		{
+			name:    "rowid is named after its alias",
+			query:   `SELECT rowid FROM t`,
+			columns: []string{"id"},
+			refs:    []ColumnRef{{0, RowidColumn}},
+		},
+		{
+			name:    "without rowid tables have no rowid",
+			query:   `SELECT rowid FROM t, v`,
+			columns: []string{"id"},
+			refs:    []ColumnRef{{0, RowidColumn}},
+		},
+		{
+			name:    "schema table",
+			query:   `SELECT sqlite_master.name, sqlite_schema.type FROM sqlite_master`,
+			columns: []string{"name", "type"},
+			refs:    []ColumnRef{{0, 1}, {0, 0}},
+		},
+		{
+			name:    "table function",
+			query:   `SELECT name, p.pk FROM pragma_table_info(?) p`,
+			columns: []string{"name", "pk"},
+			refs:    []ColumnRef{{0, 1}, {0, 5}},
+		},
+		{
+			name:    "using",
+			query:   `SELECT * FROM t JOIN u USING (a) WHERE a = 1`,
+			columns: []string{"id", "a", "B", "c"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}, {1, 1}},
+			where:   ColumnRef{0, 1},
+		},
+		{
+			name:    "natural join",
+			query:   `SELECT a, u.a, x.a FROM t AS x NATURAL LEFT JOIN u`,
+			columns: []string{"a", "a", "a"},
+			refs:    []ColumnRef{{0, 1}, {1, 0}, {0, 1}},
+		},
+		{
+			name:    "natural self-join",
+			query:   `SELECT *, t.B FROM t NATURAL JOIN t`,
+			columns: []string{"id", "a", "B", "B"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}, {0, 2}},
+		},
+		{
+			name:    "qualified star includes using columns",
+			query:   `SELECT u.* FROM t JOIN u USING (a)`,
+			columns: []string{"a", "c"},
+			refs:    []ColumnRef{{1, 0}, {1, 1}},
+		},
+		{
+			name:    "on clause",
+			query:   `SELECT id FROM t LEFT JOIN u ON c = id`,
+			columns: []string{"id"},
+			refs:    []ColumnRef{{0, 0}},
+		},
+		{
+			name:    "aliases",
+			query:   `SELECT a AS id, id FROM t WHERE id = 1 ORDER BY id`,
+			columns: []string{"id", "id"},
+			refs:    []ColumnRef{{0, 1}, {0, 0}},
+			where:   ColumnRef{0, 0},
+			orderBy: 0,
+		},
+		{
+			name:    "where falls back to aliases",
+			query:   `SELECT *, c AS z FROM u WHERE z = 1 ORDER BY a`,
+			columns: []string{"a", "c", "z"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 1}},
+			where:   2,
+			orderBy: ColumnRef{0, 0},
+		},
+		{
+			name:    "from subquery",
+			query:   `SELECT * FROM (SELECT a AS x, B FROM t) AS s, u WHERE s.x = 1`,
+			columns: []string{"x", "B", "a", "c"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {1, 0}, {1, 1}},
+			where:   ColumnRef{0, 0},
+		},
+		{
+			name:    "from subquery names are unique",
+			query:   `SELECT * FROM (SELECT t.a, t.a, u.a FROM t, u)`,
+			columns: []string{"a", "a:1", "a:2"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}, {0, 2}},
+		},
+		{
+			name:    "order by column number",
+			query:   `SELECT id, a FROM t ORDER BY 2 COLLATE NOCASE DESC`,
+			columns: []string{"id", "a"},
+			refs:    []ColumnRef{{0, 0}, {0, 1}},
+			orderBy: ColumnRef{0, 1},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			sel := resolve(t, test.query)
+
+			columns := []string{}
+			refs := []ColumnRef{}
+			for _, c := range sel.Columns {
+				columns = append(columns, c.Name)
+				refs = append(refs, sel.Refs[c.Expr.(*ast.Ident)])
+			}
+			require.Equal(test.columns, columns)
+			require.Equal(test.refs, refs)
+
+			if test.where != nil {
+				require.Equal(test.where, binding(sel, sel.Stmt.Where))
+			}
+			if test.orderBy != nil {
+				require.Equal(test.orderBy, binding(sel, sel.OrderBy[0].Expr))
+			}
+		})
+	}
+}
+
+func TestResolveErrors(tt *testing.T) {
+	for _, test := range []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{name: "unknown table", query: `SELECT * FROM nope`, err: "no such table: nope"},
+		{name: "unknown schema", query: `SELECT * FROM temp.t`, err: "no such table: temp.t"},
+		{name: "unknown star qualifier", query: `SELECT foo.* FROM t`, err: "no such table: foo"},
+		{name: "unknown column", query: `SELECT nope FROM t`, err: "no such column: nope"},
+		{name: "unknown column in where", query: `SELECT a FROM t WHERE nope = 1`, err: "no such column: nope"},
+		{name: "unknown column in order by", query: `SELECT a FROM t ORDER BY nope`, err: "no such column: nope"},
+		{name: "alias replaces table name", query: `SELECT t.a FROM t x`, err: "no such column: t.a"},
+		{name: "qualified alias", query: `SELECT a AS x FROM t ORDER BY t.x`, err: "no such column: t.x"},
+		{name: "unknown column schema", query: `SELECT temp.t.a FROM t`, err: "no such column: temp.t.a"},
+		{name: "schema with alias", query: `SELECT main.t.a FROM t x`, err: "no such column: main.t.a"},
+		{name: "ambiguous column", query: `SELECT a FROM t, u`, err: "ambiguous column name: a"},
+		{name: "ambiguous qualified column", query: `SELECT t.id FROM t, t`, err: "ambiguous column name: t.id"},
+		{name: "ambiguous rowid", query: `SELECT rowid FROM t, u`, err: "ambiguous column name: rowid"},
+		{name: "ambiguous star", query: `SELECT * FROM t, t`, err: "ambiguous column name: main.t.id"},
+		{name: "without rowid", query: `SELECT rowid FROM v`, err: "no such column: rowid"},
+		{name: "unknown function", query: `SELECT nope(a) FROM t`, err: "no such function: nope"},
+		{name: "star argument", query: `SELECT lower(*) FROM t`, err: "wrong number of arguments to function lower()"},
+		{name: "aggregate arguments", query: `SELECT string_agg(a) FROM t`, err: "wrong number of arguments to function string_agg()"},
+		{name: "aggregate in where", query: `SELECT a FROM t WHERE count(*) = 0`, err: "misuse of aggregate function count()"},
+		{name: "aggregate alias in where", query: `SELECT count(*) AS n FROM t WHERE n = 1`, err: "misuse of aggregate: count()"},
+		{name: "nested aggregate", query: `SELECT sum(max(a)) FROM t`, err: "misuse of aggregate function max()"},
+		{name: "scalar filter", query: `SELECT lower(a) FILTER (WHERE a = 1) FROM t`, err: "FILTER may not be used with non-aggregate lower()"},
+		{name: "aggregate in group by", query: `SELECT a FROM t GROUP BY count(*)`, err: "aggregate functions are not allowed in the GROUP BY clause"},
+		{name: "group by ordinal", query: `SELECT a FROM t GROUP BY 3`, err: "1st GROUP BY term out of range - should be between 1 and 1"},
+		{name: "having without aggregate", query: `SELECT a FROM t HAVING a = 1`, err: "HAVING clause on a non-aggregate query"},
+		{name: "order by ordinal", query: `SELECT a FROM t ORDER BY a, 0`, err: "2nd ORDER BY term out of range - should be between 1 and 1"},
+		{name: "aggregate in order by", query: `SELECT a FROM t ORDER BY count(*)`, err: "misuse of aggregate: count()"},
+		{name: "unknown collation", query: `SELECT a FROM t ORDER BY 1 COLLATE nope`, err: "no such collation sequence: nope"},
+		{name: "ambiguous join column", query: `SELECT a FROM t JOIN u ON t.a = u.a`, err: "ambiguous column name: a"},
+		{name: "self-join not using all columns", query: `SELECT * FROM t JOIN t USING (id)`, err: "ambiguous column name: main.t.a"},
+		{name: "unknown using column", query: `SELECT * FROM t JOIN u USING (c)`, err: "cannot join using column c - column not present in both tables"},
+		{name: "natural join with on", query: `SELECT * FROM t NATURAL JOIN u ON t.a = u.a`, err: "a NATURAL join may not have an ON or USING clause"},
+		{name: "left join on later table", query: `SELECT * FROM t LEFT JOIN u ON v.x = 1 JOIN v`, err: "ON clause references tables to its right"},
+		{name: "aggregate in on", query: `SELECT * FROM t JOIN u ON count(*) = 1`, err: "misuse of aggregate function count()"},
+		{name: "column in limit", query: `SELECT a FROM t LIMIT a`, err: "no such column: a"},
+		{name: "alias in offset", query: `SELECT a AS z FROM t LIMIT 1 OFFSET z`, err: "no such column: z"},
+		{name: "aggregate in limit", query: `SELECT a FROM t LIMIT count(*)`, err: "misuse of aggregate function count()"},
+		{name: "scalar subquery columns", query: `SELECT (SELECT a, c FROM u) FROM t`, err: "sub-select returns 2 columns - expected 1"},
+		{name: "in subquery columns", query: `SELECT a FROM t WHERE a IN (SELECT * FROM u)`, err: "sub-select returns 2 columns - expected 1"},
+		{name: "row value width", query: `SELECT a FROM t WHERE (a, b) = (1, 2, 3)`, err: "row value misused"},
+		{name: "row value operand", query: `SELECT (a, b) + 1 FROM t`, err: "row value misused"},
+		{name: "row value subquery operand", query: `SELECT a FROM t WHERE (SELECT a, c FROM u) = 1`, err: "row value misused"},
+		{name: "row value in subquery columns", query: `SELECT a FROM t WHERE (a, b) IN (SELECT a FROM u)`, err: "sub-select returns 1 columns - expected 2"},
+		{name: "unknown column in subquery", query: `SELECT a FROM t WHERE EXISTS (SELECT nope FROM u)`, err: "no such column: nope"},
+		{name: "from subquery refers to join", query: `SELECT * FROM t, (SELECT c FROM u WHERE c = t.a)`, err: "no such column: t.a"},
+		{name: "from subquery rowid", query: `SELECT rowid FROM (SELECT a FROM t)`, err: "no such column: rowid"},
+		{name: "compound columns", query: `SELECT a FROM t UNION ALL SELECT a FROM u EXCEPT SELECT * FROM u`, err: "SELECTs to the left and right of EXCEPT do not have the same number of result columns"},
+		{name: "compound order by ordinal", query: `SELECT a FROM t UNION SELECT c FROM u ORDER BY 2`, err: "1st ORDER BY term out of range - should be between 1 and 1"},
+		{name: "compound order by expression", query: `SELECT a FROM t INTERSECT SELECT c FROM u ORDER BY 1, id`, err: "2nd ORDER BY term does not match any column in the result set"},
+		{name: "compound order by outer column", query: `SELECT a FROM t WHERE EXISTS (SELECT a FROM u UNION SELECT c FROM u ORDER BY t.a)`, err: "1st ORDER BY term does not match any column in the result set"},
+		{name: "cte columns", query: `WITH x(p) AS (SELECT a, c FROM u) SELECT * FROM x`, err: "table x has 2 values for 1 columns"},
+		{name: "duplicate cte", query: `WITH x AS (SELECT a FROM u), X AS (SELECT c FROM u) SELECT * FROM x`, err: "duplicate WITH table name: X"},
+		{name: "cte with schema", query: `WITH x AS (SELECT a FROM u) SELECT * FROM main.x`, err: "no such table: main.x"},
+		{name: "circular cte", query: `WITH x AS (SELECT a FROM y), y AS (SELECT a FROM x) SELECT * FROM x`, err: "circular reference: x"},
+		{name: "recursive cte in setup", query: `WITH RECURSIVE r(n) AS (SELECT n FROM r UNION SELECT a FROM u) SELECT * FROM r`, err: "circular reference: r"},
+		{name: "recursive cte in subquery", query: `WITH RECURSIVE r(n) AS (SELECT a FROM u UNION SELECT a FROM u WHERE a IN (SELECT n FROM r)) SELECT * FROM r`, err: "circular reference: r"},
+		{name: "recursive cte twice", query: `WITH RECURSIVE r(n) AS (SELECT a FROM u UNION SELECT r.n FROM r, r AS s) SELECT * FROM r`, err: "multiple references to recursive table: r"},
+		{name: "recursive cte in own subquery", query: `WITH RECURSIVE r(n) AS (SELECT a FROM u UNION SELECT n FROM r WHERE n IN (SELECT n FROM r)) SELECT * FROM r`, err: "multiple recursive references: r"},
+		{name: "recursive aggregate", query: `WITH RECURSIVE r(n) AS (SELECT a FROM u UNION ALL SELECT max(n) FROM r) SELECT * FROM r`, err: "recursive aggregate queries not supported"},
+		{name: "window function in where", query: `SELECT a FROM t WHERE row_number() OVER () = 1`, err: "misuse of window function row_number()"},
+		{name: "window function without over", query: `SELECT rank() FROM t`, err: "misuse of window function rank()"},
+		{name: "window function in window", query: `SELECT sum(a) OVER (ORDER BY row_number() OVER ()) FROM t`, err: "misuse of window function row_number()"},
+		{name: "window function in arguments", query: `SELECT sum(row_number() OVER ()) OVER () FROM t`, err: "misuse of window function row_number()"},
+		{name: "aliased window function", query: `SELECT a, rank() OVER (ORDER BY a) AS r FROM t GROUP BY r`, err: "misuse of aliased window function r"},
+		{name: "scalar function over", query: `SELECT abs(a) OVER () FROM t`, err: "abs() may not be used as a window function"},
+		{name: "distinct window function", query: `SELECT count(DISTINCT a) OVER () FROM t`, err: "DISTINCT is not supported for window functions"},
+		{name: "filter on window function", query: `SELECT rank() FILTER (WHERE a = 1) OVER () FROM t`, err: "FILTER clause may only be used with aggregate window functions"},
+		{name: "unknown window", query: `SELECT sum(a) OVER w FROM t`, err: "no such window: w"},
+		{name: "override partition", query: `SELECT sum(a) OVER (w PARTITION BY a) FROM t WINDOW w AS (ORDER BY a)`, err: "cannot override PARTITION clause of window: w"},
+		{name: "override frame", query: `SELECT sum(a) OVER (w) FROM t WINDOW w AS (ROWS 1 PRECEDING)`, err: "cannot override frame specification of window: w"},
+		{name: "unsupported frame", query: `SELECT sum(a) OVER (ROWS BETWEEN 1 FOLLOWING AND CURRENT ROW) FROM t`, err: "unsupported frame specification"},
+		{name: "range offset without order", query: `SELECT sum(a) OVER (RANGE 1 PRECEDING) FROM t`, err: "RANGE with offset PRECEDING/FOLLOWING requires one ORDER BY expression"},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			statements, err := parser.Parse(test.query)
+			require.NoError(t, err)
+
+			_, err = Resolve(statements[0].(*ast.SelectStatement), testSchema)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestResolveSubqueries(t *testing.T) {
+	require := require.New(t)
+
+	sel := resolve(t, `SELECT a FROM t WHERE EXISTS (SELECT 1 FROM u WHERE c = t.a AND a IN (SELECT id FROM t))`)
+	exists := sel.Subqueries[sel.Stmt.Where.(*ast.ExistsExpr).Select]
+	require.NotNil(exists)
+	require.True(exists.Correlated)
+	require.False(sel.Correlated)
+
+	// Correlated references are in the Refs of the query whose sources they
+	// refer to, and unqualified names refer to the innermost query's sources.
+	where := exists.Stmt.Where.(*ast.BinaryExpr)
+	c, ta := where.X.(*ast.BinaryExpr).X.(*ast.Ident), where.X.(*ast.BinaryExpr).Y.(*ast.Ident)
+	require.Equal(ColumnRef{0, 1}, exists.Refs[c])
+	require.Equal(ColumnRef{0, 1}, sel.Refs[ta])
+	require.NotContains(exists.Refs, ta)
+
+	in := where.Y.(*ast.InExpr)
+	require.Equal(ColumnRef{0, 0}, exists.Refs[in.X.(*ast.Ident)])
+	require.False(exists.Subqueries[in.Select].Correlated)
+}
+
+func TestResolveCompound(t *testing.T) {
+	require := require.New(t)
+
+	sel := resolve(t, `SELECT a, id AS x FROM t UNION SELECT c, a FROM u ORDER BY c, x, u.a COLLATE nocase DESC`)
+	require.Len(sel.Compound, 2)
+	require.Equal(ast.CompoundUnion, sel.Compound[1].Op)
+	require.Equal(sel.Compound[0].Select.Columns, sel.Columns)
+
+	// Each ORDER BY term refers to a result column of the first SELECT where it
+	// is one, by its alias or its expression, but is replaced by the result
+	// column of the compound.
+	require.Len(sel.OrderBy, 3)
+	require.Same(sel.Columns[0].Expr, sel.OrderBy[0].Expr)
+	require.Same(sel.Columns[1].Expr, sel.OrderBy[1].Expr)
+	collate := sel.OrderBy[2].Expr.(*ast.CollateExpr)
+	require.Same(sel.Columns[1].Expr, collate.X)
+	require.Equal("nocase", collate.Collation)
+	require.Equal(ast.SortDesc, sel.OrderBy[2].Order)
+
+	// The compound is correlated if any of its SELECTs is.
+	sel = resolve(t, `SELECT a FROM t WHERE a IN (SELECT a FROM u UNION SELECT c FROM u WHERE c = t.B)`)
+	in := sel.Subqueries[sel.Stmt.Where.(*ast.InExpr).Select]
+	require.True(in.Correlated)
+	require.False(in.Compound[0].Select.Correlated)
+	require.True(in.Compound[1].Select.Correlated)
+}
+
+func TestResolveCTE(t *testing.T) {
+	require := require.New(t)
+
+	// A CTE shadows the table of the same name, and its column list renames
+	// the result columns of its body. Each reference to it resolves its body
+	// again, in the scope of the WITH clause.
+	sel := resolve(t, `WITH t(p, q) AS (SELECT a, c FROM u) SELECT t.p FROM t, t AS s WHERE s.q = t.p`)
+	require.Len(sel.Sources, 2)
+	for _, src := range sel.Sources {
+		require.NotNil(src.CTE)
+		require.NotNil(src.Subquery)
+		require.Equal("p", src.Table.Columns[0].Name)
+		require.Equal("q", src.Table.Columns[1].Name)
+	}
+	require.Same(sel.Sources[0].CTE, sel.Sources[1].CTE)
+	require.NotSame(sel.Sources[0].Subquery, sel.Sources[1].Subquery)
+	require.Equal(2, sel.Sources[0].CTE.Uses)
+	require.True(sel.Sources[0].CTE.Materialized())
+
+	// The body of a recursive CTE is a compound whose last SELECTs read the
+	// rows of the CTE itself, which have no subquery of their own.
+	sel = resolve(t, `WITH RECURSIVE r(n) AS (SELECT id FROM t UNION ALL SELECT a FROM u UNION ALL SELECT a FROM t, r WHERE t.id = r.n) SELECT n FROM r`)
+	body := sel.Sources[0].Subquery
+	require.Same(sel.Sources[0].CTE, body.CTE)
+	require.Equal(2, body.CTE.Recursive)
+	require.False(body.CTE.Materialized())
+	self := body.Compound[2].Select.Sources[1]
+	require.Same(body.CTE, self.CTE)
+	require.Nil(self.Subquery)
+}
+
+func TestResolveWindows(t *testing.T) {
+	require := require.New(t)
+
+	// A call that names a window of the WINDOW clause is computed over that
+	// window, along with its own clauses, and without a frame, over the
+	// default one.
+	sel := resolve(t, `SELECT sum(a) OVER (w ORDER BY id), rank() OVER w FROM t WINDOW w AS (PARTITION BY B) ORDER BY count(*) OVER (w ORDER BY id)`)
+	require.Len(sel.Windows, 3)
+	require.Equal("sum", sel.Windows[0].Call.Name)
+	require.Equal("count", sel.Windows[2].Call.Name)
+	sum := sel.Windows[0].Def
+	require.Len(sum.PartitionBy, 1)
+	require.Len(sum.OrderBy, 1)
+	require.Equal(ast.FrameRange, sum.Frame.Unit)
+	require.Equal(ast.UnboundedPreceding, sum.Frame.Start.Kind)
+	require.Equal(ast.CurrentRow, sum.Frame.End.Kind)
+	require.True(sameWindow(sum, sel.Refs, sel.Windows[2].Def, sel.Refs))
+	require.False(sameWindow(sum, sel.Refs, sel.Windows[1].Def, sel.Refs))
+
+	// Built-in window functions have frames of their own.
+	sel = resolve(t, `SELECT ntile(2) OVER (ORDER BY a ROWS 1 PRECEDING) FROM t`)
+	frame := sel.Windows[0].Def.Frame
+	require.Equal(ast.FrameRows, frame.Unit)
+	require.Equal(ast.CurrentRow, frame.Start.Kind)
+	require.Equal(ast.UnboundedFollowing, frame.End.Kind)
+}
+
+func resolve(t *testing.T, query string) *Select {
+	statements, err := parser.Parse(query)
+	require.NoError(t, err)
+
+	sel, err := Resolve(statements[0].(*ast.SelectStatement), testSchema)
+	require.NoError(t, err)
+
+	return sel
+}
+
+// binding returns what the first column reference in x refers to.
+func binding(sel *Select, x ast.Expr) interface{} {
+	var b interface{}
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && b == nil {
+			if ref, ok := sel.Refs[id]; ok {
+				b = ref
+			} else if i, ok := sel.Aliases[id]; ok {
+				b = i
+			}
+		}
+		return b == nil
+	})
+
+	return b
+}
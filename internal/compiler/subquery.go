@@ -0,0 +1,328 @@
+package compiler
+
+import (
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// subquery generates the code of the subquery sel, whose result rows go to dest.
+// The code runs inline, with the current query as the one around it, which its
+// correlated column references read the rows of.
+func (g *generator) subquery(sel *Select, dest destination) error {
+	defer g.enter(newQuery(sel, g.query))()
+
+	return g.selectStatement(dest)
+}
+
+// once generates the start of code that computes the result of the subquery sel,
+// which is skipped after the first time if the subquery is not correlated, since
+// its result is the same for each row. It returns the label of the end of that
+// code.
+func (g *generator) once(sel *Select) int {
+	skip := g.newLabel()
+	if !sel.Correlated {
+		g.emit(vm.OpcodeOnce, 0, skip, 0, 0, 0)
+	}
+
+	return skip
+}
+
+// scalarSubquery generates code that stores the columns of the first row of the
+// subquery x in the registers starting at target, or NULLs if it has no rows.
+func (g *generator) scalarSubquery(x *ast.SubqueryExpr, target int) error {
+	sel := g.sel.Subqueries[x.Select]
+	n := len(sel.Columns)
+	r := g.allocRegisters(n)
+	skip := g.once(sel)
+	g.emit(vm.OpcodeNull, 0, r, r+n-1, 0, 0)
+	if err := g.subquery(sel, destination{kind: destValue, reg: r}); err != nil {
+		return err
+	}
+	g.resolve(skip)
+	if n == 1 {
+		g.emit(vm.OpcodeSCopy, r, target, 0, 0, 0)
+	} else {
+		g.emit(vm.OpcodeCopy, r, target, n-1, 0, 0)
+	}
+
+	return nil
+}
+
+// subqueryColumns returns the affinities and collating sequences of the columns
+// of the subquery sel, which are those of the last SELECT of a compound.
+func (g *generator) subqueryColumns(sel *Select) (affinities []int, collations []string) {
+	if len(sel.Compound) > 0 {
+		sel = sel.Compound[len(sel.Compound)-1].Select
+	}
+	defer g.enter(newQuery(sel, g.query))()
+	for _, c := range sel.Columns {
+		affinities = append(affinities, g.affinity(c.Expr))
+		collations = append(collations, g.collation(c.Expr))
+	}
+
+	return affinities, collations
+}
+
+// exists generates code that stores whether the subquery of x has any rows in the
+// register target.
+func (g *generator) exists(x *ast.ExistsExpr, target int) error {
+	sel := g.sel.Subqueries[x.Select]
+	r := g.allocRegister()
+	skip := g.once(sel)
+	g.emit(vm.OpcodeInteger, 0, r, 0, 0, 0)
+	if err := g.subquery(sel, destination{kind: destExists, reg: r}); err != nil {
+		return err
+	}
+	g.resolve(skip)
+	g.emit(vm.OpcodeSCopy, r, target, 0, 0, 0)
+
+	return nil
+}
+
+// in generates code that stores the result of the IN operator x in the register
+// target. The values of its right-hand side are added to an ephemeral index,
+// which the left-hand operand is looked up in. The result is NULL if the operand
+// is NULL, or if it is not found and one of the values is NULL, unless there are
+// no values at all:
+//
+//	     Integer 0 target
+//	     IfNot     nonEmpty, to done
+//	     Null      target
+//	     IsNull    the operand, to done
+//	     Integer 1 target
+//	     Found     the operand, to done
+//	     Null      target
+//	     Found     NULL, to done
+//	     Integer 0 target
+//	done:
+func (g *generator) in(x *ast.InExpr, target int) error {
+	if g.width(x.X) > 1 {
+		if x.Select == nil {
+			return g.inList(x, target)
+		}
+		return g.inRows(x, target)
+	}
+
+	affinities, _ := g.inComparison(x)
+	affinity := affinities[0]
+	cursor, nonEmpty, err := g.inSet(x)
+	if err != nil {
+		return err
+	}
+
+	lhs, null := g.allocRegister(), g.allocRegister()
+	done := g.newLabel()
+	g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	g.emit(vm.OpcodeIfNot, nonEmpty, done, 0, 0, 0)
+	if err := g.expr(x.X, lhs); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeNull, 0, target, 0, 0, 0)
+	g.emit(vm.OpcodeIsNull, lhs, done, 0, 0, 0)
+	if affinity > vm.AffinityBlob {
+		g.emitStr(vm.OpcodeAffinity, lhs, 1, 0, string(rune(affinity)), 0)
+	}
+	g.emit(vm.OpcodeInteger, 1, target, 0, 0, 0)
+	g.emit(vm.OpcodeFound, cursor, done, lhs, 1, 0)
+	g.emit(vm.OpcodeNull, 0, target, 0, 0, 0)
+	g.emit(vm.OpcodeNull, 0, null, 0, 0, 0)
+	g.emit(vm.OpcodeFound, cursor, done, null, 1, 0)
+	g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	g.resolve(done)
+	if x.Not {
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// inList generates code that stores the result of the IN operator x, whose
+// left-hand operand is a row value, in the register target. It is that of
+// "x.X = y1 OR x.X = y2 ..." for the values of its list, except that x.X is
+// only evaluated once.
+func (g *generator) inList(x *ast.InExpr, target int) error {
+	lhs, err := g.vector(x.X)
+	if err != nil {
+		return err
+	}
+
+	r := g.allocRegister()
+	g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	for _, y := range x.List {
+		rhs, err := g.vector(y)
+		if err != nil {
+			return err
+		}
+		g.compareVectors(ast.OpEq, lhs, rhs, r)
+		g.emit(vm.OpcodeOr, target, r, target, 0, 0)
+	}
+	if x.Not {
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// inRows generates code that stores the result of the IN operator x, whose
+// left-hand operand is a row value, in the register target. The rows of its
+// subquery are added to an ephemeral index, which the operand is looked up in,
+// unless one of its values is NULL. If it is not found, the result is NULL if
+// there is a row that it is not definitely unequal to, because each pair of
+// their values is equal or has a NULL, like in SQLite:
+//
+//	     Integer 0 target
+//	     IfNot     nonEmpty, to done
+//	     IsNull    each value of the operand, to scan
+//	     Integer 1 target
+//	     Found     the operand, to done
+//	     Integer 0 target
+//	scan:
+//	     Rewind    cursor, to done
+//	loop:
+//	     Ne        each column and value of the operand, to next
+//	     Null      target
+//	     Goto      done
+//	next:
+//	     Next      cursor, to loop
+//	done:
+func (g *generator) inRows(x *ast.InExpr, target int) error {
+	affinities, colls := g.inComparison(x)
+	cursor, nonEmpty, err := g.inSet(x)
+	if err != nil {
+		return err
+	}
+
+	n := len(affinities)
+	done, scan, loop, next := g.newLabel(), g.newLabel(), g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	g.emit(vm.OpcodeIfNot, nonEmpty, done, 0, 0, 0)
+	lhs, err := g.vector(x.X)
+	if err != nil {
+		return err
+	}
+	g.emitStr(vm.OpcodeAffinity, lhs.reg, n, 0, affinityString(affinities), 0)
+	for i := 0; i < n; i++ {
+		g.emit(vm.OpcodeIsNull, lhs.reg+i, scan, 0, 0, 0)
+	}
+	g.emit(vm.OpcodeInteger, 1, target, 0, 0, 0)
+	g.emit(vm.OpcodeFound, cursor, done, lhs.reg, n, 0)
+	g.emit(vm.OpcodeInteger, 0, target, 0, 0, 0)
+	g.resolve(scan)
+	g.emit(vm.OpcodeRewind, cursor, done, 0, 0, 0)
+	g.resolve(loop)
+	column := g.allocRegister()
+	for i := 0; i < n; i++ {
+		g.emit(vm.OpcodeColumn, cursor, i, column, 0, 0)
+		g.emitStr(vm.OpcodeNe, column, next, lhs.reg+i, colls[i], vm.AffinityBlob)
+	}
+	g.emit(vm.OpcodeNull, 0, target, 0, 0, 0)
+	g.emit(vm.OpcodeGoto, 0, done, 0, 0, 0)
+	g.resolve(next)
+	g.emit(vm.OpcodeNext, cursor, loop, 0, 0, 0)
+	g.resolve(done)
+	if x.Not {
+		g.emit(vm.OpcodeNot, target, target, 0, 0, 0)
+	}
+
+	return nil
+}
+
+// inComparison returns the affinities and collating sequences that the IN
+// operator x compares the values of its left-hand operand with those of its
+// right-hand side with. Like a comparison, those of a subquery depend on both
+// the operand and the subquery's columns, but the values of a list are compared
+// like the operand, as in SQLite.
+func (g *generator) inComparison(x *ast.InExpr) (affinities []int, colls []string) {
+	affinities, colls = g.comparand(x.X)
+	if x.Select == nil {
+		return affinities, colls
+	}
+
+	columnAffinities, columnColls := g.subqueryColumns(g.sel.Subqueries[x.Select])
+	for i := range affinities {
+		affinities[i] = compareAffinities(affinities[i], columnAffinities[i])
+		if colls[i] == "" {
+			colls[i] = columnColls[i]
+		}
+	}
+
+	return affinities, colls
+}
+
+// affinityString returns affinities as the P4 operand of an Affinity opcode, with
+// BLOB for those that are 0.
+func affinityString(affinities []int) string {
+	b := make([]byte, len(affinities))
+	for i, affinity := range affinities {
+		if affinity == 0 {
+			affinity = vm.AffinityBlob
+		}
+		b[i] = byte(affinity)
+	}
+
+	return string(b)
+}
+
+// inSet generates code that adds the values or rows of the right-hand side of the
+// IN operator x to a new ephemeral index, converted to the affinities of the
+// comparison, and returns its cursor, along with a register that is set to 1 if
+// it has any values. The index is only filled once for a subquery that is not
+// correlated, or for a list whose values are the same for each row.
+func (g *generator) inSet(x *ast.InExpr) (cursor, nonEmpty int, err error) {
+	affinities, colls := g.inComparison(x)
+	affinity := affinities[0]
+	nonEmpty, cursor = g.allocRegister(), g.allocCursor()
+	var skip int
+	if x.Select != nil {
+		skip = g.once(g.sel.Subqueries[x.Select])
+	} else if skip = g.newLabel(); g.constant(x) {
+		g.emit(vm.OpcodeOnce, 0, skip, 0, 0, 0)
+	}
+	g.emit(vm.OpcodeInteger, 0, nonEmpty, 0, 0, 0)
+	keyInfo := &vm.KeyInfo{Collations: colls, Desc: make([]bool, len(colls))}
+	g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenEphemeral, cursor, len(colls), 0, keyInfo, 0))
+	dest := destination{kind: destSet, reg: nonEmpty, cursor: cursor, affinities: affinityString(affinities)}
+	if x.Select != nil {
+		if err := g.subquery(g.sel.Subqueries[x.Select], dest); err != nil {
+			return 0, 0, err
+		}
+	} else if len(x.List) > 0 {
+		r := g.allocRegister()
+		for _, y := range x.List {
+			if err := g.expr(y, r); err != nil {
+				return 0, 0, err
+			}
+			if affinity > vm.AffinityBlob {
+				g.emitStr(vm.OpcodeAffinity, r, 1, 0, string(rune(affinity)), 0)
+			}
+			g.emit(vm.OpcodeIdxInsert, cursor, r, 1, 0, 0)
+		}
+		g.emit(vm.OpcodeInteger, 1, nonEmpty, 0, 0, 0)
+	}
+	g.resolve(skip)
+
+	return cursor, nonEmpty, nil
+}
+
+// constant returns true if the values of the right-hand side of the IN operator
+// x are the same for each row, because they refer to no columns, other than
+// those of subqueries that are not correlated.
+func (g *generator) constant(x *ast.InExpr) bool {
+	constant := true
+	for _, y := range x.List {
+		ast.Inspect(y, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				constant = false
+			case *ast.SelectStatement:
+				if g.sel.Subqueries[n].Correlated {
+					constant = false
+				}
+				return false
+			}
+			return constant
+		})
+	}
+
+	return constant
+}
@@ -0,0 +1,1197 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/schema"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// term is one of the expressions that a WHERE or ON clause is a conjunction of.
+type term struct {
+	expr ast.Expr
+	// level is the source whose loop checks the term, which is the last of the
+	// sources that it refers to, or the right-hand source of the LEFT JOIN
+	// whose ON clause it is from.
+	level int
+	// on is set for the terms of the ON clause of a LEFT JOIN, which decide
+	// whether a row of the right-hand table matches, rather than whether a row
+	// of the join is output.
+	on bool
+	// consumed is set if the scan of a loop already ensures that the term is
+	// true, so that it need not be checked for each row.
+	consumed bool
+}
+
+// terms returns the terms of the WHERE clause and of the ON clauses of the joins.
+// The terms of the ON clause of an inner join are the same as those of the WHERE
+// clause.
+func (g *generator) terms() []*term {
+	var terms []*term
+	add := func(x ast.Expr, left int) {
+		for _, t := range splitAnd(x) {
+			if left >= 0 {
+				t.level, t.on = left, true
+			} else if t.level = g.sel.lastSource(t.expr); t.level < 0 {
+				t.level = 0
+			}
+			terms = append(terms, t)
+		}
+	}
+
+	add(g.sel.Stmt.Where, -1)
+	for i, src := range g.sel.Sources {
+		if src.Join == ast.JoinLeft {
+			add(src.On, i)
+		} else {
+			add(src.On, -1)
+		}
+	}
+
+	return terms
+}
+
+// splitAnd splits the WHERE clause x into the terms that are ANDed together.
+func splitAnd(x ast.Expr) []*term {
+	if x == nil {
+		return nil
+	}
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op == ast.OpAnd {
+		return append(splitAnd(b.X), splitAnd(b.Y)...)
+	}
+
+	return []*term{{expr: x}}
+}
+
+// constraint is a term that compares a column of a source with a value that only
+// depends on the sources before it, which may let a loop seek to the rows that
+// match it.
+type constraint struct {
+	term *term
+	// column is the column of the source's table, or RowidColumn, which is also
+	// used for the column that is an alias for the rowid.
+	column int
+	// op is one of OpcodeEq, OpcodeGt or OpcodeLt, as in "column op value".
+	op    vm.Opcode
+	value ast.Expr
+	// in is set for an IN operator, which is an equality constraint whose
+	// values the loop seeks one at a time, instead of a single value.
+	in *ast.InExpr
+	// affinity and collation are those of the comparison.
+	affinity  int
+	collation string
+	// columnAffinity is the affinity of the column.
+	columnAffinity int
+
+	// like is set for a LIKE or GLOB term with a constant prefix, f.e. "abc%",
+	// which bounds the range of the index that the matching rows are in.
+	like *likeRange
+}
+
+// likeRange is the range [lower, upper) of the strings that can match a LIKE or
+// GLOB pattern.
+type likeRange struct {
+	lower, upper string
+	// noCase is set for LIKE patterns, which match regardless of case.
+	noCase bool
+}
+
+// plan describes how a loop finds the rows of a source.
+type plan struct {
+	// index is the index that the loop scans, or nil if it scans the table.
+	index *schema.Index
+	// eqs are equality constraints on the first columns of the index, or a
+	// single one on the rowid. Those that are IN operators make the loop seek
+	// each combination of their values.
+	eqs []*constraint
+	// lower and upper bound the range of the next column of the index, or of
+	// the rowid.
+	lower, upper *constraint
+	// like bounds the next column of the index, instead of lower and upper.
+	like *constraint
+	// score estimates how much the plan narrows down the rows that are read.
+	score int
+	// grouped is set if the loop visits the rows in an order where the rows
+	// that are in the same group of a GROUP BY clause are next to each other.
+	grouped bool
+	// ordered is set if the loop visits the rows in the order of the terms of
+	// an ORDER BY clause, so that they need not be sorted.
+	ordered bool
+}
+
+// loop is a loop over the rows of a source, which is started by beginLoop and
+// ended by endLoop.
+type loop struct {
+	source int
+	plan   *plan
+	// table is the cursor of the source's table, and index that of the index
+	// that the plan scans, or -1.
+	table, index int
+	// cursor is the cursor that the loop moves with Next, or -1 if the loop
+	// only visits a single row.
+	cursor int
+	top    int
+	// next and done are the labels of the end of the loop's body and of the
+	// end of the loop.
+	next, done int
+
+	// match and first implement the loop over the right-hand table of a LEFT
+	// JOIN: r[match] is set to 1 at the address first once a row matches the
+	// ON clause, and if none did, the loop's body is run once more for a row
+	// whose columns are all NULL.
+	match, first int
+
+	// pass and again implement a loop that scans two ranges, for LIKE
+	// patterns: r[pass] is 1 while the first range is scanned, and again is
+	// the label that starts the second.
+	pass, again int
+
+	// co is the register of the coroutine that yields the rows of a subquery
+	// in the FROM clause, or 0, and end is the address of its EndCoroutine.
+	co, end int
+
+	// ins are the loops over the values of the IN operators of the plan, from
+	// the outermost in, which are around the seek of each combination of
+	// them. nextIn is the label that the seek jumps to once it finds no more
+	// rows, which moves on to the next combination.
+	ins    []inLoop
+	nextIn int
+}
+
+// inLoop is a loop over the values of an IN operator, which are the entries of
+// the ephemeral index cursor, whose current one is read at the address top.
+type inLoop struct {
+	cursor, top int
+}
+
+// planLoops returns the plans for the nested loops over the rows of the sources,
+// in the order that they appear in the FROM clause, that visit the rows of the
+// join which match the terms. Only the outermost loop can visit the rows in the
+// order of a GROUP BY or ORDER BY clause, and only if its terms are columns of
+// the first source.
+func (g *generator) planLoops(terms []*term, groupBy []ast.Expr, orderBy []*ast.OrderingTerm) []*plan {
+	if len(g.sel.Sources) == 0 {
+		// A SELECT without a FROM clause has a single row, which is in any
+		// order.
+		return []*plan{{grouped: len(groupBy) > 0, ordered: len(orderBy) > 0}}
+	}
+	if len(g.sel.Sources) > 1 {
+		for _, x := range groupBy {
+			if _, ok := g.sourceColumn(0, x); !ok {
+				groupBy = nil
+				break
+			}
+		}
+		for _, t := range orderBy {
+			x := t.Expr
+			if c, ok := x.(*ast.CollateExpr); ok {
+				x = c.X
+			}
+			if _, ok := g.sourceColumn(0, x); !ok {
+				orderBy = nil
+				break
+			}
+		}
+	}
+
+	plans := []*plan{g.planLoop(0, terms, groupBy, orderBy)}
+	for i := 1; i < len(g.sel.Sources); i++ {
+		plans = append(plans, g.planLoop(i, terms, nil, nil))
+	}
+
+	return plans
+}
+
+// planLoop returns the plan for a loop over the rows of the given source that
+// match the terms that its loop checks. Like SQLite's query planner, it seeks to
+// the matching rows using the rowid or an index if the terms constrain them. If
+// the rows are grouped by the terms of a GROUP BY clause, or sorted by those of an
+// ORDER BY clause, the loop scans an index that visits the rows in that order if
+// it would otherwise scan the whole table.
+//
+// See: https://www.sqlite.org/optoverview.html
+func (g *generator) planLoop(source int, terms []*term, groupBy []ast.Expr, orderBy []*ast.OrderingTerm) *plan {
+	p := g.bestPlan(source, g.constraints(source, terms))
+	if len(groupBy) == 0 && len(orderBy) == 0 {
+		return p
+	}
+
+	// sorted returns true if the loop with the plan q visits the rows in the
+	// order that the query needs them in.
+	sorted := func(q *plan) bool {
+		if len(groupBy) > 0 {
+			q.grouped = g.groupedBy(source, q, groupBy)
+			return q.grouped
+		}
+		q.ordered = g.orderedBy(source, q, orderBy)
+		return q.ordered
+	}
+	if sorted(p) || p.score > 0 {
+		return p
+	}
+	for _, index := range g.sel.Sources[source].Table.Indexes {
+		if index.Partial {
+			continue
+		}
+		if q := (&plan{index: index}); sorted(q) {
+			return q
+		}
+	}
+
+	return p
+}
+
+// beginLoops generates the start of the nested loops over the sources that the
+// plans describe, which opens the cursors of all of them first. The code between
+// beginLoops and endLoops is run for each row of the join.
+func (g *generator) beginLoops(terms []*term, plans []*plan) ([]*loop, error) {
+	if len(g.sel.Sources) == 0 {
+		// The single row of a SELECT without a FROM clause is visited once if
+		// the terms are true, without opening a cursor.
+		l := &loop{plan: plans[0], table: -1, index: -1, cursor: -1, next: g.newLabel(), done: g.newLabel()}
+		for _, term := range terms {
+			if err := g.jumpIfFalse(term.expr, l.next, true); err != nil {
+				return nil, err
+			}
+		}
+		return []*loop{l}, nil
+	}
+
+	loops := make([]*loop, len(plans))
+	for i, p := range plans {
+		loops[i] = g.openLoop(i, p)
+	}
+	for _, l := range loops {
+		if err := g.beginLoop(l, terms); err != nil {
+			return nil, err
+		}
+	}
+
+	return loops, nil
+}
+
+// endLoops generates the end of the nested loops, from the innermost one out.
+func (g *generator) endLoops(loops []*loop) {
+	for i := len(loops) - 1; i >= 0; i-- {
+		g.endLoop(loops[i])
+	}
+}
+
+// openLoop opens the cursors of a loop over the rows of the given source, as the
+// plan p says. Subqueries have no cursors, but the registers that their rows are
+// yielded in, unless they are the bodies of materialized CTEs, whose cursors are
+// opened by beginLoop. The sources of the recursive SELECTs of a CTE read the
+// cursor of its current row.
+func (g *generator) openLoop(source int, p *plan) *loop {
+	src := g.sel.Sources[source]
+	t := src.Table
+	l := &loop{source: source, plan: p, index: -1, next: g.newLabel(), done: g.newLabel()}
+	switch {
+	case src.CTE != nil && src.Subquery == nil:
+		l.table = g.current[src.CTE]
+	case src.CTE != nil && src.CTE.Materialized(), src.Tables != nil:
+		l.table = g.allocCursor()
+	case src.Subquery != nil:
+		l.table, l.cursor = -1, -1
+		l.co = g.allocRegister()
+		g.results[source] = g.allocRegisters(len(t.Columns))
+		return l
+	default:
+		l.table = g.allocCursor()
+		g.emit(vm.OpcodeOpenRead, l.table, t.RootPage, 0, 0, 0)
+	}
+	l.cursor = l.table
+	g.cursors[source] = l.table
+
+	if p.index != nil {
+		keyInfo := &vm.KeyInfo{}
+		for _, c := range p.index.Columns {
+			keyInfo.Collations = append(keyInfo.Collations, c.Collation)
+			keyInfo.Desc = append(keyInfo.Desc, c.Desc)
+		}
+		l.index = g.allocCursor()
+		l.cursor = l.index
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeOpenRead, l.index, p.index.RootPage, 0, keyInfo, 0))
+	}
+
+	return l
+}
+
+// beginLoop generates the start of the loop l, which finds the rows as its plan
+// says and checks the remaining terms of its level for each row. The loop over
+// the right-hand table of a LEFT JOIN checks the terms of the join's ON clause
+// before it records that a row matched, and the other terms after.
+func (g *generator) beginLoop(l *loop, terms []*term) error {
+	if g.sel.Sources[l.source].Join == ast.JoinLeft {
+		l.match = g.allocRegister()
+		g.emit(vm.OpcodeInteger, 0, l.match, 0, 0, 0)
+	}
+
+	var err error
+	switch p := l.plan; {
+	case l.co != 0:
+		err = g.coroutine(l)
+	case g.sel.Sources[l.source].Subquery != nil:
+		err = g.materialize(l)
+	case g.sel.Sources[l.source].Tables != nil:
+		err = g.tableInfo(l)
+	case p.index != nil:
+		err = g.indexScan(l, p)
+	case len(p.eqs) > 0:
+		err = g.rowidLookup(l, p)
+	case p.lower != nil || p.upper != nil:
+		err = g.rowidRange(l, p)
+	default:
+		g.emit(vm.OpcodeRewind, l.table, l.done, 0, 0, 0)
+		l.top = len(g.instructions)
+	}
+	if err != nil {
+		return err
+	}
+
+	check := func(on bool) error {
+		for _, term := range terms {
+			if term.level != l.source || term.on != on || term.consumed {
+				continue
+			}
+			if err := g.jumpIfFalse(term.expr, l.next, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(true); err != nil {
+		return err
+	}
+	if l.match != 0 {
+		l.first = g.emit(vm.OpcodeInteger, 1, l.match, 0, 0, 0)
+	}
+
+	return check(false)
+}
+
+// endLoop generates the end of the loop l. If no row of the right-hand table of a
+// LEFT JOIN matched, its cursors are moved to a NULL row, and the loop's body is
+// run once more.
+func (g *generator) endLoop(l *loop) {
+	g.resolve(l.next)
+	if l.cursor >= 0 {
+		g.emit(vm.OpcodeNext, l.cursor, l.top, 0, 0, 0)
+	}
+	if len(l.ins) > 0 {
+		g.resolve(l.nextIn)
+		for i := len(l.ins) - 1; i >= 0; i-- {
+			g.emit(vm.OpcodeNext, l.ins[i].cursor, l.ins[i].top, 0, 0, 0)
+		}
+	}
+	if l.co != 0 {
+		g.emit(vm.OpcodeGoto, 0, l.top, 0, 0, 0)
+	}
+	g.resolve(l.done)
+	if l.pass != 0 {
+		g.emit(vm.OpcodeIfPos, l.pass, l.again, 1, 0, 0)
+	}
+	if l.match != 0 {
+		matched := g.newLabel()
+		g.emit(vm.OpcodeIfPos, l.match, matched, 0, 0, 0)
+		if l.co != 0 {
+			// The coroutine has ended, so the next Yield to it is made to
+			// run its EndCoroutine again, which ends the loop.
+			r, n := g.results[l.source], len(g.sel.Sources[l.source].Table.Columns)
+			g.emit(vm.OpcodeNull, 0, r, r+n-1, 0, 0)
+			g.emit(vm.OpcodeInitCoroutine, l.co, 0, l.end, 0, 0)
+		} else {
+			g.emit(vm.OpcodeNullRow, l.table, 0, 0, 0, 0)
+		}
+		if l.index >= 0 {
+			g.emit(vm.OpcodeNullRow, l.index, 0, 0, 0, 0)
+		}
+		g.emit(vm.OpcodeGoto, 0, l.first, 0, 0, 0)
+		g.resolve(matched)
+	}
+}
+
+// coroutine generates the start of a loop over the rows of a subquery in the FROM
+// clause, which are yielded by a coroutine, like in SQLite. The subquery is run
+// again each time that the loop starts:
+//
+//	     InitCoroutine co, to top
+//	     ...           the subquery, which yields each row
+//	     EndCoroutine  co
+//	top: Yield         co, to done once the subquery ends
+//
+// The end of the loop jumps back to top, rather than moving a cursor.
+func (g *generator) coroutine(l *loop) error {
+	src := g.sel.Sources[l.source]
+	top := g.newLabel()
+	g.emit(vm.OpcodeInitCoroutine, l.co, top, len(g.instructions)+1, 0, 0)
+	dest := destination{kind: destCoroutine, reg: l.co, results: g.results[l.source]}
+	if err := g.subquery(src.Subquery, dest); err != nil {
+		return err
+	}
+	l.end = g.emit(vm.OpcodeEndCoroutine, l.co, 0, 0, 0, 0)
+	g.resolve(top)
+	l.top = g.emit(vm.OpcodeYield, l.co, l.done, 0, 0, 0)
+
+	return nil
+}
+
+// tableInfo generates the start of a loop over the rows of a call to
+// pragma_table_info, which are put in an ephemeral table each time that the loop
+// starts: one for each column of the table whose name is equal to the argument,
+// ignoring case, if there is one. The tables are known when the program is
+// compiled, since it is only run against the schema that it was compiled for:
+//
+//	     OpenEphemeral cursor
+//	     ...           the argument
+//	     Ne            the name of the first table, to next
+//	     ...           add a row for each of its columns
+//	next:
+//	     ...           the same for the other tables
+//	     Rewind        cursor, to done
+//	top:
+func (g *generator) tableInfo(l *loop) error {
+	src := g.sel.Sources[l.source]
+	g.emit(vm.OpcodeOpenEphemeral, l.table, len(src.Table.Columns), 0, 0, 0)
+	arg := g.allocRegister()
+	if err := g.expr(src.Ref.(*ast.TableFunction).Args[0], arg); err != nil {
+		return err
+	}
+
+	name, row := g.allocRegister(), g.allocRegisters(len(src.Table.Columns))
+	for _, t := range src.Tables {
+		next := g.newLabel()
+		g.emitStr(vm.OpcodeString8, 0, name, 0, t.Name, 0)
+		g.emitStr(vm.OpcodeNe, name, next, arg, "NOCASE", vm.JumpIfNull)
+
+		pk := map[int]int{}
+		for i, c := range t.PrimaryKey() {
+			pk[c] = i + 1
+		}
+		for i, c := range t.Columns {
+			g.emit(vm.OpcodeInteger, i, row, 0, 0, 0)
+			g.emitStr(vm.OpcodeString8, 0, row+1, 0, c.Name, 0)
+			g.emitStr(vm.OpcodeString8, 0, row+2, 0, c.Type, 0)
+			notNull := 0
+			if c.NotNull {
+				notNull = 1
+			}
+			g.emit(vm.OpcodeInteger, notNull, row+3, 0, 0, 0)
+			if c.Default != "" {
+				g.emitStr(vm.OpcodeString8, 0, row+4, 0, c.Default, 0)
+			} else {
+				g.emit(vm.OpcodeNull, 0, row+4, 0, 0, 0)
+			}
+			g.emit(vm.OpcodeInteger, pk[i], row+5, 0, 0, 0)
+			g.emit(vm.OpcodeInsert, l.table, row, len(src.Table.Columns), 0, 0)
+		}
+		g.resolve(next)
+	}
+
+	g.emit(vm.OpcodeRewind, l.table, l.done, 0, 0, 0)
+	l.top = len(g.instructions)
+
+	return nil
+}
+
+// rowidLookup generates the start of a loop that visits the row whose rowid is
+// equal to a value, if there is one, or the rows whose rowids are equal to each
+// of the values of an IN operator.
+func (g *generator) rowidLookup(l *loop, p *plan) error {
+	c := p.eqs[0]
+	r := g.allocRegister()
+	exit, err := g.beginIns(l, p)
+	if err != nil {
+		return err
+	}
+	if c.in != nil {
+		g.emit(vm.OpcodeColumn, l.ins[0].cursor, 0, r, 0, 0)
+	} else if err := g.expr(c.value, r); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeSeekRowid, l.cursor, exit, r, 0, 0)
+	c.term.consumed = true
+	l.cursor = -1
+
+	return nil
+}
+
+// beginIns generates the start of the loops over the values of the IN operators
+// of the plan p, whose ephemeral indexes are filled first, and returns the label
+// that the seek of each combination of their values jumps to once it is done,
+// which is the end of the loop l if there are none. Like in SQLite, the values
+// are visited in order, without duplicates:
+//
+//	     ...     fill the index of each IN operator
+//	     Rewind  in, to done
+//	top: Column  in, 0, r
+//	     ...     seek r, and visit the rows
+//	     Next    in, to top
+//	done:
+func (g *generator) beginIns(l *loop, p *plan) (int, error) {
+	var cursors []int
+	for _, c := range p.eqs {
+		if c.in == nil {
+			continue
+		}
+		cursor, _, err := g.inSet(c.in)
+		if err != nil {
+			return 0, err
+		}
+		cursors = append(cursors, cursor)
+	}
+	if l.again != 0 {
+		// Each pass of a loop over two ranges visits each of the values.
+		g.resolve(l.again)
+	}
+	if len(cursors) == 0 {
+		return l.done, nil
+	}
+
+	l.nextIn = g.newLabel()
+	for _, cursor := range cursors {
+		g.emit(vm.OpcodeRewind, cursor, l.done, 0, 0, 0)
+		l.ins = append(l.ins, inLoop{cursor: cursor, top: len(g.instructions)})
+	}
+
+	return l.nextIn, nil
+}
+
+// rowidRange generates the start of a loop over the rows whose rowids are in a
+// range.
+func (g *generator) rowidRange(l *loop, p *plan) error {
+	if c := p.lower; c != nil {
+		r := g.allocRegister()
+		if err := g.expr(c.value, r); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeIsNull, r, l.done, 0, 0, 0)
+		g.emit(vm.OpcodeSeekGT, l.cursor, l.done, r, 1, 0)
+		c.term.consumed = true
+	} else {
+		g.emit(vm.OpcodeRewind, l.cursor, l.done, 0, 0, 0)
+	}
+
+	if c := p.upper; c != nil {
+		r, rowid := g.allocRegister(), g.allocRegister()
+		if err := g.expr(c.value, r); err != nil {
+			return err
+		}
+		l.top = len(g.instructions)
+		// The scan ends at the first rowid that is not less than the bound,
+		// or immediately if the bound is NULL.
+		g.emit(vm.OpcodeRowid, l.cursor, rowid, 0, 0, 0)
+		g.emit(vm.OpcodeGe, r, l.done, rowid, 0, vm.AffinityNumeric|vm.JumpIfNull)
+		c.term.consumed = true
+		return nil
+	}
+	l.top = len(g.instructions)
+
+	return nil
+}
+
+// indexScan generates the start of a loop over the entries of an index that are
+// in the range that the plan p constrains them to, which seeks the table's
+// cursor to the row of each entry.
+func (g *generator) indexScan(l *loop, p *plan) error {
+	if p.like != nil {
+		// The first pass scans the strings in the range, and the second the
+		// blobs, which sort after all strings.
+		l.pass = g.allocRegister()
+		g.emit(vm.OpcodeInteger, 1, l.pass, 0, 0, 0)
+		l.again = g.newLabel()
+	}
+	exit, err := g.beginIns(l, p)
+	if err != nil {
+		return err
+	}
+
+	// The key holds the values of the equality constraints, followed by the
+	// bound of the range, if there is one.
+	n := len(p.eqs)
+	key := g.allocRegisters(n + 1)
+	affinities := ""
+	ins := l.ins
+	for i, c := range p.eqs {
+		if c.in != nil {
+			g.emit(vm.OpcodeColumn, ins[0].cursor, 0, key+i, 0, 0)
+			ins = ins[1:]
+		} else if err := g.expr(c.value, key+i); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeIsNull, key+i, exit, 0, 0, 0)
+		affinities += string(rune(keyAffinity(c)))
+		c.term.consumed = true
+	}
+	if n > 0 {
+		g.emitStr(vm.OpcodeAffinity, key, n, 0, affinities, 0)
+	}
+
+	// end is the key that the scan stops at, with the opcode that compares an
+	// entry with it.
+	end, endOp, endLen := key, vm.OpcodeIdxGT, n
+	switch {
+	case p.like != nil:
+		like := p.like.like
+		g.emitStr(vm.OpcodeString8, 0, key+n, l.pass, like.lower, 0)
+		g.emit(vm.OpcodeSeekGE, l.cursor, exit, key, n+1, 0)
+		end, endOp, endLen = g.allocRegisters(n+1), vm.OpcodeIdxGE, n+1
+		if n > 0 {
+			g.emit(vm.OpcodeCopy, key, end, n-1, 0, 0)
+		}
+		g.emitStr(vm.OpcodeString8, 0, end+n, l.pass, like.upper, 0)
+	case p.lower != nil:
+		if err := g.bound(p.lower, key+n, exit); err != nil {
+			return err
+		}
+		g.emit(vm.OpcodeSeekGT, l.cursor, exit, key, n+1, 0)
+	case p.upper != nil:
+		// NULLs sort first in indexes, but are never less than the bound.
+		g.emit(vm.OpcodeNull, 0, key+n, 0, 0, 0)
+		g.emit(vm.OpcodeSeekGT, l.cursor, exit, key, n+1, 0)
+	default:
+		g.emit(vm.OpcodeSeekGE, l.cursor, exit, key, n, 0)
+	}
+	if p.upper != nil {
+		end, endOp, endLen = g.allocRegisters(n+1), vm.OpcodeIdxGE, n+1
+		if n > 0 {
+			g.emit(vm.OpcodeCopy, key, end, n-1, 0, 0)
+		}
+		if err := g.bound(p.upper, end+n, exit); err != nil {
+			return err
+		}
+	}
+
+	l.top = len(g.instructions)
+	if endLen > 0 {
+		g.emit(endOp, l.cursor, exit, end, endLen, 0)
+	}
+	g.emit(vm.OpcodeDeferredSeek, l.cursor, 0, l.table, 0, 0)
+
+	return nil
+}
+
+// bound generates code that stores the bound of the range constraint c in the
+// register r, converted to the affinity that it is compared with, and that jumps
+// to done if it is NULL.
+func (g *generator) bound(c *constraint, r, done int) error {
+	if err := g.expr(c.value, r); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeIsNull, r, done, 0, 0, 0)
+	g.emitStr(vm.OpcodeAffinity, r, 1, 0, string(rune(keyAffinity(c))), 0)
+	c.term.consumed = true
+
+	return nil
+}
+
+// keyAffinity returns the affinity that the value of the constraint c is converted
+// to before it is compared with the entries of an index.
+func keyAffinity(c *constraint) int {
+	if c.affinity == vm.AffinityBlob {
+		return vm.AffinityBlob
+	}
+
+	return c.columnAffinity
+}
+
+// constraints returns the constraints that the terms put on the given source.
+// Those of the right-hand table of a LEFT JOIN only come from its ON clause, since
+// the other terms are also checked for its NULL row.
+func (g *generator) constraints(source int, terms []*term) []*constraint {
+	t := g.sel.Sources[source].Table
+	left := g.sel.Sources[source].Join == ast.JoinLeft
+	alias, hasAlias := t.RowidAlias()
+
+	// column returns the column of the source that x refers to, if it does.
+	column := func(x ast.Expr) (int, bool) {
+		id, ok := x.(*ast.Ident)
+		if !ok {
+			return 0, false
+		}
+		if _, ok := g.sel.Aliases[id]; ok {
+			return 0, false
+		}
+		ref, ok := g.sel.Refs[id]
+		if !ok || ref.Source != source {
+			return 0, false
+		}
+		if hasAlias && ref.Column == alias {
+			return RowidColumn, true
+		}
+		return ref.Column, true
+	}
+
+	var constraints []*constraint
+	for _, term := range terms {
+		if term.level != source || (left && !term.on) {
+			continue
+		}
+		switch x := term.expr.(type) {
+		case *ast.BinaryExpr:
+			if x.Op != ast.OpEq && x.Op != ast.OpGt && x.Op != ast.OpLt {
+				continue
+			}
+			c := &constraint{
+				term:      term,
+				affinity:  g.comparisonAffinity(x.X, x.Y),
+				collation: g.comparisonCollation(x.X, x.Y),
+			}
+			if col, ok := column(x.X); ok && g.available(x.Y, source) {
+				c.column, c.op, c.value = col, comparisonOpcodes[x.Op], x.Y
+			} else if col, ok := column(x.Y); ok && g.available(x.X, source) {
+				// "value > column" is "column < value", and vice versa.
+				c.column, c.op, c.value = col, comparisonOpcodes[x.Op], x.X
+				switch c.op {
+				case vm.OpcodeGt:
+					c.op = vm.OpcodeLt
+				case vm.OpcodeLt:
+					c.op = vm.OpcodeGt
+				}
+			} else {
+				continue
+			}
+			c.columnAffinity = vm.AffinityInteger
+			if c.column != RowidColumn {
+				c.columnAffinity = vm.TypeAffinity(t.Columns[c.column].Type)
+			}
+			constraints = append(constraints, c)
+		case *ast.InExpr:
+			col, ok := column(x.X)
+			if !ok || x.Not {
+				continue
+			}
+			if x.Select != nil && !g.available(x.Select, source) {
+				continue
+			}
+			available := true
+			for _, y := range x.List {
+				available = available && g.available(y, source)
+			}
+			if !available {
+				continue
+			}
+			c := &constraint{term: term, column: col, op: vm.OpcodeEq, in: x, columnAffinity: vm.AffinityInteger}
+			affinities, colls := g.inComparison(x)
+			c.affinity, c.collation = affinities[0], colls[0]
+			if c.column != RowidColumn {
+				c.columnAffinity = vm.TypeAffinity(t.Columns[c.column].Type)
+			}
+			constraints = append(constraints, c)
+		case *ast.LikeExpr:
+			col, ok := column(x.X)
+			if !ok || col == RowidColumn {
+				continue
+			}
+			c := &constraint{term: term, column: col, columnAffinity: vm.TypeAffinity(t.Columns[col].Type)}
+			if c.like = likePrefix(x, c.columnAffinity); c.like != nil {
+				constraints = append(constraints, c)
+			}
+		}
+	}
+
+	return constraints
+}
+
+// available returns true if x only depends on the rows of the sources before the
+// given one, which the loops around the source's loop are at.
+func (g *generator) available(x ast.Node, source int) bool {
+	available := true
+	ast.Inspect(x, func(n ast.Node) bool {
+		// Identifiers refer to a column, or to a result column by its alias.
+		// Those that are not in Refs are columns of the sources of
+		// subqueries, or of the queries around this one, which do not
+		// depend on the row of any source of this one.
+		if id, ok := n.(*ast.Ident); ok {
+			if _, ok := g.sel.Aliases[id]; ok {
+				available = false
+			} else if ref, ok := g.sel.Refs[id]; ok {
+				available = ref.Source < source
+			}
+		}
+		return available
+	})
+
+	return available
+}
+
+// likePrefix returns the range of strings that x can match, if its pattern is a
+// string literal that starts with characters that are not wildcards, and if an
+// index on a column with the given affinity can be used to find them. LIKE
+// ignores the case of ASCII characters, so its range is from the upper case
+// prefix to the lower case one, which is a range of the index if it sorts with
+// NOCASE, or with BINARY if the prefix has no letters.
+func likePrefix(x *ast.LikeExpr, affinity int) *likeRange {
+	if x.Not || (x.Op != ast.OpLike && x.Op != ast.OpGlob) {
+		return nil
+	}
+	pattern, ok := x.Y.(*ast.Literal)
+	if !ok || pattern.Kind != ast.StringLiteral {
+		return nil
+	}
+
+	wildcards, escape := "%_", -1
+	if x.Op == ast.OpGlob {
+		wildcards = "*?["
+	}
+	if x.Escape != nil {
+		e, ok := x.Escape.(*ast.Literal)
+		if !ok || e.Kind != ast.StringLiteral || len(e.Value) != 1 {
+			return nil
+		}
+		escape = int(e.Value[0])
+	}
+
+	var prefix []byte
+	for i := 0; i < len(pattern.Value); i++ {
+		c := pattern.Value[i]
+		if int(c) == escape {
+			i++
+			if i == len(pattern.Value) {
+				return nil
+			}
+			c = pattern.Value[i]
+		} else if strings.IndexByte(wildcards, c) >= 0 {
+			break
+		}
+		prefix = append(prefix, c)
+	}
+	if len(prefix) == 0 || prefix[len(prefix)-1] == 0xff {
+		return nil
+	}
+
+	r := &likeRange{noCase: x.Op == ast.OpLike}
+	lower, upper := string(prefix), string(prefix)
+	if r.noCase {
+		lower, upper = asciiCase(lower, true), asciiCase(upper, false)
+	}
+	upper = upper[:len(upper)-1] + string(upper[len(upper)-1]+1)
+	r.lower, r.upper = lower, upper
+
+	// Columns that are not TEXT may hold numbers, which sort before strings, so
+	// the range only holds all matches if no number can match the prefix.
+	if affinity != vm.AffinityText {
+		if vm.IsNumber(lower) || vm.IsNumber(upper) || lower == "-" {
+			return nil
+		}
+	}
+
+	return r
+}
+
+// bestPlan returns the plan for the loop over the given source that narrows down
+// the rows that it reads the most, using the constraints on the source.
+func (g *generator) bestPlan(source int, constraints []*constraint) *plan {
+	t := g.sel.Sources[source].Table
+	best := &plan{}
+
+	// The rowid is unique, so a lookup of a single rowid beats any index, and
+	// one of each of the values of an IN operator beats all but a lookup of a
+	// single entry of a unique index.
+	for _, c := range constraints {
+		if c.column != RowidColumn || c.like != nil || !affinityOk(c, vm.AffinityInteger) {
+			continue
+		}
+		switch {
+		case c.in != nil:
+			if best.score < 800 {
+				best = &plan{eqs: []*constraint{c}, score: 800}
+			}
+		case c.op == vm.OpcodeEq:
+			return &plan{eqs: []*constraint{c}, score: 1000}
+		case c.op == vm.OpcodeGt && best.lower == nil && len(best.eqs) == 0:
+			best.lower, best.score = c, 60
+		case c.op == vm.OpcodeLt && best.upper == nil && len(best.eqs) == 0:
+			best.upper, best.score = c, 60
+		}
+	}
+
+	for _, index := range t.Indexes {
+		if p := indexPlan(index, constraints); p != nil && p.score > best.score {
+			best = p
+		}
+	}
+
+	return best
+}
+
+// indexPlan returns the plan for a scan of the index that is constrained by the
+// most constraints, or nil if none of them constrain it.
+func indexPlan(index *schema.Index, constraints []*constraint) *plan {
+	if index.Partial {
+		return nil
+	}
+
+	p := &plan{index: index}
+	// find returns the first constraint on the index column i with the given
+	// opcode that can be used to seek the index, which is an IN operator if in
+	// is set.
+	find := func(i int, op vm.Opcode, in bool) *constraint {
+		ic := index.Columns[i]
+		for _, c := range constraints {
+			if c.column != ic.Column || c.like != nil || c.op != op || (c.in != nil) != in {
+				continue
+			}
+			if !strings.EqualFold(collationName(c.collation), collationName(ic.Collation)) {
+				continue
+			}
+			if !affinityOk(c, c.columnAffinity) {
+				continue
+			}
+			return c
+		}
+		return nil
+	}
+
+	ins := 0
+	for i, ic := range index.Columns {
+		if ic.Column == schema.ExprColumn {
+			break
+		}
+		if c := find(i, vm.OpcodeEq, false); c != nil {
+			p.eqs = append(p.eqs, c)
+			continue
+		}
+		if c := find(i, vm.OpcodeEq, true); c != nil {
+			p.eqs = append(p.eqs, c)
+			ins++
+			continue
+		}
+		if ic.Desc {
+			break
+		}
+		p.lower, p.upper = find(i, vm.OpcodeGt, false), find(i, vm.OpcodeLt, false)
+		if p.lower == nil && p.upper == nil {
+			p.like = findLike(ic, constraints)
+		}
+		break
+	}
+
+	switch {
+	case len(p.eqs) == len(index.Columns) && index.Unique:
+		p.score = 900
+	case p.lower != nil || p.upper != nil || p.like != nil:
+		p.score = 100*len(p.eqs) + 50
+	default:
+		p.score = 100 * len(p.eqs)
+	}
+	if p.score == 0 {
+		return nil
+	}
+	// Each IN operator multiplies the number of seeks.
+	p.score -= 10 * ins
+
+	return p
+}
+
+// groupedBy returns true if the loop over the given source with the plan p visits
+// the rows that are in the same group of the GROUP BY terms one after the other.
+// This is the case if each term is a column of the source, with the column's
+// collating sequence, and the columns that the plan does not constrain to a
+// single value are the next columns of the index that it scans, in any order, or
+// the rowid of the table that it scans.
+func (g *generator) groupedBy(source int, p *plan, groupBy []ast.Expr) bool {
+	if hasIn(p) {
+		return false
+	}
+	if p.index == nil && len(p.eqs) > 0 {
+		// A rowid lookup visits a single row.
+		return true
+	}
+
+	columns := map[int]string{}
+	for _, x := range groupBy {
+		column, ok := g.sourceColumn(source, x)
+		if !ok {
+			return false
+		}
+		columns[column] = collationName(g.collation(x))
+	}
+	for _, c := range p.eqs {
+		if coll, ok := columns[c.column]; ok && strings.EqualFold(coll, collationName(c.collation)) {
+			delete(columns, c.column)
+		}
+	}
+
+	if p.index == nil {
+		_, ok := columns[RowidColumn]
+		return len(columns) == 0 || (len(columns) == 1 && ok)
+	}
+	rest := p.index.Columns[len(p.eqs):]
+	if len(columns) > len(rest) {
+		return false
+	}
+	for _, ic := range rest[:len(columns)] {
+		coll, ok := columns[ic.Column]
+		if !ok || ic.Column == schema.ExprColumn || !strings.EqualFold(coll, collationName(ic.Collation)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// orderedBy returns true if the loop over the given source with the plan p visits
+// the rows in the order of the ORDER BY terms. This is the case if each term is a
+// column of the source that sorts NULLs first, and the columns that the plan does
+// not constrain to a single value are the next columns of the index that it scans,
+// in the same order and direction and with the same collating sequences, which
+// may be followed by the rowid. A scan of the table visits the rows in the order
+// of their rowids.
+func (g *generator) orderedBy(source int, p *plan, orderBy []*ast.OrderingTerm) bool {
+	if hasIn(p) {
+		return false
+	}
+	if p.index == nil && len(p.eqs) > 0 {
+		// A rowid lookup visits a single row.
+		return true
+	}
+
+	var keys []schema.IndexColumn
+	for _, t := range orderBy {
+		x := t.Expr
+		if c, ok := x.(*ast.CollateExpr); ok {
+			x = c.X
+		}
+		column, ok := g.sourceColumn(source, x)
+		if !ok || bigNull(t) {
+			return false
+		}
+		coll := collationName(g.collation(t.Expr))
+
+		constrained := false
+		for _, c := range p.eqs {
+			if c.column == column && strings.EqualFold(coll, collationName(c.collation)) {
+				constrained = true
+			}
+		}
+		if !constrained {
+			keys = append(keys, schema.IndexColumn{Column: column, Collation: coll, Desc: t.Order == ast.SortDesc})
+		}
+	}
+
+	var columns []schema.IndexColumn
+	if p.index != nil {
+		columns = p.index.Columns[len(p.eqs):]
+	}
+	for i, k := range keys {
+		if i == len(columns) {
+			// The entries of an index are followed by their rowids.
+			return i == len(keys)-1 && k.Column == RowidColumn && !k.Desc
+		}
+		ic := columns[i]
+		if ic.Column != k.Column || ic.Desc != k.Desc || !strings.EqualFold(k.Collation, collationName(ic.Collation)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasIn returns true if the plan p seeks the values of an IN operator, whose
+// rows are visited for one value after the other.
+func hasIn(p *plan) bool {
+	for _, c := range p.eqs {
+		if c.in != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bigNull returns true if the ORDER BY term t sorts NULLs after the other values,
+// before its direction is applied.
+func bigNull(t *ast.OrderingTerm) bool {
+	if t.Order == ast.SortDesc {
+		return t.Nulls == ast.NullsFirst
+	}
+
+	return t.Nulls == ast.NullsLast
+}
+
+// sourceColumn returns the column of the given source that x refers to, either
+// directly or through the alias of a result column, if it is a column reference.
+// The column that is an alias for the rowid is returned as RowidColumn.
+func (g *generator) sourceColumn(source int, x ast.Expr) (int, bool) {
+	for {
+		id, ok := x.(*ast.Ident)
+		if !ok {
+			return 0, false
+		}
+		if i, ok := g.sel.Aliases[id]; ok {
+			x = g.sel.Columns[i].Expr
+			continue
+		}
+		ref, ok := g.sel.Refs[id]
+		if !ok || ref.Source != source {
+			return 0, false
+		}
+		if alias, ok := g.sel.Sources[source].Table.RowidAlias(); ok && ref.Column == alias {
+			return RowidColumn, true
+		}
+		return ref.Column, true
+	}
+}
+
+// findLike returns a LIKE or GLOB constraint on the index column ic whose range is
+// a range of the index.
+func findLike(ic schema.IndexColumn, constraints []*constraint) *constraint {
+	for _, c := range constraints {
+		if c.column != ic.Column || c.like == nil {
+			continue
+		}
+		coll := strings.ToUpper(collationName(ic.Collation))
+		switch {
+		case !c.like.noCase && coll == "BINARY":
+		case c.like.noCase && coll == "NOCASE":
+		case c.like.noCase && coll == "BINARY" && c.like.lower == asciiCase(c.like.lower, false):
+			// The prefix has no letters, so it matches regardless of case.
+		default:
+			continue
+		}
+		return c
+	}
+
+	return nil
+}
+
+// affinityOk returns true if the comparison of the constraint c can use an index
+// on a column with the given affinity, which is only the case if the comparison
+// does not convert the values in the index differently than the index does.
+// It is a port of SQLite's sqlite3IndexAffinityOk.
+func affinityOk(c *constraint, columnAffinity int) bool {
+	switch {
+	case c.affinity < vm.AffinityText:
+		return true
+	case c.affinity == vm.AffinityText:
+		return columnAffinity == vm.AffinityText
+	default:
+		return columnAffinity >= vm.AffinityNumeric
+	}
+}
+
+func collationName(name string) string {
+	if name == "" {
+		return "BINARY"
+	}
+
+	return name
+}
+
+// asciiCase converts the ASCII letters in s to upper or lower case, leaving the
+// other characters as they are, like NOCASE does.
+func asciiCase(s string, upper bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case upper && c >= 'a' && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case !upper && c >= 'A' && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+	}
+
+	return string(b)
+}
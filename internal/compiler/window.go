@@ -0,0 +1,704 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// windowPass holds the registers and cursors of the calls to window functions of
+// the current query that are computed over the same window. Like in SQLite, each
+// window is a pass over the rows of the query, which are sorted by its PARTITION
+// BY and ORDER BY terms, and the rows of each partition are then added to an
+// ephemeral table, from which the frame of each row is computed.
+type windowPass struct {
+	def   *ast.Window
+	calls []*ast.CallExpr
+
+	// refs and values are the columns, and the results of the calls to the
+	// aggregate functions and to the window functions of the passes before
+	// this one, that the rows carry from one pass to the next one.
+	refs   []ColumnRef
+	values []*ast.CallExpr
+
+	// sorter is the cursor of the sorter that sorts the rows by the keys of
+	// the window, followed by a sequence number, so that the rows that are
+	// equal keep the order of the pass before, and by the refs and values.
+	sorter int
+	// table is the cursor of the ephemeral table of the rows of the current
+	// partition, which are numbered from 1. Each holds the number of the
+	// group of peers that it is in, its ORDER BY term, for RANGE frames with
+	// offsets, the arguments of each call, followed by its FILTER clause if
+	// it has one, from the column in inputs, and the refs and values from
+	// column carried.
+	table   int
+	inputs  []int
+	carried int
+	// acc and res are the first of the registers of the accumulators of the
+	// calls, and of their results.
+	acc, res int
+
+	// cur is the number of the current row of the partition, group and key
+	// hold its group and ORDER BY term, and k the number of a row of the
+	// frame, whose group or ORDER BY term is read into cand.
+	cur, group, key, k, cand int
+}
+
+// rangeKey returns true if the rows of the pass carry their ORDER BY term, which
+// the bounds of a RANGE frame with offsets are computed from.
+func (p *windowPass) rangeKey() bool {
+	f := p.def.Frame
+	return f.Unit == ast.FrameRange && (f.Start.Offset != nil || f.End.Offset != nil)
+}
+
+// minMax returns true if the i-th call of the pass is to min or max, which compare
+// their argument using its collating sequence.
+func (p *windowPass) minMax(i int) bool {
+	call := p.calls[i]
+	f, err := vm.LookupAggregate(call.Name, len(call.Args))
+	return err == nil && f.Collation
+}
+
+// keys returns the PARTITION BY and ORDER BY terms that the rows of the pass are
+// sorted by.
+func (p *windowPass) keys() []ast.Expr {
+	keys := append([]ast.Expr{}, p.def.PartitionBy...)
+	for _, t := range p.def.OrderBy {
+		keys = append(keys, t.Expr)
+	}
+
+	return keys
+}
+
+// width returns the number of values of the entries of the sorter of the pass.
+func (p *windowPass) width() int {
+	return len(p.keys()) + 1 + len(p.refs) + len(p.values)
+}
+
+// windowPasses returns the passes of the current query, which are grouped by
+// the windows of its calls to window functions in the order that they first
+// appear, but computed in the reverse of that order, like in SQLite. The rows
+// are output in the order of the last pass, unless there is an ORDER BY clause.
+func (g *generator) windowPasses() []*windowPass {
+	var passes []*windowPass
+	for _, w := range g.sel.Windows {
+		var pass *windowPass
+		for _, p := range passes {
+			if sameWindow(p.def, g.sel.Refs, w.Def, g.sel.Refs) {
+				pass = p
+				break
+			}
+		}
+		if pass == nil {
+			pass = &windowPass{def: w.Def}
+			passes = append(passes, pass)
+		}
+		pass.calls = append(pass.calls, w.Call)
+	}
+	for i, j := 0, len(passes)-1; i < j; i, j = i+1, j-1 {
+		passes[i], passes[j] = passes[j], passes[i]
+	}
+
+	done := map[*ast.CallExpr]int{}
+	values := append([]*ast.CallExpr{}, g.sel.Aggregates...)
+	for _, call := range values {
+		done[call] = 0
+	}
+	for _, p := range passes {
+		p.refs = g.columnRefs(g.outputExprs(), done)
+		p.values = values
+		for _, call := range p.calls {
+			done[call] = 0
+		}
+		values = append(values[:len(values):len(values)], p.calls...)
+	}
+
+	return passes
+}
+
+// windowSelect generates the body of the program for a query that calls window
+// functions. The rows of the query, which are those of the groups of an
+// aggregate query, are added to the sorter of the first pass, and each pass adds
+// its rows to the sorter of the next one, along with the results of its calls,
+// until the last one outputs them:
+//
+//	SorterOpen   for each pass
+//	...          the rows of the query
+//	SorterInsert into the sorter of the first pass
+//	...
+//	...          each pass, see windowPass
+//	ResultRow    in the last pass
+//	...
+func (g *generator) windowSelect(terms []*term, dest destination) error {
+	passes := g.windowPasses()
+	if err := g.beginOutput(false, dest); err != nil {
+		return err
+	}
+	for _, p := range passes {
+		keyInfo := g.windowKeyInfo(p.def.PartitionBy, p.def.OrderBy)
+		keyInfo.Collations = append(keyInfo.Collations, "")
+		keyInfo.Desc = append(keyInfo.Desc, false)
+		p.sorter = g.allocCursor()
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeSorterOpen, p.sorter, p.width(), 0, keyInfo, 0))
+	}
+
+	insert := func() error { return g.windowInsert(passes[0]) }
+	if g.sel.Aggregate() {
+		plans := g.planLoops(terms, g.sel.GroupBy, nil)
+		if err := g.aggregateSelect(terms, plans, insert); err != nil {
+			return err
+		}
+	} else {
+		plans := g.planLoops(terms, nil, nil)
+		loops, err := g.beginLoops(terms, plans)
+		if err != nil {
+			return err
+		}
+		if err := insert(); err != nil {
+			return err
+		}
+		g.endLoops(loops)
+	}
+
+	if g.accumulators == nil {
+		g.accumulators = map[*ast.CallExpr]int{}
+	}
+	for i, p := range passes {
+		row := g.resultRow
+		if i+1 < len(passes) {
+			next := passes[i+1]
+			row = func() error { return g.windowInsert(next) }
+		}
+		if err := g.windowPass(p, row); err != nil {
+			return err
+		}
+	}
+	g.columnRegs = nil
+	g.endOutput()
+
+	return nil
+}
+
+// windowKeyInfo returns the KeyInfo that compares the PARTITION BY terms and the
+// ORDER BY terms of a window, in that order.
+func (g *generator) windowKeyInfo(partitionBy []ast.Expr, orderBy []*ast.OrderingTerm) *vm.KeyInfo {
+	keyInfo := &vm.KeyInfo{}
+	for _, x := range partitionBy {
+		keyInfo.Collations = append(keyInfo.Collations, g.collation(x))
+		keyInfo.Desc = append(keyInfo.Desc, false)
+	}
+	for i, t := range orderBy {
+		keyInfo.Collations = append(keyInfo.Collations, g.collation(t.Expr))
+		keyInfo.Desc = append(keyInfo.Desc, t.Order == ast.SortDesc)
+		if bigNull(t) {
+			if keyInfo.BigNull == nil {
+				keyInfo.BigNull = make([]bool, len(partitionBy)+len(orderBy))
+			}
+			keyInfo.BigNull[len(partitionBy)+i] = true
+		}
+	}
+
+	return keyInfo
+}
+
+// windowInsert generates code that adds the current row to the sorter of the pass
+// p.
+func (g *generator) windowInsert(p *windowPass) error {
+	keys, n := p.keys(), p.width()
+	entry := g.allocRegisters(n)
+	for i, x := range keys {
+		if err := g.expr(x, entry+i); err != nil {
+			return err
+		}
+	}
+	g.emit(vm.OpcodeSequence, p.sorter, entry+len(keys), 0, 0, 0)
+	carried := entry + len(keys) + 1
+	for i, ref := range p.refs {
+		g.column(ref, carried+i)
+	}
+	for i, call := range p.values {
+		if err := g.expr(call, carried+len(p.refs)+i); err != nil {
+			return err
+		}
+	}
+	g.emit(vm.OpcodeSorterInsert, p.sorter, entry, n, 0, 0)
+
+	return nil
+}
+
+// windowPass generates the code of the pass p, which reads the sorted rows into
+// the ephemeral table of the pass until the partition changes, and then computes
+// the calls of each row of the partition and outputs it with row:
+//
+//	     SorterSort   to done
+//	top: SorterData
+//	     Compare      the PARTITION BY terms with those of the last row
+//	     Jump         to same, if they are equal
+//	     Gosub        flush
+//	     OpenEphemeral to empty the table
+//	     ...
+//	same:
+//	     Compare      the ORDER BY terms with those of the last row
+//	     Jump         to insert, if they are equal
+//	     AddImm       the group, which starts a new group of peers
+//	insert:
+//	     Insert       the row into the table
+//	     SorterNext   to top
+//	done:
+//	     Gosub        flush
+//	     Goto         end
+//	flush:
+//	     ...          see windowFlush
+//	     Return
+//	end:
+func (g *generator) windowPass(p *windowPass, row func() error) error {
+	def := p.def
+	np, no := len(def.PartitionBy), len(def.OrderBy)
+	keys, n := np+no, p.width()
+
+	p.table = g.allocCursor()
+	width := 1
+	if p.rangeKey() {
+		width++
+	}
+	for _, call := range p.calls {
+		p.inputs = append(p.inputs, width)
+		width += len(call.Args)
+		if call.Filter != nil {
+			width++
+		}
+	}
+	p.carried = width
+	width += len(p.refs) + len(p.values)
+
+	p.acc = g.allocRegisters(len(p.calls))
+	p.res = g.allocRegisters(len(p.calls))
+	rows, group := g.allocRegister(), g.allocRegister()
+	entry := g.allocRegisters(n)
+	prev := g.allocRegisters(keys)
+	flush, flushLabel := g.allocRegister(), g.newLabel()
+
+	g.emit(vm.OpcodeOpenEphemeral, p.table, width, 0, 0, 0)
+	g.emit(vm.OpcodeInteger, 0, rows, 0, 0, 0)
+	g.emit(vm.OpcodeInteger, 0, group, 0, 0, 0)
+	if keys > 0 {
+		g.emit(vm.OpcodeNull, 0, prev, prev+keys-1, 0, 0)
+	}
+	done, end := g.newLabel(), g.newLabel()
+	g.emit(vm.OpcodeSorterSort, p.sorter, done, 0, 0, 0)
+	top := len(g.instructions)
+	g.emit(vm.OpcodeSorterData, p.sorter, entry, n, 0, 0)
+
+	// The expressions of the row read the values that it carries.
+	carried := entry + keys + 1
+	g.columnRegs = map[ColumnRef]int{}
+	for i, ref := range p.refs {
+		g.columnRegs[ref] = carried + i
+	}
+	for i, call := range p.values {
+		g.accumulators[call] = carried + len(p.refs) + i
+	}
+
+	insert := g.newLabel()
+	if np > 0 {
+		same := g.newLabel()
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeCompare, prev, entry, np, g.windowKeyInfo(def.PartitionBy, nil), 0))
+		next := len(g.instructions) + 1
+		g.emit(vm.OpcodeJump, next, same, next, 0, 0)
+		g.emit(vm.OpcodeGosub, flush, flushLabel, 0, 0, 0)
+		g.emit(vm.OpcodeOpenEphemeral, p.table, width, 0, 0, 0)
+		g.emit(vm.OpcodeInteger, 0, rows, 0, 0, 0)
+		g.emit(vm.OpcodeInteger, 0, group, 0, 0, 0)
+		g.emit(vm.OpcodeCopy, entry, prev, keys-1, 0, 0)
+		g.emit(vm.OpcodeGoto, 0, insert, 0, 0, 0)
+		g.resolve(same)
+	}
+	if no > 0 {
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeCompare, prev+np, entry+np, no, g.windowKeyInfo(nil, def.OrderBy), 0))
+		next := len(g.instructions) + 1
+		g.emit(vm.OpcodeJump, next, insert, next, 0, 0)
+		g.emit(vm.OpcodeAddImm, group, 1, 0, 0, 0)
+		g.emit(vm.OpcodeCopy, entry+np, prev+np, no-1, 0, 0)
+	}
+	g.resolve(insert)
+
+	r := g.allocRegisters(width)
+	g.emit(vm.OpcodeSCopy, group, r, 0, 0, 0)
+	if p.rangeKey() {
+		g.emit(vm.OpcodeSCopy, entry+np, r+1, 0, 0, 0)
+	}
+	for i, call := range p.calls {
+		for j, arg := range call.Args {
+			if err := g.expr(arg, r+p.inputs[i]+j); err != nil {
+				return err
+			}
+		}
+		if call.Filter != nil {
+			if err := g.expr(call.Filter, r+p.inputs[i]+len(call.Args)); err != nil {
+				return err
+			}
+		}
+	}
+	if m := len(p.refs) + len(p.values); m > 0 {
+		g.emit(vm.OpcodeCopy, carried, r+p.carried, m-1, 0, 0)
+	}
+	g.emit(vm.OpcodeInsert, p.table, r, width, 0, 0)
+	g.emit(vm.OpcodeAddImm, rows, 1, 0, 0, 0)
+	g.emit(vm.OpcodeSorterNext, p.sorter, top, 0, 0, 0)
+	g.resolve(done)
+	g.emit(vm.OpcodeGosub, flush, flushLabel, 0, 0, 0)
+	g.emit(vm.OpcodeGoto, 0, end, 0, 0, 0)
+
+	g.resolve(flushLabel)
+	skip := g.newLabel()
+	g.emit(vm.OpcodeIfPos, rows, skip, 0, 0, 0)
+	g.emit(vm.OpcodeReturn, flush, 0, 0, 0, 0)
+	g.resolve(skip)
+	if err := g.windowFlush(p, row); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeReturn, flush, 0, 0, 0, 0)
+	g.resolve(end)
+
+	return nil
+}
+
+// windowFlush generates the code that computes the calls of the pass p for each
+// row of the partition in its table, and outputs it with row. The frame of each
+// row is the rows that were added to the accumulators but not removed from them,
+// which are numbered from the one after removed up to added, since the bounds of
+// the frame only move forward from one row to the next:
+//
+//	     Null         the accumulators
+//	loop:
+//	     AddImm       cur, the number of the next row
+//	     SeekRowid    to done, if there is none
+//	add: SeekRowid    the row after added, to added, if there is none
+//	     ...          to added, if it is after the end of the frame
+//	     AggStep      for each call
+//	     AddImm       added
+//	     Goto         add
+//	added:
+//	     ...          likewise, AggInverse the rows before its start
+//	     AggValue     for each call
+//	     ...          output the row
+//	     Goto         loop
+//	done:
+//
+// The calls that cannot remove rows, and those of a frame that excludes rows,
+// are computed from all the rows of the frame instead, for each row.
+func (g *generator) windowFlush(p *windowPass, row func() error) error {
+	f := p.def.Frame
+
+	var startOff, endOff int
+	if f.Start.Offset != nil {
+		startOff = g.allocRegister()
+		if err := g.frameOffset(f, f.Start.Offset, "starting", startOff); err != nil {
+			return err
+		}
+	}
+	if f.End.Offset != nil {
+		endOff = g.allocRegister()
+		if err := g.frameOffset(f, f.End.Offset, "ending", endOff); err != nil {
+			return err
+		}
+	}
+
+	// The calls that are computed incrementally, or from all of the rows of
+	// the frame.
+	var steps, scans []int
+	for i, call := range p.calls {
+		fn, err := vm.LookupAggregate(call.Name, len(call.Args))
+		if err != nil {
+			return err
+		}
+		if f.Exclude != ast.ExcludeNoOthers || (f.Start.Kind != ast.UnboundedPreceding && !fn.Inverse) {
+			scans = append(scans, i)
+		} else {
+			steps = append(steps, i)
+		}
+	}
+
+	added, removed := g.allocRegister(), g.allocRegister()
+	p.cur, p.group, p.key = g.allocRegister(), g.allocRegister(), g.allocRegister()
+	p.k, p.cand = g.allocRegister(), g.allocRegister()
+	g.emit(vm.OpcodeNull, 0, p.acc, p.acc+len(p.calls)-1, 0, 0)
+	g.emit(vm.OpcodeInteger, 0, added, 0, 0, 0)
+	g.emit(vm.OpcodeInteger, 0, removed, 0, 0, 0)
+	g.emit(vm.OpcodeInteger, 0, p.cur, 0, 0, 0)
+
+	loop, done := len(g.instructions), g.newLabel()
+	g.emit(vm.OpcodeAddImm, p.cur, 1, 0, 0, 0)
+	g.emit(vm.OpcodeSeekRowid, p.table, done, p.cur, 0, 0)
+	g.emit(vm.OpcodeColumn, p.table, 0, p.group, 0, 0)
+	if p.rangeKey() {
+		g.emit(vm.OpcodeColumn, p.table, 1, p.key, 0, 0)
+	}
+	start := g.frameBound(p, f.Start, startOff)
+	end := g.frameBound(p, f.End, endOff)
+
+	// The rows up to the end of the frame are added.
+	add, addDone := len(g.instructions), g.newLabel()
+	g.emit(vm.OpcodeSCopy, added, p.k, 0, 0, 0)
+	g.emit(vm.OpcodeAddImm, p.k, 1, 0, 0, 0)
+	g.emit(vm.OpcodeSeekRowid, p.table, addDone, p.k, 0, 0)
+	if f.End.Kind != ast.UnboundedFollowing {
+		g.frameTest(p, f.End, end, addDone, true)
+	}
+	for _, i := range steps {
+		g.windowStep(p, i, vm.OpcodeAggStep)
+	}
+	g.emit(vm.OpcodeAddImm, added, 1, 0, 0, 0)
+	g.emit(vm.OpcodeGoto, 0, add, 0, 0, 0)
+	g.resolve(addDone)
+
+	// The rows before the start of the frame are removed.
+	if f.Start.Kind != ast.UnboundedPreceding {
+		remove, removeDone := len(g.instructions), g.newLabel()
+		g.emit(vm.OpcodeSCopy, removed, p.k, 0, 0, 0)
+		g.emit(vm.OpcodeAddImm, p.k, 1, 0, 0, 0)
+		g.emit(vm.OpcodeGt, added, removeDone, p.k, 0, 0)
+		g.emit(vm.OpcodeSeekRowid, p.table, removeDone, p.k, 0, 0)
+		g.frameTest(p, f.Start, start, removeDone, false)
+		for _, i := range steps {
+			g.windowStep(p, i, vm.OpcodeAggInverse)
+		}
+		g.emit(vm.OpcodeAddImm, removed, 1, 0, 0, 0)
+		g.emit(vm.OpcodeGoto, 0, remove, 0, 0, 0)
+		g.resolve(removeDone)
+	}
+	for _, i := range steps {
+		call := p.calls[i]
+		g.emitStr(vm.OpcodeAggValue, p.acc+i, len(call.Args), p.res+i, strings.ToLower(call.Name), 0)
+	}
+
+	// Like in SQLite, where they keep the rows of moving frames in an index,
+	// min and max return the last of the values that are equal, rather than
+	// the first one, so their rows are visited in reverse.
+	var forward, reverse []int
+	for _, i := range scans {
+		if f.Start.Kind != ast.UnboundedPreceding && p.minMax(i) {
+			reverse = append(reverse, i)
+		} else {
+			forward = append(forward, i)
+		}
+	}
+	g.windowScan(p, forward, removed, added, false)
+	g.windowScan(p, reverse, removed, added, true)
+
+	// The expressions of the row read the values that it carries, and the
+	// results of the calls.
+	g.emit(vm.OpcodeSeekRowid, p.table, done, p.cur, 0, 0)
+	values := g.allocRegisters(len(p.refs) + len(p.values))
+	for i := range p.refs {
+		g.emit(vm.OpcodeColumn, p.table, p.carried+i, values+i, 0, 0)
+	}
+	for i := range p.values {
+		g.emit(vm.OpcodeColumn, p.table, p.carried+len(p.refs)+i, values+len(p.refs)+i, 0, 0)
+	}
+	g.columnRegs = map[ColumnRef]int{}
+	for i, ref := range p.refs {
+		g.columnRegs[ref] = values + i
+	}
+	for i, call := range p.values {
+		g.accumulators[call] = values + len(p.refs) + i
+	}
+	for i, call := range p.calls {
+		g.accumulators[call] = p.res + i
+	}
+	if err := row(); err != nil {
+		return err
+	}
+	g.emit(vm.OpcodeGoto, 0, loop, 0, 0, 0)
+	g.resolve(done)
+
+	return nil
+}
+
+// windowScan generates code that computes the calls of the pass p with the given
+// indexes from each row of the frame, those after removed up to added, which are
+// visited in reverse if reverse is set, except those that the frame excludes.
+func (g *generator) windowScan(p *windowPass, calls []int, removed, added int, reverse bool) {
+	if len(calls) == 0 {
+		return
+	}
+
+	for _, i := range calls {
+		g.emit(vm.OpcodeNull, 0, p.acc+i, 0, 0, 0)
+	}
+	scan, done := g.newLabel(), g.newLabel()
+	if reverse {
+		// Negative P2 operands are labels, so the number is decremented by
+		// subtracting 1 rather than with AddImm.
+		one := g.allocRegister()
+		g.emit(vm.OpcodeInteger, 1, one, 0, 0, 0)
+		g.emit(vm.OpcodeSCopy, added, p.k, 0, 0, 0)
+		g.emit(vm.OpcodeAddImm, p.k, 1, 0, 0, 0)
+		g.resolve(scan)
+		g.emit(vm.OpcodeSubtract, one, p.k, p.k, 0, 0)
+		g.emit(vm.OpcodeLe, removed, done, p.k, 0, 0)
+	} else {
+		g.emit(vm.OpcodeSCopy, removed, p.k, 0, 0, 0)
+		g.resolve(scan)
+		g.emit(vm.OpcodeAddImm, p.k, 1, 0, 0, 0)
+		g.emit(vm.OpcodeGt, added, done, p.k, 0, 0)
+	}
+	g.emit(vm.OpcodeSeekRowid, p.table, done, p.k, 0, 0)
+	g.frameExclude(p, scan)
+	for _, i := range calls {
+		g.windowStep(p, i, vm.OpcodeAggStep)
+	}
+	g.emit(vm.OpcodeGoto, 0, scan, 0, 0, 0)
+	g.resolve(done)
+	for _, i := range calls {
+		call := p.calls[i]
+		g.emitStr(vm.OpcodeAggValue, p.acc+i, len(call.Args), p.res+i, strings.ToLower(call.Name), 0)
+	}
+}
+
+// frameOffset generates code that computes the offset x of a bound of the frame f
+// into the register target. Like in SQLite, the program fails unless it is a
+// non-negative integer, or for RANGE frames, a non-negative number.
+func (g *generator) frameOffset(f *ast.Frame, x ast.Expr, bound string, target int) error {
+	if err := g.expr(x, target); err != nil {
+		return err
+	}
+
+	fail, ok := g.newLabel(), g.newLabel()
+	msg := "frame " + bound + " offset must be a non-negative integer"
+	if f.Unit == ast.FrameRange {
+		// Text and blobs are greater than numbers.
+		msg = "frame " + bound + " offset must be a non-negative number"
+		empty := g.allocRegister()
+		g.emitStr(vm.OpcodeString8, 0, empty, 0, "", 0)
+		g.emitStr(vm.OpcodeGe, empty, fail, target, "", vm.AffinityNumeric|vm.JumpIfNull)
+	} else {
+		g.emit(vm.OpcodeMustBeInt, target, fail, 0, 0, 0)
+	}
+	zero := g.allocRegister()
+	g.emit(vm.OpcodeInteger, 0, zero, 0, 0, 0)
+	g.emitStr(vm.OpcodeGe, zero, ok, target, "", vm.AffinityNumeric)
+	g.resolve(fail)
+	g.emitStr(vm.OpcodeHalt, 1, 0, 0, msg, 0)
+	g.resolve(ok)
+
+	return nil
+}
+
+// frameBound generates code that computes the bound b of the frame of the current
+// row of the pass p, whose offset is in the register off, and returns the
+// register that holds it: the number of a row, for ROWS frames, that of a group
+// of peers, for GROUPS frames and the current row of RANGE frames, or else an
+// ORDER BY term. Like in SQLite, text and blobs are their own bounds, as are
+// NULLs, so that the frames of the rows whose ORDER BY term is NULL are their
+// peers.
+func (g *generator) frameBound(p *windowPass, b ast.FrameBound, off int) int {
+	f := p.def.Frame
+	switch {
+	case b.Kind == ast.UnboundedPreceding || b.Kind == ast.UnboundedFollowing:
+		return 0
+	case b.Kind == ast.CurrentRow && f.Unit == ast.FrameRows:
+		return p.cur
+	case b.Kind == ast.CurrentRow:
+		return p.group
+	}
+
+	// The bounds of RANGE frames of descending ORDER BY terms are reversed.
+	op := vm.OpcodeAdd
+	if (b.Kind == ast.Preceding) != (f.Unit == ast.FrameRange && p.def.OrderBy[0].Order == ast.SortDesc) {
+		op = vm.OpcodeSubtract
+	}
+	target := g.allocRegister()
+	switch f.Unit {
+	case ast.FrameRows:
+		g.emit(op, off, p.cur, target, 0, 0)
+	case ast.FrameGroups:
+		g.emit(op, off, p.group, target, 0, 0)
+	default:
+		skip := g.newLabel()
+		empty := g.allocRegister()
+		g.emit(vm.OpcodeSCopy, p.key, target, 0, 0, 0)
+		g.emitStr(vm.OpcodeString8, 0, empty, 0, "", 0)
+		g.emitStr(vm.OpcodeGe, empty, skip, target, "", 0)
+		g.emit(op, off, target, target, 0, 0)
+		g.resolve(skip)
+	}
+
+	return target
+}
+
+// frameTest generates code that jumps to label if the row that the table of the
+// pass p is at, whose number is in p.k, is after the bound b of the frame, which
+// is in the register bound, if end is set, or is not before it otherwise.
+func (g *generator) frameTest(p *windowPass, b ast.FrameBound, bound, label int, end bool) {
+	f := p.def.Frame
+	op := vm.OpcodeGe
+	if end {
+		op = vm.OpcodeGt
+	}
+	switch {
+	case f.Unit == ast.FrameRows:
+		g.emit(op, bound, label, p.k, 0, 0)
+	case f.Unit == ast.FrameGroups || b.Kind == ast.CurrentRow:
+		g.emit(vm.OpcodeColumn, p.table, 0, p.cand, 0, 0)
+		g.emit(op, bound, label, p.cand, 0, 0)
+	default:
+		g.emit(vm.OpcodeColumn, p.table, 1, p.cand, 0, 0)
+		g.append(vm.NewInstructionKeyInfo(vm.OpcodeCompare, p.cand, bound, 1, g.windowKeyInfo(nil, p.def.OrderBy[:1]), 0))
+		// Only the P2 operands of jumps are labels, so the others jump to a
+		// Goto.
+		jump := len(g.instructions) + 1
+		next := jump + 1
+		if end {
+			g.emit(vm.OpcodeJump, next, next, jump, 0, 0)
+		} else {
+			g.emit(vm.OpcodeJump, next, label, jump, 0, 0)
+		}
+		g.emit(vm.OpcodeGoto, 0, label, 0, 0, 0)
+	}
+}
+
+// frameExclude generates code that jumps to label if the row that the table of
+// the pass p is at, whose number is in p.k, is excluded from the frame of the
+// current row by its EXCLUDE clause.
+func (g *generator) frameExclude(p *windowPass, label int) {
+	switch p.def.Frame.Exclude {
+	case ast.ExcludeCurrentRow:
+		g.emit(vm.OpcodeEq, p.cur, label, p.k, 0, 0)
+	case ast.ExcludeGroup:
+		g.emit(vm.OpcodeColumn, p.table, 0, p.cand, 0, 0)
+		g.emit(vm.OpcodeEq, p.group, label, p.cand, 0, 0)
+	case ast.ExcludeTies:
+		// The current row is the only one of its group that is not excluded.
+		step := g.newLabel()
+		g.emit(vm.OpcodeColumn, p.table, 0, p.cand, 0, 0)
+		g.emit(vm.OpcodeNe, p.group, step, p.cand, 0, 0)
+		g.emit(vm.OpcodeNe, p.cur, label, p.k, 0, 0)
+		g.resolve(step)
+	}
+}
+
+// windowStep generates code that adds the row that the table of the pass p is at
+// to the accumulator of its i-th call, or removes it, if op is AggInverse, unless
+// the FILTER clause of the call is not true for it.
+func (g *generator) windowStep(p *windowPass, i int, op vm.Opcode) {
+	call := p.calls[i]
+	skip := g.newLabel()
+	if call.Filter != nil {
+		filter := g.allocRegister()
+		g.emit(vm.OpcodeColumn, p.table, p.inputs[i]+len(call.Args), filter, 0, 0)
+		g.emit(vm.OpcodeIfNot, filter, skip, 1, 0, 0)
+	}
+
+	args := g.allocRegisters(len(call.Args))
+	for j := range call.Args {
+		g.emit(vm.OpcodeColumn, p.table, p.inputs[i]+j, args+j, 0, 0)
+	}
+	name := strings.ToLower(call.Name)
+	if p.minMax(i) && op == vm.OpcodeAggStep {
+		g.emitStr(vm.OpcodeCollSeq, 0, 0, 0, g.collation(call.Args[0]), 0)
+	}
+	g.emitStr(op, 0, args, p.acc+i, name, len(call.Args))
+	g.resolve(skip)
+}
@@ -0,0 +1,29 @@
+package parser
+
+// SyntaxError is returned when a query is not valid SQL. It is formatted like
+// SQLite's error messages, f.e.: near "FORM": syntax error
+type SyntaxError struct {
+	// Line and Column are the position of the offending token. Lines are
+	// numbered from 1 and columns, in characters, from 0.
+	Line   int
+	Column int
+	// Token is the text of the offending token. It is empty if the query
+	// ended unexpectedly.
+	Token string
+	// Unrecognized is set if Token is not a valid token, such as an
+	// unterminated string literal.
+	Unrecognized bool
+	// Expected lists the names of the tokens that were valid in place of Token.
+	Expected []string
+}
+
+func (e *SyntaxError) Error() string {
+	switch {
+	case e.Unrecognized:
+		return `unrecognized token: "` + e.Token + `"`
+	case e.Token == "":
+		return "incomplete input"
+	default:
+		return `near "` + e.Token + `": syntax error`
+	}
+}
@@ -0,0 +1,579 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/colinking/go-sqlite3-native/ast"
+)
+
+// tokenType identifies the kind of a token.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenSelect
+	tokenFrom
+	tokenWhere
+	tokenOrder
+	tokenBy
+	tokenAsc
+	tokenDesc
+	tokenLimit
+	tokenCast
+	tokenAs
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLike
+	tokenGlob
+	tokenRegexp
+	tokenEscape
+	tokenGroup
+	tokenHaving
+	tokenDistinct
+	tokenCollate
+	tokenJoin
+	tokenOn
+	tokenUsing
+	tokenIn
+	tokenExists
+	tokenUnion
+	tokenAll
+	tokenIntersect
+	tokenExcept
+	tokenWith
+	tokenRecursive
+	tokenBetween
+	tokenCase
+	tokenWhen
+	tokenThen
+	tokenElse
+	tokenEnd
+	tokenIs
+	tokenNull
+	tokenIsNull
+	tokenNotNull
+	tokenStar
+	tokenPlaceholder
+	tokenEqual
+	tokenNotEqual
+	tokenGreater
+	tokenGreaterEqual
+	tokenLess
+	tokenLessEqual
+	tokenPlus
+	tokenMinus
+	tokenSlash
+	tokenPercent
+	tokenConcat
+	tokenAmpersand
+	tokenPipe
+	tokenShiftLeft
+	tokenShiftRight
+	tokenTilde
+	tokenNumber
+	tokenStringLiteral
+	tokenBlobLiteral
+	tokenPragmaTableInfo
+	tokenIdentifier
+	tokenComma
+	tokenDot
+	tokenLParen
+	tokenRParen
+	tokenSemicolon
+)
+
+// tokenNames are the names of each token type, as used in SyntaxError.Expected.
+var tokenNames = [...]string{
+	tokenEOF:             "<EOF>",
+	tokenSelect:          "Select",
+	tokenFrom:            "From",
+	tokenWhere:           "Where",
+	tokenOrder:           "Order",
+	tokenBy:              "By",
+	tokenAsc:             "Asc",
+	tokenDesc:            "Desc",
+	tokenLimit:           "Limit",
+	tokenCast:            "Cast",
+	tokenAs:              "As",
+	tokenAnd:             "And",
+	tokenOr:              "Or",
+	tokenNot:             "Not",
+	tokenLike:            "Like",
+	tokenGlob:            "Glob",
+	tokenRegexp:          "Regexp",
+	tokenEscape:          "Escape",
+	tokenGroup:           "Group",
+	tokenHaving:          "Having",
+	tokenDistinct:        "Distinct",
+	tokenCollate:         "Collate",
+	tokenJoin:            "Join",
+	tokenOn:              "On",
+	tokenUsing:           "Using",
+	tokenIn:              "In",
+	tokenExists:          "Exists",
+	tokenUnion:           "Union",
+	tokenAll:             "All",
+	tokenIntersect:       "Intersect",
+	tokenExcept:          "Except",
+	tokenWith:            "With",
+	tokenRecursive:       "Recursive",
+	tokenBetween:         "Between",
+	tokenCase:            "Case",
+	tokenWhen:            "When",
+	tokenThen:            "Then",
+	tokenElse:            "Else",
+	tokenEnd:             "End",
+	tokenIs:              "Is",
+	tokenNull:            "Null",
+	tokenIsNull:          "IsNull",
+	tokenNotNull:         "NotNull",
+	tokenStar:            "*",
+	tokenPlaceholder:     "Placeholder",
+	tokenEqual:           "Equal",
+	tokenNotEqual:        "NotEqual",
+	tokenGreater:         ">",
+	tokenGreaterEqual:    ">=",
+	tokenLess:            "<",
+	tokenLessEqual:       "<=",
+	tokenPlus:            "+",
+	tokenMinus:           "-",
+	tokenSlash:           "/",
+	tokenPercent:         "%",
+	tokenConcat:          "||",
+	tokenAmpersand:       "&",
+	tokenPipe:            "|",
+	tokenShiftLeft:       "<<",
+	tokenShiftRight:      ">>",
+	tokenTilde:           "~",
+	tokenNumber:          "Number",
+	tokenStringLiteral:   "StringLiteral",
+	tokenBlobLiteral:     "BlobLiteral",
+	tokenPragmaTableInfo: "PragmaTableInfo",
+	tokenIdentifier:      "Identifier",
+	tokenComma:           ",",
+	tokenDot:             ".",
+	tokenLParen:          "(",
+	tokenRParen:          ")",
+	tokenSemicolon:       ";",
+}
+
+func (t tokenType) String() string {
+	return tokenNames[t]
+}
+
+// keywords are the words that are lexed as keywords rather than identifiers,
+// regardless of their case.
+var keywords = []struct {
+	word string
+	typ  tokenType
+}{
+	{"SELECT", tokenSelect},
+	{"FROM", tokenFrom},
+	{"WHERE", tokenWhere},
+	{"ORDER", tokenOrder},
+	{"BY", tokenBy},
+	{"ASC", tokenAsc},
+	{"DESC", tokenDesc},
+	{"LIMIT", tokenLimit},
+	{"CAST", tokenCast},
+	{"AS", tokenAs},
+	{"AND", tokenAnd},
+	{"OR", tokenOr},
+	{"NOT", tokenNot},
+	{"LIKE", tokenLike},
+	{"GLOB", tokenGlob},
+	{"REGEXP", tokenRegexp},
+	{"ESCAPE", tokenEscape},
+	{"GROUP", tokenGroup},
+	{"HAVING", tokenHaving},
+	{"DISTINCT", tokenDistinct},
+	{"COLLATE", tokenCollate},
+	{"JOIN", tokenJoin},
+	{"ON", tokenOn},
+	{"USING", tokenUsing},
+	{"IN", tokenIn},
+	{"EXISTS", tokenExists},
+	{"UNION", tokenUnion},
+	{"ALL", tokenAll},
+	{"INTERSECT", tokenIntersect},
+	{"EXCEPT", tokenExcept},
+	{"WITH", tokenWith},
+	{"RECURSIVE", tokenRecursive},
+	{"BETWEEN", tokenBetween},
+	{"CASE", tokenCase},
+	{"WHEN", tokenWhen},
+	{"THEN", tokenThen},
+	{"ELSE", tokenElse},
+	{"END", tokenEnd},
+	{"IS", tokenIs},
+	{"NULL", tokenNull},
+	{"ISNULL", tokenIsNull},
+	{"NOTNULL", tokenNotNull},
+	{"PRAGMA_TABLE_INFO", tokenPragmaTableInfo},
+}
+
+type token struct {
+	typ tokenType
+	// text is the token's source text. It is empty for tokenEOF.
+	text string
+	pos  ast.Pos
+}
+
+// lexer splits a query into tokens, always matching the longest possible token:
+//
+//   - Keywords are case-insensitive.
+//   - Identifiers start with a letter, "_" or a non-ASCII character in the Basic
+//     Multilingual Plane, followed by any of those, digits or "$". They may also
+//     be quoted with "double quotes", [brackets] or `backticks`.
+//   - Numbers are integers, decimals with an optional exponent, f.e. 1.5e3 or .5,
+//     or hexadecimal integers, f.e. 0x1F.
+//   - Strings are 'single-quoted' and blobs are X'hex', f.e. X'0aFF'.
+//   - Parameters are ?, ?NNN, :AAAA, @AAAA or $AAAA.
+//   - Quotes are escaped by doubling them, except within [brackets].
+//   - Whitespace, -- line comments and /* block comments */ are skipped.
+type lexer struct {
+	src string
+
+	// offset, line and column are the position of the next character.
+	offset int
+	line   int
+	column int
+}
+
+func newLexer(src string) lexer {
+	return lexer{src: src, line: 1}
+}
+
+func (l *lexer) pos() ast.Pos {
+	return ast.Pos{Offset: l.offset, Line: l.line, Column: l.column}
+}
+
+// peek returns the character n bytes ahead of the next character, or -1 at the
+// end of the query. Only ASCII characters are returned, any other character is
+// returned as utf8.RuneSelf.
+func (l *lexer) peek(n int) rune {
+	if l.offset+n >= len(l.src) {
+		return -1
+	}
+
+	if c := l.src[l.offset+n]; c < utf8.RuneSelf {
+		return rune(c)
+	}
+
+	return utf8.RuneSelf
+}
+
+// advance moves past the next n bytes, which must end on a character boundary.
+func (l *lexer) advance(n int) {
+	end := l.offset + n
+	for l.offset < end {
+		c := l.src[l.offset]
+		switch {
+		case c == '\n':
+			l.line++
+			l.column = 0
+			l.offset++
+		case c < utf8.RuneSelf:
+			l.column++
+			l.offset++
+		default:
+			_, size := utf8.DecodeRuneInString(l.src[l.offset:])
+			l.column++
+			l.offset += size
+		}
+	}
+}
+
+// next returns the next token. At the end of the query, a tokenEOF is returned.
+//
+// If the next characters are not a valid token, a *SyntaxError is returned and
+// the lexer moves past the character at which the token became invalid.
+func (l *lexer) next() (token, error) {
+	l.skip()
+
+	start := l.pos()
+	typ, n, ok := l.scan()
+	if !ok {
+		// The text of an unrecognized token includes the character that could
+		// not be matched.
+		if l.offset+n < len(l.src) {
+			_, size := utf8.DecodeRuneInString(l.src[l.offset+n:])
+			n += size
+		}
+		text := l.src[l.offset : l.offset+n]
+		l.advance(n)
+
+		return token{}, &SyntaxError{
+			Line:         start.Line,
+			Column:       start.Column,
+			Token:        text,
+			Unrecognized: true,
+		}
+	}
+
+	text := l.src[l.offset : l.offset+n]
+	l.advance(n)
+
+	if typ == tokenIdentifier {
+		typ = keyword(text)
+	}
+
+	return token{typ: typ, text: text, pos: start}, nil
+}
+
+// skip moves past any whitespace and comments.
+func (l *lexer) skip() {
+	for {
+		switch c := l.peek(0); {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			l.advance(1)
+		case c == '-' && l.peek(1) == '-':
+			end := strings.IndexAny(l.src[l.offset:], "\r\n")
+			if end < 0 {
+				end = len(l.src) - l.offset
+			}
+			l.advance(end)
+		case c == '/' && l.peek(1) == '*':
+			// Unterminated block comments extend to the end of the query.
+			end := strings.Index(l.src[l.offset+2:], "*/")
+			if end < 0 {
+				l.advance(len(l.src) - l.offset)
+			} else {
+				l.advance(end + 4)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// scan returns the type and length in bytes of the token at the next character,
+// without consuming it. If there is no valid token, ok is false and n is the
+// length of the valid prefix.
+func (l *lexer) scan() (typ tokenType, n int, ok bool) {
+	c := l.peek(0)
+	switch {
+	case c < 0:
+		return tokenEOF, 0, true
+	case c == 'x' || c == 'X':
+		if l.peek(1) == '\'' {
+			if n, ok := l.scanBlob(); ok {
+				return tokenBlobLiteral, n, true
+			}
+		}
+		return tokenIdentifier, l.scanIdentifierChars(0), true
+	case isIdentifierStart(c):
+		n := l.scanIdentifierChars(0)
+		if n == 0 {
+			// Characters outside of the Basic Multilingual Plane are not
+			// allowed in identifiers.
+			return 0, 0, false
+		}
+		return tokenIdentifier, n, true
+	case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+		return tokenNumber, l.scanNumber(), true
+	}
+
+	switch c {
+	case '\'':
+		return l.scanQuoted(tokenStringLiteral, '\'')
+	case '"':
+		return l.scanQuoted(tokenIdentifier, '"')
+	case '`':
+		return l.scanQuoted(tokenIdentifier, '`')
+	case '[':
+		end := strings.IndexByte(l.src[l.offset:], ']')
+		if end < 0 {
+			return 0, len(l.src) - l.offset, false
+		}
+		return tokenIdentifier, end + 1, true
+	case '?':
+		n := 1
+		for isDigit(l.peek(n)) {
+			n++
+		}
+		return tokenPlaceholder, n, true
+	case ':', '@', '$':
+		n := l.scanIdentifierChars(1)
+		if n == 1 {
+			return 0, 1, false
+		}
+		return tokenPlaceholder, n, true
+	case '*':
+		return tokenStar, 1, true
+	case '=':
+		if l.peek(1) == '=' {
+			return tokenEqual, 2, true
+		}
+		return tokenEqual, 1, true
+	case '!':
+		if l.peek(1) == '=' {
+			return tokenNotEqual, 2, true
+		}
+		return 0, 1, false
+	case '<':
+		switch l.peek(1) {
+		case '=':
+			return tokenLessEqual, 2, true
+		case '>':
+			return tokenNotEqual, 2, true
+		case '<':
+			return tokenShiftLeft, 2, true
+		}
+		return tokenLess, 1, true
+	case '>':
+		switch l.peek(1) {
+		case '=':
+			return tokenGreaterEqual, 2, true
+		case '>':
+			return tokenShiftRight, 2, true
+		}
+		return tokenGreater, 1, true
+	case '|':
+		if l.peek(1) == '|' {
+			return tokenConcat, 2, true
+		}
+		return tokenPipe, 1, true
+	case '+':
+		return tokenPlus, 1, true
+	case '-':
+		return tokenMinus, 1, true
+	case '/':
+		return tokenSlash, 1, true
+	case '%':
+		return tokenPercent, 1, true
+	case '&':
+		return tokenAmpersand, 1, true
+	case '~':
+		return tokenTilde, 1, true
+	case ',':
+		return tokenComma, 1, true
+	case '.':
+		return tokenDot, 1, true
+	case '(':
+		return tokenLParen, 1, true
+	case ')':
+		return tokenRParen, 1, true
+	case ';':
+		return tokenSemicolon, 1, true
+	}
+
+	return 0, 0, false
+}
+
+// scanIdentifierChars returns the length of the run of identifier characters that
+// starts n bytes after the next character, plus n.
+func (l *lexer) scanIdentifierChars(n int) int {
+	for l.offset+n < len(l.src) {
+		c := l.src[l.offset+n]
+		if c < utf8.RuneSelf {
+			if !isIdentifierStart(rune(c)) && !isDigit(rune(c)) && c != '$' {
+				break
+			}
+			n++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(l.src[l.offset+n:])
+		if r > 0xFFFF {
+			break
+		}
+		n += size
+	}
+
+	return n
+}
+
+// scanNumber returns the length of the numeric literal at the next character.
+func (l *lexer) scanNumber() int {
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') && isHexDigit(l.peek(2)) {
+		n := 3
+		for isHexDigit(l.peek(n)) {
+			n++
+		}
+		return n
+	}
+
+	n := 0
+	for isDigit(l.peek(n)) {
+		n++
+	}
+	if l.peek(n) == '.' {
+		n++
+		for isDigit(l.peek(n)) {
+			n++
+		}
+	}
+
+	// The exponent is only part of the number if it contains a digit.
+	if c := l.peek(n); c == 'e' || c == 'E' {
+		e := n + 1
+		if c := l.peek(e); c == '+' || c == '-' {
+			e++
+		}
+		if isDigit(l.peek(e)) {
+			n = e
+			for isDigit(l.peek(n)) {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// scanBlob returns the length of the blob literal at the next character, or
+// false if it is not a valid blob literal.
+func (l *lexer) scanBlob() (int, bool) {
+	n := 2
+	for isHexDigit(l.peek(n)) && isHexDigit(l.peek(n+1)) {
+		n += 2
+	}
+	if l.peek(n) != '\'' {
+		return 0, false
+	}
+
+	return n + 1, true
+}
+
+// scanQuoted returns the length of the token at the next character that is
+// enclosed in quote characters, where a doubled quote is an escaped quote.
+func (l *lexer) scanQuoted(typ tokenType, quote byte) (tokenType, int, bool) {
+	n := 1
+	for {
+		end := strings.IndexByte(l.src[l.offset+n:], quote)
+		if end < 0 {
+			return 0, len(l.src) - l.offset, false
+		}
+		n += end + 1
+		if l.offset+n >= len(l.src) || l.src[l.offset+n] != quote {
+			return typ, n, true
+		}
+		n++
+	}
+}
+
+// keyword returns the type of the keyword that word spells, or tokenIdentifier.
+func keyword(word string) tokenType {
+	for _, k := range keywords {
+		if len(k.word) == len(word) && strings.EqualFold(k.word, word) {
+			return k.typ
+		}
+	}
+
+	return tokenIdentifier
+}
+
+func isIdentifierStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= utf8.RuneSelf
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
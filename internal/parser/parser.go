@@ -1,54 +1,1385 @@
 package parser
 
 import (
-	"github.com/antlr/antlr4/runtime/Go/antlr"
-	"github.com/colinking/go-sqlite3-native/internal/parser/generated"
-	"github.com/colinking/go-sqlite3-native/internal/vm"
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/ast"
 )
 
-//go:generate antlr -Dlanguage=Go -o generated -package generated SQL.g4
+// Parse parses query, which may contain multiple statements, into an AST. A
+// *SyntaxError is returned if query is not valid SQL.
+//
+// This is a hand-written recursive-descent parser. The grammar that it accepts is
+// documented on each production, in ANTLR syntax. For more on the SQLite grammar,
+// see the official, Lemon-based, grammar: https://github.com/sqlite/sqlite/blob/master/src/parse.y
+func Parse(query string) (statements []ast.Statement, err error) {
+	p := parser{lexer: newLexer(query)}
+
+	// Syntax errors abort parsing by panicking with a *SyntaxError, which
+	// avoids threading errors through every production.
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			statements, err = nil, serr
+		}
+	}()
+
+	p.next()
+	return p.parseStart(), nil
+}
+
+type parser struct {
+	lexer lexer
+	// tok is the current token.
+	tok token
+}
+
+// next advances to the next token.
+func (p *parser) next() {
+	tok, err := p.lexer.next()
+	if err != nil {
+		panic(err)
+	}
+	p.tok = tok
+}
+
+// peek returns the type of the token after the current one, without advancing.
+// If that token is not valid, tokenEOF is returned: the error is reported once
+// the parser advances to it.
+func (p *parser) peek() tokenType {
+	l := p.lexer
+	tok, _ := l.next()
+
+	return tok.typ
+}
+
+// errorExpected aborts parsing with a syntax error at the current token, which is
+// not one of the expected token types.
+func (p *parser) errorExpected(expected ...tokenType) {
+	serr := &SyntaxError{
+		Line:     p.tok.pos.Line,
+		Column:   p.tok.pos.Column,
+		Token:    p.tok.text,
+		Expected: make([]string, len(expected)),
+	}
+	for i, typ := range expected {
+		serr.Expected[i] = typ.String()
+	}
+
+	panic(serr)
+}
+
+// expect consumes the current token, which must be of type typ.
+func (p *parser) expect(typ tokenType) token {
+	if p.tok.typ != typ {
+		p.errorExpected(typ)
+	}
+	tok := p.tok
+	p.next()
+
+	return tok
+}
+
+// parseStart parses:
+//
+//	start
+//	  : statement? (Semicolon statement?)* EOF
+//	  ;
+func (p *parser) parseStart() []ast.Statement {
+	statements := []ast.Statement{}
+	for {
+		switch {
+		case p.tok.typ == tokenWith, p.tok.typ == tokenSelect, p.atWord("PRAGMA"):
+			statements = append(statements, p.parseStatement())
+		case p.tok.typ == tokenSemicolon, p.tok.typ == tokenEOF:
+		default:
+			p.errorExpected(tokenEOF, tokenWith, tokenSelect, tokenSemicolon)
+		}
+
+		switch p.tok.typ {
+		case tokenSemicolon:
+			p.next()
+		case tokenEOF:
+			return statements
+		default:
+			p.errorExpected(tokenEOF, tokenSemicolon)
+		}
+	}
+}
+
+// parseStatement parses:
+//
+//	statement
+//	  : select
+//	  | pragma
+//	  ;
+func (p *parser) parseStatement() ast.Statement {
+	if p.atWord("PRAGMA") {
+		return p.parsePragma()
+	}
+
+	return p.parseSelect()
+}
+
+// parsePragma parses:
+//
+//	pragma
+//	  : Pragma (Identifier Dot)? Identifier (Equal pragmaValue | LParen pragmaValue RParen)?
+//	  ;
+//
+//	pragmaValue
+//	  : signedNumber
+//	  | StringLiteral
+//	  | Identifier
+//	  | On
+//	  ;
+//
+// PRAGMA is not a reserved keyword, like in SQLite.
+func (p *parser) parsePragma() *ast.PragmaStatement {
+	stmt := &ast.PragmaStatement{Pragma: p.tok.pos}
+	p.expectWord("PRAGMA")
+	stmt.Name = unquoteIdent(p.expect(tokenIdentifier).text)
+	if p.tok.typ == tokenDot {
+		p.next()
+		stmt.Schema, stmt.Name = stmt.Name, unquoteIdent(p.expect(tokenIdentifier).text)
+	}
+
+	switch p.tok.typ {
+	case tokenEqual:
+		p.next()
+		stmt.Value = p.parsePragmaValue()
+	case tokenLParen:
+		p.next()
+		stmt.Value = p.parsePragmaValue()
+		p.expect(tokenRParen)
+	}
+
+	return stmt
+}
+
+// parsePragmaValue parses a pragmaValue, as described by parsePragma. Names,
+// including ON, are returned as *ast.Idents.
+func (p *parser) parsePragmaValue() ast.Expr {
+	switch p.tok.typ {
+	case tokenPlus, tokenMinus:
+		op := &ast.UnaryExpr{OpPos: p.tok.pos, Op: ast.OpPlus}
+		if p.tok.typ == tokenMinus {
+			op.Op = ast.OpNeg
+		}
+		p.next()
+		op.X = p.parseNumber()
+		return op
+	case tokenNumber, tokenStringLiteral:
+		return p.parseValue()
+	case tokenIdentifier, tokenOn:
+		name := p.tok
+		p.next()
+		return &ast.Ident{NamePos: name.pos, Name: unquoteIdent(name.text)}
+	default:
+		p.errorExpected(tokenPlus, tokenMinus, tokenNumber, tokenStringLiteral, tokenIdentifier, tokenOn)
+		return nil
+	}
+}
+
+// parseSelect parses:
+//
+//	select
+//	  : with? selectCore compound* orderBy? limit?
+//	  ;
+func (p *parser) parseSelect() *ast.SelectStatement {
+	var with *ast.With
+	if p.tok.typ == tokenWith {
+		with = p.parseWith()
+	}
+	stmt := p.parseSelectCore()
+	stmt.With = with
+
+	// compound
+	//   : (Union All? | Intersect | Except) selectCore
+	//   ;
+	for p.tok.typ == tokenUnion || p.tok.typ == tokenIntersect || p.tok.typ == tokenExcept {
+		c := &ast.CompoundSelect{OpPos: p.tok.pos}
+		switch p.tok.typ {
+		case tokenUnion:
+			c.Op = ast.CompoundUnion
+		case tokenIntersect:
+			c.Op = ast.CompoundIntersect
+		case tokenExcept:
+			c.Op = ast.CompoundExcept
+		}
+		p.next()
+		if c.Op == ast.CompoundUnion && p.tok.typ == tokenAll {
+			c.Op = ast.CompoundUnionAll
+			p.next()
+		}
+		c.Select = p.parseSelectCore()
+		stmt.Compound = append(stmt.Compound, c)
+	}
+
+	if p.tok.typ == tokenOrder {
+		stmt.OrderBy = p.parseOrderBy()
+	}
+
+	// limit
+	//   : Limit expr ((Offset | Comma) expr)?
+	//   ;
+	//
+	// OFFSET is not a keyword, so that it can still be used as a name. In
+	// "LIMIT a, b", a is the offset.
+	if p.tok.typ == tokenLimit {
+		p.next()
+		stmt.Limit = p.parseExpr()
+		switch {
+		case p.tok.typ == tokenComma:
+			p.next()
+			stmt.Offset, stmt.Limit = stmt.Limit, p.parseExpr()
+		case p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "OFFSET"):
+			p.next()
+			stmt.Offset = p.parseExpr()
+		}
+	}
+
+	return stmt
+}
+
+// parseWith parses:
+//
+//	with
+//	  : With Recursive? cte (Comma cte)*
+//	  ;
+func (p *parser) parseWith() *ast.With {
+	with := &ast.With{With: p.expect(tokenWith).pos}
+	if p.tok.typ == tokenRecursive {
+		with.Recursive = true
+		p.next()
+	}
+	with.CTEs = []*ast.CTE{p.parseCTE()}
+	for p.tok.typ == tokenComma {
+		p.next()
+		with.CTEs = append(with.CTEs, p.parseCTE())
+	}
+
+	return with
+}
+
+// parseCTE parses:
+//
+//	cte
+//	  : Identifier (LParen Identifier (Comma Identifier)* RParen)? As (Not? Materialized)? subquery
+//	  ;
+//
+// MATERIALIZED is not a keyword, so that it can still be used as a name.
+func (p *parser) parseCTE() *ast.CTE {
+	name := p.expect(tokenIdentifier)
+	cte := &ast.CTE{NamePos: name.pos, Name: unquoteIdent(name.text)}
+	if p.tok.typ == tokenLParen {
+		p.next()
+		cte.Columns = []string{unquoteIdent(p.expect(tokenIdentifier).text)}
+		for p.tok.typ == tokenComma {
+			p.next()
+			cte.Columns = append(cte.Columns, unquoteIdent(p.expect(tokenIdentifier).text))
+		}
+		p.expect(tokenRParen)
+	}
+	p.expect(tokenAs)
+	materialized := func() bool {
+		return p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "MATERIALIZED")
+	}
+	if p.tok.typ == tokenNot {
+		p.next()
+		if !materialized() {
+			p.errorExpected(tokenIdentifier)
+		}
+		cte.Materialized = ast.NotMaterialized
+		p.next()
+	} else if materialized() {
+		cte.Materialized = ast.Materialized
+		p.next()
+	}
+	cte.Select = p.parseSubquery()
+
+	return cte
+}
 
-func Parse(query string) ([]vm.Instruction, error) {
-	// This parser is based on the antlr language and uses the official Go antlr runtime.
-	// For more information on how this works, see: https://blog.gopheracademy.com/advent-2017/parsing-with-antlr4-and-go/
-	// Further inspiration was taken from the unofficial SQLite antlr grammar: https://github.com/antlr/grammars-v4/blob/master/sql/sqlite/SQLite.g4
-	// Along with the official, but Lemon-based, SQLite grammar: https://github.com/sqlite/sqlite/blob/master/src/parse.y
+// parseSelectCore parses a SELECT without an ORDER BY or LIMIT clause:
+//
+//	selectCore
+//	  : Select (Distinct | All)? resultColumn (Comma resultColumn)* (From tables)? where? groupBy? having?
+//	  ;
+func (p *parser) parseSelectCore() *ast.SelectStatement {
+	stmt := &ast.SelectStatement{
+		Select: p.expect(tokenSelect).pos,
+	}
+	switch p.tok.typ {
+	case tokenDistinct:
+		stmt.Distinct = true
+		p.next()
+	case tokenAll:
+		p.next()
+	}
+	stmt.Columns = []*ast.ResultColumn{p.parseResultColumn()}
+	for p.tok.typ == tokenComma {
+		p.next()
+		stmt.Columns = append(stmt.Columns, p.parseResultColumn())
+	}
+	if p.tok.typ == tokenFrom {
+		p.next()
+		stmt.From = p.parseTables()
+	}
 
-	is := antlr.NewInputStream(query)
+	if p.tok.typ == tokenWhere {
+		stmt.Where = p.parseWhere()
+	}
 
-	// Create a lexer which can take arbitrary user-supplied strings and convert them
-	// into tokens that we can produce a parse tree on.
-	lexer := generated.NewSQLLexer(is)
-	stream := antlr.NewCommonTokenStream(lexer, antlr.LexerDefaultTokenChannel)
+	// groupBy
+	//   : Group By exprList
+	//   ;
+	if p.tok.typ == tokenGroup {
+		p.next()
+		p.expect(tokenBy)
+		stmt.GroupBy = p.parseExprList()
+	}
 
-	// Create a parser that can consume the list of tokens and produce a parse tree that we can walk:
-	parser := generated.NewSQLParser(stream)
+	// having
+	//   : Having expr
+	//   ;
+	if p.tok.typ == tokenHaving {
+		p.next()
+		stmt.Having = p.parseExpr()
+	}
 
-	// Create a listener that we will use to hook into antlr's runtime as it walks through
-	// the parse tree.
-	l := listener{
-		program: []vm.Instruction{},
+	// window
+	//   : Window namedWindow (Comma namedWindow)*
+	//   ;
+	if p.atWindowClause() {
+		p.next()
+		stmt.Windows = []*ast.NamedWindow{p.parseNamedWindow()}
+		for p.tok.typ == tokenComma {
+			p.next()
+			stmt.Windows = append(stmt.Windows, p.parseNamedWindow())
+		}
 	}
 
-	// Walk through the parse tree. This walk will invoke methods on the listener
-	// which we can catch in order to produce our bytecode program.
-	antlr.ParseTreeWalkerDefault.Walk(&l, parser.Start())
+	return stmt
+}
 
-	return l.program, nil
+// atWord returns true if the current token is the identifier word, which is a
+// keyword that is not reserved, f.e. OVER, so that it can still be used as a name.
+func (p *parser) atWord(word string) bool {
+	return p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, word)
 }
 
-type listener struct {
-	*generated.BaseSQLListener
+// expectWord consumes the current token, which must be the identifier word.
+func (p *parser) expectWord(word string) {
+	if !p.atWord(word) {
+		p.errorExpected(tokenIdentifier)
+	}
+	p.next()
+}
+
+// atWindowClause returns true if the current token starts a WINDOW clause. Like in
+// SQLite, WINDOW is only a keyword if it is followed by a name and AS, so that it
+// can still be used as a name, f.e. as the alias of a table.
+func (p *parser) atWindowClause() bool {
+	if !p.atWord("WINDOW") {
+		return false
+	}
+	l := p.lexer
+	name, _ := l.next()
+	as, _ := l.next()
+
+	return name.typ == tokenIdentifier && as.typ == tokenAs
+}
+
+// parseNamedWindow parses:
+//
+//	namedWindow
+//	  : Identifier As windowDefn
+//	  ;
+func (p *parser) parseNamedWindow() *ast.NamedWindow {
+	name := p.expect(tokenIdentifier)
+	p.expect(tokenAs)
+
+	return &ast.NamedWindow{NamePos: name.pos, Name: unquoteIdent(name.text), Window: p.parseWindowDefn()}
+}
+
+// parseWindow parses:
+//
+//	window
+//	  : Identifier
+//	  | windowDefn
+//	  ;
+func (p *parser) parseWindow() *ast.Window {
+	if p.tok.typ == tokenIdentifier {
+		name := p.expect(tokenIdentifier)
+		return &ast.Window{NamePos: name.pos, Name: unquoteIdent(name.text)}
+	}
+
+	return p.parseWindowDefn()
+}
+
+// parseWindowDefn parses:
+//
+//	windowDefn
+//	  : LParen Identifier? (Partition By exprList)? orderBy? frame? RParen
+//	  ;
+//
+// The identifier is the name of the window that this one is based on. PARTITION
+// and the units of frames are not keywords, so an identifier is only read as one
+// of them if it is followed by what follows them.
+func (p *parser) parseWindowDefn() *ast.Window {
+	w := &ast.Window{Lparen: p.expect(tokenLParen).pos}
+	if p.tok.typ == tokenIdentifier && !p.atPartitionBy() && !p.atFrame() {
+		w.Base = unquoteIdent(p.tok.text)
+		p.next()
+	}
+
+	if p.atPartitionBy() {
+		p.next()
+		p.next()
+		w.PartitionBy = p.parseExprList()
+	}
+	if p.tok.typ == tokenOrder {
+		w.OrderBy = p.parseOrderBy()
+	}
+	if p.atFrame() {
+		w.Frame = p.parseFrame()
+	}
+	p.expect(tokenRParen)
 
-	program []vm.Instruction
+	return w
 }
 
-var _ generated.SQLListener = &listener{}
+// atPartitionBy returns true if the current token starts a PARTITION BY clause.
+func (p *parser) atPartitionBy() bool {
+	return p.atWord("PARTITION") && p.peek() == tokenBy
+}
+
+// atFrame returns true if the current token starts a frame: it is a unit, followed
+// by BETWEEN or the start of a bound.
+func (p *parser) atFrame() bool {
+	if !p.atWord("RANGE") && !p.atWord("ROWS") && !p.atWord("GROUPS") {
+		return false
+	}
+	switch p.peek() {
+	case tokenBetween, tokenIdentifier, tokenNumber, tokenStringLiteral, tokenBlobLiteral, tokenPlaceholder, tokenCast, tokenLParen:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseFrame parses:
+//
+//	frame
+//	  : (Range | Rows | Groups) (frameBound | Between frameBound And frameBound) frameExclude?
+//	  ;
+//
+//	frameExclude
+//	  : Exclude (No Others | Current Row | Group | Ties)
+//	  ;
+//
+// A frame with a single bound ends at the current row.
+func (p *parser) parseFrame() *ast.Frame {
+	f := &ast.Frame{UnitPos: p.tok.pos}
+	switch {
+	case p.atWord("RANGE"):
+		f.Unit = ast.FrameRange
+	case p.atWord("ROWS"):
+		f.Unit = ast.FrameRows
+	default:
+		f.Unit = ast.FrameGroups
+	}
+	p.next()
+
+	if p.tok.typ == tokenBetween {
+		p.next()
+		f.Start = p.parseFrameBound(true)
+		p.expect(tokenAnd)
+		f.End = p.parseFrameBound(false)
+	} else {
+		f.Start = p.parseFrameBound(true)
+		f.End = ast.FrameBound{Kind: ast.CurrentRow}
+	}
+
+	if p.atWord("EXCLUDE") {
+		p.next()
+		switch {
+		case p.atWord("NO"):
+			p.next()
+			p.expectWord("OTHERS")
+			f.Exclude = ast.ExcludeNoOthers
+		case p.atWord("CURRENT"):
+			p.next()
+			p.expectWord("ROW")
+			f.Exclude = ast.ExcludeCurrentRow
+		case p.tok.typ == tokenGroup:
+			p.next()
+			f.Exclude = ast.ExcludeGroup
+		default:
+			p.expectWord("TIES")
+			f.Exclude = ast.ExcludeTies
+		}
+	}
+
+	return f
+}
+
+// parseFrameBound parses the start of a frame, if start is set, or its end:
+//
+//	frameBound
+//	  : Unbounded (Preceding | Following)
+//	  | Current Row
+//	  | expr (Preceding | Following)
+//	  ;
+//
+// A frame cannot start with UNBOUNDED FOLLOWING, nor end with UNBOUNDED PRECEDING.
+func (p *parser) parseFrameBound(start bool) ast.FrameBound {
+	switch {
+	case p.atWord("UNBOUNDED") && p.peek() == tokenIdentifier:
+		p.next()
+		if start {
+			p.expectWord("PRECEDING")
+			return ast.FrameBound{Kind: ast.UnboundedPreceding}
+		}
+		p.expectWord("FOLLOWING")
+		return ast.FrameBound{Kind: ast.UnboundedFollowing}
+	case p.atWord("CURRENT") && p.peek() == tokenIdentifier:
+		p.next()
+		p.expectWord("ROW")
+		return ast.FrameBound{Kind: ast.CurrentRow}
+	}
+
+	b := ast.FrameBound{Offset: p.parseExpr()}
+	switch {
+	case p.atWord("PRECEDING"):
+		b.Kind = ast.Preceding
+	case p.atWord("FOLLOWING"):
+		b.Kind = ast.Following
+	default:
+		p.errorExpected(tokenIdentifier)
+	}
+	p.next()
+
+	return b
+}
+
+// parseOrderBy parses:
+//
+//	orderBy
+//	  : Order By orderingTerm (Comma orderingTerm)*
+//	  ;
+func (p *parser) parseOrderBy() []*ast.OrderingTerm {
+	p.expect(tokenOrder)
+	p.expect(tokenBy)
+	terms := []*ast.OrderingTerm{p.parseOrderingTerm()}
+	for p.tok.typ == tokenComma {
+		p.next()
+		terms = append(terms, p.parseOrderingTerm())
+	}
+
+	return terms
+}
+
+// parseOrderingTerm parses:
+//
+//	orderingTerm
+//	  : expr (Asc | Desc)? (Nulls (First | Last))?
+//	  ;
+//
+// NULLS, FIRST and LAST are not keywords, so that they can still be used as names.
+func (p *parser) parseOrderingTerm() *ast.OrderingTerm {
+	term := &ast.OrderingTerm{Expr: p.parseExpr()}
+
+	switch p.tok.typ {
+	case tokenAsc:
+		term.Order = ast.SortAsc
+		p.next()
+	case tokenDesc:
+		term.Order = ast.SortDesc
+		p.next()
+	}
+
+	if p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "NULLS") {
+		p.next()
+		switch {
+		case p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "FIRST"):
+			term.Nulls = ast.NullsFirst
+		case p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "LAST"):
+			term.Nulls = ast.NullsLast
+		default:
+			p.errorExpected(tokenIdentifier)
+		}
+		p.next()
+	}
 
-// EnterStart is called when production start is entered.
-func (s *listener) EnterStart(ctx *generated.StartContext) {
-	// TODO: add the basic structure
+	return term
 }
 
-// ExitStart is called when production start is exited.
-func (s *listener) ExitStart(ctx *generated.StartContext) {}
+// parseResultColumn parses:
+//
+//	resultColumn
+//	  : Star
+//	  | Identifier Dot Star
+//	  | expr alias?
+//	  ;
+func (p *parser) parseResultColumn() *ast.ResultColumn {
+	switch p.tok.typ {
+	case tokenStar:
+		column := &ast.ResultColumn{Star: true, StarPos: p.tok.pos}
+		p.next()
+		return column
+	case tokenIdentifier:
+		first := p.tok
+		p.next()
+		if p.tok.typ == tokenDot && p.peek() == tokenStar {
+			p.next()
+			p.next()
+			return &ast.ResultColumn{Star: true, StarPos: first.pos, Table: unquoteIdent(first.text)}
+		}
+		expr := p.parseExprAfter(p.parseOperandAfter(first), 1)
+		return &ast.ResultColumn{Expr: expr, Alias: p.parseAlias()}
+	default:
+		return &ast.ResultColumn{Expr: p.parseExpr(), Alias: p.parseAlias()}
+	}
+}
+
+// parseTables parses:
+//
+//	tables
+//	  : table (joinOperator table joinConstraint?)*
+//	  ;
+//
+//	joinConstraint
+//	  : On expr
+//	  | Using LParen Identifier (Comma Identifier)* RParen
+//	  ;
+//
+// The tables are joined by left-associative *ast.Joins.
+func (p *parser) parseTables() ast.TableRef {
+	from := p.parseTable()
+	for p.tok.typ == tokenComma || p.tok.typ == tokenJoin || joinKeyword(p.tok) != "" {
+		join := &ast.Join{X: from, OpPos: p.tok.pos}
+		p.parseJoinOperator(join)
+		join.Y = p.parseTable()
+
+		switch p.tok.typ {
+		case tokenOn:
+			p.next()
+			join.On = p.parseExpr()
+		case tokenUsing:
+			p.next()
+			p.expect(tokenLParen)
+			join.Using = []string{unquoteIdent(p.expect(tokenIdentifier).text)}
+			for p.tok.typ == tokenComma {
+				p.next()
+				join.Using = append(join.Using, unquoteIdent(p.expect(tokenIdentifier).text))
+			}
+			p.expect(tokenRParen)
+		}
+		from = join
+	}
+
+	return from
+}
+
+// parseJoinOperator parses:
+//
+//	joinOperator
+//	  : Comma
+//	  | Natural? (Left Outer? | Right Outer? | Full Outer? | Inner | Cross)? Join
+//	  ;
+//
+// NATURAL, LEFT, RIGHT, FULL, OUTER, INNER and CROSS are not keywords, so that
+// they can still be used as names.
+func (p *parser) parseJoinOperator(join *ast.Join) {
+	if p.tok.typ == tokenComma {
+		join.Op = ast.JoinComma
+		p.next()
+		return
+	}
+
+	join.Op = ast.JoinInner
+	if joinKeyword(p.tok) == "NATURAL" {
+		join.Natural = true
+		p.next()
+	}
+	outer := true
+	switch joinKeyword(p.tok) {
+	case "LEFT":
+		join.Op = ast.JoinLeft
+	case "RIGHT":
+		join.Op = ast.JoinRight
+	case "FULL":
+		join.Op = ast.JoinFull
+	case "INNER":
+		outer = false
+	case "CROSS":
+		join.Op, outer = ast.JoinCross, false
+	default:
+		p.expect(tokenJoin)
+		return
+	}
+	p.next()
+	if outer && joinKeyword(p.tok) == "OUTER" {
+		p.next()
+	}
+	p.expect(tokenJoin)
+}
+
+// joinKeyword returns the upper-cased keyword of a join operator that tok spells,
+// if it is an unquoted identifier, or else "".
+func joinKeyword(tok token) string {
+	if tok.typ != tokenIdentifier {
+		return ""
+	}
+	switch word := strings.ToUpper(tok.text); word {
+	case "NATURAL", "LEFT", "RIGHT", "FULL", "OUTER", "INNER", "CROSS":
+		return word
+	}
+
+	return ""
+}
+
+// parseTable parses:
+//
+//	table
+//	  : (Identifier Dot)? Identifier alias?
+//	  | PragmaTableInfo LParen Placeholder RParen alias?
+//	  | subquery alias?
+//	  ;
+func (p *parser) parseTable() ast.TableRef {
+	switch p.tok.typ {
+	case tokenIdentifier:
+		name := &ast.TableName{NamePos: p.tok.pos, Name: unquoteIdent(p.tok.text)}
+		p.next()
+		if p.tok.typ == tokenDot {
+			p.next()
+			name.Schema, name.Name = name.Name, unquoteIdent(p.expect(tokenIdentifier).text)
+		}
+		name.Alias = p.parseAlias()
+		return name
+	case tokenPragmaTableInfo:
+		fn := &ast.TableFunction{NamePos: p.tok.pos, Name: strings.ToLower(p.tok.text)}
+		p.next()
+		p.expect(tokenLParen)
+		fn.Args = []ast.Expr{p.parseParam()}
+		p.expect(tokenRParen)
+		fn.Alias = p.parseAlias()
+		return fn
+	case tokenLParen:
+		table := &ast.SubqueryTable{Lparen: p.tok.pos}
+		table.Select = p.parseSubquery()
+		table.Alias = p.parseAlias()
+		return table
+	default:
+		p.errorExpected(tokenPragmaTableInfo, tokenIdentifier, tokenLParen)
+		return nil
+	}
+}
+
+// parseAlias parses an optional alias, returning "" if there is none:
+//
+//	alias
+//	  : As? Identifier
+//	  ;
+//
+// Like in SQLite, the keywords of join operators, f.e. LEFT, are only aliases if
+// they follow AS, and WINDOW is not an alias if it starts a WINDOW clause.
+func (p *parser) parseAlias() string {
+	switch {
+	case p.tok.typ == tokenAs:
+		p.next()
+		return unquoteIdent(p.expect(tokenIdentifier).text)
+	case p.tok.typ == tokenIdentifier && joinKeyword(p.tok) == "" && !p.atWindowClause():
+		alias := unquoteIdent(p.tok.text)
+		p.next()
+		return alias
+	default:
+		return ""
+	}
+}
+
+// parseWhere parses:
+//
+//	where
+//	  : Where expr
+//	  ;
+func (p *parser) parseWhere() ast.Expr {
+	p.expect(tokenWhere)
+
+	return p.parseExpr()
+}
+
+// parseExpr parses:
+//
+//	expr
+//	  : operand
+//	  | (Minus | Plus | Tilde) expr
+//	  | expr Collate Identifier
+//	  | expr Concat expr
+//	  | expr (Star | Slash | Percent) expr
+//	  | expr (Plus | Minus) expr
+//	  | expr (Ampersand | Pipe | ShiftLeft | ShiftRight) expr
+//	  | expr (Less | LessEqual | Greater | GreaterEqual) expr
+//	  | expr (Equal | NotEqual) expr
+//	  | expr Is Not? (Distinct From)? expr
+//	  | expr (IsNull | NotNull | Not Null)
+//	  | expr Not? (Like | Glob | Regexp) expr (Escape expr)?
+//	  | expr Not? Between expr And expr
+//	  | expr Not? In (subquery | LParen (expr (Comma expr)*)? RParen | (Identifier Dot)? Identifier)
+//	  | Not expr
+//	  | expr And expr
+//	  | expr Or expr
+//	  ;
+//
+// The alternatives are listed from the most tightly binding to the least, except
+// that those from "=" to IN bind equally tightly, as ast.Operator.Precedence
+// describes. Binary operators are left-associative.
+func (p *parser) parseExpr() ast.Expr {
+	return p.parseExprAfter(p.parseUnaryExpr(), 1)
+}
+
+// parseSubexpr parses an expression whose operators bind at least as tightly as
+// the precedence prec.
+func (p *parser) parseSubexpr(prec int) ast.Expr {
+	return p.parseExprAfter(p.parseUnaryExpr(), prec)
+}
+
+// binaryOperators are the operators that the tokens of binary operators stand
+// for, other than those of IS, BETWEEN and IN, which are parsed separately.
+var binaryOperators = map[tokenType]ast.Operator{
+	tokenOr:           ast.OpOr,
+	tokenAnd:          ast.OpAnd,
+	tokenEqual:        ast.OpEq,
+	tokenNotEqual:     ast.OpNe,
+	tokenLike:         ast.OpLike,
+	tokenGlob:         ast.OpGlob,
+	tokenRegexp:       ast.OpRegexp,
+	tokenLess:         ast.OpLt,
+	tokenLessEqual:    ast.OpLe,
+	tokenGreater:      ast.OpGt,
+	tokenGreaterEqual: ast.OpGe,
+	tokenAmpersand:    ast.OpBitAnd,
+	tokenPipe:         ast.OpBitOr,
+	tokenShiftLeft:    ast.OpShiftLeft,
+	tokenShiftRight:   ast.OpShiftRight,
+	tokenPlus:         ast.OpAdd,
+	tokenMinus:        ast.OpSub,
+	tokenStar:         ast.OpMul,
+	tokenSlash:        ast.OpDiv,
+	tokenPercent:      ast.OpRem,
+	tokenConcat:       ast.OpConcat,
+}
+
+// precedence returns the precedence of the binary or postfix operator at the
+// current token, or 0 if it is not one.
+func (p *parser) precedence() int {
+	if op, ok := binaryOperators[p.tok.typ]; ok {
+		return op.Precedence()
+	}
+
+	switch p.tok.typ {
+	case tokenIs, tokenIsNull, tokenNotNull, tokenNot, tokenBetween, tokenIn:
+		return ast.OpEq.Precedence()
+	case tokenCollate:
+		return ast.CollatePrecedence
+	default:
+		return 0
+	}
+}
+
+// parseExprAfter parses the rest of an expression whose first operand, x, has
+// already been parsed, applying the operators that bind at least as tightly as
+// the precedence prec.
+func (p *parser) parseExprAfter(x ast.Expr, prec int) ast.Expr {
+	for {
+		opPrec := p.precedence()
+		if opPrec == 0 || opPrec < prec {
+			return x
+		}
+
+		opPos := p.tok.pos
+		switch typ := p.tok.typ; typ {
+		case tokenCollate:
+			p.next()
+			name := p.expect(tokenIdentifier)
+			x = &ast.CollateExpr{X: x, Collation: unquoteIdent(name.text)}
+		case tokenIsNull, tokenNotNull:
+			p.next()
+			x = isNull(x, opPos, opPos, typ == tokenNotNull)
+		case tokenIs:
+			p.next()
+			expr := &ast.BinaryExpr{X: x, OpPos: opPos, Op: ast.OpIs}
+			if p.tok.typ == tokenNot {
+				expr.Op = ast.OpIsNot
+				p.next()
+			}
+			if p.tok.typ == tokenDistinct {
+				// "IS DISTINCT FROM" is "IS NOT", and "IS NOT DISTINCT
+				// FROM" is "IS".
+				if expr.Op == ast.OpIs {
+					expr.Op = ast.OpIsNot
+				} else {
+					expr.Op = ast.OpIs
+				}
+				p.next()
+				p.expect(tokenFrom)
+			}
+			expr.Y = p.parseSubexpr(opPrec + 1)
+			x = expr
+		case tokenNot, tokenLike, tokenGlob, tokenRegexp, tokenBetween, tokenIn:
+			x = p.parseNegatableAfter(x, opPrec)
+		default:
+			p.next()
+			x = &ast.BinaryExpr{X: x, OpPos: opPos, Op: binaryOperators[typ], Y: p.parseSubexpr(opPrec + 1)}
+		}
+	}
+}
+
+// parseNegatableAfter parses an operator that may be preceded by NOT, whose
+// left-hand operand, x, has already been parsed, and whose precedence is prec.
+func (p *parser) parseNegatableAfter(x ast.Expr, prec int) ast.Expr {
+	opPos := p.tok.pos
+	not := false
+	if p.tok.typ == tokenNot {
+		not = true
+		p.next()
+	}
+
+	switch p.tok.typ {
+	case tokenNull:
+		if !not {
+			break
+		}
+		null := p.tok.pos
+		p.next()
+		return isNull(x, opPos, null, true)
+	case tokenLike, tokenGlob, tokenRegexp:
+		expr := &ast.LikeExpr{X: x, Not: not, OpPos: opPos, Op: binaryOperators[p.tok.typ]}
+		p.next()
+		expr.Y = p.parseSubexpr(prec + 1)
+		if p.tok.typ == tokenEscape {
+			p.next()
+			expr.Escape = p.parseSubexpr(prec + 1)
+		}
+		return expr
+	case tokenBetween:
+		p.next()
+		expr := &ast.BetweenExpr{X: x, Not: not, OpPos: opPos}
+		// Like in SQLite, the lower bound extends up to the AND, so that
+		// "a BETWEEN b = c AND d" is "a BETWEEN (b = c) AND d", but the upper
+		// bound ends at the next operator that binds like BETWEEN.
+		expr.Low = p.parseSubexpr(ast.OpNot.Precedence())
+		p.expect(tokenAnd)
+		expr.High = p.parseSubexpr(prec + 1)
+		return expr
+	case tokenIn:
+		p.next()
+		in := &ast.InExpr{X: x, Not: not, OpPos: opPos}
+		if p.tok.typ == tokenIdentifier {
+			// Like in SQLite, "x IN t" is parsed as "x IN (SELECT * FROM t)".
+			name := &ast.TableName{NamePos: p.tok.pos, Name: unquoteIdent(p.tok.text)}
+			p.next()
+			if p.tok.typ == tokenDot {
+				p.next()
+				name.Schema, name.Name = name.Name, unquoteIdent(p.expect(tokenIdentifier).text)
+			}
+			in.Select = &ast.SelectStatement{
+				Select:  name.NamePos,
+				Columns: []*ast.ResultColumn{{Star: true, StarPos: name.NamePos}},
+				From:    name,
+			}
+			return in
+		}
+		if next := p.peek(); next == tokenSelect || next == tokenWith {
+			in.Select = p.parseSubquery()
+			return in
+		}
+		p.expect(tokenLParen)
+		if p.tok.typ != tokenRParen {
+			in.List = p.parseExprList()
+		}
+		p.expect(tokenRParen)
+		return in
+	}
+
+	p.errorExpected(tokenLike, tokenGlob, tokenRegexp, tokenBetween, tokenIn, tokenNull)
+	return nil
+}
+
+// isNull returns "x IS NULL", or "x IS NOT NULL" if not is set, which "x ISNULL",
+// "x NOTNULL" and "x NOT NULL" are parsed as.
+func isNull(x ast.Expr, opPos, null ast.Pos, not bool) *ast.BinaryExpr {
+	expr := &ast.BinaryExpr{X: x, OpPos: opPos, Op: ast.OpIs, Y: &ast.Literal{ValuePos: null, Kind: ast.NullLiteral}}
+	if not {
+		expr.Op = ast.OpIsNot
+	}
+
+	return expr
+}
+
+// parseUnaryExpr parses an operand, or one of the unary operators of expr, along
+// with its operand.
+func (p *parser) parseUnaryExpr() ast.Expr {
+	opPos := p.tok.pos
+	switch p.tok.typ {
+	case tokenNot:
+		p.next()
+		return &ast.UnaryExpr{OpPos: opPos, Op: ast.OpNot, X: p.parseSubexpr(ast.OpNot.Precedence())}
+	case tokenMinus, tokenPlus, tokenTilde:
+		op := map[tokenType]ast.Operator{tokenMinus: ast.OpNeg, tokenPlus: ast.OpPlus, tokenTilde: ast.OpBitNot}[p.tok.typ]
+		p.next()
+		return &ast.UnaryExpr{OpPos: opPos, Op: op, X: p.parseUnaryExpr()}
+	default:
+		return p.parseOperand()
+	}
+}
+
+// parseExprList parses:
+//
+//	exprList
+//	  : expr (Comma expr)*
+//	  ;
+func (p *parser) parseExprList() []ast.Expr {
+	list := []ast.Expr{p.parseExpr()}
+	for p.tok.typ == tokenComma {
+		p.next()
+		list = append(list, p.parseExpr())
+	}
+
+	return list
+}
+
+// parseSubquery parses:
+//
+//	subquery
+//	  : LParen select RParen
+//	  ;
+func (p *parser) parseSubquery() *ast.SelectStatement {
+	p.expect(tokenLParen)
+	stmt := p.parseSelect()
+	p.expect(tokenRParen)
+
+	return stmt
+}
+
+// parseOperand parses:
+//
+//	operand
+//	  : call
+//	  | columnRef
+//	  | value
+//	  | Cast LParen expr As typeName RParen
+//	  | case
+//	  | subquery
+//	  | Exists subquery
+//	  | LParen exprList RParen
+//	  ;
+//
+// An expression in parentheses by itself is parsed as that expression, and a
+// list of them as an *ast.RowExpr.
+func (p *parser) parseOperand() ast.Expr {
+	switch p.tok.typ {
+	case tokenIdentifier:
+		first := p.tok
+		p.next()
+		return p.parseOperandAfter(first)
+	case tokenNumber, tokenStringLiteral, tokenBlobLiteral, tokenPlaceholder, tokenNull:
+		return p.parseValue()
+	case tokenCast:
+		cast := &ast.CastExpr{Cast: p.tok.pos}
+		p.next()
+		p.expect(tokenLParen)
+		cast.X = p.parseExpr()
+		p.expect(tokenAs)
+		cast.Type = p.parseTypeName()
+		p.expect(tokenRParen)
+		return cast
+	case tokenCase:
+		return p.parseCase()
+	case tokenExists:
+		exists := &ast.ExistsExpr{Exists: p.tok.pos}
+		p.next()
+		exists.Select = p.parseSubquery()
+		return exists
+	case tokenLParen:
+		if next := p.peek(); next == tokenSelect || next == tokenWith {
+			subquery := &ast.SubqueryExpr{Lparen: p.tok.pos}
+			subquery.Select = p.parseSubquery()
+			return subquery
+		}
+		row := &ast.RowExpr{Lparen: p.tok.pos}
+		p.next()
+		row.List = p.parseExprList()
+		p.expect(tokenRParen)
+		if len(row.List) == 1 {
+			return row.List[0]
+		}
+		return row
+	default:
+		p.errorExpected(tokenIdentifier, tokenCast, tokenCase, tokenPlaceholder, tokenNumber, tokenStringLiteral, tokenBlobLiteral, tokenNull, tokenExists, tokenLParen)
+		return nil
+	}
+}
+
+// parseTypeName parses:
+//
+//	typeName
+//	  : Identifier+ (LParen signedNumber (Comma signedNumber)? RParen)?
+//	  ;
+//
+//	signedNumber
+//	  : (Plus | Minus)? Number
+//	  ;
+//
+// The type name is returned with its names unquoted and separated by single spaces,
+// f.e. "DOUBLE PRECISION" or "DECIMAL(10,2)", which SQLite derives the affinity of
+// the type from.
+func (p *parser) parseTypeName() string {
+	names := []string{unquoteIdent(p.expect(tokenIdentifier).text)}
+	for p.tok.typ == tokenIdentifier {
+		names = append(names, unquoteIdent(p.tok.text))
+		p.next()
+	}
+	typ := strings.Join(names, " ")
+	if p.tok.typ == tokenLParen {
+		p.next()
+		typ += "(" + p.parseSignedNumber()
+		if p.tok.typ == tokenComma {
+			p.next()
+			typ += "," + p.parseSignedNumber()
+		}
+		p.expect(tokenRParen)
+		typ += ")"
+	}
+
+	return typ
+}
+
+// parseSignedNumber parses a signedNumber, as described by parseTypeName, and
+// returns its text.
+func (p *parser) parseSignedNumber() string {
+	sign := ""
+	if p.tok.typ == tokenPlus || p.tok.typ == tokenMinus {
+		sign = p.tok.text
+		p.next()
+	}
+
+	return sign + p.expect(tokenNumber).text
+}
+
+// parseCase parses:
+//
+//	case
+//	  : Case expr? (When expr Then expr)+ (Else expr)? End
+//	  ;
+func (p *parser) parseCase() *ast.CaseExpr {
+	expr := &ast.CaseExpr{Case: p.expect(tokenCase).pos}
+	if p.tok.typ != tokenWhen {
+		expr.X = p.parseExpr()
+	}
+	for {
+		when := &ast.When{When: p.expect(tokenWhen).pos}
+		when.Cond = p.parseExpr()
+		p.expect(tokenThen)
+		when.Result = p.parseExpr()
+		expr.Whens = append(expr.Whens, when)
+		if p.tok.typ != tokenWhen {
+			break
+		}
+	}
+	if p.tok.typ == tokenElse {
+		p.next()
+		expr.Else = p.parseExpr()
+	}
+	p.expect(tokenEnd)
+
+	return expr
+}
+
+// parseOperandAfter parses the rest of a call or column reference, whose first
+// identifier has already been consumed.
+func (p *parser) parseOperandAfter(first token) ast.Expr {
+	if p.tok.typ == tokenLParen {
+		return p.parseCallAfter(first)
+	}
+
+	return p.parseColumnRefAfter(first)
+}
+
+// parseCallAfter parses the rest of a call, whose name has already been consumed:
+//
+//	call
+//	  : Identifier LParen (Star | Distinct? exprList)? RParen filter? over?
+//	  ;
+//
+//	filter
+//	  : Filter LParen Where expr RParen
+//	  ;
+//
+//	over
+//	  : Over window
+//	  ;
+//
+// FILTER and OVER are not keywords, so that they can still be used as names.
+func (p *parser) parseCallAfter(name token) *ast.CallExpr {
+	call := &ast.CallExpr{NamePos: name.pos, Name: unquoteIdent(name.text)}
+	p.expect(tokenLParen)
+	switch p.tok.typ {
+	case tokenStar:
+		call.Star = true
+		p.next()
+	case tokenRParen:
+	default:
+		if p.tok.typ == tokenDistinct {
+			call.Distinct = true
+			p.next()
+		}
+		call.Args = p.parseExprList()
+	}
+	call.Rparen = p.expect(tokenRParen).pos
+
+	if p.tok.typ == tokenIdentifier && strings.EqualFold(p.tok.text, "FILTER") && p.peek() == tokenLParen {
+		p.next()
+		p.next()
+		p.expect(tokenWhere)
+		call.Filter = p.parseExpr()
+		p.expect(tokenRParen)
+	}
+
+	if p.atWord("OVER") {
+		if next := p.peek(); next == tokenLParen || next == tokenIdentifier {
+			p.next()
+			call.Over = p.parseWindow()
+		}
+	}
+
+	return call
+}
+
+// parseValue parses:
+//
+//	value
+//	  : Number
+//	  | StringLiteral
+//	  | BlobLiteral
+//	  | Placeholder
+//	  | Null
+//	  ;
+func (p *parser) parseValue() ast.Expr {
+	switch p.tok.typ {
+	case tokenNumber:
+		return p.parseNumber()
+	case tokenStringLiteral:
+		text := p.tok.text
+		lit := &ast.Literal{
+			ValuePos: p.tok.pos,
+			Kind:     ast.StringLiteral,
+			Value:    text[1 : len(text)-1],
+		}
+		if strings.Contains(lit.Value, "''") {
+			lit.Value = strings.ReplaceAll(lit.Value, "''", "'")
+		}
+		p.next()
+		return lit
+	case tokenBlobLiteral:
+		text := p.tok.text
+		lit := &ast.Literal{
+			ValuePos: p.tok.pos,
+			Kind:     ast.BlobLiteral,
+			Value:    text[2 : len(text)-1],
+		}
+		p.next()
+		return lit
+	case tokenPlaceholder:
+		return p.parseParam()
+	case tokenNull:
+		lit := &ast.Literal{ValuePos: p.tok.pos, Kind: ast.NullLiteral}
+		p.next()
+		return lit
+	default:
+		p.errorExpected(tokenPlaceholder, tokenNumber, tokenStringLiteral, tokenBlobLiteral, tokenNull)
+		return nil
+	}
+}
+
+// parseColumnRefAfter parses the rest of a column reference, whose first
+// identifier has already been consumed:
+//
+//	columnRef
+//	  : ((Identifier Dot)? Identifier Dot)? Identifier
+//	  ;
+func (p *parser) parseColumnRefAfter(first token) *ast.Ident {
+	id := &ast.Ident{NamePos: first.pos, Name: unquoteIdent(first.text)}
+	for i := 0; i < 2 && p.tok.typ == tokenDot; i++ {
+		p.next()
+		id.Schema, id.Table, id.Name = id.Table, id.Name, unquoteIdent(p.expect(tokenIdentifier).text)
+	}
+
+	return id
+}
+
+func (p *parser) parseNumber() *ast.Literal {
+	tok := p.expect(tokenNumber)
+	return &ast.Literal{ValuePos: tok.pos, Kind: ast.NumberLiteral, Value: tok.text}
+}
+
+func (p *parser) parseParam() *ast.Param {
+	tok := p.expect(tokenPlaceholder)
+	return &ast.Param{NamePos: tok.pos, Name: tok.text}
+}
+
+// unquoteIdent returns the name of an identifier, removing its quotes if it was
+// written as "name", [name] or `name`.
+func unquoteIdent(text string) string {
+	switch text[0] {
+	case '"':
+		return strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	case '`':
+		return strings.ReplaceAll(text[1:len(text)-1], "``", "`")
+	case '[':
+		return text[1 : len(text)-1]
+	default:
+		return text
+	}
+}
+
+// Split splits query into its ";"-separated statements, returning the source text
+// of each statement without the terminating semicolon or surrounding whitespace.
+// Empty statements are dropped.
+func Split(query string) []string {
+	l := newLexer(query)
+
+	statements := []string{}
+	start, stop := -1, -1
+	for {
+		l.skip()
+		offset := l.offset
+		tok, err := l.next()
+		if err != nil {
+			// Unrecognized tokens are reported when the statement is parsed,
+			// here they are kept as part of the statement.
+			tok = token{typ: tokenIdentifier, text: query[offset:l.offset], pos: ast.Pos{Offset: offset}}
+		}
+
+		if tok.typ == tokenEOF || tok.typ == tokenSemicolon {
+			if start >= 0 {
+				statements = append(statements, query[start:stop])
+			}
+			if tok.typ == tokenEOF {
+				return statements
+			}
+			start, stop = -1, -1
+			continue
+		}
+
+		if start < 0 {
+			start = tok.pos.Offset
+		}
+		stop = tok.pos.Offset + len(tok.text)
+	}
+}
@@ -3,27 +3,935 @@ package parser
 import (
 	"testing"
 
-	"github.com/colinking/go-sqlite3-native/internal/vm"
+	"github.com/colinking/go-sqlite3-native/ast"
 	"github.com/stretchr/testify/require"
 )
 
 func TestParser(tt *testing.T) {
 	for _, test := range []struct {
-		name    string
-		sql     string
-		program []vm.Instruction
+		name       string
+		sql        string
+		statements []ast.Statement
 	}{
 		{
-			name:    "simple select",
-			sql:     `SELECT * FROM table1`,
-			program: []vm.Instruction{},
+			name: "simple select",
+			sql:  `SELECT * FROM table1`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "table1"},
+				},
+			},
+		},
+		{
+			name: "select without from",
+			sql:  `SELECT 1 + 1, ?`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.BinaryExpr{
+							X:     &ast.Literal{ValuePos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Kind: ast.NumberLiteral, Value: "1"},
+							OpPos: ast.Pos{Offset: 9, Line: 1, Column: 9},
+							Op:    ast.OpAdd,
+							Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 11, Line: 1, Column: 11}, Kind: ast.NumberLiteral, Value: "1"},
+						}},
+						{Expr: &ast.Param{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "?"}},
+					},
+				},
+			},
+		},
+		{
+			name: "all clauses",
+			sql:  "select a, \"b c\"\nfrom t\nwhere a = 'x''y' and [b c] > cast(? as BLOB)\norder by a desc limit 10;",
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Name: "a"}},
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 10, Line: 1, Column: 10}, Name: "b c"}},
+					},
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 21, Line: 2, Column: 5}, Name: "t"},
+					Where: &ast.BinaryExpr{
+						X: &ast.BinaryExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 29, Line: 3, Column: 6}, Name: "a"},
+							OpPos: ast.Pos{Offset: 31, Line: 3, Column: 8},
+							Op:    ast.OpEq,
+							Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 33, Line: 3, Column: 10}, Kind: ast.StringLiteral, Value: "x'y"},
+						},
+						OpPos: ast.Pos{Offset: 40, Line: 3, Column: 17},
+						Op:    ast.OpAnd,
+						Y: &ast.BinaryExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 44, Line: 3, Column: 21}, Name: "b c"},
+							OpPos: ast.Pos{Offset: 50, Line: 3, Column: 27},
+							Op:    ast.OpGt,
+							Y: &ast.CastExpr{
+								Cast: ast.Pos{Offset: 52, Line: 3, Column: 29},
+								X:    &ast.Param{NamePos: ast.Pos{Offset: 57, Line: 3, Column: 34}, Name: "?"},
+								Type: "BLOB",
+							},
+						},
+					},
+					OrderBy: []*ast.OrderingTerm{
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 77, Line: 4, Column: 9}, Name: "a"}, Order: ast.SortDesc},
+					},
+					Limit: &ast.Literal{ValuePos: ast.Pos{Offset: 90, Line: 4, Column: 22}, Kind: ast.NumberLiteral, Value: "10"},
+				},
+			},
+		},
+		{
+			name: "multiple statements",
+			sql:  `SELECT * FROM pragma_table_info(:t); SELECT "é" FROM t`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}}},
+					From: &ast.TableFunction{
+						NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14},
+						Name:    "pragma_table_info",
+						Args:    []ast.Expr{&ast.Param{NamePos: ast.Pos{Offset: 32, Line: 1, Column: 32}, Name: ":t"}},
+					},
+				},
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 37, Line: 1, Column: 37},
+					Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 44, Line: 1, Column: 44}, Name: "é"}}},
+					// "é" is two bytes, but one character:
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 54, Line: 1, Column: 53}, Name: "t"},
+				},
+			},
+		},
+		{
+			name: "qualified names and aliases",
+			sql:  `SELECT t.*, main.t.a AS x, b y FROM main.t AS t, u v, pragma_table_info(?) p WHERE t.a = 1 ORDER BY x`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Table: "t"},
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 12, Line: 1, Column: 12}, Schema: "main", Table: "t", Name: "a"}, Alias: "x"},
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 27, Line: 1, Column: 27}, Name: "b"}, Alias: "y"},
+					},
+					From: &ast.Join{
+						X: &ast.Join{
+							X:     &ast.TableName{NamePos: ast.Pos{Offset: 36, Line: 1, Column: 36}, Schema: "main", Name: "t", Alias: "t"},
+							OpPos: ast.Pos{Offset: 47, Line: 1, Column: 47},
+							Y:     &ast.TableName{NamePos: ast.Pos{Offset: 49, Line: 1, Column: 49}, Name: "u", Alias: "v"},
+						},
+						OpPos: ast.Pos{Offset: 52, Line: 1, Column: 52},
+						Y: &ast.TableFunction{
+							NamePos: ast.Pos{Offset: 54, Line: 1, Column: 54},
+							Name:    "pragma_table_info",
+							Args:    []ast.Expr{&ast.Param{NamePos: ast.Pos{Offset: 72, Line: 1, Column: 72}, Name: "?"}},
+							Alias:   "p",
+						},
+					},
+					Where: &ast.BinaryExpr{
+						X:     &ast.Ident{NamePos: ast.Pos{Offset: 83, Line: 1, Column: 83}, Table: "t", Name: "a"},
+						OpPos: ast.Pos{Offset: 87, Line: 1, Column: 87},
+						Op:    ast.OpEq,
+						Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 89, Line: 1, Column: 89}, Kind: ast.NumberLiteral, Value: "1"},
+					},
+					OrderBy: []*ast.OrderingTerm{
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 100, Line: 1, Column: 100}, Name: "x"}},
+					},
+				},
+			},
+		},
+		{
+			name: "pattern matching",
+			sql:  `SELECT * FROM t WHERE a LIKE 'x!%%' ESCAPE '!' OR b NOT GLOB ? AND c REGEXP '^x'`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "t"},
+					Where: &ast.BinaryExpr{
+						X: &ast.LikeExpr{
+							X:      &ast.Ident{NamePos: ast.Pos{Offset: 22, Line: 1, Column: 22}, Name: "a"},
+							OpPos:  ast.Pos{Offset: 24, Line: 1, Column: 24},
+							Op:     ast.OpLike,
+							Y:      &ast.Literal{ValuePos: ast.Pos{Offset: 29, Line: 1, Column: 29}, Kind: ast.StringLiteral, Value: "x!%%"},
+							Escape: &ast.Literal{ValuePos: ast.Pos{Offset: 43, Line: 1, Column: 43}, Kind: ast.StringLiteral, Value: "!"},
+						},
+						OpPos: ast.Pos{Offset: 47, Line: 1, Column: 47},
+						Op:    ast.OpOr,
+						Y: &ast.BinaryExpr{
+							X: &ast.LikeExpr{
+								X:     &ast.Ident{NamePos: ast.Pos{Offset: 50, Line: 1, Column: 50}, Name: "b"},
+								Not:   true,
+								OpPos: ast.Pos{Offset: 52, Line: 1, Column: 52},
+								Op:    ast.OpGlob,
+								Y:     &ast.Param{NamePos: ast.Pos{Offset: 61, Line: 1, Column: 61}, Name: "?"},
+							},
+							OpPos: ast.Pos{Offset: 63, Line: 1, Column: 63},
+							Op:    ast.OpAnd,
+							Y: &ast.LikeExpr{
+								X:     &ast.Ident{NamePos: ast.Pos{Offset: 67, Line: 1, Column: 67}, Name: "c"},
+								OpPos: ast.Pos{Offset: 69, Line: 1, Column: 69},
+								Op:    ast.OpRegexp,
+								Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 76, Line: 1, Column: 76}, Kind: ast.StringLiteral, Value: "^x"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "functions and grouping",
+			sql:  `SELECT a, count(*), sum(DISTINCT b) FILTER (WHERE b > 0) FROM t GROUP BY a, 2 HAVING count() > 1`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Name: "a"}},
+						{Expr: &ast.CallExpr{NamePos: ast.Pos{Offset: 10, Line: 1, Column: 10}, Name: "count", Star: true, Rparen: ast.Pos{Offset: 17, Line: 1, Column: 17}}},
+						{Expr: &ast.CallExpr{
+							NamePos:  ast.Pos{Offset: 20, Line: 1, Column: 20},
+							Name:     "sum",
+							Distinct: true,
+							Args:     []ast.Expr{&ast.Ident{NamePos: ast.Pos{Offset: 33, Line: 1, Column: 33}, Name: "b"}},
+							Rparen:   ast.Pos{Offset: 34, Line: 1, Column: 34},
+							Filter: &ast.BinaryExpr{
+								X:     &ast.Ident{NamePos: ast.Pos{Offset: 50, Line: 1, Column: 50}, Name: "b"},
+								OpPos: ast.Pos{Offset: 52, Line: 1, Column: 52},
+								Op:    ast.OpGt,
+								Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 54, Line: 1, Column: 54}, Kind: ast.NumberLiteral, Value: "0"},
+							},
+						}},
+					},
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 62, Line: 1, Column: 62}, Name: "t"},
+					GroupBy: []ast.Expr{
+						&ast.Ident{NamePos: ast.Pos{Offset: 73, Line: 1, Column: 73}, Name: "a"},
+						&ast.Literal{ValuePos: ast.Pos{Offset: 76, Line: 1, Column: 76}, Kind: ast.NumberLiteral, Value: "2"},
+					},
+					Having: &ast.BinaryExpr{
+						X:     &ast.CallExpr{NamePos: ast.Pos{Offset: 85, Line: 1, Column: 85}, Name: "count", Rparen: ast.Pos{Offset: 91, Line: 1, Column: 91}},
+						OpPos: ast.Pos{Offset: 93, Line: 1, Column: 93},
+						Op:    ast.OpGt,
+						Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 95, Line: 1, Column: 95}, Kind: ast.NumberLiteral, Value: "1"},
+					},
+				},
+			},
+		},
+		{
+			name: "ordering terms",
+			sql:  `SELECT a FROM t ORDER BY a COLLATE NOCASE DESC NULLS FIRST, lower(b), 2 nulls last`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Name: "a"}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "t"},
+					OrderBy: []*ast.OrderingTerm{
+						{
+							Expr: &ast.CollateExpr{
+								X:         &ast.Ident{NamePos: ast.Pos{Offset: 25, Line: 1, Column: 25}, Name: "a"},
+								Collation: "NOCASE",
+							},
+							Order: ast.SortDesc,
+							Nulls: ast.NullsFirst,
+						},
+						{Expr: &ast.CallExpr{
+							NamePos: ast.Pos{Offset: 60, Line: 1, Column: 60},
+							Name:    "lower",
+							Args:    []ast.Expr{&ast.Ident{NamePos: ast.Pos{Offset: 66, Line: 1, Column: 66}, Name: "b"}},
+							Rparen:  ast.Pos{Offset: 67, Line: 1, Column: 67},
+						}},
+						{
+							Expr:  &ast.Literal{ValuePos: ast.Pos{Offset: 70, Line: 1, Column: 70}, Kind: ast.NumberLiteral, Value: "2"},
+							Nulls: ast.NullsLast,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "distinct and limit with offset",
+			sql:  `SELECT DISTINCT a FROM t LIMIT ?, 10; SELECT a FROM t LIMIT 5 OFFSET :n`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:   ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Distinct: true,
+					Columns:  []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 16, Line: 1, Column: 16}, Name: "a"}}},
+					From:     &ast.TableName{NamePos: ast.Pos{Offset: 23, Line: 1, Column: 23}, Name: "t"},
+					Limit:    &ast.Literal{ValuePos: ast.Pos{Offset: 34, Line: 1, Column: 34}, Kind: ast.NumberLiteral, Value: "10"},
+					Offset:   &ast.Param{NamePos: ast.Pos{Offset: 31, Line: 1, Column: 31}, Name: "?"},
+				},
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 38, Line: 1, Column: 38},
+					Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 45, Line: 1, Column: 45}, Name: "a"}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 52, Line: 1, Column: 52}, Name: "t"},
+					Limit:   &ast.Literal{ValuePos: ast.Pos{Offset: 60, Line: 1, Column: 60}, Kind: ast.NumberLiteral, Value: "5"},
+					Offset:  &ast.Param{NamePos: ast.Pos{Offset: 69, Line: 1, Column: 69}, Name: ":n"},
+				},
+			},
+		},
+		{
+			name: "joins",
+			sql:  `SELECT * FROM a AS left LEFT OUTER JOIN b ON x = b.x, c NATURAL CROSS JOIN d JOIN e USING (y, "z")`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}}},
+					From: &ast.Join{
+						X: &ast.Join{
+							X: &ast.Join{
+								X: &ast.Join{
+									X:     &ast.TableName{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "a", Alias: "left"},
+									OpPos: ast.Pos{Offset: 24, Line: 1, Column: 24},
+									Op:    ast.JoinLeft,
+									Y:     &ast.TableName{NamePos: ast.Pos{Offset: 40, Line: 1, Column: 40}, Name: "b"},
+									On: &ast.BinaryExpr{
+										X:     &ast.Ident{NamePos: ast.Pos{Offset: 45, Line: 1, Column: 45}, Name: "x"},
+										OpPos: ast.Pos{Offset: 47, Line: 1, Column: 47},
+										Op:    ast.OpEq,
+										Y:     &ast.Ident{NamePos: ast.Pos{Offset: 49, Line: 1, Column: 49}, Table: "b", Name: "x"},
+									},
+								},
+								OpPos: ast.Pos{Offset: 52, Line: 1, Column: 52},
+								Op:    ast.JoinComma,
+								Y:     &ast.TableName{NamePos: ast.Pos{Offset: 54, Line: 1, Column: 54}, Name: "c"},
+							},
+							OpPos:   ast.Pos{Offset: 56, Line: 1, Column: 56},
+							Op:      ast.JoinCross,
+							Natural: true,
+							Y:       &ast.TableName{NamePos: ast.Pos{Offset: 75, Line: 1, Column: 75}, Name: "d"},
+						},
+						OpPos: ast.Pos{Offset: 77, Line: 1, Column: 77},
+						Op:    ast.JoinInner,
+						Y:     &ast.TableName{NamePos: ast.Pos{Offset: 82, Line: 1, Column: 82}, Name: "e"},
+						Using: []string{"y", "z"},
+					},
+				},
+			},
+		},
+		{
+			name: "subqueries",
+			sql:  `SELECT (SELECT a FROM u) FROM (SELECT * FROM v) x WHERE b NOT IN (SELECT c FROM w) AND NOT EXISTS (SELECT * FROM y)`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Expr: &ast.SubqueryExpr{
+						Lparen: ast.Pos{Offset: 7, Line: 1, Column: 7},
+						Select: &ast.SelectStatement{
+							Select:  ast.Pos{Offset: 8, Line: 1, Column: 8},
+							Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 15, Line: 1, Column: 15}, Name: "a"}}},
+							From:    &ast.TableName{NamePos: ast.Pos{Offset: 22, Line: 1, Column: 22}, Name: "u"},
+						},
+					}}},
+					From: &ast.SubqueryTable{
+						Lparen: ast.Pos{Offset: 30, Line: 1, Column: 30},
+						Select: &ast.SelectStatement{
+							Select:  ast.Pos{Offset: 31, Line: 1, Column: 31},
+							Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 38, Line: 1, Column: 38}}},
+							From:    &ast.TableName{NamePos: ast.Pos{Offset: 45, Line: 1, Column: 45}, Name: "v"},
+						},
+						Alias: "x",
+					},
+					Where: &ast.BinaryExpr{
+						X: &ast.InExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 56, Line: 1, Column: 56}, Name: "b"},
+							Not:   true,
+							OpPos: ast.Pos{Offset: 58, Line: 1, Column: 58},
+							Select: &ast.SelectStatement{
+								Select:  ast.Pos{Offset: 66, Line: 1, Column: 66},
+								Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 73, Line: 1, Column: 73}, Name: "c"}}},
+								From:    &ast.TableName{NamePos: ast.Pos{Offset: 80, Line: 1, Column: 80}, Name: "w"},
+							},
+						},
+						OpPos: ast.Pos{Offset: 83, Line: 1, Column: 83},
+						Op:    ast.OpAnd,
+						Y: &ast.UnaryExpr{
+							OpPos: ast.Pos{Offset: 87, Line: 1, Column: 87},
+							Op:    ast.OpNot,
+							X: &ast.ExistsExpr{
+								Exists: ast.Pos{Offset: 91, Line: 1, Column: 91},
+								Select: &ast.SelectStatement{
+									Select:  ast.Pos{Offset: 99, Line: 1, Column: 99},
+									Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 106, Line: 1, Column: 106}}},
+									From:    &ast.TableName{NamePos: ast.Pos{Offset: 113, Line: 1, Column: 113}, Name: "y"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "in lists",
+			sql:  `SELECT * FROM t WHERE a IN (1, ?) AND b NOT IN ()`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 7, Line: 1, Column: 7}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Name: "t"},
+					Where: &ast.BinaryExpr{
+						X: &ast.InExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 22, Line: 1, Column: 22}, Name: "a"},
+							OpPos: ast.Pos{Offset: 24, Line: 1, Column: 24},
+							List: []ast.Expr{
+								&ast.Literal{ValuePos: ast.Pos{Offset: 28, Line: 1, Column: 28}, Kind: ast.NumberLiteral, Value: "1"},
+								&ast.Param{NamePos: ast.Pos{Offset: 31, Line: 1, Column: 31}, Name: "?"},
+							},
+						},
+						OpPos: ast.Pos{Offset: 34, Line: 1, Column: 34},
+						Op:    ast.OpAnd,
+						Y: &ast.InExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 38, Line: 1, Column: 38}, Name: "b"},
+							Not:   true,
+							OpPos: ast.Pos{Offset: 40, Line: 1, Column: 40},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "in table",
+			sql:  `SELECT a IN main.t`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.InExpr{
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Name: "a"},
+							OpPos: ast.Pos{Offset: 9, Line: 1, Column: 9},
+							Select: &ast.SelectStatement{
+								Select:  ast.Pos{Offset: 12, Line: 1, Column: 12},
+								Columns: []*ast.ResultColumn{{Star: true, StarPos: ast.Pos{Offset: 12, Line: 1, Column: 12}}},
+								From:    &ast.TableName{NamePos: ast.Pos{Offset: 12, Line: 1, Column: 12}, Schema: "main", Name: "t"},
+							},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "operator precedence",
+			sql:  `SELECT -a + b * c FROM t WHERE NOT a = 1 OR b NOT BETWEEN 1 AND 2 AND c ISNULL`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.BinaryExpr{
+							X:     &ast.UnaryExpr{OpPos: ast.Pos{Offset: 7, Line: 1, Column: 7}, Op: ast.OpNeg, X: &ast.Ident{NamePos: ast.Pos{Offset: 8, Line: 1, Column: 8}, Name: "a"}},
+							OpPos: ast.Pos{Offset: 10, Line: 1, Column: 10},
+							Op:    ast.OpAdd,
+							Y:     &ast.BinaryExpr{X: &ast.Ident{NamePos: ast.Pos{Offset: 12, Line: 1, Column: 12}, Name: "b"}, OpPos: ast.Pos{Offset: 14, Line: 1, Column: 14}, Op: ast.OpMul, Y: &ast.Ident{NamePos: ast.Pos{Offset: 16, Line: 1, Column: 16}, Name: "c"}},
+						}},
+					},
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 23, Line: 1, Column: 23}, Name: "t"},
+					Where: &ast.BinaryExpr{
+						X: &ast.UnaryExpr{
+							OpPos: ast.Pos{Offset: 31, Line: 1, Column: 31},
+							Op:    ast.OpNot,
+							X:     &ast.BinaryExpr{X: &ast.Ident{NamePos: ast.Pos{Offset: 35, Line: 1, Column: 35}, Name: "a"}, OpPos: ast.Pos{Offset: 37, Line: 1, Column: 37}, Op: ast.OpEq, Y: &ast.Literal{ValuePos: ast.Pos{Offset: 39, Line: 1, Column: 39}, Kind: ast.NumberLiteral, Value: "1"}},
+						},
+						OpPos: ast.Pos{Offset: 41, Line: 1, Column: 41},
+						Op:    ast.OpOr,
+						Y: &ast.BinaryExpr{
+							X: &ast.BetweenExpr{
+								X:     &ast.Ident{NamePos: ast.Pos{Offset: 44, Line: 1, Column: 44}, Name: "b"},
+								Not:   true,
+								OpPos: ast.Pos{Offset: 46, Line: 1, Column: 46},
+								Low:   &ast.Literal{ValuePos: ast.Pos{Offset: 58, Line: 1, Column: 58}, Kind: ast.NumberLiteral, Value: "1"},
+								High:  &ast.Literal{ValuePos: ast.Pos{Offset: 64, Line: 1, Column: 64}, Kind: ast.NumberLiteral, Value: "2"},
+							},
+							OpPos: ast.Pos{Offset: 66, Line: 1, Column: 66},
+							Op:    ast.OpAnd,
+							Y: &ast.BinaryExpr{
+								X:     &ast.Ident{NamePos: ast.Pos{Offset: 70, Line: 1, Column: 70}, Name: "c"},
+								OpPos: ast.Pos{Offset: 72, Line: 1, Column: 72},
+								Op:    ast.OpIs,
+								Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 72, Line: 1, Column: 72}, Kind: ast.NullLiteral},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "not exists binds like not",
+			sql:  `SELECT NOT EXISTS (SELECT 1) + 1`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.UnaryExpr{
+							OpPos: ast.Pos{Offset: 7, Line: 1, Column: 7},
+							Op:    ast.OpNot,
+							X: &ast.BinaryExpr{
+								X: &ast.ExistsExpr{
+									Exists: ast.Pos{Offset: 11, Line: 1, Column: 11},
+									Select: &ast.SelectStatement{
+										Select:  ast.Pos{Offset: 19, Line: 1, Column: 19},
+										Columns: []*ast.ResultColumn{{Expr: &ast.Literal{ValuePos: ast.Pos{Offset: 26, Line: 1, Column: 26}, Kind: ast.NumberLiteral, Value: "1"}}},
+									},
+								},
+								OpPos: ast.Pos{Offset: 29, Line: 1, Column: 29},
+								Op:    ast.OpAdd,
+								Y:     &ast.Literal{ValuePos: ast.Pos{Offset: 31, Line: 1, Column: 31}, Kind: ast.NumberLiteral, Value: "1"},
+							},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "case, cast and row values",
+			sql:  `SELECT CASE a WHEN 1 THEN x ELSE NULL END, CAST(a + 1 AS TEXT) FROM t WHERE (a, b) IS NOT DISTINCT FROM (1, 2)`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.CaseExpr{
+							Case:  ast.Pos{Offset: 7, Line: 1, Column: 7},
+							X:     &ast.Ident{NamePos: ast.Pos{Offset: 12, Line: 1, Column: 12}, Name: "a"},
+							Whens: []*ast.When{{When: ast.Pos{Offset: 14, Line: 1, Column: 14}, Cond: &ast.Literal{ValuePos: ast.Pos{Offset: 19, Line: 1, Column: 19}, Kind: ast.NumberLiteral, Value: "1"}, Result: &ast.Ident{NamePos: ast.Pos{Offset: 26, Line: 1, Column: 26}, Name: "x"}}},
+							Else:  &ast.Literal{ValuePos: ast.Pos{Offset: 33, Line: 1, Column: 33}, Kind: ast.NullLiteral},
+						}},
+						{Expr: &ast.CastExpr{
+							Cast: ast.Pos{Offset: 43, Line: 1, Column: 43},
+							X:    &ast.BinaryExpr{X: &ast.Ident{NamePos: ast.Pos{Offset: 48, Line: 1, Column: 48}, Name: "a"}, OpPos: ast.Pos{Offset: 50, Line: 1, Column: 50}, Op: ast.OpAdd, Y: &ast.Literal{ValuePos: ast.Pos{Offset: 52, Line: 1, Column: 52}, Kind: ast.NumberLiteral, Value: "1"}},
+							Type: "TEXT",
+						}},
+					},
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 68, Line: 1, Column: 68}, Name: "t"},
+					Where: &ast.BinaryExpr{
+						X:     &ast.RowExpr{Lparen: ast.Pos{Offset: 76, Line: 1, Column: 76}, List: []ast.Expr{&ast.Ident{NamePos: ast.Pos{Offset: 77, Line: 1, Column: 77}, Name: "a"}, &ast.Ident{NamePos: ast.Pos{Offset: 80, Line: 1, Column: 80}, Name: "b"}}},
+						OpPos: ast.Pos{Offset: 83, Line: 1, Column: 83},
+						Op:    ast.OpIs,
+						Y:     &ast.RowExpr{Lparen: ast.Pos{Offset: 104, Line: 1, Column: 104}, List: []ast.Expr{&ast.Literal{ValuePos: ast.Pos{Offset: 105, Line: 1, Column: 105}, Kind: ast.NumberLiteral, Value: "1"}, &ast.Literal{ValuePos: ast.Pos{Offset: 108, Line: 1, Column: 108}, Kind: ast.NumberLiteral, Value: "2"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "compound selects",
+			sql:  `SELECT ALL a FROM t UNION ALL SELECT b FROM u EXCEPT SELECT c FROM v ORDER BY 1`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select:  ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 11, Line: 1, Column: 11}, Name: "a"}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 18, Line: 1, Column: 18}, Name: "t"},
+					Compound: []*ast.CompoundSelect{
+						{
+							OpPos: ast.Pos{Offset: 20, Line: 1, Column: 20},
+							Op:    ast.CompoundUnionAll,
+							Select: &ast.SelectStatement{
+								Select:  ast.Pos{Offset: 30, Line: 1, Column: 30},
+								Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 37, Line: 1, Column: 37}, Name: "b"}}},
+								From:    &ast.TableName{NamePos: ast.Pos{Offset: 44, Line: 1, Column: 44}, Name: "u"},
+							},
+						},
+						{
+							OpPos: ast.Pos{Offset: 46, Line: 1, Column: 46},
+							Op:    ast.CompoundExcept,
+							Select: &ast.SelectStatement{
+								Select:  ast.Pos{Offset: 53, Line: 1, Column: 53},
+								Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 60, Line: 1, Column: 60}, Name: "c"}}},
+								From:    &ast.TableName{NamePos: ast.Pos{Offset: 67, Line: 1, Column: 67}, Name: "v"},
+							},
+						},
+					},
+					OrderBy: []*ast.OrderingTerm{{Expr: &ast.Literal{ValuePos: ast.Pos{Offset: 78, Line: 1, Column: 78}, Kind: ast.NumberLiteral, Value: "1"}}},
+				},
+			},
+		},
+		{
+			name: "common table expressions",
+			sql:  `WITH RECURSIVE c(a) AS NOT MATERIALIZED (SELECT x FROM u) SELECT a FROM c`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					With: &ast.With{
+						With:      ast.Pos{Offset: 0, Line: 1, Column: 0},
+						Recursive: true,
+						CTEs: []*ast.CTE{
+							{
+								NamePos:      ast.Pos{Offset: 15, Line: 1, Column: 15},
+								Name:         "c",
+								Columns:      []string{"a"},
+								Materialized: ast.NotMaterialized,
+								Select: &ast.SelectStatement{
+									Select:  ast.Pos{Offset: 41, Line: 1, Column: 41},
+									Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 48, Line: 1, Column: 48}, Name: "x"}}},
+									From:    &ast.TableName{NamePos: ast.Pos{Offset: 55, Line: 1, Column: 55}, Name: "u"},
+								},
+							},
+						},
+					},
+					Select:  ast.Pos{Offset: 58, Line: 1, Column: 58},
+					Columns: []*ast.ResultColumn{{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 65, Line: 1, Column: 65}, Name: "a"}}},
+					From:    &ast.TableName{NamePos: ast.Pos{Offset: 72, Line: 1, Column: 72}, Name: "c"},
+				},
+			},
+		},
+		{
+			name: "window functions",
+			sql:  `SELECT rank() OVER w, sum(a) OVER (w ROWS 1 PRECEDING) FROM t window WINDOW w AS (PARTITION BY b ORDER BY a)`,
+			statements: []ast.Statement{
+				&ast.SelectStatement{
+					Select: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Columns: []*ast.ResultColumn{
+						{Expr: &ast.CallExpr{
+							NamePos: ast.Pos{Offset: 7, Line: 1, Column: 7},
+							Name:    "rank",
+							Rparen:  ast.Pos{Offset: 12, Line: 1, Column: 12},
+							Over:    &ast.Window{NamePos: ast.Pos{Offset: 19, Line: 1, Column: 19}, Name: "w"},
+						}},
+						{Expr: &ast.CallExpr{
+							NamePos: ast.Pos{Offset: 22, Line: 1, Column: 22},
+							Name:    "sum",
+							Args:    []ast.Expr{&ast.Ident{NamePos: ast.Pos{Offset: 26, Line: 1, Column: 26}, Name: "a"}},
+							Rparen:  ast.Pos{Offset: 27, Line: 1, Column: 27},
+							Over: &ast.Window{
+								Lparen: ast.Pos{Offset: 34, Line: 1, Column: 34},
+								Base:   "w",
+								Frame: &ast.Frame{
+									UnitPos: ast.Pos{Offset: 37, Line: 1, Column: 37},
+									Unit:    ast.FrameRows,
+									Start: ast.FrameBound{
+										Kind:   ast.Preceding,
+										Offset: &ast.Literal{ValuePos: ast.Pos{Offset: 42, Line: 1, Column: 42}, Kind: ast.NumberLiteral, Value: "1"},
+									},
+									End: ast.FrameBound{Kind: ast.CurrentRow},
+								},
+							},
+						}},
+					},
+					From: &ast.TableName{NamePos: ast.Pos{Offset: 60, Line: 1, Column: 60}, Name: "t", Alias: "window"},
+					Windows: []*ast.NamedWindow{
+						{
+							NamePos: ast.Pos{Offset: 76, Line: 1, Column: 76},
+							Name:    "w",
+							Window: &ast.Window{
+								Lparen:      ast.Pos{Offset: 81, Line: 1, Column: 81},
+								PartitionBy: []ast.Expr{&ast.Ident{NamePos: ast.Pos{Offset: 95, Line: 1, Column: 95}, Name: "b"}},
+								OrderBy: []*ast.OrderingTerm{
+									{Expr: &ast.Ident{NamePos: ast.Pos{Offset: 106, Line: 1, Column: 106}, Name: "a"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "pragmas",
+			sql:  `pragma foreign_keys = ON; PRAGMA main.cache_size(-2000); PRAGMA optimize`,
+			statements: []ast.Statement{
+				&ast.PragmaStatement{
+					Pragma: ast.Pos{Offset: 0, Line: 1, Column: 0},
+					Name:   "foreign_keys",
+					Value:  &ast.Ident{NamePos: ast.Pos{Offset: 22, Line: 1, Column: 22}, Name: "ON"},
+				},
+				&ast.PragmaStatement{
+					Pragma: ast.Pos{Offset: 26, Line: 1, Column: 26},
+					Schema: "main",
+					Name:   "cache_size",
+					Value: &ast.UnaryExpr{
+						OpPos: ast.Pos{Offset: 49, Line: 1, Column: 49},
+						Op:    ast.OpNeg,
+						X:     &ast.Literal{ValuePos: ast.Pos{Offset: 50, Line: 1, Column: 50}, Kind: ast.NumberLiteral, Value: "2000"},
+					},
+				},
+				&ast.PragmaStatement{Pragma: ast.Pos{Offset: 57, Line: 1, Column: 57}, Name: "optimize"},
+			},
+		},
+		{
+			name:       "empty query",
+			sql:        ``,
+			statements: []ast.Statement{},
 		},
 	} {
 		tt.Run(test.name, func(t *testing.T) {
-			result, err := Parse(test.sql)
+			statements, err := Parse(test.sql)
 			require.NoError(t, err)
 
-			require.Equal(t, test.program, result)
+			require.Equal(t, test.statements, statements)
+		})
+	}
+}
+
+func TestSplit(tt *testing.T) {
+	for _, test := range []struct {
+		name       string
+		sql        string
+		statements []string
+	}{
+		{
+			name:       "single statement",
+			sql:        `SELECT * FROM table1`,
+			statements: []string{"SELECT * FROM table1"},
+		},
+		{
+			name:       "terminated statement",
+			sql:        `SELECT * FROM table1;`,
+			statements: []string{"SELECT * FROM table1"},
+		},
+		{
+			name:       "multiple statements",
+			sql:        "SELECT * FROM table1;\n\tSELECT column1 FROM table2 WHERE column1 = ? ;",
+			statements: []string{"SELECT * FROM table1", "SELECT column1 FROM table2 WHERE column1 = ?"},
+		},
+		{
+			name:       "empty statements",
+			sql:        ` ; SELECT * FROM table1;; `,
+			statements: []string{"SELECT * FROM table1"},
+		},
+		{
+			name:       "semicolons in strings and comments",
+			sql:        "SELECT * FROM t WHERE a = 'x;y'; -- trailing; comment\nSELECT * /* ; */ FROM t",
+			statements: []string{"SELECT * FROM t WHERE a = 'x;y'", "SELECT * /* ; */ FROM t"},
+		},
+		{
+			name:       "empty query",
+			sql:        ``,
+			statements: []string{},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.statements, Split(test.sql))
+		})
+	}
+}
+
+type lexed struct {
+	typ  tokenType
+	text string
+}
+
+func TestLexer(tt *testing.T) {
+	for _, test := range []struct {
+		name   string
+		sql    string
+		tokens []lexed
+	}{
+		{
+			name: "case-insensitive keywords",
+			sql:  `select * FROM t Where`,
+			tokens: []lexed{
+				{tokenSelect, "select"},
+				{tokenStar, "*"},
+				{tokenFrom, "FROM"},
+				{tokenIdentifier, "t"},
+				{tokenWhere, "Where"},
+			},
+		},
+		{
+			name: "identifiers",
+			sql:  "a table_1 \"quoted \"\" id\" [brack eted] `back``tick` caf\u00e9 selected",
+			tokens: []lexed{
+				{tokenIdentifier, "a"},
+				{tokenIdentifier, "table_1"},
+				{tokenIdentifier, `"quoted "" id"`},
+				{tokenIdentifier, "[brack eted]"},
+				{tokenIdentifier, "`back``tick`"},
+				{tokenIdentifier, "caf\u00e9"},
+				{tokenIdentifier, "selected"},
+			},
+		},
+		{
+			name: "invalid utf-8",
+			sql:  "a\xffb \xfe",
+			tokens: []lexed{
+				{tokenIdentifier, "a\xffb"},
+				{tokenIdentifier, "\xfe"},
+			},
+		},
+		{
+			name: "numbers",
+			sql:  `1 12.5 .5 3. 1e10 2.5E-3 0x1F 0XaB`,
+			tokens: []lexed{
+				{tokenNumber, "1"},
+				{tokenNumber, "12.5"},
+				{tokenNumber, ".5"},
+				{tokenNumber, "3."},
+				{tokenNumber, "1e10"},
+				{tokenNumber, "2.5E-3"},
+				{tokenNumber, "0x1F"},
+				{tokenNumber, "0XaB"},
+			},
+		},
+		{
+			name: "strings and blobs",
+			sql:  `'' 'it''s' 'a;b' X'' x'0aF3'`,
+			tokens: []lexed{
+				{tokenStringLiteral, "''"},
+				{tokenStringLiteral, "'it''s'"},
+				{tokenStringLiteral, "'a;b'"},
+				{tokenBlobLiteral, "X''"},
+				{tokenBlobLiteral, "x'0aF3'"},
+			},
+		},
+		{
+			name: "placeholders",
+			sql:  `? ?12 :name @name $name`,
+			tokens: []lexed{
+				{tokenPlaceholder, "?"},
+				{tokenPlaceholder, "?12"},
+				{tokenPlaceholder, ":name"},
+				{tokenPlaceholder, "@name"},
+				{tokenPlaceholder, "$name"},
+			},
+		},
+		{
+			name: "operators",
+			sql:  `= == != <> < <= << > >= >> || | - + / % & ~ .`,
+			tokens: []lexed{
+				{tokenEqual, "="},
+				{tokenEqual, "=="},
+				{tokenNotEqual, "!="},
+				{tokenNotEqual, "<>"},
+				{tokenLess, "<"},
+				{tokenLessEqual, "<="},
+				{tokenShiftLeft, "<<"},
+				{tokenGreater, ">"},
+				{tokenGreaterEqual, ">="},
+				{tokenShiftRight, ">>"},
+				{tokenConcat, "||"},
+				{tokenPipe, "|"},
+				{tokenMinus, "-"},
+				{tokenPlus, "+"},
+				{tokenSlash, "/"},
+				{tokenPercent, "%"},
+				{tokenAmpersand, "&"},
+				{tokenTilde, "~"},
+				{tokenDot, "."},
+			},
+		},
+		{
+			name: "comments",
+			sql:  "SELECT -- a comment\n* /* a\n * block; comment */ FROM t /* unterminated",
+			tokens: []lexed{
+				{tokenSelect, "SELECT"},
+				{tokenStar, "*"},
+				{tokenFrom, "FROM"},
+				{tokenIdentifier, "t"},
+			},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.sql)
+
+			tokens := []lexed{}
+			for {
+				tok, err := l.next()
+				require.NoError(t, err)
+				if tok.typ == tokenEOF {
+					break
+				}
+				tokens = append(tokens, lexed{typ: tok.typ, text: tok.text})
+			}
+			require.Equal(t, test.tokens, tokens)
 		})
 	}
 }
+
+func TestParseErrors(tt *testing.T) {
+	for _, test := range []struct {
+		name string
+		sql  string
+		err  *SyntaxError
+		msg  string
+	}{
+		{
+			name: "misspelled keyword",
+			sql:  `SELECT * FORM t`,
+			err:  &SyntaxError{Line: 1, Column: 9, Token: "FORM", Expected: []string{"<EOF>", ";"}},
+			msg:  `near "FORM": syntax error`,
+		},
+		{
+			name: "missing pragma value",
+			sql:  `PRAGMA foreign_keys = ;`,
+			err:  &SyntaxError{Line: 1, Column: 22, Token: ";", Expected: []string{"+", "-", "Number", "StringLiteral", "Identifier", "On"}},
+			msg:  `near ";": syntax error`,
+		},
+		{
+			name: "error in a later statement",
+			sql:  "SELECT * FROM t;\nSELECT a b c FROM t",
+			err:  &SyntaxError{Line: 2, Column: 11, Token: "c", Expected: []string{"<EOF>", ";"}},
+			msg:  `near "c": syntax error`,
+		},
+		{
+			name: "missing qualified name",
+			sql:  `SELECT t. FROM t`,
+			err:  &SyntaxError{Line: 1, Column: 10, Token: "FROM", Expected: []string{"Identifier"}},
+			msg:  `near "FROM": syntax error`,
+		},
+		{
+			name: "too many qualifiers",
+			sql:  `SELECT main.t.a.b FROM t`,
+			err:  &SyntaxError{Line: 1, Column: 15, Token: ".", Expected: []string{"<EOF>", ";"}},
+			msg:  `near ".": syntax error`,
+		},
+		{
+			name: "missing statement",
+			sql:  `FROM t`,
+			err:  &SyntaxError{Line: 1, Column: 0, Token: "FROM", Expected: []string{"<EOF>", "With", "Select", ";"}},
+			msg:  `near "FROM": syntax error`,
+		},
+		{
+			name: "incomplete input",
+			sql:  "SELECT *\nFROM",
+			err:  &SyntaxError{Line: 2, Column: 4, Expected: []string{"PragmaTableInfo", "Identifier", "("}},
+			msg:  `incomplete input`,
+		},
+		{
+			name: "unknown join type",
+			sql:  `SELECT * FROM a INNER LEFT JOIN b`,
+			err:  &SyntaxError{Line: 1, Column: 22, Token: "LEFT", Expected: []string{"Join"}},
+			msg:  `near "LEFT": syntax error`,
+		},
+		{
+			name: "missing operator after not",
+			sql:  `SELECT * FROM t WHERE a NOT = 1`,
+			err:  &SyntaxError{Line: 1, Column: 28, Token: "=", Expected: []string{"Like", "Glob", "Regexp", "Between", "In", "Null"}},
+			msg:  `near "=": syntax error`,
+		},
+		{
+			name: "case without when",
+			sql:  `SELECT CASE a ELSE 1 END FROM t`,
+			err:  &SyntaxError{Line: 1, Column: 14, Token: "ELSE", Expected: []string{"When"}},
+			msg:  `near "ELSE": syntax error`,
+		},
+		{
+			name: "frame starting with unbounded following",
+			sql:  `SELECT sum(a) OVER (ROWS BETWEEN UNBOUNDED FOLLOWING AND CURRENT ROW) FROM t`,
+			err:  &SyntaxError{Line: 1, Column: 43, Token: "FOLLOWING", Expected: []string{"Identifier"}},
+			msg:  `near "FOLLOWING": syntax error`,
+		},
+		{
+			name: "unterminated string",
+			sql:  `SELECT * FROM t WHERE a = 'abc`,
+			err:  &SyntaxError{Line: 1, Column: 26, Token: "'abc", Unrecognized: true},
+			msg:  `unrecognized token: "'abc"`,
+		},
+		{
+			name: "unrecognized character",
+			sql:  `SELECT * FROM t WHERE a = #`,
+			err:  &SyntaxError{Line: 1, Column: 26, Token: "#", Unrecognized: true},
+			msg:  `unrecognized token: "#"`,
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.sql)
+			require.Equal(t, test.err, err)
+			require.EqualError(t, err, test.msg)
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	queries := []string{
+		"select * from table1;",
+		"select * from core___source_id_write_key_mapping where write_key=CAST('eE7e8Kpd7Xv6WJ8gzCofFh' AS BLOB);",
+		"SELECT name, type FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk ASC",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(queries[i%len(queries)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The CREATE statements stored in sqlite_schema have already been validated by
+// SQLite when they were executed, so we only need a forgiving tokenizer and
+// parser that extracts the parts of the statement we care about (column names,
+// declared types and constraints) and skips the rest.
+
+type ddlTokenType int
+
+const (
+	ddlTokenEOF ddlTokenType = iota
+	// An identifier or keyword, after removing any quoting.
+	ddlTokenIdentifier
+	// A string, number or blob literal.
+	ddlTokenLiteral
+	// Any other single character, such as '(' or ','.
+	ddlTokenPunctuation
+)
+
+type ddlToken struct {
+	typ ddlTokenType
+	// text is the (unquoted) token text.
+	text string
+	// start and end are the byte offsets of this token in the statement.
+	start, end int
+	// quoted is true for identifiers written as "x", [x] or `x`.
+	quoted bool
+}
+
+// is returns true if this token is the keyword or punctuation s.
+func (t ddlToken) is(s string) bool {
+	if t.typ == ddlTokenPunctuation {
+		return t.text == s
+	}
+
+	return t.typ == ddlTokenIdentifier && !t.quoted && strings.EqualFold(t.text, s)
+}
+
+func tokenizeDDL(sql string) ([]ddlToken, error) {
+	tokens := []ddlToken{}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += 2 + end + 2
+			}
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(sql) {
+					return nil, fmt.Errorf("unterminated quote at offset %d", start)
+				}
+				if sql[i] == closing {
+					// Quotes are escaped by doubling them, except for [brackets].
+					if closing != ']' && i+1 < len(sql) && sql[i+1] == closing {
+						b.WriteByte(closing)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(sql[i])
+				i++
+			}
+
+			typ := ddlTokenIdentifier
+			if c == '\'' {
+				typ = ddlTokenLiteral
+			}
+			tokens = append(tokens, ddlToken{typ: typ, text: b.String(), start: start, end: i, quoted: typ == ddlTokenIdentifier})
+		case (c == 'x' || c == 'X') && i+1 < len(sql) && sql[i+1] == '\'':
+			end := strings.IndexByte(sql[i+2:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated blob at offset %d", start)
+			}
+			i += 2 + end + 1
+			tokens = append(tokens, ddlToken{typ: ddlTokenLiteral, text: sql[start:i], start: start, end: i})
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			for i < len(sql) && (isIdentifierChar(sql[i]) || sql[i] == '.' ||
+				((sql[i] == '+' || sql[i] == '-') && (sql[i-1] == 'e' || sql[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, ddlToken{typ: ddlTokenLiteral, text: sql[start:i], start: start, end: i})
+		case isIdentifierChar(c):
+			for i < len(sql) && (isIdentifierChar(sql[i]) || sql[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, ddlToken{typ: ddlTokenIdentifier, text: sql[start:i], start: start, end: i})
+		default:
+			i++
+			tokens = append(tokens, ddlToken{typ: ddlTokenPunctuation, text: sql[start:i], start: start, end: i})
+		}
+	}
+
+	return append(tokens, ddlToken{typ: ddlTokenEOF, start: len(sql), end: len(sql)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierChar(c byte) bool {
+	// Any non-ASCII byte is treated as a part of an identifier, like SQLite does.
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+type ddlParser struct {
+	sql    string
+	tokens []ddlToken
+	pos    int
+}
+
+func (p *ddlParser) peek() ddlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ddlParser) next() ddlToken {
+	t := p.tokens[p.pos]
+	if t.typ != ddlTokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// accept consumes the next tokens if they match the keywords in ss.
+func (p *ddlParser) accept(ss ...string) bool {
+	for i, s := range ss {
+		if p.pos+i >= len(p.tokens) || !p.tokens[p.pos+i].is(s) {
+			return false
+		}
+	}
+	p.pos += len(ss)
+
+	return true
+}
+
+func (p *ddlParser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expected %q near %q", s, p.peek().text)
+	}
+
+	return nil
+}
+
+func (p *ddlParser) identifier() (string, error) {
+	t := p.next()
+	if t.typ != ddlTokenIdentifier && t.typ != ddlTokenLiteral {
+		return "", fmt.Errorf("expected a name near %q", t.text)
+	}
+
+	return t.text, nil
+}
+
+// skipParens skips over a parenthesized group of tokens, including nested groups.
+// The next token must be the opening parenthesis.
+func (p *ddlParser) skipParens() error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	for depth := 1; depth > 0; {
+		t := p.next()
+		switch {
+		case t.typ == ddlTokenEOF:
+			return fmt.Errorf("unbalanced parentheses")
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// endOfDefinition returns true if the next token ends a column definition or constraint.
+func (p *ddlParser) endOfDefinition() bool {
+	t := p.peek()
+	return t.typ == ddlTokenEOF || t.is(",") || t.is(")")
+}
+
+// parseCreateTable parses a CREATE TABLE statement:
+//
+// https://www.sqlite.org/lang_createtable.html
+//
+// A nil table is returned for CREATE VIRTUAL TABLE statements.
+func parseCreateTable(sql string) (*Table, error) {
+	tokens, err := tokenizeDDL(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &ddlParser{sql: sql, tokens: tokens}
+
+	if err := p.expect("CREATE"); err != nil {
+		return nil, err
+	}
+	if p.accept("VIRTUAL") {
+		return nil, nil
+	}
+	_ = p.accept("TEMP") || p.accept("TEMPORARY")
+	if err := p.expect("TABLE"); err != nil {
+		return nil, err
+	}
+	_ = p.accept("IF", "NOT", "EXISTS")
+
+	t := &Table{}
+	if t.Name, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if p.accept(".") {
+		// The name was qualified with a schema name.
+		if t.Name, err = p.identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.isTableConstraint() {
+			if err := p.parseTableConstraint(t); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := p.parseColumnDefinition(t); err != nil {
+				return nil, err
+			}
+		}
+
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	// Table options, f.e. "WITHOUT ROWID, STRICT":
+	for p.peek().typ != ddlTokenEOF {
+		if p.accept("WITHOUT", "ROWID") {
+			t.WithoutRowid = true
+		} else {
+			p.next()
+		}
+	}
+
+	// An INTEGER PRIMARY KEY is the rowid, rather than an index.
+	if _, ok := t.RowidAlias(); ok {
+		uniques := t.uniques[:0]
+		for _, u := range t.uniques {
+			if !u.primaryKey {
+				uniques = append(uniques, u)
+			}
+		}
+		t.uniques = uniques
+	}
+	if len(t.uniques) == 0 {
+		t.uniques = nil
+	}
+	for _, u := range t.uniques {
+		t.defaultCollations(u.columns)
+	}
+
+	return t, nil
+}
+
+// parseCreateIndex parses a CREATE INDEX statement on the table t:
+//
+// https://www.sqlite.org/lang_createindex.html
+func parseCreateIndex(sql string, t *Table) (*Index, error) {
+	tokens, err := tokenizeDDL(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &ddlParser{sql: sql, tokens: tokens}
+
+	if err := p.expect("CREATE"); err != nil {
+		return nil, err
+	}
+	index := &Index{Unique: p.accept("UNIQUE")}
+	if err := p.expect("INDEX"); err != nil {
+		return nil, err
+	}
+	_ = p.accept("IF", "NOT", "EXISTS")
+	if index.Name, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if p.accept(".") {
+		if index.Name, err = p.identifier(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("ON"); err != nil {
+		return nil, err
+	}
+	if _, err := p.identifier(); err != nil {
+		return nil, err
+	}
+
+	if index.Columns, err = p.parseIndexedColumns(t); err != nil {
+		return nil, err
+	}
+	t.defaultCollations(index.Columns)
+	index.Partial = p.accept("WHERE")
+
+	return index, nil
+}
+
+// parseIndexedColumns parses a parenthesized list of the columns in an index or
+// in a PRIMARY KEY or UNIQUE constraint of the table t:
+//
+// https://www.sqlite.org/syntax/indexed-column.html
+func (p *ddlParser) parseIndexedColumns(t *Table) ([]IndexColumn, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	columns := []IndexColumn{}
+	for {
+		c := IndexColumn{Column: ExprColumn}
+		start := p.pos
+		if name, err := p.identifier(); err == nil && p.isIndexedColumnEnd() {
+			if idx, ok := t.Column(name); ok {
+				c.Column = idx
+			}
+		} else {
+			// An expression, which extends up to its modifiers.
+			p.pos = start
+			for !p.isIndexedColumnEnd() {
+				if p.peek().is("(") {
+					if err := p.skipParens(); err != nil {
+						return nil, err
+					}
+				} else {
+					p.next()
+				}
+			}
+		}
+
+		if p.accept("COLLATE") {
+			name, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			c.Collation = name
+		}
+		if p.accept("DESC") {
+			c.Desc = true
+		} else {
+			_ = p.accept("ASC")
+		}
+		columns = append(columns, c)
+
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+// isIndexedColumnEnd returns true if the next token ends the name or expression of
+// an indexed column.
+func (p *ddlParser) isIndexedColumnEnd() bool {
+	t := p.peek()
+	return t.typ == ddlTokenEOF || t.is(",") || t.is(")") || t.is("COLLATE") || t.is("ASC") || t.is("DESC")
+}
+
+func (p *ddlParser) isTableConstraint() bool {
+	t := p.peek()
+	return t.is("CONSTRAINT") || t.is("PRIMARY") || t.is("UNIQUE") || t.is("CHECK") || t.is("FOREIGN")
+}
+
+// parseColumnDefinition parses a column name, its optional type and any column constraints:
+//
+// https://www.sqlite.org/syntax/column-def.html
+func (p *ddlParser) parseColumnDefinition(t *Table) error {
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	c := Column{Name: name}
+
+	// The declared type is any sequence of names, optionally followed by one or two
+	// parenthesized numbers, that precedes the first column constraint.
+	typeStart, typeEnd := -1, -1
+	for !p.endOfDefinition() && !p.isColumnConstraint() {
+		tok := p.peek()
+		if typeStart < 0 {
+			typeStart = tok.start
+		}
+		if tok.is("(") {
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		} else {
+			p.next()
+		}
+		typeEnd = p.tokens[p.pos-1].end
+	}
+	if typeStart >= 0 {
+		c.Type = p.sql[typeStart:typeEnd]
+	}
+
+	idx := len(t.Columns)
+	for !p.endOfDefinition() {
+		switch {
+		case p.accept("PRIMARY", "KEY"):
+			c.PrimaryKey = true
+			t.primaryKey = []int{idx}
+			column := IndexColumn{Column: idx}
+			if p.accept("DESC") {
+				// Quirk: "INTEGER PRIMARY KEY DESC" does not alias the rowid.
+				t.descPrimaryKey = true
+				column.Desc = true
+			}
+			t.addUnique([]IndexColumn{column}, true)
+		case p.accept("UNIQUE"):
+			t.addUnique([]IndexColumn{{Column: idx}}, false)
+		case p.accept("COLLATE"):
+			if c.Collation, err = p.identifier(); err != nil {
+				return err
+			}
+		case p.accept("NOT", "NULL"):
+			c.NotNull = true
+		case p.accept("DEFAULT"):
+			// The default is a signed number, a literal, a name or an
+			// expression in parentheses, whose text is kept without them.
+			start := p.peek()
+			if start.is("(") {
+				if err := p.skipParens(); err != nil {
+					return err
+				}
+				c.Default = strings.TrimSpace(p.sql[start.end:p.tokens[p.pos-1].start])
+			} else {
+				if start.is("+") || start.is("-") {
+					p.next()
+				}
+				p.next()
+				c.Default = p.sql[start.start:p.tokens[p.pos-1].end]
+			}
+		case p.peek().is("("):
+			// f.e. the expression in CHECK (...) or AS (...)
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		default:
+			p.next()
+		}
+	}
+
+	t.Columns = append(t.Columns, c)
+
+	return nil
+}
+
+func (p *ddlParser) isColumnConstraint() bool {
+	for _, keyword := range []string{
+		"CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK", "DEFAULT",
+		"COLLATE", "REFERENCES", "GENERATED", "AS",
+	} {
+		if p.peek().is(keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTableConstraint parses a constraint that follows the column definitions:
+//
+// https://www.sqlite.org/syntax/table-constraint.html
+func (p *ddlParser) parseTableConstraint(t *Table) error {
+	if p.accept("CONSTRAINT") {
+		if _, err := p.identifier(); err != nil {
+			return err
+		}
+	}
+
+	primaryKey := p.accept("PRIMARY", "KEY")
+	if primaryKey || p.accept("UNIQUE") {
+		columns, err := p.parseIndexedColumns(t)
+		if err != nil {
+			return err
+		}
+		for _, c := range columns {
+			if primaryKey && c.Column >= 0 {
+				t.Columns[c.Column].PrimaryKey = true
+				t.primaryKey = append(t.primaryKey, c.Column)
+			}
+		}
+		t.addUnique(columns, primaryKey)
+	}
+
+	for !p.endOfDefinition() {
+		if p.peek().is("(") {
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		} else {
+			p.next()
+		}
+	}
+
+	return nil
+}
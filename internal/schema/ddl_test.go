@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCreateTable(tt *testing.T) {
+	for _, test := range []struct {
+		name       string
+		sql        string
+		table      *Table
+		rowidAlias int
+	}{
+		{
+			name: "untyped columns",
+			sql:  `CREATE TABLE table1(column1, column2)`,
+			table: &Table{
+				Name: "table1",
+				Columns: []Column{
+					{Name: "column1"},
+					{Name: "column2"},
+				},
+			},
+			rowidAlias: -1,
+		},
+		{
+			name: "types and column constraints",
+			sql: `CREATE TABLE IF NOT EXISTS "main"."my table" (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				[name] VARCHAR ( 255 ) NOT NULL DEFAULT 'x,y' COLLATE NOCASE,
+				` + "`price`" + ` DECIMAL(10, 2) CHECK (price > (0)), -- a comment
+				amount UNSIGNED BIG INT NULL,
+				/* another comment */ created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			table: &Table{
+				Name: "my table",
+				Columns: []Column{
+					{Name: "id", Type: "INTEGER", PrimaryKey: true},
+					{Name: "name", Type: "VARCHAR ( 255 )", NotNull: true, Collation: "NOCASE", Default: "'x,y'"},
+					{Name: "price", Type: "DECIMAL(10, 2)"},
+					{Name: "amount", Type: "UNSIGNED BIG INT"},
+					{Name: "created_at", Type: "DATETIME", Default: "CURRENT_TIMESTAMP"},
+				},
+				primaryKey: []int{0},
+			},
+			rowidAlias: 0,
+		},
+		{
+			name: "table constraints",
+			sql: `create table t (
+				a int, b text not null,
+				constraint pk primary key (a collate binary desc, b),
+				unique (b), foreign key (b) references other(x) on delete cascade
+			) without rowid, strict`,
+			table: &Table{
+				Name: "t",
+				Columns: []Column{
+					{Name: "a", Type: "int", PrimaryKey: true},
+					{Name: "b", Type: "text", NotNull: true, PrimaryKey: true},
+				},
+				WithoutRowid: true,
+				primaryKey:   []int{0, 1},
+				uniques: []unique{
+					{columns: []IndexColumn{{Column: 0, Collation: "binary", Desc: true}, {Column: 1}}, primaryKey: true},
+					{columns: []IndexColumn{{Column: 1}}},
+				},
+			},
+			rowidAlias: -1,
+		},
+		{
+			name: "integer primary key desc is not a rowid alias",
+			sql:  `CREATE TABLE t (a INTEGER PRIMARY KEY DESC)`,
+			table: &Table{
+				Name: "t",
+				Columns: []Column{
+					{Name: "a", Type: "INTEGER", PrimaryKey: true},
+				},
+				descPrimaryKey: true,
+				primaryKey:     []int{0},
+				uniques: []unique{
+					{columns: []IndexColumn{{Column: 0, Desc: true}}, primaryKey: true},
+				},
+			},
+			rowidAlias: -1,
+		},
+		{
+			name: "default values",
+			sql:  `CREATE TABLE t (a DEFAULT - 1, b DEFAULT ( 1 + (2) ) NOT NULL, c DEFAULT x'0A', d DEFAULT "abc")`,
+			table: &Table{
+				Name: "t",
+				Columns: []Column{
+					{Name: "a", Default: "- 1"},
+					{Name: "b", NotNull: true, Default: "1 + (2)"},
+					{Name: "c", Default: "x'0A'"},
+					{Name: "d", Default: `"abc"`},
+				},
+			},
+			rowidAlias: -1,
+		},
+		{
+			name:  "virtual tables are skipped",
+			sql:   `CREATE VIRTUAL TABLE t USING fts5(a)`,
+			table: nil,
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			table, err := parseCreateTable(test.sql)
+			require.NoError(t, err)
+			require.Equal(t, test.table, table)
+
+			if table != nil {
+				idx, _ := table.RowidAlias()
+				require.Equal(t, test.rowidAlias, idx)
+			}
+		})
+	}
+}
+
+func TestParseCreateIndex(tt *testing.T) {
+	table := &Table{
+		Name: "t",
+		Columns: []Column{
+			{Name: "a"},
+			{Name: "b", Collation: "NOCASE"},
+		},
+	}
+
+	for _, test := range []struct {
+		name  string
+		sql   string
+		index *Index
+	}{
+		{
+			name: "columns",
+			sql:  `CREATE INDEX idx ON t (b, a)`,
+			index: &Index{
+				Name:    "idx",
+				Columns: []IndexColumn{{Column: 1, Collation: "NOCASE"}, {Column: 0}},
+			},
+		},
+		{
+			name: "modifiers",
+			sql:  `create unique index if not exists main."my idx" on t ("A" collate rtrim desc, [b] asc)`,
+			index: &Index{
+				Name:    "my idx",
+				Columns: []IndexColumn{{Column: 0, Collation: "rtrim", Desc: true}, {Column: 1, Collation: "NOCASE"}},
+				Unique:  true,
+			},
+		},
+		{
+			name: "expressions and partial indexes",
+			sql:  `CREATE INDEX idx ON t (lower(a) DESC, a + (b * 2), b) WHERE a > 0`,
+			index: &Index{
+				Name:    "idx",
+				Columns: []IndexColumn{{Column: ExprColumn, Desc: true}, {Column: ExprColumn}, {Column: 1, Collation: "NOCASE"}},
+				Partial: true,
+			},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			index, err := parseCreateIndex(test.sql, table)
+			require.NoError(t, err)
+			require.Equal(t, test.index, index)
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/colinking/go-sqlite3-native/internal/tree"
+)
+
+// SchemaTableName is the name of the table that stores the schema of a DB. It is
+// also available under its legacy name, sqlite_master.
+const SchemaTableName = "sqlite_schema"
+
+// SchemaTableRootPage is the root page of the sqlite_schema table, which is always
+// stored on the first page of a DB.
+const SchemaTableRootPage = 1
+
+// Schema is an in-memory catalog of the tables in a DB. It is loaded from the
+// sqlite_schema table, which stores the CREATE statement of every table, index,
+// view and trigger in a DB:
+//
+//	CREATE TABLE sqlite_schema(
+//	  type text,
+//	  name text,
+//	  tbl_name text,
+//	  rootpage integer,
+//	  sql text
+//	);
+//
+// https://www.sqlite.org/schematab.html
+type Schema struct {
+	// Cookie is the schema cookie that this schema was loaded at. If the DB's
+	// schema cookie has changed, then this Schema is out-of-date.
+	Cookie int
+
+	// tables is keyed by the lower-case table name, since table names are case-insensitive.
+	tables map[string]*Table
+}
+
+// Table describes a table defined by a CREATE TABLE statement.
+type Table struct {
+	Name     string
+	RootPage int
+	Columns  []Column
+
+	// WithoutRowid is true for tables declared as WITHOUT ROWID.
+	WithoutRowid bool
+
+	// Indexes are the table's indexes, including those that SQLite creates
+	// automatically for PRIMARY KEY and UNIQUE constraints.
+	Indexes []*Index
+
+	// descPrimaryKey is true if the PRIMARY KEY was declared as a column
+	// constraint with the DESC modifier.
+	descPrimaryKey bool
+
+	// primaryKey holds the indexes of the columns of the PRIMARY KEY, in the
+	// order that they were declared in it.
+	primaryKey []int
+
+	// uniques are the PRIMARY KEY and UNIQUE constraints that SQLite creates
+	// automatic indexes for, in the order that they are numbered.
+	uniques []unique
+}
+
+// unique is a PRIMARY KEY or UNIQUE constraint.
+type unique struct {
+	columns    []IndexColumn
+	primaryKey bool
+}
+
+// ExprColumn is the column index of an index column that is an expression, rather
+// than a column of the table.
+const ExprColumn = -2
+
+// Index describes an index on a table, either defined by a CREATE INDEX
+// statement or created automatically for a constraint.
+//
+// Each entry in an index holds the values of its columns followed by the rowid of
+// the row that it refers to: https://www.sqlite.org/fileformat2.html#index_format
+type Index struct {
+	Name     string
+	RootPage int
+	Columns  []IndexColumn
+	// Unique is true if no two entries in the index have the same, non-NULL,
+	// values.
+	Unique bool
+	// Partial is true if the index only holds the rows that match a WHERE clause.
+	Partial bool
+}
+
+// IndexColumn describes a column in an index.
+type IndexColumn struct {
+	// Column is the index of the column in the table, or ExprColumn.
+	Column int
+	// Collation is the name of the collating sequence that the column is
+	// sorted with, f.e. "NOCASE", or "" for BINARY.
+	Collation string
+	// Desc is true if the column is sorted in descending order.
+	Desc bool
+}
+
+// Column describes a column in a table.
+type Column struct {
+	Name string
+	// Type is the declared type of the column exactly as it was written in the
+	// CREATE TABLE statement, f.e. "VARCHAR(255)", or "" if no type was given.
+	Type string
+	// NotNull is true if the column has a NOT NULL constraint.
+	NotNull bool
+	// PrimaryKey is true if the column is a part of the table's PRIMARY KEY.
+	PrimaryKey bool
+	// Collation is the name of the column's default collating sequence, f.e.
+	// "NOCASE", or "" if it was not declared.
+	Collation string
+	// Default is the text of the column's DEFAULT value as it was written, f.e.
+	// "'abc'" or "-1", without the parentheses around an expression, or "" if
+	// it has none.
+	Default string
+}
+
+// Load reads the sqlite_schema table to build a catalog of the DB's tables.
+func Load(tm *tree.TreeManager) (*Schema, error) {
+	header, err := tm.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := tm.Open(SchemaTableRootPage)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	type indexEntry struct {
+		name, table, sql string
+		rootPage         int
+	}
+
+	tables := []*Table{}
+	indexes := []indexEntry{}
+	for t.Next() {
+		record := t.Get()
+
+		typ, _ := record.GetColumn(0).Value().(string)
+		name, _ := record.GetColumn(1).Value().(string)
+		tableName, _ := record.GetColumn(2).Value().(string)
+		rootPage, _ := record.GetColumn(3).AsInt()
+		sql, _ := record.GetColumn(4).Value().(string)
+
+		if typ == "index" {
+			indexes = append(indexes, indexEntry{name: name, table: tableName, sql: sql, rootPage: rootPage})
+			continue
+		}
+		if typ != "table" {
+			continue
+		}
+
+		table, err := parseCreateTable(sql)
+		if err != nil {
+			return nil, fmt.Errorf("malformed database schema (%s): %w", name, err)
+		}
+		if table == nil {
+			// f.e. a virtual table, which we do not support reading from.
+			continue
+		}
+		table.RootPage = rootPage
+		tables = append(tables, table)
+	}
+	if err := t.Err(); err != nil {
+		return nil, err
+	}
+
+	s := New(header.SchemaCookieNumber, tables...)
+	for _, entry := range indexes {
+		table, ok := s.Table(entry.table)
+		if !ok {
+			continue
+		}
+
+		var index *Index
+		if entry.sql == "" {
+			// Automatic indexes have no SQL. Instead, they are numbered in the
+			// order that their constraints were declared, f.e.
+			// sqlite_autoindex_t_1: https://www.sqlite.org/fileformat2.html#intschema
+			prefix := "sqlite_autoindex_" + table.Name + "_"
+			n, err := strconv.Atoi(strings.TrimPrefix(entry.name, prefix))
+			if !strings.HasPrefix(entry.name, prefix) || err != nil || n < 1 || n > len(table.uniques) {
+				return nil, fmt.Errorf("malformed database schema (%s): unknown automatic index", entry.name)
+			}
+			index = &Index{Name: entry.name, Columns: table.uniques[n-1].columns, Unique: true}
+		} else {
+			var err error
+			index, err = parseCreateIndex(entry.sql, table)
+			if err != nil {
+				return nil, fmt.Errorf("malformed database schema (%s): %w", entry.name, err)
+			}
+		}
+		index.RootPage = entry.rootPage
+		table.Indexes = append(table.Indexes, index)
+	}
+
+	return s, nil
+}
+
+// New returns a catalog of the given tables, as of the given schema cookie.
+func New(cookie int, tables ...*Table) *Schema {
+	s := &Schema{
+		Cookie: cookie,
+		tables: map[string]*Table{},
+	}
+
+	// The schema table is not listed within itself, so we add it explicitly.
+	s.addTable(&Table{
+		Name:     SchemaTableName,
+		RootPage: SchemaTableRootPage,
+		Columns: []Column{
+			{Name: "type", Type: "text"},
+			{Name: "name", Type: "text"},
+			{Name: "tbl_name", Type: "text"},
+			{Name: "rootpage", Type: "int"},
+			{Name: "sql", Type: "text"},
+		},
+	})
+	s.tables["sqlite_master"] = s.tables[SchemaTableName]
+
+	for _, t := range tables {
+		s.addTable(t)
+	}
+
+	return s
+}
+
+func (s *Schema) addTable(t *Table) {
+	s.tables[strings.ToLower(t.Name)] = t
+}
+
+// Table returns the table with the given name. Table names are case-insensitive.
+func (s *Schema) Table(name string) (*Table, bool) {
+	t, ok := s.tables[strings.ToLower(name)]
+	return t, ok
+}
+
+// Tables returns the tables of the schema, ordered by name.
+func (s *Schema) Tables() []*Table {
+	tables := make([]*Table, 0, len(s.tables))
+	for _, t := range s.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+
+	return tables
+}
+
+// PrimaryKey returns the indexes of the columns of the table's PRIMARY KEY, in
+// the order that they were declared in it, or nil if it has none.
+func (t *Table) PrimaryKey() []int {
+	return t.primaryKey
+}
+
+// Column returns the index of the column with the given name. Column names are
+// case-insensitive.
+func (t *Table) Column(name string) (int, bool) {
+	for i, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// RowidAlias returns the index of the column that is an alias for the rowid, if any.
+//
+// A column is an alias for the rowid if it is the only column in the PRIMARY KEY
+// of a rowid table and its declared type is exactly "INTEGER":
+// https://www.sqlite.org/lang_createtable.html#rowid
+func (t *Table) RowidAlias() (int, bool) {
+	if t.WithoutRowid || t.descPrimaryKey {
+		return -1, false
+	}
+
+	idx := -1
+	for i, c := range t.Columns {
+		if !c.PrimaryKey {
+			continue
+		}
+		if idx >= 0 {
+			// This is a composite primary key.
+			return -1, false
+		}
+		idx = i
+	}
+	if idx < 0 || !strings.EqualFold(t.Columns[idx].Type, "INTEGER") {
+		return -1, false
+	}
+
+	return idx, true
+}
+
+// addUnique adds a PRIMARY KEY or UNIQUE constraint on columns, unless an earlier
+// constraint has the same columns, in which case SQLite does not create another
+// index.
+func (t *Table) addUnique(columns []IndexColumn, primaryKey bool) {
+	for _, u := range t.uniques {
+		if reflect.DeepEqual(u.columns, columns) {
+			return
+		}
+	}
+
+	t.uniques = append(t.uniques, unique{columns: columns, primaryKey: primaryKey})
+}
+
+// defaultCollations sets the collation of the index columns that were declared
+// without one to the collation of their table column.
+func (t *Table) defaultCollations(columns []IndexColumn) {
+	for i, c := range columns {
+		if c.Collation == "" && c.Column >= 0 {
+			columns[i].Collation = t.Columns[c.Column].Collation
+		}
+	}
+}
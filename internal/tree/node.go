@@ -3,6 +3,7 @@ package tree
 import (
 	"database/sql/driver"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
@@ -13,6 +14,9 @@ import (
 	"github.com/colinking/go-sqlite3-native/internal/pager"
 )
 
+// ErrCorrupt is returned, wrapped, when the DB file is not well-formed.
+var ErrCorrupt = errors.New("database disk image is malformed")
+
 type node struct {
 	pager *pager.Pager
 
@@ -71,6 +75,18 @@ func (r Record) String() string {
 	return fmt.Sprintf("rowid=%+v columns=[%s]", r.rowid, strings.Join(row, "|"))
 }
 
+// Rowid returns the rowid of a table record, or the rowid that an index entry
+// refers to.
+func (r Record) Rowid() int {
+	return r.rowid
+}
+
+// NumColumns returns the number of columns stored in the record. For index
+// entries, this excludes the trailing rowid.
+func (r Record) NumColumns() int {
+	return len(r.columns)
+}
+
 func (r Record) GetColumn(idx int) Column {
 	if idx < len(r.columns) {
 		return r.columns[idx]
@@ -110,8 +126,8 @@ func (c Column) String() string {
 }
 
 func (c Column) AsInt() (int, bool) {
-	// [1, 6] are the various int64 types
-	if c.typ >= 1 && c.typ <= 6 {
+	// [1, 6] are the various int64 types, and 8 and 9 are the constants 0 and 1.
+	if (c.typ >= 1 && c.typ <= 6) || c.typ == 8 || c.typ == 9 {
 		i64 := c.Value().(int64)
 		return int(i64), true
 	}
@@ -120,27 +136,27 @@ func (c Column) AsInt() (int, bool) {
 }
 
 func (c Column) Value() driver.Value {
-	// TODO: validate this works with negative integers (2's complement)
-
+	// Integers are stored as big-endian two's complement, so the smaller sizes
+	// are sign-extended by shifting them into the top of an int64 and back.
 	switch c.typ {
 	case 0:
 		return nil
 	case 1:
-		return int64(c.content[0])
+		return int64(int8(c.content[0]))
 	case 2:
-		return int64(binary.BigEndian.Uint16(c.content))
+		return int64(int16(binary.BigEndian.Uint16(c.content)))
 	case 3:
 		// stdlib binary does not have a 24-bit option
 		b := c.content
-		u := uint32(b[2]) | uint32(b[1])<<8 | uint32(b[0])<<16
-		return int64(u)
+		u := uint64(b[2]) | uint64(b[1])<<8 | uint64(b[0])<<16
+		return int64(u<<40) >> 40
 	case 4:
-		return int64(binary.BigEndian.Uint32(c.content))
+		return int64(int32(binary.BigEndian.Uint32(c.content)))
 	case 5:
 		// stdlib binary does not have a 48-bit option
 		b := c.content
 		u := uint64(b[5]) | uint64(b[4])<<8 | uint64(b[3])<<16 | uint64(b[2])<<24 | uint64(b[1])<<32 | uint64(b[0])<<40
-		return int64(u)
+		return int64(u<<16) >> 16
 	case 6:
 		return int64(binary.BigEndian.Uint64(c.content))
 	case 7:
@@ -162,33 +178,34 @@ func (c Column) Value() driver.Value {
 }
 
 // https://www.sqlite.org/fileformat2.html#serialtype
-func columnContentSize(typ int) int {
+func columnContentSize(typ int) (int, error) {
 	switch typ {
 	case 0, 8, 9:
-		return 0
+		return 0, nil
 	case 1:
-		return 1
+		return 1, nil
 	case 2:
-		return 2
+		return 2, nil
 	case 3:
-		return 3
+		return 3, nil
 	case 4:
-		return 4
+		return 4, nil
 	case 5:
-		return 6
+		return 6, nil
 	case 6, 7:
-		return 8
+		return 8, nil
 	case 10, 11:
-		// https://github.com/sqlite/sqlite/blob/96e3c39bd58ede59150c00e4f8609cbac674ffae/tool/offsets.c#L216
-		// return 0
-		panic(fmt.Errorf("cannot support columns of type=%d", typ))
+		// These are reserved for internal use, and never appear in a
+		// well-formed database file.
+		return 0, fmt.Errorf("%w: reserved serial type %d", ErrCorrupt, typ)
 	default:
+		if typ < 0 {
+			return 0, fmt.Errorf("%w: invalid serial type %d", ErrCorrupt, typ)
+		}
 		if typ%2 == 0 {
-			return (typ - 12) / 2
+			return (typ - 12) / 2, nil
 		} else {
-			// https://github.com/sqlite/sqlite/blob/96e3c39bd58ede59150c00e4f8609cbac674ffae/tool/offsets.c#L216
-			// should this be 12?
-			return (typ - 13) / 2
+			return (typ - 13) / 2, nil
 		}
 	}
 }
@@ -330,10 +347,17 @@ func newNode(pageNumber int, pgr *pager.Pager, parent *node) (n *node, err error
 				columns: columns,
 			})
 		case TreeTypeIndexInterior:
+			// Unlike in table trees, the keys of interior cells are entries in
+			// the index, which sort between the entries of their neighbouring
+			// children.
 			children = append(children, &child{
 				keyColumns: columns,
 				pageNumber: childPageNumber,
 			})
+			records = append(records, Record{
+				rowid:   rowid,
+				columns: columns,
+			})
 		case TreeTypeIndexLeaf:
 			records = append(records, Record{
 				rowid:   rowid,
@@ -383,7 +407,13 @@ func readColumns(content []byte) ([]Column, error) {
 	}
 	columns := make([]Column, 0, len(columnTypes))
 	for _, typ := range columnTypes {
-		size := columnContentSize(typ)
+		size, err := columnContentSize(typ)
+		if err != nil {
+			return nil, err
+		}
+		if contentOffset+size > len(content) {
+			return nil, fmt.Errorf("%w: column of %d bytes overflows record of %d bytes", ErrCorrupt, size, len(content))
+		}
 		columns = append(columns, Column{
 			typ:     typ,
 			content: content[contentOffset : contentOffset+size],
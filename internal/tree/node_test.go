@@ -0,0 +1,120 @@
+package tree
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegerRoundTrip(t *testing.T) {
+	// Check the boundaries of each of the integer serial types, where the
+	// encoding switches from one size to the next.
+	values := []int64{0, 1, -1, math.MinInt64, math.MaxInt64}
+	for _, bits := range []uint{8, 16, 24, 32, 48, 64} {
+		max := int64(uint64(1)<<(bits-1) - 1)
+		min := -max - 1
+		values = append(values, min, min+1, max, max-1)
+		if bits < 64 {
+			values = append(values, min-1, max+1)
+		}
+	}
+
+	for _, v := range values {
+		require.Equal(t, v, roundTrip(t, v), "%d", v)
+	}
+
+	require.NoError(t, quick.Check(func(v int64) bool {
+		return roundTrip(t, v) == v
+	}, nil))
+}
+
+func TestReadColumnsCorrupt(t *testing.T) {
+	for name, record := range map[string][]byte{
+		"reserved serial type 10": {2, 10},
+		"reserved serial type 11": {2, 11},
+		"truncated integer":       {2, 4, 0, 0},
+		"truncated text":          {2, 19, 'a'},
+	} {
+		_, err := readColumns(record)
+		require.True(t, errors.Is(err, ErrCorrupt), "%s: %v", name, err)
+	}
+}
+
+// roundTrip encodes v into a record and decodes it again.
+func roundTrip(t *testing.T, v int64) int64 {
+	columns, err := readColumns(encodeRecord(v))
+	require.NoError(t, err)
+	require.Len(t, columns, 1)
+
+	return columns[0].Value().(int64)
+}
+
+// encodeRecord encodes integers in the SQLite record format, using the smallest
+// serial type for each, like SQLite does:
+// https://www.sqlite.org/fileformat2.html#record_format
+func encodeRecord(values ...int64) []byte {
+	header := []byte{}
+	body := []byte{}
+	for _, v := range values {
+		typ, size := integerSerialType(v)
+		header = appendVarint(header, uint64(typ))
+
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		body = append(body, b[8-size:]...)
+	}
+
+	// The header's size includes its own varint, which is a single byte for
+	// the records in these tests.
+	record := appendVarint(nil, uint64(len(header)+1))
+	record = append(record, header...)
+
+	return append(record, body...)
+}
+
+func integerSerialType(v int64) (typ int, size int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	default:
+		return 6, 8
+	}
+}
+
+// appendVarint appends v as a SQLite varint, which is big-endian with 7 bits per
+// byte, except for the 9th byte which holds 8 bits.
+func appendVarint(b []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		buf := make([]byte, 9)
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf...)
+	}
+
+	buf := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		buf = append([]byte{byte(v&0x7f) | 0x80}, buf...)
+	}
+
+	return append(b, buf...)
+}
@@ -3,6 +3,7 @@ package tree
 import (
 	"bytes"
 	"io"
+	"sort"
 
 	"github.com/colinking/go-sqlite3-native/internal/pager"
 	"github.com/segmentio/events/v2"
@@ -135,13 +136,21 @@ func (t *Tree) ResetCursor() {
 	t.cursorStack = []int{-1}
 }
 
+// The cursor's position within each node on the path from the root is kept in
+// cursorStack. In leaf pages and table interior pages, the position is the index
+// of the record or child. Index interior pages also hold entries, which sort
+// between their children, so their positions alternate between the two: 2i is
+// the i-th child and 2i+1 is the i-th entry.
+
+// Next moves the cursor to the next entry in the tree, returning false once
+// there are no more entries.
 func (t *Tree) Next() bool {
 	for {
 		// Move the cursor to the next record/child in this node:
 		idx := t.cursorStackPeek() + 1
 
 		switch t.cursor.typ {
-		case TreeTypeTableLeaf:
+		case TreeTypeTableLeaf, TreeTypeIndexLeaf:
 			// If there is a record at this index, then we've found a next record:
 			if idx < len(t.cursor.records) {
 				// Store this new index so we can access it on the next Get() call:
@@ -150,61 +159,119 @@ func (t *Tree) Next() bool {
 			}
 			// Otherwise, we've exahusted all records in this leaf node. This means we
 			// should move to the next leaf node.
-			if t.cursor.parent == nil {
+			if !t.up() {
 				// This means there are no more nodes to look for a next record in.
 				return false
 			}
-			t.cursorStackPop()
-			t.cursor = t.cursor.parent
 		case TreeTypeTableInterior:
 			if idx < len(t.cursor.children) {
 				// Move our cursor to this child where we will continue the search
 				t.cursorStack[len(t.cursorStack)-1] = idx
-				t.cursorStack = append(t.cursorStack, -1)
-				if t.cursor.children[idx].node == nil {
-					// We lazy-load children pages until we need them:
-					node, err := newNode(t.cursor.children[idx].pageNumber, t.pager, t.cursor)
-					if err != nil {
-						t.setError(err)
-						return false
-					}
-					t.cursor.children[idx].node = node
+				if !t.down(idx) {
+					return false
 				}
-				t.cursor = t.cursor.children[idx].node
-			} else {
+			} else if !t.up() {
 				// Otherwise, we should pop to this node's parent to continue the search there.
-				if t.cursor.parent == nil {
-					// This means there are no more nodes to look for a next record in.
+				return false
+			}
+		case TreeTypeIndexInterior:
+			if idx >= 2*len(t.cursor.children)-1 {
+				if !t.up() {
 					return false
 				}
-				t.cursorStackPop()
-				t.cursor = t.cursor.parent
+				continue
+			}
+			t.cursorStack[len(t.cursorStack)-1] = idx
+			if idx%2 == 1 {
+				return true
+			}
+			if !t.down(idx / 2) {
+				return false
 			}
 		}
 	}
 }
 
-func (t *Tree) SeekGE(key []byte) error {
-	// TODO: binary search to more efficiently search through children
-
-	// for {
-	// for idx, chld := range t.cursor.children {
-	// All children in chld.node are < chld.key
-	//
-	// chld.key
-	// if t.cursor.children[idx].node == nil {
-	// 	// We lazy-load children pages until we need them:
-	// 	node, err := newNode(t.cursor.children[idx].pageNumber, t.pager, t.cursor)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	t.cursor.children[idx].node = node
-	// }
-	// }
-	// }
+// Seek moves the cursor to the first entry for which less returns false, which
+// must be false for every entry after it, returning false if there is no such
+// entry. For table trees, less is passed records that only hold a rowid.
+func (t *Tree) Seek(less func(Record) bool) bool {
+	t.ResetCursor()
 
-	return nil
-	// t.cursor
+	for {
+		n := t.cursor
+		switch n.typ {
+		case TreeTypeTableLeaf, TreeTypeIndexLeaf:
+			idx := sort.Search(len(n.records), func(i int) bool {
+				return !less(n.records[i])
+			})
+			// Position the cursor just before that entry, which also moves to
+			// the next page if every entry in this one is less.
+			t.cursorStack[len(t.cursorStack)-1] = idx - 1
+			return t.Next()
+		case TreeTypeTableInterior:
+			// The last child is the right-most pointer, which has no key.
+			idx := sort.Search(len(n.children)-1, func(i int) bool {
+				return !less(Record{rowid: n.children[i].keyInt})
+			})
+			t.cursorStack[len(t.cursorStack)-1] = idx
+			if !t.down(idx) {
+				return false
+			}
+		case TreeTypeIndexInterior:
+			idx := sort.Search(len(n.records), func(i int) bool {
+				return !less(n.records[i])
+			})
+			t.cursorStack[len(t.cursorStack)-1] = 2 * idx
+			if !t.down(idx) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// SeekRowid moves the cursor of a table tree to the record with the given rowid,
+// returning false if there is none.
+func (t *Tree) SeekRowid(rowid int) bool {
+	found := t.Seek(func(r Record) bool {
+		return r.rowid < rowid
+	})
+
+	return found && t.Get().rowid == rowid
+}
+
+// down moves the cursor to the idx-th child of the current node.
+func (t *Tree) down(idx int) bool {
+	chld := t.cursor.children[idx]
+	if chld.node == nil {
+		// We lazy-load children pages until we need them:
+		node, err := newNode(chld.pageNumber, t.pager, t.cursor)
+		if err != nil {
+			t.setError(err)
+			return false
+		}
+		chld.node = node
+	}
+
+	t.cursorStack = append(t.cursorStack, -1)
+	t.cursor = chld.node
+
+	return true
+}
+
+// up moves the cursor to the parent of the current node, returning false if it is
+// the root.
+func (t *Tree) up() bool {
+	if t.cursor.parent == nil {
+		return false
+	}
+
+	t.cursorStackPop()
+	t.cursor = t.cursor.parent
+
+	return true
 }
 
 // cursorStackPeek returns the last index in cursorStack
@@ -218,7 +285,12 @@ func (t *Tree) cursorStackPop() {
 }
 
 func (t *Tree) Get() Record {
-	return t.cursor.records[t.cursorStackPeek()]
+	idx := t.cursorStackPeek()
+	if t.cursor.typ == TreeTypeIndexInterior {
+		return t.cursor.records[idx/2]
+	}
+
+	return t.cursor.records[idx]
 }
 
 func (t *Tree) setError(err error) {
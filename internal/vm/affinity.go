@@ -0,0 +1,486 @@
+package vm
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type affinities, as used by the P5 operand of comparison opcodes and by the
+// P2 operand of Cast: https://www.sqlite.org/datatype3.html#type_affinity
+const (
+	AffinityBlob    = 'A'
+	AffinityText    = 'B'
+	AffinityNumeric = 'C'
+	AffinityInteger = 'D'
+	AffinityReal    = 'E'
+)
+
+// TypeAffinity returns the affinity of a column with the declared type declType,
+// f.e. "VARCHAR(255)", following the rules in:
+// https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+//
+// It also determines the affinity that the type name in a CAST expression
+// converts to.
+func TypeAffinity(declType string) int {
+	if declType == "" {
+		return AffinityBlob
+	}
+
+	// The rules are applied in order, so "FLOATING POINT" has INTEGER affinity
+	// because it contains "INT".
+	t := strings.ToUpper(declType)
+	affinity := int(AffinityNumeric)
+	for i := 1; i <= len(t); i++ {
+		switch prefix := t[:i]; {
+		case strings.HasSuffix(prefix, "INT"):
+			return AffinityInteger
+		case strings.HasSuffix(prefix, "CHAR"), strings.HasSuffix(prefix, "CLOB"), strings.HasSuffix(prefix, "TEXT"):
+			affinity = AffinityText
+		case strings.HasSuffix(prefix, "BLOB"):
+			if affinity == AffinityNumeric || affinity == AffinityReal {
+				affinity = AffinityBlob
+			}
+		case strings.HasSuffix(prefix, "REAL"), strings.HasSuffix(prefix, "FLOA"), strings.HasSuffix(prefix, "DOUB"):
+			if affinity == AffinityNumeric {
+				affinity = AffinityReal
+			}
+		}
+	}
+
+	return affinity
+}
+
+// applyAffinity converts r into the storage class preferred by affinity, as when
+// a value is stored in a column with that affinity. Values that cannot be
+// converted without losing information are returned unchanged.
+//
+// See: https://www.sqlite.org/datatype3.html#type_affinity
+func applyAffinity(r Register, affinity int) Register {
+	switch affinity {
+	case AffinityText:
+		return textAffinity(r)
+	case AffinityNumeric, AffinityInteger:
+		r = numericAffinity(r)
+		if r.typ == RegisterTypeFloat {
+			// Reals without a fractional part are stored as integers.
+			if i, ok := realAsInt(r.Float); ok {
+				return Register{typ: RegisterTypeInt, Int: int(i)}
+			}
+		}
+		return r
+	case AffinityReal:
+		r = numericAffinity(r)
+		if r.typ == RegisterTypeInt {
+			return Register{typ: RegisterTypeFloat, Float: float64(r.Int)}
+		}
+		return r
+	default:
+		return r
+	}
+}
+
+// cast converts r as if by a "CAST(r AS type)" expression, where affinity is the
+// affinity of type. NULL is returned unchanged.
+//
+// See: https://www.sqlite.org/lang_expr.html#castexpr
+func cast(r Register, affinity int) Register {
+	if r.typ == RegisterTypeNull {
+		return r
+	}
+
+	switch affinity {
+	case AffinityBlob:
+		if r.typ == RegisterTypeBlob {
+			return r
+		}
+		return Register{typ: RegisterTypeBlob, Blob: []byte(textValue(r))}
+	case AffinityText:
+		return Register{typ: RegisterTypeString, String: textValue(r)}
+	case AffinityInteger:
+		return Register{typ: RegisterTypeInt, Int: intValue(r)}
+	case AffinityReal:
+		return Register{typ: RegisterTypeFloat, Float: realValue(r)}
+	default:
+		return numerify(r)
+	}
+}
+
+// numerify converts text and blobs into numbers by parsing their longest prefix
+// that is a number, like numericValue, except that reals that are small integers
+// become integers. Numbers are returned unchanged.
+func numerify(r Register) Register {
+	if r.typ == RegisterTypeInt || r.typ == RegisterTypeFloat {
+		return r
+	}
+
+	n := numericValue(r)
+	if n.typ == RegisterTypeFloat {
+		// Only integers that a real can represent exactly, with room to spare,
+		// are converted.
+		i := realToInt(n.Float)
+		if n.Float == 0 || (float64(i) == n.Float && i >= -1<<51 && i < 1<<51) {
+			return Register{typ: RegisterTypeInt, Int: int(i)}
+		}
+	}
+
+	return n
+}
+
+// numericAffinity converts text that is a well-formed number into that number.
+// Other values are returned unchanged.
+func numericAffinity(r Register) Register {
+	if r.typ != RegisterTypeString {
+		return r
+	}
+
+	n, whole := parseNumber(r.String)
+	if !whole {
+		return r
+	}
+
+	return n
+}
+
+// textAffinity converts numbers into text. Other values are returned unchanged.
+func textAffinity(r Register) Register {
+	if r.typ != RegisterTypeInt && r.typ != RegisterTypeFloat {
+		return r
+	}
+
+	return Register{typ: RegisterTypeString, String: textValue(r)}
+}
+
+// numericValue returns r as a number: text and blobs are converted by parsing the
+// longest prefix that is a number, or 0 if there is none.
+func numericValue(r Register) Register {
+	switch r.typ {
+	case RegisterTypeInt, RegisterTypeFloat:
+		return r
+	case RegisterTypeString:
+		n, _ := parseNumber(r.String)
+		return n
+	case RegisterTypeBlob:
+		n, _ := parseNumber(string(r.Blob))
+		return n
+	default:
+		return Register{typ: RegisterTypeInt}
+	}
+}
+
+// intValue returns r as an integer. Reals are truncated, saturating at the bounds
+// of an int64, and text and blobs are converted by parsing their longest prefix
+// that is an integer.
+func intValue(r Register) int {
+	switch r.typ {
+	case RegisterTypeInt:
+		return r.Int
+	case RegisterTypeFloat:
+		return int(realToInt(r.Float))
+	case RegisterTypeString:
+		return int(parseIntPrefix(r.String))
+	case RegisterTypeBlob:
+		return int(parseIntPrefix(string(r.Blob)))
+	default:
+		return 0
+	}
+}
+
+// realValue returns r as a real.
+func realValue(r Register) float64 {
+	switch r := numericValue(r); r.typ {
+	case RegisterTypeInt:
+		return float64(r.Int)
+	default:
+		return r.Float
+	}
+}
+
+// textValue returns r as text.
+func textValue(r Register) string {
+	switch r.typ {
+	case RegisterTypeInt:
+		return strconv.Itoa(r.Int)
+	case RegisterTypeFloat:
+		return formatFloat(r.Float)
+	case RegisterTypeString:
+		return r.String
+	case RegisterTypeBlob:
+		return string(r.Blob)
+	default:
+		return ""
+	}
+}
+
+// formatFloat renders f like SQLite's "%!.15g" format: with 15 significant digits,
+// in exponential notation if its exponent is less than -4 or at least 15, and
+// always with a decimal point, f.e. "1.0" or "1.0e+20".
+func formatFloat(f float64) string {
+	spec := printfSpec{altForm2: true, precision: 15}
+
+	return spec.formatFloat(f, printfVerbs['g'])
+}
+
+// realAsInt returns f as an integer, if it has no fractional part and is strictly
+// within the bounds of an int64.
+func realAsInt(f float64) (int64, bool) {
+	i := realToInt(f)
+	if float64(i) != f || i == math.MinInt64 || i == math.MaxInt64 {
+		return 0, false
+	}
+
+	return i, true
+}
+
+// rowidValue returns r as a rowid, after applying NUMERIC affinity. ok is false if
+// it is not an integer, which no rowid can be equal to.
+func rowidValue(r Register) (rowid int, ok bool) {
+	switch r := numericAffinity(r); r.typ {
+	case RegisterTypeInt:
+		return r.Int, true
+	case RegisterTypeFloat:
+		i, ok := realAsInt(r.Float)
+		return int(i), ok
+	default:
+		return 0, false
+	}
+}
+
+// realToInt truncates f, saturating at the bounds of an int64.
+func realToInt(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	default:
+		return int64(f)
+	}
+}
+
+// ParseReal converts the text of a real literal, f.e. "1.5e3", into a real that is
+// rounded exactly like SQLite rounds it.
+func ParseReal(s string) float64 {
+	return atof(s)
+}
+
+// IsNumber returns whether s, ignoring surrounding whitespace, is a well-formed
+// number, which NUMERIC affinity would convert into a number.
+func IsNumber(s string) bool {
+	_, whole := parseNumber(s)
+
+	return whole
+}
+
+// parseNumber parses the longest prefix of s that is a number, ignoring leading
+// whitespace. The number is an integer if it has no decimal point or exponent and
+// fits into an int64, and is a real otherwise. If there is no such prefix, it is
+// the integer 0. whole is true if all of s, ignoring trailing whitespace, is a
+// number.
+func parseNumber(s string) (n Register, whole bool) {
+	start := 0
+	for start < len(s) && isSpace(s[start]) {
+		start++
+	}
+
+	i := start
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits++
+	}
+	isInt := true
+	if i < len(s) && s[i] == '.' {
+		isInt = false
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return Register{typ: RegisterTypeInt}, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		// The exponent is only a part of the number if it has digits.
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+			isInt = false
+		}
+	}
+	prefix := s[start:i]
+
+	for ; i < len(s) && isSpace(s[i]); i++ {
+	}
+	whole = i == len(s)
+
+	if isInt {
+		if v, err := strconv.ParseInt(prefix, 10, 64); err == nil {
+			return Register{typ: RegisterTypeInt, Int: int(v)}, whole
+		}
+	}
+
+	return Register{typ: RegisterTypeFloat, Float: atof(prefix)}, whole
+}
+
+// atof converts s, which must be a well-formed real, like SQLite's sqlite3AtoF:
+// it reads up to 19 significant digits into an integer and then scales it by
+// the exponent with double-double arithmetic, which is not always correctly
+// rounded, so that the result is the same as SQLite's. Out of range values are
+// converted into +/-Inf.
+func atof(s string) float64 {
+	i := 0
+	neg := false
+	if s[i] == '+' || s[i] == '-' {
+		neg = s[i] == '-'
+		i++
+	}
+
+	// The significand, with digits that do not fit ignored, and the exponent of
+	// its last digit.
+	const limit = (math.MaxUint64 - 9) / 10
+	var m uint64
+	e := 0
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		if m >= limit {
+			e++
+			continue
+		}
+		m = m*10 + uint64(s[i]-'0')
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			if m < limit {
+				m = m*10 + uint64(s[i]-'0')
+				e--
+			}
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		esign := 1
+		if s[i] == '+' || s[i] == '-' {
+			if s[i] == '-' {
+				esign = -1
+			}
+			i++
+		}
+		exp := 0
+		for ; i < len(s) && isDigit(s[i]); i++ {
+			if exp < 10000 {
+				exp = exp*10 + int(s[i]-'0')
+			} else {
+				exp = 10000
+			}
+		}
+		e += esign * exp
+	}
+
+	if m == 0 {
+		if neg {
+			return math.Copysign(0, -1)
+		}
+		return 0
+	}
+
+	// Make the exponent smaller, where that is exact.
+	for e > 0 && m < (math.MaxUint64-0x7ff)/10 {
+		m *= 10
+		e--
+	}
+	for e < 0 && m%10 == 0 {
+		m /= 10
+		e++
+	}
+
+	// rr is m as a double-double.
+	rr := [2]float64{float64(m), 0}
+	if rr[0] <= 18446744073709549568.0 {
+		m2 := uint64(rr[0])
+		if m >= m2 {
+			rr[1] = float64(m - m2)
+		} else {
+			rr[1] = -float64(m2 - m)
+		}
+	}
+	for ; e >= 100; e -= 100 {
+		dekkerMul2(&rr, 1.0e+100, -1.5902891109759918046e+83)
+	}
+	for ; e >= 10; e -= 10 {
+		dekkerMul2(&rr, 1.0e+10, 0)
+	}
+	for ; e >= 1; e-- {
+		dekkerMul2(&rr, 1.0e+01, 0)
+	}
+	for ; e <= -100; e += 100 {
+		dekkerMul2(&rr, 1.0e-100, -1.99918998026028836196e-117)
+	}
+	for ; e <= -10; e += 10 {
+		dekkerMul2(&rr, 1.0e-10, -3.6432197315497741579e-27)
+	}
+	for ; e <= -1; e++ {
+		dekkerMul2(&rr, 1.0e-01, -5.5511151231257827021e-18)
+	}
+
+	f := rr[0] + rr[1]
+	if math.IsNaN(f) {
+		f = math.Inf(1)
+	}
+	if neg {
+		f = -f
+	}
+
+	return f
+}
+
+// parseIntPrefix parses the longest prefix of s that is an integer, ignoring
+// leading whitespace, saturating at the bounds of an int64.
+func parseIntPrefix(s string) int64 {
+	i := 0
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	neg := false
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		neg = s[i] == '-'
+		i++
+	}
+
+	var u uint64
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		if u > math.MaxInt64/10 {
+			// The result saturates, but the remaining digits are consumed.
+			u = math.MaxInt64 + 1
+			continue
+		}
+		u = u*10 + uint64(s[i]-'0')
+	}
+
+	switch {
+	case neg && u > math.MaxInt64:
+		return math.MinInt64
+	case neg:
+		return -int64(u)
+	case u > math.MaxInt64:
+		return math.MaxInt64
+	default:
+		return int64(u)
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
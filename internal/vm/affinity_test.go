@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"database/sql/driver"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeAffinity(t *testing.T) {
+	for declType, affinity := range map[string]int{
+		"":                 AffinityBlob,
+		"INT":              AffinityInteger,
+		"integer":          AffinityInteger,
+		"TINYINT":          AffinityInteger,
+		"UNSIGNED BIG INT": AffinityInteger,
+		"CHARINT":          AffinityInteger,
+		"FLOATING POINT":   AffinityInteger,
+		"VARCHAR(255)":     AffinityText,
+		"NCHAR(55)":        AffinityText,
+		"TEXT":             AffinityText,
+		"clob":             AffinityText,
+		"CHAR BLOB":        AffinityText,
+		"BLOB":             AffinityBlob,
+		"REAL BLOB":        AffinityBlob,
+		"BLOB REAL":        AffinityBlob,
+		"REAL":             AffinityReal,
+		"DOUBLE PRECISION": AffinityReal,
+		"FLOAT":            AffinityReal,
+		"NUMERIC":          AffinityNumeric,
+		"DECIMAL(10,5)":    AffinityNumeric,
+		"BOOLEAN":          AffinityNumeric,
+		"DATETIME":         AffinityNumeric,
+		"STRING":           AffinityNumeric,
+	} {
+		require.Equal(t, string(rune(affinity)), string(rune(TypeAffinity(declType))), declType)
+	}
+}
+
+// The expected values in these tests were produced by sqlite3 3.50.2.
+
+func TestCast(tt *testing.T) {
+	for _, test := range []struct {
+		value driver.Value
+		// want has the expected result of casting value to each of BLOB, TEXT,
+		// NUMERIC, INTEGER and REAL.
+		want [5]driver.Value
+	}{
+		{value: nil, want: [5]driver.Value{nil, nil, nil, nil, nil}},
+		{value: int64(12), want: [5]driver.Value{[]byte("12"), "12", int64(12), int64(12), 12.0}},
+		{value: int64(-1), want: [5]driver.Value{[]byte("-1"), "-1", int64(-1), int64(-1), -1.0}},
+		{value: 1.5, want: [5]driver.Value{[]byte("1.5"), "1.5", 1.5, int64(1), 1.5}},
+		{value: 1.0, want: [5]driver.Value{[]byte("1.0"), "1.0", 1.0, int64(1), 1.0}},
+		{value: 1e20, want: [5]driver.Value{[]byte("1.0e+20"), "1.0e+20", 1e20, int64(math.MaxInt64), 1e20}},
+		{value: math.Copysign(0, -1), want: [5]driver.Value{[]byte("0.0"), "0.0", math.Copysign(0, -1), int64(0), math.Copysign(0, -1)}},
+		{value: math.Inf(1), want: [5]driver.Value{[]byte("Inf"), "Inf", math.Inf(1), int64(math.MaxInt64), math.Inf(1)}},
+		{value: "abc", want: [5]driver.Value{[]byte("abc"), "abc", int64(0), int64(0), 0.0}},
+		{value: "12abc", want: [5]driver.Value{[]byte("12abc"), "12abc", int64(12), int64(12), 12.0}},
+		{value: " 1.5 ", want: [5]driver.Value{[]byte(" 1.5 "), " 1.5 ", 1.5, int64(1), 1.5}},
+		{value: "1e3", want: [5]driver.Value{[]byte("1e3"), "1e3", int64(1000), int64(1), 1000.0}},
+		{value: "1.0", want: [5]driver.Value{[]byte("1.0"), "1.0", int64(1), int64(1), 1.0}},
+		{value: "1.5x", want: [5]driver.Value{[]byte("1.5x"), "1.5x", 1.5, int64(1), 1.5}},
+		{value: ".5e1", want: [5]driver.Value{[]byte(".5e1"), ".5e1", int64(5), int64(0), 5.0}},
+		{value: "1e20", want: [5]driver.Value{[]byte("1e20"), "1e20", 1e20, int64(1), 1e20}},
+		{value: "0x10", want: [5]driver.Value{[]byte("0x10"), "0x10", int64(0), int64(0), 0.0}},
+		{value: "9223372036854775808", want: [5]driver.Value{[]byte("9223372036854775808"), "9223372036854775808", 9223372036854775808.0, int64(math.MaxInt64), 9223372036854775808.0}},
+		{value: "-9223372036854775809", want: [5]driver.Value{[]byte("-9223372036854775809"), "-9223372036854775809", -9223372036854775808.0, int64(math.MinInt64), -9223372036854775808.0}},
+		{value: "9223372036854775807.0", want: [5]driver.Value{[]byte("9223372036854775807.0"), "9223372036854775807.0", 9223372036854775807.0, int64(math.MaxInt64), 9223372036854775807.0}},
+		{value: []byte("12"), want: [5]driver.Value{[]byte("12"), "12", int64(12), int64(12), 12.0}},
+		{value: []byte{}, want: [5]driver.Value{[]byte{}, "", int64(0), int64(0), 0.0}},
+	} {
+		for i, affinity := range []int{AffinityBlob, AffinityText, AffinityNumeric, AffinityInteger, AffinityReal} {
+			r := &Registers{}
+			require.NoError(tt, r.SetValue(0, test.value))
+			require.NoError(tt, r.cast(0, affinity))
+			require.Equal(tt, test.want[i], r.Get(0).Value(), "CAST(%#v AS %c)", test.value, affinity)
+		}
+	}
+}
+
+func TestApplyAffinity(tt *testing.T) {
+	for _, test := range []struct {
+		value driver.Value
+		// want has the expected result of storing value in a column with each
+		// of TEXT, NUMERIC, INTEGER, REAL and BLOB affinity.
+		want [5]driver.Value
+	}{
+		{value: nil, want: [5]driver.Value{nil, nil, nil, nil, nil}},
+		{value: "5", want: [5]driver.Value{"5", int64(5), int64(5), 5.0, "5"}},
+		{value: "5.0", want: [5]driver.Value{"5.0", int64(5), int64(5), 5.0, "5.0"}},
+		{value: " 5 ", want: [5]driver.Value{" 5 ", int64(5), int64(5), 5.0, " 5 "}},
+		{value: "5x", want: [5]driver.Value{"5x", "5x", "5x", "5x", "5x"}},
+		{value: 5.0, want: [5]driver.Value{"5.0", int64(5), int64(5), 5.0, 5.0}},
+		{value: int64(5), want: [5]driver.Value{"5", int64(5), int64(5), 5.0, int64(5)}},
+		{value: 5.5, want: [5]driver.Value{"5.5", 5.5, 5.5, 5.5, 5.5}},
+		{value: []byte("5"), want: [5]driver.Value{[]byte("5"), []byte("5"), []byte("5"), []byte("5"), []byte("5")}},
+		{value: "1e3", want: [5]driver.Value{"1e3", int64(1000), int64(1000), 1000.0, "1e3"}},
+		{value: "1e20", want: [5]driver.Value{"1e20", 1e20, 1e20, 1e20, "1e20"}},
+		{value: "9223372036854775808", want: [5]driver.Value{"9223372036854775808", 9223372036854775808.0, 9223372036854775808.0, 9223372036854775808.0, "9223372036854775808"}},
+		{value: 1e20, want: [5]driver.Value{"1.0e+20", 1e20, 1e20, 1e20, 1e20}},
+		{value: "abc", want: [5]driver.Value{"abc", "abc", "abc", "abc", "abc"}},
+	} {
+		for i, affinity := range []int{AffinityText, AffinityNumeric, AffinityInteger, AffinityReal, AffinityBlob} {
+			r := &Registers{}
+			require.NoError(tt, r.SetValue(0, test.value))
+			r.ApplyAffinity(0, affinity)
+			require.Equal(tt, test.want[i], r.Get(0).Value(), "%#v with affinity %c", test.value, affinity)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for f, want := range map[float64]string{
+		1:                       "1.0",
+		-2.5:                    "-2.5",
+		100:                     "100.0",
+		0.1 + 0.2:               "0.3",
+		1.0 / 3:                 "0.333333333333333",
+		0.000123:                "0.000123",
+		1e-5:                    "1.0e-05",
+		1e14:                    "100000000000000.0",
+		1e15:                    "1.0e+15",
+		1e16:                    "1.0e+16",
+		1e20:                    "1.0e+20",
+		1.5e300:                 "1.5e+300",
+		123456789012345.6:       "123456789012346.0",
+		123456789012345678.0:    "1.23456789012346e+17",
+		9.99999999999999e14:     "999999999999999.0",
+		2e-320:                  "1.99997773436537e-320",
+		math.Copysign(0, -1):    "0.0",
+		math.Inf(1):             "Inf",
+		math.Inf(-1):            "-Inf",
+		-9223372036854775808.0:  "-9.22337203685478e+18",
+		9223372036854775807.0:   "9.22337203685478e+18",
+		0.00001234567890123456:  "1.23456789012346e-05",
+		12345678901234567890e10: "1.23456789012346e+29",
+	} {
+		require.Equal(t, want, formatFloat(f), "%v", f)
+	}
+}
+
+func TestAtof(t *testing.T) {
+	for s, want := range map[string]float64{
+		"1.5":                        1.5,
+		"-0.0":                       math.Copysign(0, -1),
+		"1e99999":                    math.Inf(1),
+		"-1e99999":                   math.Inf(-1),
+		"1e-99999":                   0,
+		"123456789012345678901234.5": 1.2345678901234568e+23,
+		// SQLite's conversion is not correctly rounded: this is 1 ulp above the
+		// nearest real, 8.30117917497365e+133.
+		"8.30117917497365e+133": 8.301179174973651e+133,
+	} {
+		require.Equal(t, want, atof(s), s)
+	}
+}
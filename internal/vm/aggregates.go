@@ -0,0 +1,367 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Aggregate is a built-in aggregate SQL function, as called by OpcodeAggStep for
+// each row of a group and by OpcodeAggFinal for its result. Called as window
+// functions, they are also called by OpcodeAggInverse for each row that leaves
+// the frame, and by OpcodeAggValue for the result of each row.
+//
+// See: https://www.sqlite.org/lang_aggfunc.html
+type Aggregate struct {
+	Name string
+	// MinArgs and MaxArgs are the bounds of the number of arguments that the
+	// function accepts.
+	MinArgs int
+	MaxArgs int
+	// Collation is set for functions that compare their argument using its
+	// collating sequence, which is passed to them by a preceding OpcodeCollSeq.
+	Collation bool
+	// Inverse is set for functions that can remove rows from their state with
+	// OpcodeAggInverse.
+	Inverse bool
+	// Window is set for the built-in window functions, which can only be called
+	// as window functions.
+	Window bool
+
+	new func() aggregateState
+}
+
+// aggregateState accumulates the rows of a group for an aggregate function.
+type aggregateState interface {
+	// step adds the arguments of a row to the state. skip is set if the row
+	// does not change the result, which min and max report so that bare
+	// columns can be read from the row that they return.
+	step(args []Register, coll collation) (skip bool, err error)
+	// final returns the result for the rows that were added. Window functions
+	// call it once for each row of the partition, in order.
+	final() (Register, error)
+}
+
+// inverter is implemented by the states that can remove the first of the rows
+// that are left, which window functions do once it leaves the frame.
+type inverter interface {
+	inverse(args []Register) error
+}
+
+// aggregates are the built-in aggregate functions, keyed by their lower-case name.
+var aggregates = map[string]*Aggregate{}
+
+func registerAggregate(name string, minArgs, maxArgs int, new func() aggregateState) *Aggregate {
+	a := &Aggregate{Name: name, MinArgs: minArgs, MaxArgs: maxArgs, new: new}
+	_, a.Inverse = new().(inverter)
+	aggregates[name] = a
+
+	return a
+}
+
+// IsAggregate returns true if there is an aggregate function with the given
+// case-insensitive name, regardless of the number of arguments that it accepts.
+func IsAggregate(name string) bool {
+	_, ok := aggregates[strings.ToLower(name)]
+	return ok
+}
+
+// LookupAggregate returns the aggregate function with the given case-insensitive
+// name, checking that it accepts numArgs arguments.
+func LookupAggregate(name string, numArgs int) (*Aggregate, error) {
+	a, ok := aggregates[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("no such function: %s", name)
+	}
+	if numArgs < a.MinArgs || numArgs > a.MaxArgs {
+		return nil, fmt.Errorf("wrong number of arguments to function %s()", name)
+	}
+
+	return a, nil
+}
+
+func init() {
+	registerAggregate("count", 0, 1, func() aggregateState { return &countState{} })
+	registerAggregate("sum", 1, 1, func() aggregateState { return &sumState{kind: kindSum} })
+	registerAggregate("total", 1, 1, func() aggregateState { return &sumState{kind: kindTotal} })
+	registerAggregate("avg", 1, 1, func() aggregateState { return &sumState{kind: kindAvg} })
+	registerAggregate("min", 1, 1, func() aggregateState { return &minMaxState{sign: -1} })
+	registerAggregate("max", 1, 1, func() aggregateState { return &minMaxState{sign: 1} })
+	registerAggregate("group_concat", 1, 2, func() aggregateState { return &groupConcatState{} })
+	registerAggregate("string_agg", 2, 2, func() aggregateState { return &groupConcatState{} })
+	aggregates["min"].Collation = true
+	aggregates["max"].Collation = true
+}
+
+// countState counts the rows, for count(*), or the non-NULL arguments.
+type countState struct {
+	n int
+}
+
+func (s *countState) step(args []Register, coll collation) (bool, error) {
+	if len(args) == 0 || args[0].typ != RegisterTypeNull {
+		s.n++
+	}
+
+	return false, nil
+}
+
+func (s *countState) inverse(args []Register) error {
+	if len(args) == 0 || args[0].typ != RegisterTypeNull {
+		s.n--
+	}
+
+	return nil
+}
+
+func (s *countState) final() (Register, error) {
+	return Register{typ: RegisterTypeInt, Int: s.n}, nil
+}
+
+// sumKind is the function that a sumState computes.
+type sumKind int
+
+const (
+	kindSum sumKind = iota
+	kindTotal
+	kindAvg
+)
+
+// sumState adds up the non-NULL arguments, for sum, total and avg. Like SQLite,
+// integers are added exactly until a real is added or the sum overflows, after
+// which the sum is approximated with Kahan-Babuska-Neumaier summation.
+type sumState struct {
+	kind sumKind
+	// n is the number of non-NULL arguments.
+	n int
+	// iSum is the exact sum of integers, until approx is set, and then rSum
+	// and rErr are the approximate sum and its error term.
+	iSum       int64
+	rSum, rErr float64
+	approx     bool
+	// overflow is set if the integer sum overflowed, and no real was added
+	// since, which is an error for sum.
+	overflow bool
+}
+
+// bigInt is the magnitude from which integers cannot all be represented exactly as
+// reals, 2^52.
+const bigInt = 4503599627370496
+
+func (s *sumState) step(args []Register, coll collation) (bool, error) {
+	arg := args[0]
+	if arg.typ == RegisterTypeNull {
+		return false, nil
+	}
+	s.n++
+
+	n := numericAffinity(arg)
+	switch {
+	case !s.approx && n.typ == RegisterTypeInt:
+		x := int64(n.Int)
+		sum := s.iSum + x
+		if (x > 0 && sum < s.iSum) || (x < 0 && sum > s.iSum) {
+			s.overflow = true
+			s.startApprox()
+			s.addInt(x)
+		} else {
+			s.iSum = sum
+		}
+	case !s.approx:
+		s.startApprox()
+		s.add(realValue(arg))
+	case n.typ == RegisterTypeInt:
+		s.addInt(int64(n.Int))
+	default:
+		s.overflow = false
+		s.add(realValue(arg))
+	}
+
+	return false, nil
+}
+
+// inverse subtracts the argument, like SQLite: exactly, unless the sum is already
+// approximated.
+func (s *sumState) inverse(args []Register) error {
+	arg := args[0]
+	if arg.typ == RegisterTypeNull {
+		return nil
+	}
+	s.n--
+
+	switch n := numericAffinity(arg); {
+	case !s.approx:
+		s.iSum -= int64(n.Int)
+	case n.typ == RegisterTypeInt && n.Int == math.MinInt64:
+		s.addInt(math.MaxInt64)
+		s.addInt(1)
+	case n.typ == RegisterTypeInt:
+		s.addInt(-int64(n.Int))
+	default:
+		s.add(-realValue(arg))
+	}
+
+	return nil
+}
+
+// startApprox switches from the exact integer sum to the approximate sum.
+func (s *sumState) startApprox() {
+	s.approx = true
+	if s.iSum <= -bigInt || s.iSum >= bigInt {
+		small := s.iSum % 16384
+		s.rSum, s.rErr = float64(s.iSum-small), float64(small)
+	} else {
+		s.rSum, s.rErr = float64(s.iSum), 0
+	}
+}
+
+// add adds r to the approximate sum.
+func (s *sumState) add(r float64) {
+	t := s.rSum + r
+	if math.Abs(s.rSum) > math.Abs(r) {
+		s.rErr += (s.rSum - t) + r
+	} else {
+		s.rErr += (r - t) + s.rSum
+	}
+	s.rSum = t
+}
+
+// addInt adds the integer i to the approximate sum, in two parts if it is too big
+// to be represented exactly as a real.
+func (s *sumState) addInt(i int64) {
+	if i <= -bigInt || i >= bigInt {
+		big := i - i%16384
+		s.add(float64(big))
+		s.add(float64(i - big))
+		return
+	}
+	s.add(float64(i))
+}
+
+// real returns the sum as a real.
+func (s *sumState) real() float64 {
+	if !s.approx {
+		return float64(s.iSum)
+	}
+	if math.IsInf(s.rErr, 0) {
+		return s.rSum
+	}
+
+	return s.rSum + s.rErr
+}
+
+func (s *sumState) final() (Register, error) {
+	switch {
+	case s.kind == kindTotal:
+		return Register{typ: RegisterTypeFloat, Float: s.real()}, nil
+	case s.n == 0:
+		// sum and avg are NULL if there are no non-NULL arguments.
+		return Register{typ: RegisterTypeNull}, nil
+	case s.kind == kindAvg:
+		return Register{typ: RegisterTypeFloat, Float: s.real() / float64(s.n)}, nil
+	case s.overflow:
+		return Register{}, errIntegerOverflow
+	case s.approx:
+		return Register{typ: RegisterTypeFloat, Float: s.real()}, nil
+	default:
+		return Register{typ: RegisterTypeInt, Int: int(s.iSum)}, nil
+	}
+}
+
+// minMaxState keeps the smallest non-NULL argument, if sign is -1, or the largest,
+// if sign is 1. Of equal arguments, it keeps the first.
+type minMaxState struct {
+	sign int
+	best Register
+	ok   bool
+}
+
+func (s *minMaxState) step(args []Register, coll collation) (bool, error) {
+	arg := args[0]
+	if arg.typ == RegisterTypeNull {
+		return s.ok, nil
+	}
+	if s.ok {
+		if cmp := compareValues(s.best, arg, coll); (s.sign < 0 && cmp <= 0) || (s.sign > 0 && cmp >= 0) {
+			return true, nil
+		}
+	}
+	if arg.typ == RegisterTypeBlob {
+		arg.Blob = append([]byte{}, arg.Blob...)
+	}
+	s.best, s.ok = arg, true
+
+	return false, nil
+}
+
+func (s *minMaxState) final() (Register, error) {
+	if !s.ok {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	return s.best, nil
+}
+
+// groupConcatState concatenates the non-NULL arguments as text, separated by the
+// second argument, which is "," if there is none.
+type groupConcatState struct {
+	// values holds the text of the arguments that are left from head on, and
+	// seps the separators that precede them, that of the first one excepted.
+	values, seps []string
+	head         int
+	// n is the length of the result.
+	n int
+}
+
+func (s *groupConcatState) step(args []Register, coll collation) (bool, error) {
+	if args[0].typ == RegisterTypeNull {
+		return false, nil
+	}
+
+	sep := ","
+	if len(args) == 2 {
+		sep = textValue(args[1])
+	}
+	if s.head < len(s.values) {
+		s.n += len(sep)
+	}
+	value := textValue(args[0])
+	s.values, s.seps = append(s.values, value), append(s.seps, sep)
+	s.n += len(value)
+	if s.n > maxLength {
+		return false, ErrTooBig
+	}
+
+	return false, nil
+}
+
+// inverse removes the first value, along with the separator that follows it.
+func (s *groupConcatState) inverse(args []Register) error {
+	if args[0].typ == RegisterTypeNull {
+		return nil
+	}
+
+	s.n -= len(s.values[s.head])
+	s.head++
+	if s.head < len(s.values) {
+		s.n -= len(s.seps[s.head])
+	}
+
+	return nil
+}
+
+func (s *groupConcatState) final() (Register, error) {
+	if s.head == len(s.values) {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	var b strings.Builder
+	b.Grow(s.n)
+	b.WriteString(s.values[s.head])
+	for i := s.head + 1; i < len(s.values); i++ {
+		b.WriteString(s.seps[i])
+		b.WriteString(s.values[i])
+	}
+
+	return text(b.String()), nil
+}
@@ -0,0 +1,353 @@
+package vm
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The expected values in these tests were produced by sqlite3 3.50.2.
+
+func TestAggregates(tt *testing.T) {
+	for _, test := range []struct {
+		name string
+		call string
+		coll string
+		// rows holds the arguments of each row.
+		rows [][]driver.Value
+		want driver.Value
+	}{
+		{name: "count rows", call: "count", rows: [][]driver.Value{{}, {}}, want: int64(2)},
+		{name: "count values", call: "count", rows: [][]driver.Value{{int64(1)}, {nil}, {"x"}}, want: int64(2)},
+		{name: "count nothing", call: "count", want: int64(0)},
+		{name: "sum integers", call: "sum", rows: [][]driver.Value{{int64(1)}, {int64(2)}, {nil}}, want: int64(3)},
+		{name: "sum text", call: "sum", rows: [][]driver.Value{{"12"}, {"abc"}, {[]byte("12")}}, want: 24.0},
+		{name: "sum real text", call: "sum", rows: [][]driver.Value{{"1e1"}}, want: 10.0},
+		{name: "sum reals", call: "sum", rows: [][]driver.Value{{0.1}, {0.2}, {0.3}}, want: 0.6},
+		{name: "sum overflow then real", call: "sum", rows: [][]driver.Value{{int64(9223372036854775807)}, {int64(1)}, {-1.0}}, want: 9223372036854775810.0},
+		{name: "sum nulls", call: "sum", rows: [][]driver.Value{{nil}}, want: nil},
+		{name: "total", call: "total", rows: [][]driver.Value{{int64(9223372036854775807)}, {int64(1)}}, want: 9223372036854775810.0},
+		{name: "total nulls", call: "total", rows: [][]driver.Value{{nil}}, want: 0.0},
+		{name: "avg", call: "avg", rows: [][]driver.Value{{int64(1)}, {int64(2)}, {nil}}, want: 1.5},
+		{name: "avg nulls", call: "avg", rows: [][]driver.Value{{nil}}, want: nil},
+		{name: "min", call: "min", rows: [][]driver.Value{{"b"}, {"A"}, {int64(1)}, {[]byte{0}}, {nil}}, want: int64(1)},
+		{name: "max", call: "max", rows: [][]driver.Value{{"b"}, {"A"}, {int64(1)}, {[]byte{0}}, {nil}}, want: []byte{0}},
+		{name: "min nocase", call: "min", coll: "NOCASE", rows: [][]driver.Value{{"b"}, {"A"}, {"a"}, {"B"}}, want: "A"},
+		{name: "max nocase", call: "max", coll: "NOCASE", rows: [][]driver.Value{{"b"}, {"A"}, {"a"}, {"B"}}, want: "b"},
+		{name: "min nulls", call: "min", rows: [][]driver.Value{{nil}}, want: nil},
+		{name: "group_concat", call: "group_concat", rows: [][]driver.Value{{int64(1)}, {nil}, {2.5}}, want: "1,2.5"},
+		{
+			name: "group_concat separators",
+			call: "group_concat",
+			rows: [][]driver.Value{{int64(1), "-"}, {nil, "+"}, {2.5, nil}, {[]byte("hi"), "|"}},
+			want: "12.5|hi",
+		},
+		{name: "group_concat nulls", call: "group_concat", rows: [][]driver.Value{{nil}}, want: nil},
+		{name: "string_agg", call: "string_agg", rows: [][]driver.Value{{"a", ";"}, {"b", ";"}}, want: "a;b"},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			coll, err := lookupCollation(test.coll)
+			require.NoError(t, err)
+
+			numArgs := 1
+			if len(test.rows) > 0 {
+				numArgs = len(test.rows[0])
+			}
+			f, err := LookupAggregate(test.call, numArgs)
+			require.NoError(t, err)
+
+			state := f.new()
+			for _, row := range test.rows {
+				regs := &Registers{}
+				args := make([]Register, len(row))
+				for i, v := range row {
+					require.NoError(t, regs.SetValue(i, v))
+					args[i] = regs.Get(i)
+				}
+				_, err := state.step(args, coll)
+				require.NoError(t, err)
+			}
+
+			result, err := state.final()
+			require.NoError(t, err)
+			require.Equal(t, test.want, result.Value())
+		})
+	}
+}
+
+func TestSumOverflow(t *testing.T) {
+	f, err := LookupAggregate("sum", 1)
+	require.NoError(t, err)
+
+	state := f.new()
+	for _, i := range []int{9223372036854775807, 1, -1} {
+		_, err := state.step([]Register{{typ: RegisterTypeInt, Int: i}}, nil)
+		require.NoError(t, err)
+	}
+	_, err = state.final()
+	require.EqualError(t, err, "integer overflow")
+}
+
+func TestLookupAggregate(t *testing.T) {
+	_, err := LookupAggregate("COUNT", 0)
+	require.NoError(t, err)
+
+	_, err = LookupAggregate("string_agg", 1)
+	require.EqualError(t, err, "wrong number of arguments to function string_agg()")
+
+	_, err = LookupAggregate("lower", 1)
+	require.EqualError(t, err, "no such function: lower")
+	require.True(t, IsAggregate("Max"))
+	require.False(t, IsAggregate("lower"))
+}
+
+// TestGroupBy runs a program that groups sorted rows, like one compiled for:
+//
+//	SELECT g, count(*), max(v) FROM (VALUES ('b', 1), ('a', 2), ('B', 3)) GROUP BY g COLLATE NOCASE
+func TestGroupBy(t *testing.T) {
+	require := require.New(t)
+
+	// r1 and r2 hold the key and value of each row, r3 the key of the group,
+	// r4 and r5 the accumulators and r6 the return address of the output
+	// subroutine.
+	keyInfo := &KeyInfo{Collations: []string{"NOCASE"}, Desc: []bool{false}}
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstructionKeyInfo(OpcodeSorterOpen, 0, 2, 0, keyInfo, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "b", 0),
+			NewInstruction(OpcodeInteger, 1, 2, 0, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 2, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "a", 0),
+			NewInstruction(OpcodeInteger, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 2, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "B", 0),
+			NewInstruction(OpcodeInteger, 3, 2, 0, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 2, 0, 0),
+			NewInstruction(OpcodeSorterSort, 0, 28, 0, 0, 0),
+			NewInstruction(OpcodeSorterData, 0, 1, 2, 0, 0),
+			NewInstruction(OpcodeCopy, 1, 3, 0, 0, 0),
+			// 14: each row is compared with the key of the group, which is
+			// output when it changes.
+			NewInstruction(OpcodeSorterData, 0, 1, 2, 0, 0),
+			NewInstructionKeyInfo(OpcodeCompare, 3, 1, 1, keyInfo, 0),
+			NewInstruction(OpcodeJump, 17, 19, 17, 0, 0),
+			NewInstruction(OpcodeGosub, 6, 24, 0, 0, 0),
+			NewInstruction(OpcodeCopy, 1, 3, 0, 0, 0),
+			NewInstructionStr(OpcodeAggStep, 0, 0, 4, "count", 0),
+			NewInstructionStr(OpcodeAggStep, 0, 2, 5, "max", 1),
+			NewInstruction(OpcodeSorterNext, 0, 14, 0, 0, 0),
+			NewInstruction(OpcodeGosub, 6, 24, 0, 0, 0),
+			NewInstruction(OpcodeGoto, 0, 28, 0, 0, 0),
+			// 24: the output subroutine.
+			NewInstructionStr(OpcodeAggFinal, 4, 0, 0, "count", 0),
+			NewInstructionStr(OpcodeAggFinal, 5, 1, 0, "max", 0),
+			NewInstruction(OpcodeResultRow, 3, 3, 0, 0, 0),
+			NewInstruction(OpcodeReturn, 6, 0, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	var rows [][]driver.Value
+	for {
+		row, err := e.Next()
+		require.NoError(err)
+		if row == nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	require.Equal([][]driver.Value{
+		{"a", int64(1), int64(2)},
+		{"b", int64(2), int64(3)},
+	}, rows)
+}
+
+func TestWindowFunctions(tt *testing.T) {
+	// The rows of the partition are a = 1, 2, 3, 3 and 5, ordered by a.
+	a := [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}, {int64(3)}, {int64(5)}}
+	// frame returns the same frame for each row.
+	frame := func(start, end int) [][2]int {
+		return [][2]int{{start, end}, {start, end}, {start, end}, {start, end}, {start, end}}
+	}
+	for _, test := range []struct {
+		name string
+		call string
+		rows [][]driver.Value
+		// frames holds the first row of the frame of each row, and the row
+		// after its last one.
+		frames [][2]int
+		want   []driver.Value
+	}{
+		{
+			name:   "row_number",
+			call:   "row_number",
+			rows:   [][]driver.Value{{}, {}, {}},
+			frames: [][2]int{{0, 1}, {0, 2}, {0, 3}},
+			want:   []driver.Value{int64(1), int64(2), int64(3)},
+		},
+		{
+			name:   "rank",
+			call:   "rank",
+			rows:   [][]driver.Value{{}, {}, {}, {}, {}},
+			frames: [][2]int{{0, 1}, {0, 2}, {0, 4}, {0, 4}, {0, 5}},
+			want:   []driver.Value{int64(1), int64(2), int64(3), int64(3), int64(5)},
+		},
+		{
+			name:   "dense_rank",
+			call:   "dense_rank",
+			rows:   [][]driver.Value{{}, {}, {}, {}, {}},
+			frames: [][2]int{{0, 1}, {0, 2}, {0, 4}, {0, 4}, {0, 5}},
+			want:   []driver.Value{int64(1), int64(2), int64(3), int64(3), int64(4)},
+		},
+		{
+			name:   "percent_rank",
+			call:   "percent_rank",
+			rows:   [][]driver.Value{{}, {}, {}, {}, {}},
+			frames: [][2]int{{0, 5}, {1, 5}, {2, 5}, {2, 5}, {4, 5}},
+			want:   []driver.Value{0.0, 0.25, 0.5, 0.5, 1.0},
+		},
+		{
+			name:   "cume_dist",
+			call:   "cume_dist",
+			rows:   [][]driver.Value{{}, {}, {}, {}, {}},
+			frames: [][2]int{{1, 5}, {2, 5}, {4, 5}, {4, 5}, {5, 5}},
+			want:   []driver.Value{0.2, 0.4, 0.8, 0.8, 1.0},
+		},
+		{
+			name:   "ntile",
+			call:   "ntile",
+			rows:   [][]driver.Value{{int64(3)}, {int64(3)}, {int64(3)}, {int64(3)}, {int64(3)}},
+			frames: [][2]int{{0, 5}, {1, 5}, {2, 5}, {3, 5}, {4, 5}},
+			want:   []driver.Value{int64(1), int64(1), int64(2), int64(2), int64(3)},
+		},
+		{
+			name:   "ntile with more buckets than rows",
+			call:   "ntile",
+			rows:   [][]driver.Value{{"9"}, {nil}},
+			frames: [][2]int{{0, 2}, {1, 2}},
+			want:   []driver.Value{int64(1), int64(2)},
+		},
+		{
+			name:   "lag",
+			call:   "lag",
+			rows:   a,
+			frames: frame(0, 5),
+			want:   []driver.Value{nil, int64(1), int64(2), int64(3), int64(3)},
+		},
+		{
+			name:   "lead with offsets and default",
+			call:   "lead",
+			rows:   [][]driver.Value{{"x", int64(2), "d"}, {"y", "1", nil}, {"z", 1.5, "d"}, {"w", nil, "d"}},
+			frames: [][2]int{{0, 4}, {0, 4}, {0, 4}, {0, 4}},
+			want:   []driver.Value{"z", "z", "d", "d"},
+		},
+		{
+			name:   "first_value",
+			call:   "first_value",
+			rows:   a,
+			frames: [][2]int{{0, 2}, {0, 3}, {1, 4}, {2, 5}, {3, 5}},
+			want:   []driver.Value{int64(1), int64(1), int64(2), int64(3), int64(3)},
+		},
+		{
+			name:   "last_value",
+			call:   "last_value",
+			rows:   a,
+			frames: [][2]int{{0, 1}, {0, 2}, {1, 4}, {4, 4}, {4, 5}},
+			want:   []driver.Value{int64(1), int64(2), int64(3), nil, int64(5)},
+		},
+		{
+			name:   "nth_value",
+			call:   "nth_value",
+			rows:   [][]driver.Value{{"x", int64(2)}, {"y", int64(2)}, {"z", "2"}, {"w", 2.0}},
+			frames: [][2]int{{0, 1}, {0, 2}, {1, 3}, {2, 4}},
+			want:   []driver.Value{nil, "y", "z", "w"},
+		},
+		{
+			name:   "sliding sum",
+			call:   "sum",
+			rows:   [][]driver.Value{{int64(1)}, {nil}, {2.5}, {int64(3)}, {int64(4)}},
+			frames: [][2]int{{0, 2}, {0, 3}, {1, 4}, {2, 5}, {3, 5}},
+			want:   []driver.Value{int64(1), 3.5, 5.5, 9.5, 7.0},
+		},
+		{
+			name:   "sliding count",
+			call:   "count",
+			rows:   [][]driver.Value{{int64(1)}, {nil}, {int64(3)}},
+			frames: [][2]int{{0, 2}, {1, 3}, {2, 3}},
+			want:   []driver.Value{int64(1), int64(1), int64(1)},
+		},
+		{
+			name:   "sliding group_concat",
+			call:   "group_concat",
+			rows:   [][]driver.Value{{"a", "-"}, {nil, "+"}, {"b", ";"}, {"c", "|"}},
+			frames: [][2]int{{0, 2}, {0, 3}, {1, 4}, {3, 4}},
+			want:   []driver.Value{"a", "a;b", "b|c", "c"},
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			f, err := LookupAggregate(test.call, len(test.rows[0]))
+			require.NoError(t, err)
+
+			args := make([][]Register, len(test.rows))
+			for i, row := range test.rows {
+				regs := &Registers{}
+				for j, v := range row {
+					require.NoError(t, regs.SetValue(j, v))
+					args[i] = append(args[i], regs.Get(j))
+				}
+			}
+
+			// The rows are added and removed like the frames of a window do:
+			// each row once, in order.
+			state := f.new()
+			added, removed := 0, 0
+			var got []driver.Value
+			for _, frame := range test.frames {
+				for ; added < frame[1]; added++ {
+					_, err := state.step(args[added], nil)
+					require.NoError(t, err)
+				}
+				for ; removed < frame[0]; removed++ {
+					require.NoError(t, state.(inverter).inverse(args[removed]))
+				}
+				result, err := state.final()
+				require.NoError(t, err)
+				got = append(got, result.Value())
+			}
+			require.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestWindowFunctionErrors(t *testing.T) {
+	for _, test := range []struct {
+		call string
+		args []Register
+		err  string
+	}{
+		{call: "ntile", args: []Register{{typ: RegisterTypeInt}}, err: "argument of ntile must be a positive integer"},
+		{call: "ntile", args: []Register{{typ: RegisterTypeString, String: "x"}}, err: "argument of ntile must be a positive integer"},
+		{call: "nth_value", args: []Register{{typ: RegisterTypeNull}, {typ: RegisterTypeFloat, Float: 1.5}}, err: "second argument to nth_value must be a positive integer"},
+		{call: "nth_value", args: []Register{{typ: RegisterTypeNull}, {typ: RegisterTypeNull}}, err: "second argument to nth_value must be a positive integer"},
+	} {
+		f, err := LookupAggregate(test.call, len(test.args))
+		require.NoError(t, err)
+		_, err = f.new().step(test.args, nil)
+		require.EqualError(t, err, test.err, test.call)
+	}
+
+	f, err := LookupAggregate("rank", 0)
+	require.NoError(t, err)
+	require.True(t, f.Window)
+	require.False(t, f.Inverse)
+
+	f, err = LookupAggregate("max", 1)
+	require.NoError(t, err)
+	require.False(t, f.Window)
+	require.False(t, f.Inverse)
+}
@@ -5,7 +5,28 @@ import "fmt"
 type Program struct {
 	Instructions    []Instruction
 	NumPlaceholders int
-	Columns         []string
+	// Parameters holds the name of each parameter, where Parameters[i] is the
+	// name of parameter i+1, including its prefix (f.e. ":id"). Anonymous "?"
+	// parameters have an empty name. It is either empty or has NumPlaceholders
+	// entries.
+	Parameters []string
+	Columns    []string
+	// ColumnMetadata describes each of the result columns in Columns.
+	ColumnMetadata []ColumnMetadata
+}
+
+// ColumnMetadata describes the table column that a result column is read from,
+// similar to SQLite's sqlite3_column_decltype and sqlite3_column_table_name.
+type ColumnMetadata struct {
+	// Table and Column name the table column that the result column is read from.
+	// They are empty if the result column is not a direct reference to a table
+	// column, f.e. if it is an expression.
+	Table  string
+	Column string
+	// DeclType is the declared type of the table column, f.e. "VARCHAR(255)".
+	DeclType string
+	// NotNull is true if the table column can never contain NULL.
+	NotNull bool
 }
 
 func (p Program) String() string {
@@ -25,10 +46,40 @@ type Instruction struct {
 	P4 struct {
 		i int
 		s string
+		f float64
+		k *KeyInfo
 	}
 	P5 int
 }
 
+// KeyInfo describes how the entries of an index, sorter or ephemeral index are
+// compared, as the P4 operand of the instruction that opens it or of a Compare.
+type KeyInfo struct {
+	// Collations holds the name of the collating sequence of each column, where
+	// "" is BINARY.
+	Collations []string
+	// Desc is true for each column that is sorted in descending order.
+	Desc []bool
+	// BigNull is true for each column whose NULLs sort after the other values,
+	// before Desc is applied, like SQLite's KEYINFO_ORDER_BIGNULL. It is set by
+	// "ASC NULLS LAST" and "DESC NULLS FIRST", and may be nil.
+	BigNull []bool
+}
+
+func (k *KeyInfo) String() string {
+	// Like SQLite's EXPLAIN output, f.e. "k(2,-NOCASE,)"
+	s := fmt.Sprintf("k(%d", len(k.Collations))
+	for i, coll := range k.Collations {
+		s += ","
+		if k.Desc[i] {
+			s += "-"
+		}
+		s += coll
+	}
+
+	return s + ")"
+}
+
 func NewInstruction(op Opcode, p1, p2, p3, p4, p5 int) Instruction {
 	in := Instruction{
 		Op: op,
@@ -55,9 +106,44 @@ func NewInstructionStr(op Opcode, p1, p2, p3 int, p4 string, p5 int) Instruction
 	return in
 }
 
+// NewInstructionReal returns an instruction whose P4 operand is a real, as used by
+// OpcodeReal.
+func NewInstructionReal(op Opcode, p1, p2, p3 int, p4 float64, p5 int) Instruction {
+	in := Instruction{
+		Op: op,
+		P1: p1,
+		P2: p2,
+		P3: p3,
+		P5: p5,
+	}
+	in.P4.f = p4
+
+	return in
+}
+
+// NewInstructionKeyInfo returns an instruction whose P4 operand is a KeyInfo, as
+// used by OpcodeOpenRead to open an index, or by OpcodeSorterOpen.
+func NewInstructionKeyInfo(op Opcode, p1, p2, p3 int, p4 *KeyInfo, p5 int) Instruction {
+	in := Instruction{
+		Op: op,
+		P1: p1,
+		P2: p2,
+		P3: p3,
+		P5: p5,
+	}
+	in.P4.k = p4
+	in.P4.i = len(p4.Collations)
+
+	return in
+}
+
 func (i Instruction) String() string {
 	p4 := i.P4.s
-	if p4 == "" {
+	if i.P4.k != nil {
+		p4 = i.P4.k.String()
+	} else if p4 == "" && i.P4.f != 0 {
+		p4 = fmt.Sprintf("%g", i.P4.f)
+	} else if p4 == "" {
 		p4 = fmt.Sprintf("%d", i.P4.i)
 	}
 
@@ -83,4 +169,77 @@ const (
 	OpcodeGoto
 	OpcodeNext
 	OpcodeRewind
+	OpcodeVariable
+	OpcodeInteger
+	OpcodeReal
+	OpcodeNull
+	OpcodeBlob
+	OpcodeCopy
+	OpcodeSCopy
+	OpcodeAdd
+	OpcodeSubtract
+	OpcodeMultiply
+	OpcodeDivide
+	OpcodeRemainder
+	OpcodeConcat
+	OpcodeBitAnd
+	OpcodeBitOr
+	OpcodeShiftLeft
+	OpcodeShiftRight
+	OpcodeEq
+	OpcodeNe
+	OpcodeLt
+	OpcodeLe
+	OpcodeGt
+	OpcodeGe
+	OpcodeZeroOrNull
+	OpcodeAnd
+	OpcodeOr
+	OpcodeNot
+	OpcodeIf
+	OpcodeIfNot
+	OpcodeAffinity
+	OpcodeRealAffinity
+	OpcodeFunction
+	OpcodeRowid
+	OpcodeSeekGT
+	OpcodeSeekRowid
+	OpcodeIdxGE
+	OpcodeIdxLT
+	OpcodeIdxLE
+	OpcodeIfPos
+	OpcodeAggStep
+	OpcodeAggFinal
+	OpcodeCollSeq
+	OpcodeCompare
+	OpcodeJump
+	OpcodeGosub
+	OpcodeReturn
+	OpcodeSorterOpen
+	OpcodeSorterInsert
+	OpcodeSorterSort
+	OpcodeSorterNext
+	OpcodeSorterData
+	OpcodeOpenEphemeral
+	OpcodeFound
+	OpcodeIdxInsert
+	OpcodeDecrJumpZero
+	OpcodeMustBeInt
+	OpcodeOffsetLimit
+	OpcodeNullRow
+	OpcodeNotFound
+	OpcodeOnce
+	OpcodeInitCoroutine
+	OpcodeYield
+	OpcodeEndCoroutine
+	OpcodeIdxDelete
+	OpcodeOpenDup
+	OpcodeSequence
+	OpcodeInsert
+	OpcodeDelete
+	OpcodeAggInverse
+	OpcodeAggValue
+	OpcodeAddImm
+	OpcodeNotNull
+	OpcodeBitNot
 )
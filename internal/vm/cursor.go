@@ -0,0 +1,196 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/colinking/go-sqlite3-native/internal/tree"
+)
+
+// cursor is a b-tree opened by OpcodeOpenRead, or a sorter, ephemeral index or
+// ephemeral table opened by OpcodeSorterOpen or OpcodeOpenEphemeral.
+type cursor struct {
+	tree *tree.Tree
+	// index is set for cursors on an index, rather than a table, whose
+	// entries are in the order of the index's columns.
+	index bool
+	// nullRow is set by OpcodeNullRow until the cursor is moved again, while
+	// all of its columns are NULL and it has no next entry.
+	nullRow bool
+	keyOrder
+
+	// entries holds the entries of an ephemeral index or table, which are
+	// kept in memory instead of in a b-tree, and pos is the entry that the
+	// cursor is at. Those of a table, which has no KeyInfo, are kept in the
+	// order that they were inserted, and may be equal.
+	entries [][]Register
+	pos     int
+	// seq is the next sequence number of OpcodeSequence.
+	seq int
+	// sorter holds the entries of a sorter.
+	sorter *sorter
+}
+
+func newCursor(t *tree.Tree, keyInfo *KeyInfo) (*cursor, error) {
+	c := &cursor{tree: t}
+	if keyInfo != nil {
+		order, err := newKeyOrder(keyInfo)
+		if err != nil {
+			return nil, err
+		}
+		c.index, c.keyOrder = true, order
+	}
+
+	return c, nil
+}
+
+// close releases the resources of the cursor, which are the temporary files of a
+// sorter.
+func (c *cursor) close() error {
+	if c.sorter == nil {
+		return nil
+	}
+
+	return c.sorter.close()
+}
+
+// keyOrder is the order of the entries of an index or sorter.
+type keyOrder struct {
+	collations []collation
+	desc       []bool
+	bigNull    []bool
+}
+
+func newKeyOrder(keyInfo *KeyInfo) (keyOrder, error) {
+	order := keyOrder{desc: keyInfo.Desc, bigNull: keyInfo.BigNull}
+	for _, name := range keyInfo.Collations {
+		coll, err := lookupCollation(name)
+		if err != nil {
+			return keyOrder{}, err
+		}
+		order.collations = append(order.collations, coll)
+	}
+
+	return order, nil
+}
+
+// compareKey compares an entry of the index with key, which may hold fewer values
+// than the index has columns, in which case only that prefix of the entry is
+// compared.
+func (c *cursor) compareKey(entry tree.Record, key []Register) int {
+	values := make([]Register, len(key))
+	for i := range key {
+		values[i] = columnRegister(entry.GetColumn(i))
+	}
+
+	return c.compare(values, key)
+}
+
+// compare compares the first len(b) values of a with those of b, using the
+// collating sequence of each column, or BINARY if there is none, and reversing
+// the order of the columns that are sorted in descending order. NULLs are equal
+// to each other and sort before other values, like in an index, or after them for
+// the columns that are flagged as BigNull.
+func (o keyOrder) compare(a, b []Register) int {
+	for i, k := range b {
+		v := a[i]
+
+		var cmp int
+		switch {
+		case isNull(v) && isNull(k):
+			cmp = 0
+		case isNull(v):
+			cmp = -1
+		case isNull(k):
+			cmp = 1
+		default:
+			coll := collations["BINARY"]
+			if i < len(o.collations) {
+				coll = o.collations[i]
+			}
+			cmp = compareValues(v, k, coll)
+		}
+		if (isNull(v) || isNull(k)) && i < len(o.bigNull) && o.bigNull[i] {
+			cmp = -cmp
+		}
+		if i < len(o.desc) && o.desc[i] {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+
+	return 0
+}
+
+// isNull returns true if r is NULL, including if it was never set.
+func isNull(r Register) bool {
+	return r.typ == RegisterTypeNull || r.typ == RegisterTypeUnknown
+}
+
+// find returns the position of the first entry of an ephemeral index that is not
+// less than key, and whether that entry is equal to it.
+func (c *cursor) find(key []Register) (int, bool) {
+	i := sort.Search(len(c.entries), func(i int) bool {
+		return c.compare(c.entries[i], key) >= 0
+	})
+
+	return i, i < len(c.entries) && c.compare(c.entries[i], key) == 0
+}
+
+// insert adds key to an ephemeral index. Like in SQLite, it replaces an entry
+// that is equal to it, which may differ from it if their collating sequences
+// consider them equal, f.e. "a" and "A" with NOCASE.
+func (c *cursor) insert(key []Register) {
+	i, found := c.find(key)
+	if !found {
+		c.entries = append(c.entries, nil)
+		copy(c.entries[i+1:], c.entries[i:])
+	}
+	c.entries[i] = key
+}
+
+// delete removes the entry of an ephemeral index that is equal to key, if any.
+func (c *cursor) delete(key []Register) {
+	if i, found := c.find(key); found {
+		c.entries = append(c.entries[:i], c.entries[i+1:]...)
+	}
+}
+
+// deleteCurrent removes the entry of an ephemeral index or table that the cursor
+// is at, which leaves it at the next one.
+func (c *cursor) deleteCurrent() {
+	if c.pos == 0 {
+		// Queues remove their first entry, which needs no copying.
+		c.entries = c.entries[1:]
+		return
+	}
+	c.entries = append(c.entries[:c.pos], c.entries[c.pos+1:]...)
+}
+
+// seek moves the cursor to the first entry that is greater than or equal to key,
+// or greater than key if gt is set, returning false if there is no such entry. For
+// tables, key holds a single value that is compared with the rowids.
+func (c *cursor) seek(key []Register, gt bool) bool {
+	if !c.index {
+		k := key[0]
+		return c.tree.Seek(func(r tree.Record) bool {
+			cmp := compareValues(Register{typ: RegisterTypeInt, Int: r.Rowid()}, k, nil)
+			return cmp < 0 || (gt && cmp == 0)
+		})
+	}
+
+	return c.tree.Seek(func(r tree.Record) bool {
+		cmp := c.compareKey(r, key)
+		return cmp < 0 || (gt && cmp == 0)
+	})
+}
+
+// columnRegister returns the value of a column read from a b-tree.
+func columnRegister(col tree.Column) Register {
+	r := &Registers{}
+	// Columns only hold the types that a register can.
+	_ = r.SetValue(0, col.Value())
+
+	return r.Get(0)
+}
@@ -0,0 +1,370 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Flags for the P5 operand of comparison opcodes, alongside the affinity.
+const (
+	// JumpIfNull makes a comparison jump if either operand is NULL.
+	JumpIfNull = 0x10
+	// NullEq makes Eq and Ne treat NULL as a value that is equal to itself, as
+	// in "IS" and "IS NOT".
+	NullEq = 0x80
+
+	affinityMask = 0x47
+)
+
+// arithmetic returns the result of the arithmetic opcode op on a and b. Text and
+// blob operands are converted to numbers first. Integer results that overflow are
+// computed as reals, instead, and the result is NULL if either operand is NULL or
+// if it is a division by zero.
+func arithmetic(op Opcode, a, b Register) Register {
+	if a.typ == RegisterTypeNull || b.typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}
+	}
+
+	a, b = numericValue(a), numericValue(b)
+	if a.typ == RegisterTypeInt && b.typ == RegisterTypeInt {
+		x, y := int64(a.Int), int64(b.Int)
+		var z int64
+		overflow := false
+		switch op {
+		case OpcodeAdd:
+			z = x + y
+			overflow = (x >= 0) == (y >= 0) && (z >= 0) != (x >= 0)
+		case OpcodeSubtract:
+			z = x - y
+			overflow = (x >= 0) != (y >= 0) && (z >= 0) != (x >= 0)
+		case OpcodeMultiply:
+			z = x * y
+			overflow = x != 0 && (z/x != y || (x == -1 && y == math.MinInt64))
+		case OpcodeDivide:
+			if y == 0 {
+				return Register{typ: RegisterTypeNull}
+			}
+			overflow = x == math.MinInt64 && y == -1
+			if !overflow {
+				z = x / y
+			}
+		case OpcodeRemainder:
+			if y == 0 {
+				return Register{typ: RegisterTypeNull}
+			}
+			if y == -1 {
+				// This avoids MinInt64 % -1, which overflows.
+				y = 1
+			}
+			z = x % y
+		}
+		if !overflow {
+			return Register{typ: RegisterTypeInt, Int: int(z)}
+		}
+	}
+
+	x, y := realValue(a), realValue(b)
+	var z float64
+	switch op {
+	case OpcodeAdd:
+		z = x + y
+	case OpcodeSubtract:
+		z = x - y
+	case OpcodeMultiply:
+		z = x * y
+	case OpcodeDivide:
+		if y == 0 {
+			return Register{typ: RegisterTypeNull}
+		}
+		z = x / y
+	case OpcodeRemainder:
+		// The remainder of reals is computed on their integer parts.
+		i, j := realToInt(x), realToInt(y)
+		if j == 0 {
+			return Register{typ: RegisterTypeNull}
+		}
+		if j == -1 {
+			j = 1
+		}
+		z = float64(i % j)
+	}
+	if math.IsNaN(z) {
+		return Register{typ: RegisterTypeNull}
+	}
+
+	return Register{typ: RegisterTypeFloat, Float: z}
+}
+
+// bitwise returns the result of the bitwise opcode op on a and b, which are
+// converted to integers. The result is NULL if either operand is NULL.
+func bitwise(op Opcode, a, b Register) Register {
+	if a.typ == RegisterTypeNull || b.typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}
+	}
+
+	x, y := int64(intValue(a)), int64(intValue(b))
+	switch op {
+	case OpcodeBitAnd:
+		x &= y
+	case OpcodeBitOr:
+		x |= y
+	case OpcodeShiftLeft, OpcodeShiftRight:
+		// Shifting by a negative amount shifts in the other direction.
+		if y < 0 {
+			if op == OpcodeShiftLeft {
+				op = OpcodeShiftRight
+			} else {
+				op = OpcodeShiftLeft
+			}
+			y = -y
+			if y < 0 {
+				y = 64
+			}
+		}
+		switch {
+		case y >= 64 && (x >= 0 || op == OpcodeShiftLeft):
+			x = 0
+		case y >= 64:
+			x = -1
+		case op == OpcodeShiftLeft:
+			x = int64(uint64(x) << uint(y))
+		default:
+			// Right shifts are arithmetic, so negative numbers stay negative.
+			x >>= uint(y)
+		}
+	}
+
+	return Register{typ: RegisterTypeInt, Int: int(x)}
+}
+
+// concat returns the concatenation of a and b as text, or NULL if either is NULL.
+func concat(a, b Register) Register {
+	if a.typ == RegisterTypeNull || b.typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}
+	}
+
+	return Register{typ: RegisterTypeString, String: textValue(a) + textValue(b)}
+}
+
+// compare returns the result of the comparison opcode op on a and b, after
+// applying the affinity and flags in p5 and comparing text with the collating
+// sequence coll. ok is false if the comparison is NULL, because either operand is
+// NULL and p5 does not include NullEq.
+func compare(op Opcode, a, b Register, p5 int, coll collation) (result, ok bool) {
+	var cmp int
+	if a.typ == RegisterTypeNull || b.typ == RegisterTypeNull {
+		if p5&NullEq == 0 {
+			return false, false
+		}
+		switch {
+		case a.typ == b.typ:
+			cmp = 0
+		case a.typ == RegisterTypeNull:
+			cmp = -1
+		default:
+			cmp = 1
+		}
+	} else {
+		affinity := p5 & affinityMask
+		switch {
+		case affinity >= AffinityNumeric:
+			a, b = numericAffinity(a), numericAffinity(b)
+		case affinity == AffinityText && (a.typ == RegisterTypeString || b.typ == RegisterTypeString):
+			a, b = textAffinity(a), textAffinity(b)
+		}
+		cmp = compareValues(a, b, coll)
+	}
+
+	switch op {
+	case OpcodeEq:
+		return cmp == 0, true
+	case OpcodeNe:
+		return cmp != 0, true
+	case OpcodeLt:
+		return cmp < 0, true
+	case OpcodeLe:
+		return cmp <= 0, true
+	case OpcodeGt:
+		return cmp > 0, true
+	case OpcodeGe:
+		return cmp >= 0, true
+	default:
+		panic(fmt.Sprintf("vm: unexpected comparison opcode %s", op))
+	}
+}
+
+// compareValues compares two non-NULL values, returning a negative number if a
+// sorts before b, 0 if they are equal and a positive number otherwise. Numbers
+// sort before text, which sorts before blobs.
+//
+// See: https://www.sqlite.org/datatype3.html#sort_order
+func compareValues(a, b Register, coll collation) int {
+	class := func(r Register) int {
+		switch r.typ {
+		case RegisterTypeInt, RegisterTypeFloat:
+			return 0
+		case RegisterTypeString:
+			return 1
+		default:
+			return 2
+		}
+	}
+	if ca, cb := class(a), class(b); ca != cb {
+		return ca - cb
+	}
+
+	switch a.typ {
+	case RegisterTypeInt:
+		if b.typ == RegisterTypeInt {
+			return compareInts(int64(a.Int), int64(b.Int))
+		}
+		return compareIntFloat(int64(a.Int), b.Float)
+	case RegisterTypeFloat:
+		if b.typ == RegisterTypeInt {
+			return -compareIntFloat(int64(b.Int), a.Float)
+		}
+		switch {
+		case a.Float < b.Float:
+			return -1
+		case a.Float > b.Float:
+			return 1
+		default:
+			return 0
+		}
+	case RegisterTypeString:
+		return coll(a.String, b.String)
+	default:
+		return bytes.Compare(a.Blob, b.Blob)
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareIntFloat compares an integer with a real without losing the precision
+// of either, which converting one into the other could.
+func compareIntFloat(i int64, r float64) int {
+	switch {
+	case math.IsNaN(r):
+		return 1
+	case r < -9223372036854775808.0:
+		return 1
+	case r >= 9223372036854775808.0:
+		return -1
+	}
+
+	if cmp := compareInts(i, int64(r)); cmp != 0 {
+		return cmp
+	}
+	switch s := float64(i); {
+	case s < r:
+		return -1
+	case s > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// collation compares two strings, like strings.Compare.
+type collation func(a, b string) int
+
+// collations are the built-in collating sequences, keyed by their upper-case
+// name: https://www.sqlite.org/datatype3.html#collating_sequences
+var collations = map[string]collation{
+	"BINARY": strings.Compare,
+	"NOCASE": func(a, b string) int {
+		// Only ASCII characters are folded.
+		return strings.Compare(asciiLower(a), asciiLower(b))
+	},
+	"RTRIM": func(a, b string) int {
+		return strings.Compare(strings.TrimRight(a, " "), strings.TrimRight(b, " "))
+	},
+}
+
+// lookupCollation returns the collating sequence with the given name. The empty
+// name refers to BINARY.
+func lookupCollation(name string) (collation, error) {
+	if name == "" {
+		return strings.Compare, nil
+	}
+
+	coll, ok := collations[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("no such collation sequence: %s", name)
+	}
+
+	return coll, nil
+}
+
+// CheckCollation returns an error if there is no collating sequence with the given
+// case-insensitive name.
+func CheckCollation(name string) error {
+	_, err := lookupCollation(name)
+	return err
+}
+
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// truth returns whether r is true, i.e. a non-zero number. ok is false if r is
+// NULL.
+func truth(r Register) (result, ok bool) {
+	switch r.typ {
+	case RegisterTypeNull:
+		return false, false
+	case RegisterTypeInt:
+		return r.Int != 0, true
+	default:
+		return realValue(r) != 0, true
+	}
+}
+
+// logic returns the result of And or Or on a and b, using SQL's three-valued
+// logic: https://www.sqlite.org/lang_expr.html#booleanexpr
+func logic(op Opcode, a, b Register) Register {
+	x, xok := truth(a)
+	y, yok := truth(b)
+
+	var result, ok bool
+	if op == OpcodeAnd {
+		// FALSE AND NULL is FALSE.
+		result = x && y
+		ok = (xok && yok) || (xok && !x) || (yok && !y)
+	} else {
+		// TRUE OR NULL is TRUE.
+		result = x || y
+		ok = (xok && yok) || (xok && x) || (yok && y)
+	}
+	if !ok {
+		return Register{typ: RegisterTypeNull}
+	}
+
+	return boolean(result)
+}
+
+func boolean(b bool) Register {
+	if b {
+		return Register{typ: RegisterTypeInt, Int: 1}
+	}
+
+	return Register{typ: RegisterTypeInt, Int: 0}
+}
@@ -0,0 +1,657 @@
+package vm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxLength is the largest string or blob that functions produce, which is
+// SQLite's default SQLITE_MAX_LENGTH.
+const maxLength = 1000000000
+
+// ErrTooBig is returned if a function would produce a string or blob larger than
+// SQLite's length limit.
+var ErrTooBig = errors.New("string or blob too big")
+
+var errIntegerOverflow = errors.New("integer overflow")
+
+// Function is a built-in scalar SQL function, as called by OpcodeFunction.
+//
+// See: https://www.sqlite.org/lang_corefunc.html
+type Function struct {
+	Name string
+	// MinArgs and MaxArgs are the bounds of the number of arguments that the
+	// function accepts. MaxArgs is -1 if there is no upper bound.
+	MinArgs int
+	MaxArgs int
+
+	call func(args []Register) (Register, error)
+}
+
+// functions are the built-in scalar functions, keyed by their lower-case name.
+var functions = map[string]*Function{}
+
+func register(name string, minArgs, maxArgs int, call func(args []Register) (Register, error)) {
+	functions[name] = &Function{Name: name, MinArgs: minArgs, MaxArgs: maxArgs, call: call}
+}
+
+// LookupFunction returns the scalar function with the given case-insensitive
+// name, checking that it accepts numArgs arguments.
+func LookupFunction(name string, numArgs int) (*Function, error) {
+	f, ok := functions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("no such function: %s", name)
+	}
+	if numArgs < f.MinArgs || (f.MaxArgs >= 0 && numArgs > f.MaxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments to function %s()", name)
+	}
+
+	return f, nil
+}
+
+// Call calls the function with args, which must be a valid number of arguments.
+func (f *Function) Call(args []Register) (Register, error) {
+	for i, arg := range args {
+		if arg.typ == RegisterTypeUnknown {
+			args[i] = Register{typ: RegisterTypeNull}
+		}
+	}
+
+	r, err := f.call(args)
+	if err != nil {
+		return Register{}, err
+	}
+	if len(r.String) > maxLength || len(r.Blob) > maxLength {
+		return Register{}, ErrTooBig
+	}
+
+	return r, nil
+}
+
+func init() {
+	register("length", 1, 1, length)
+	register("lower", 1, 1, func(args []Register) (Register, error) {
+		return mapText(args[0], asciiLower), nil
+	})
+	register("upper", 1, 1, func(args []Register) (Register, error) {
+		return mapText(args[0], asciiUpper), nil
+	})
+	register("substr", 2, 3, substr)
+	register("substring", 2, 3, substr)
+	register("trim", 1, 2, trimFunction(true, true))
+	register("ltrim", 1, 2, trimFunction(true, false))
+	register("rtrim", 1, 2, trimFunction(false, true))
+	register("replace", 3, 3, replace)
+	register("instr", 2, 2, instr)
+	register("abs", 1, 1, abs)
+	register("round", 1, 2, round)
+	register("coalesce", 2, -1, coalesce)
+	register("ifnull", 2, 2, coalesce)
+	register("nullif", 2, 2, func(args []Register) (Register, error) {
+		if args[0].typ != RegisterTypeNull && args[1].typ != RegisterTypeNull && compareValues(args[0], args[1], strings.Compare) == 0 {
+			return Register{typ: RegisterTypeNull}, nil
+		}
+		return args[0], nil
+	})
+	register("iif", 2, 3, iif)
+	register("typeof", 1, 1, func(args []Register) (Register, error) {
+		return text(typeName(args[0])), nil
+	})
+	register("hex", 1, 1, func(args []Register) (Register, error) {
+		return text(strings.ToUpper(hex.EncodeToString(blobValue(args[0])))), nil
+	})
+	register("unhex", 1, 2, unhex)
+	register("quote", 1, 1, func(args []Register) (Register, error) {
+		return text(quote(args[0])), nil
+	})
+	register("char", 0, -1, char)
+	register("unicode", 1, 1, unicode)
+	register("min", 2, -1, minMax(-1))
+	register("max", 2, -1, minMax(1))
+	register("random", 0, 0, func(args []Register) (Register, error) {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return Register{}, err
+		}
+		return Register{typ: RegisterTypeInt, Int: int(int64(binary.LittleEndian.Uint64(b[:])))}, nil
+	})
+	register("randomblob", 1, 1, func(args []Register) (Register, error) {
+		n := int64(intValue(args[0]))
+		if n < 1 {
+			n = 1
+		}
+		if n > maxLength {
+			return Register{}, ErrTooBig
+		}
+		b := make([]byte, n)
+		if _, err := rand.Read(b); err != nil {
+			return Register{}, err
+		}
+		return Register{typ: RegisterTypeBlob, Blob: b}, nil
+	})
+	register("zeroblob", 1, 1, func(args []Register) (Register, error) {
+		n := int64(intValue(args[0]))
+		if n < 0 {
+			n = 0
+		}
+		if n > maxLength {
+			return Register{}, ErrTooBig
+		}
+		return Register{typ: RegisterTypeBlob, Blob: make([]byte, n)}, nil
+	})
+	register("like", 2, 3, like(likeInfo))
+	register("glob", 2, 2, like(globInfo))
+	register("regexp", 2, 2, regexpFunction)
+	register("printf", 0, -1, printf)
+	register("format", 0, -1, printf)
+}
+
+func text(s string) Register {
+	return Register{typ: RegisterTypeString, String: s}
+}
+
+// cString returns r as text, up to its first NUL character, as SQLite's functions
+// see text that they treat as a C string.
+func cString(r Register) string {
+	s := textValue(r)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
+
+// blobValue returns r as a blob. Numbers are converted into text first.
+func blobValue(r Register) []byte {
+	if r.typ == RegisterTypeBlob {
+		return r.Blob
+	}
+
+	return []byte(textValue(r))
+}
+
+func typeName(r Register) string {
+	switch r.typ {
+	case RegisterTypeInt:
+		return "integer"
+	case RegisterTypeFloat:
+		return "real"
+	case RegisterTypeString:
+		return "text"
+	case RegisterTypeBlob:
+		return "blob"
+	default:
+		return "null"
+	}
+}
+
+// length returns the number of characters in text, up to the first NUL, the
+// number of bytes in a blob and the length of a number as text.
+func length(args []Register) (Register, error) {
+	switch r := args[0]; r.typ {
+	case RegisterTypeNull:
+		return r, nil
+	case RegisterTypeBlob:
+		return Register{typ: RegisterTypeInt, Int: len(r.Blob)}, nil
+	case RegisterTypeString:
+		return Register{typ: RegisterTypeInt, Int: charLen(cString(r))}, nil
+	default:
+		return Register{typ: RegisterTypeInt, Int: len(textValue(r))}, nil
+	}
+}
+
+// charLen returns the number of UTF-8 characters in s.
+func charLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); i += utf8Len(s[i:]) {
+		n++
+	}
+
+	return n
+}
+
+// mapText applies f to r as text. NULL is returned unchanged.
+func mapText(r Register, f func(string) string) Register {
+	if r.typ == RegisterTypeNull {
+		return r
+	}
+
+	return text(f(textValue(r)))
+}
+
+func asciiUpper(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// substr returns the characters of text, or bytes of a blob, starting at the
+// 1-based position args[1], with negative positions counting from the end. The
+// length args[2] may be negative to return the characters before the position.
+func substr(args []Register) (Register, error) {
+	for _, arg := range args {
+		if arg.typ == RegisterTypeNull {
+			return arg, nil
+		}
+	}
+
+	p1 := int64(intValue(args[1]))
+	p2 := int64(maxLength)
+	if len(args) == 3 {
+		p2 = int64(intValue(args[2]))
+	}
+
+	// units is the number of characters or bytes, and sub returns those
+	// between start and end.
+	var units int64
+	var sub func(start, end int64) Register
+	if args[0].typ == RegisterTypeBlob {
+		b := args[0].Blob
+		units = int64(len(b))
+		sub = func(start, end int64) Register {
+			return Register{typ: RegisterTypeBlob, Blob: b[start:end]}
+		}
+	} else {
+		s := cString(args[0])
+		var offsets []int
+		for i := 0; i < len(s); i += utf8Len(s[i:]) {
+			offsets = append(offsets, i)
+		}
+		offsets = append(offsets, len(s))
+		units = int64(len(offsets) - 1)
+		sub = func(start, end int64) Register {
+			return text(s[offsets[start]:offsets[end]])
+		}
+	}
+
+	// This follows substrFunc in SQLite's func.c.
+	negP2 := false
+	if p2 < 0 {
+		p2 = -p2
+		negP2 = true
+	}
+	if p1 < 0 {
+		p1 += units
+		if p1 < 0 {
+			if p2 < 0 {
+				p2 = 0
+			} else {
+				p2 += p1
+			}
+			p1 = 0
+		}
+	} else if p1 > 0 {
+		p1--
+	} else if p2 > 0 {
+		p2--
+	}
+	if negP2 {
+		p1 -= p2
+		if p1 < 0 {
+			p2 += p1
+			p1 = 0
+		}
+	}
+	if p1 > units {
+		p1 = units
+	}
+	if p2 < 0 {
+		p2 = 0
+	}
+	if p1+p2 > units {
+		p2 = units - p1
+	}
+
+	return sub(p1, p1+p2), nil
+}
+
+// trimFunction returns a function that removes the characters in args[1], or
+// spaces, from the left and/or right of args[0].
+func trimFunction(left, right bool) func(args []Register) (Register, error) {
+	return func(args []Register) (Register, error) {
+		if args[0].typ == RegisterTypeNull {
+			return args[0], nil
+		}
+		s := textValue(args[0])
+
+		chars := []string{" "}
+		if len(args) == 2 {
+			if args[1].typ == RegisterTypeNull {
+				return args[1], nil
+			}
+			set := cString(args[1])
+			chars = nil
+			for i := 0; i < len(set); i += utf8Len(set[i:]) {
+				chars = append(chars, set[i:i+utf8Len(set[i:])])
+			}
+		}
+
+		trimmed := true
+		for left && trimmed && s != "" {
+			trimmed = false
+			for _, c := range chars {
+				if strings.HasPrefix(s, c) {
+					s, trimmed = s[len(c):], true
+					break
+				}
+			}
+		}
+		trimmed = true
+		for right && trimmed && s != "" {
+			trimmed = false
+			for _, c := range chars {
+				if strings.HasSuffix(s, c) {
+					s, trimmed = s[:len(s)-len(c)], true
+					break
+				}
+			}
+		}
+
+		return text(s), nil
+	}
+}
+
+// replace replaces every occurrence of args[1] in args[0] with args[2]. If args[1]
+// is empty, args[0] is returned unchanged.
+func replace(args []Register) (Register, error) {
+	if args[0].typ == RegisterTypeNull || args[1].typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+	pattern := textValue(args[1])
+	if pattern == "" || pattern[0] == 0 {
+		return args[0], nil
+	}
+	if args[2].typ == RegisterTypeNull {
+		return args[2], nil
+	}
+
+	return text(strings.ReplaceAll(textValue(args[0]), pattern, textValue(args[2]))), nil
+}
+
+// instr returns the 1-based position of the first occurrence of args[1] in args[0],
+// in bytes if both are blobs and in characters otherwise, or 0 if there is none.
+func instr(args []Register) (Register, error) {
+	if args[0].typ == RegisterTypeNull || args[1].typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	if args[0].typ == RegisterTypeBlob && args[1].typ == RegisterTypeBlob {
+		i := strings.Index(string(args[0].Blob), string(args[1].Blob))
+		return Register{typ: RegisterTypeInt, Int: i + 1}, nil
+	}
+
+	haystack, needle := textValue(args[0]), textValue(args[1])
+	i := strings.Index(haystack, needle)
+	if i < 0 {
+		return Register{typ: RegisterTypeInt}, nil
+	}
+
+	return Register{typ: RegisterTypeInt, Int: charLen(haystack[:i]) + 1}, nil
+}
+
+// abs returns the absolute value of an integer, or of any other value as a real.
+func abs(args []Register) (Register, error) {
+	switch r := args[0]; r.typ {
+	case RegisterTypeNull:
+		return r, nil
+	case RegisterTypeInt:
+		if r.Int == math.MinInt64 {
+			return Register{}, errIntegerOverflow
+		}
+		if r.Int < 0 {
+			r.Int = -r.Int
+		}
+		return r, nil
+	default:
+		// Unlike math.Abs, this keeps the sign of negative zero.
+		f := realValue(r)
+		if f < 0 {
+			f = -f
+		}
+		return Register{typ: RegisterTypeFloat, Float: f}, nil
+	}
+}
+
+// round rounds args[0] to args[1] digits after the decimal point, which is
+// clamped to between 0 and 30, and returns a real.
+func round(args []Register) (Register, error) {
+	n := int64(0)
+	if len(args) == 2 {
+		if args[1].typ == RegisterTypeNull {
+			return args[1], nil
+		}
+		n = int64(intValue(args[1]))
+		if n > 30 {
+			n = 30
+		}
+		if n < 0 {
+			n = 0
+		}
+	}
+	if args[0].typ == RegisterTypeNull {
+		return args[0], nil
+	}
+
+	r := realValue(args[0])
+	switch {
+	case r < -4503599627370496.0 || r > +4503599627370496.0:
+		// The value has no fractional part.
+	case n == 0:
+		// Halfway cases are rounded away from zero.
+		if r < 0 {
+			r = float64(int64(r - 0.5))
+		} else {
+			r = float64(int64(r + 0.5))
+		}
+	default:
+		s, _ := sqlPrintf("%!.*f", []Register{{typ: RegisterTypeInt, Int: int(n)}, {typ: RegisterTypeFloat, Float: r}})
+		r = atof(s)
+	}
+
+	return Register{typ: RegisterTypeFloat, Float: r}, nil
+}
+
+// coalesce returns its first argument that is not NULL.
+func coalesce(args []Register) (Register, error) {
+	for _, arg := range args {
+		if arg.typ != RegisterTypeNull {
+			return arg, nil
+		}
+	}
+
+	return Register{typ: RegisterTypeNull}, nil
+}
+
+// iif returns args[1] if args[0] is true, and args[2], or NULL, otherwise.
+func iif(args []Register) (Register, error) {
+	if result, _ := truth(args[0]); result {
+		return args[1], nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+
+	return Register{typ: RegisterTypeNull}, nil
+}
+
+// unhex decodes hexadecimal text into a blob, ignoring characters in args[1]
+// between pairs of digits. The result is NULL if the text is not valid.
+func unhex(args []Register) (Register, error) {
+	if args[0].typ == RegisterTypeNull {
+		return args[0], nil
+	}
+	pass := ""
+	if len(args) == 2 {
+		if args[1].typ == RegisterTypeNull {
+			return args[1], nil
+		}
+		pass = cString(args[1])
+	}
+
+	s := cString(args[0])
+	b := []byte{}
+	for i := 0; i < len(s); {
+		if !isHexDigit(s[i]) {
+			c := s[i : i+utf8Len(s[i:])]
+			if !strings.Contains(pass, c) {
+				return Register{typ: RegisterTypeNull}, nil
+			}
+			i += len(c)
+			continue
+		}
+		if i+1 >= len(s) || !isHexDigit(s[i+1]) {
+			return Register{typ: RegisterTypeNull}, nil
+		}
+		v, _ := strconv.ParseUint(s[i:i+2], 16, 8)
+		b = append(b, byte(v))
+		i += 2
+	}
+
+	return Register{typ: RegisterTypeBlob, Blob: b}, nil
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// quote returns r as an SQL literal.
+func quote(r Register) string {
+	switch r.typ {
+	case RegisterTypeInt:
+		return strconv.Itoa(r.Int)
+	case RegisterTypeFloat:
+		// Reals are rendered with 15 significant digits, if that reads back as
+		// the same value, and with 20 otherwise.
+		args := []Register{r}
+		s, _ := sqlPrintf("%!0.15g", args)
+		if atof(s) != r.Float {
+			s, _ = sqlPrintf("%!0.20e", args)
+		}
+		return s
+	case RegisterTypeString:
+		s, _ := sqlPrintf("%Q", []Register{r})
+		return s
+	case RegisterTypeBlob:
+		return "X'" + strings.ToUpper(hex.EncodeToString(r.Blob)) + "'"
+	default:
+		return "NULL"
+	}
+}
+
+// char returns the text made of the characters with the given code points.
+// Invalid code points are replaced with U+FFFD, but surrogates are encoded as is.
+func char(args []Register) (Register, error) {
+	var b []byte
+	for _, arg := range args {
+		c := int64(intValue(arg))
+		if c < 0 || c > 0x10ffff {
+			c = 0xfffd
+		}
+		switch {
+		case c < 0x80:
+			b = append(b, byte(c))
+		case c < 0x800:
+			b = append(b, 0xc0|byte(c>>6), 0x80|byte(c&0x3f))
+		case c < 0x10000:
+			b = append(b, 0xe0|byte(c>>12), 0x80|byte(c>>6&0x3f), 0x80|byte(c&0x3f))
+		default:
+			b = append(b, 0xf0|byte(c>>18), 0x80|byte(c>>12&0x3f), 0x80|byte(c>>6&0x3f), 0x80|byte(c&0x3f))
+		}
+	}
+
+	return text(string(b)), nil
+}
+
+// unicode returns the code point of the first character of args[0], or NULL if it
+// is empty. Invalid characters are decoded like SQLite's sqlite3Utf8Read does.
+func unicode(args []Register) (Register, error) {
+	s := cString(args[0])
+	if args[0].typ == RegisterTypeNull || s == "" {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	c, _ := utf8Read(s)
+
+	return Register{typ: RegisterTypeInt, Int: c}, nil
+}
+
+// utf8Read decodes the first character of s, which must not be empty, and returns
+// it with its length in bytes. Invalid characters are decoded like SQLite's
+// sqlite3Utf8Read does.
+func utf8Read(s string) (c int, n int) {
+	c = int(s[0])
+	if c < 0xc0 {
+		return c, 1
+	}
+
+	// The lead byte contributes the bits that follow its length prefix.
+	switch {
+	case c < 0xe0:
+		c &= 0x1f
+	case c < 0xf0:
+		c &= 0x0f
+	case c < 0xf8:
+		c &= 0x07
+	case c < 0xfc:
+		c &= 0x03
+	case c < 0xfe:
+		c &= 0x01
+	default:
+		c = 0
+	}
+	n = 1
+	for ; n < len(s) && s[n]&0xc0 == 0x80; n++ {
+		c = c<<6 + int(s[n]&0x3f)
+	}
+	if c < 0x80 || c&0xfffff800 == 0xd800 || c&0xfffffffe == 0xfffe {
+		c = 0xfffd
+	}
+
+	return c, n
+}
+
+// minMax returns a function that returns its smallest argument, if sign is -1, or
+// its largest, if sign is 1, or NULL if any argument is NULL. Of equal arguments,
+// min returns the last and max the first.
+func minMax(sign int) func(args []Register) (Register, error) {
+	return func(args []Register) (Register, error) {
+		best := args[0]
+		for _, arg := range args {
+			if arg.typ == RegisterTypeNull {
+				return arg, nil
+			}
+			cmp := compareValues(best, arg, strings.Compare)
+			if (sign < 0 && cmp >= 0) || (sign > 0 && cmp < 0) {
+				best = arg
+			}
+		}
+
+		return best, nil
+	}
+}
+
+// printf formats its arguments according to the format in args[0]. The result is
+// NULL if there is no format, it is NULL or it produces no output at all.
+func printf(args []Register) (Register, error) {
+	if len(args) == 0 || args[0].typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	s, written := sqlPrintf(cString(args[0]), args[1:])
+	if !written {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	return text(s), nil
+}
@@ -0,0 +1,196 @@
+package vm
+
+import (
+	"database/sql/driver"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The expected values in these tests were produced by sqlite3 3.50.2.
+
+func TestFunctions(tt *testing.T) {
+	for _, test := range []struct {
+		call string
+		args []driver.Value
+		want driver.Value
+	}{
+		{call: "length", args: []driver.Value{"héllo"}, want: int64(5)},
+		{call: "length", args: []driver.Value{"a\x00b"}, want: int64(1)},
+		{call: "length", args: []driver.Value{[]byte("héllo")}, want: int64(6)},
+		{call: "length", args: []driver.Value{-1.5}, want: int64(4)},
+		{call: "length", args: []driver.Value{nil}, want: nil},
+		{call: "lower", args: []driver.Value{"ÀBC"}, want: "Àbc"},
+		{call: "upper", args: []driver.Value{[]byte("abc")}, want: "ABC"},
+		{call: "upper", args: []driver.Value{1.0}, want: "1.0"},
+		{call: "substr", args: []driver.Value{"héllo", int64(2), int64(3)}, want: "éll"},
+		{call: "substr", args: []driver.Value{"héllo", int64(0), int64(2)}, want: "h"},
+		{call: "substr", args: []driver.Value{"héllo", int64(-2)}, want: "lo"},
+		{call: "substr", args: []driver.Value{"héllo", int64(3), int64(-2)}, want: "hé"},
+		{call: "substr", args: []driver.Value{"héllo", int64(-10), int64(7)}, want: "hé"},
+		{call: "substr", args: []driver.Value{"héllo", int64(4294967298)}, want: ""},
+		{call: "substr", args: []driver.Value{[]byte{1, 2, 3}, int64(2)}, want: []byte{2, 3}},
+		{call: "substr", args: []driver.Value{int64(12345), int64(2), int64(2)}, want: "23"},
+		{call: "substr", args: []driver.Value{"abc", nil}, want: nil},
+		{call: "trim", args: []driver.Value{"  x  "}, want: "x"},
+		{call: "trim", args: []driver.Value{"xyxaxy", "xy"}, want: "a"},
+		{call: "ltrim", args: []driver.Value{"ééa", "é"}, want: "a"},
+		{call: "rtrim", args: []driver.Value{int64(100), int64(0)}, want: "1"},
+		{call: "trim", args: []driver.Value{"abc", nil}, want: nil},
+		{call: "replace", args: []driver.Value{"abcabc", "b", "xx"}, want: "axxcaxxc"},
+		{call: "replace", args: []driver.Value{int64(5), "", int64(1)}, want: int64(5)},
+		{call: "replace", args: []driver.Value{"abc", "b", nil}, want: nil},
+		{call: "instr", args: []driver.Value{"héllo", "l"}, want: int64(3)},
+		{call: "instr", args: []driver.Value{[]byte("héllo"), []byte("l")}, want: int64(4)},
+		{call: "instr", args: []driver.Value{int64(12345), int64(34)}, want: int64(3)},
+		{call: "instr", args: []driver.Value{"abc", ""}, want: int64(1)},
+		{call: "instr", args: []driver.Value{"abc", "d"}, want: int64(0)},
+		{call: "abs", args: []driver.Value{int64(-5)}, want: int64(5)},
+		{call: "abs", args: []driver.Value{"-5"}, want: 5.0},
+		{call: "abs", args: []driver.Value{"x"}, want: 0.0},
+		{call: "abs", args: []driver.Value{math.Copysign(0, -1)}, want: math.Copysign(0, -1)},
+		{call: "round", args: []driver.Value{2.675, int64(2)}, want: 2.67},
+		{call: "round", args: []driver.Value{2.5}, want: 3.0},
+		{call: "round", args: []driver.Value{-2.5}, want: -3.0},
+		{call: "round", args: []driver.Value{1234.5678, int64(-1)}, want: 1235.0},
+		{call: "round", args: []driver.Value{0.25, int64(1)}, want: 0.3},
+		{call: "round", args: []driver.Value{0.35, int64(1)}, want: 0.3},
+		{call: "round", args: []driver.Value{1.25, int64(4294967297)}, want: 1.25},
+		{call: "round", args: []driver.Value{4503599627370497.5}, want: 4503599627370497.5},
+		{call: "round", args: []driver.Value{"3.7"}, want: 4.0},
+		{call: "round", args: []driver.Value{int64(1), nil}, want: nil},
+		{call: "coalesce", args: []driver.Value{nil, nil, 2.5, int64(1)}, want: 2.5},
+		{call: "ifnull", args: []driver.Value{nil, nil}, want: nil},
+		{call: "nullif", args: []driver.Value{int64(1), 1.0}, want: nil},
+		{call: "nullif", args: []driver.Value{int64(1), "1"}, want: int64(1)},
+		{call: "iif", args: []driver.Value{"0.5", "yes", "no"}, want: "yes"},
+		{call: "iif", args: []driver.Value{nil, "yes", "no"}, want: "no"},
+		{call: "iif", args: []driver.Value{int64(0), "yes"}, want: nil},
+		{call: "typeof", args: []driver.Value{1.0}, want: "real"},
+		{call: "typeof", args: []driver.Value{nil}, want: "null"},
+		{call: "hex", args: []driver.Value{[]byte{0, 0xab}}, want: "00AB"},
+		{call: "hex", args: []driver.Value{1.5}, want: "312E35"},
+		{call: "hex", args: []driver.Value{nil}, want: ""},
+		{call: "unhex", args: []driver.Value{"41 42", " "}, want: []byte("AB")},
+		{call: "unhex", args: []driver.Value{""}, want: []byte{}},
+		{call: "unhex", args: []driver.Value{"414"}, want: nil},
+		{call: "unhex", args: []driver.Value{"4 1", " "}, want: nil},
+		{call: "quote", args: []driver.Value{"it's"}, want: "'it''s'"},
+		{call: "quote", args: []driver.Value{[]byte{0x0a, 0xff}}, want: "X'0AFF'"},
+		{call: "quote", args: []driver.Value{nil}, want: "NULL"},
+		{call: "quote", args: []driver.Value{0.1}, want: "0.1"},
+		{call: "quote", args: []driver.Value{1.0 / 3}, want: "3.333333333333333148e-01"},
+		{call: "quote", args: []driver.Value{9223372036854775808.0}, want: "9.22337203685477581e+18"},
+		{call: "quote", args: []driver.Value{math.Inf(-1)}, want: "-9.0e+999"},
+		{call: "char", args: []driver.Value{int64(104), int64(233), "105", int64(-1)}, want: "héi�"},
+		{call: "char", args: []driver.Value{int64(0xd800)}, want: "\xed\xa0\x80"},
+		{call: "char", args: []driver.Value{}, want: ""},
+		{call: "unicode", args: []driver.Value{"élan"}, want: int64(233)},
+		{call: "unicode", args: []driver.Value{[]byte{0x80}}, want: int64(128)},
+		{call: "unicode", args: []driver.Value{""}, want: nil},
+		{call: "min", args: []driver.Value{int64(2), 1.0, int64(1)}, want: int64(1)},
+		{call: "min", args: []driver.Value{"a", int64(9)}, want: int64(9)},
+		{call: "max", args: []driver.Value{int64(1), 1.0, "a", []byte("a")}, want: []byte("a")},
+		{call: "max", args: []driver.Value{int64(1), nil}, want: nil},
+		{call: "zeroblob", args: []driver.Value{int64(3)}, want: []byte{0, 0, 0}},
+		{call: "zeroblob", args: []driver.Value{int64(-1)}, want: []byte{}},
+		{call: "like", args: []driver.Value{"A_C", "abc"}, want: int64(1)},
+		{call: "like", args: []driver.Value{"é%", "É"}, want: int64(0)},
+		{call: "like", args: []driver.Value{"a", "a\x00b"}, want: int64(1)},
+		{call: "like", args: []driver.Value{"a\\%c", "a%c", "\\"}, want: int64(1)},
+		{call: "like", args: []driver.Value{"a\\%c", "abc", "\\"}, want: int64(0)},
+		{call: "like", args: []driver.Value{"a%%", "ab", "%"}, want: int64(0)},
+		{call: "like", args: []driver.Value{"a", "a", nil}, want: nil},
+		{call: "like", args: []driver.Value{"%1%", int64(213)}, want: int64(1)},
+		{call: "glob", args: []driver.Value{"[a-c]*", "banana"}, want: int64(1)},
+		{call: "glob", args: []driver.Value{"[^a-c]*", "banana"}, want: int64(0)},
+		{call: "glob", args: []driver.Value{"*[]]", "x]"}, want: int64(1)},
+		{call: "glob", args: []driver.Value{"A?C", "abc"}, want: int64(0)},
+		{call: "glob", args: []driver.Value{nil, "abc"}, want: nil},
+		{call: "regexp", args: []driver.Value{"^a.c$", "abc"}, want: int64(1)},
+		{call: "regexp", args: []driver.Value{"b", "abc"}, want: int64(1)},
+		{call: "regexp", args: []driver.Value{"^b", "abc"}, want: int64(0)},
+		{call: "regexp", args: []driver.Value{"a", nil}, want: nil},
+		{call: "printf", args: []driver.Value{"%d%%|%5.2f|%-4s|%q", "12abc", math.Pi, "ab", "it's"}, want: "12%| 3.14|ab  |it''s"},
+		{call: "format", args: []driver.Value{"%s and %s", "a"}, want: "a and "},
+		{call: "printf", args: []driver.Value{nil}, want: nil},
+		{call: "printf", args: []driver.Value{""}, want: nil},
+		{call: "printf", args: []driver.Value{}, want: nil},
+	} {
+		tt.Run(test.call, func(t *testing.T) {
+			f, err := LookupFunction(test.call, len(test.args))
+			require.NoError(t, err)
+
+			r := &Registers{}
+			args := make([]Register, len(test.args))
+			for i, arg := range test.args {
+				require.NoError(t, r.SetValue(i, arg))
+				args[i] = r.Get(i)
+			}
+			result, err := f.Call(args)
+			require.NoError(t, err)
+			require.Equal(t, test.want, result.Value(), "%s(%#v)", test.call, test.args)
+		})
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	for _, test := range []struct {
+		call string
+		args []driver.Value
+		err  string
+	}{
+		{call: "abs", args: []driver.Value{int64(math.MinInt64)}, err: "integer overflow"},
+		{call: "zeroblob", args: []driver.Value{int64(2000000000)}, err: "string or blob too big"},
+		{call: "randomblob", args: []driver.Value{int64(2000000000)}, err: "string or blob too big"},
+		{call: "like", args: []driver.Value{"a", "a", "ab"}, err: "ESCAPE expression must be a single character"},
+		{call: "like", args: []driver.Value{"a", "a", ""}, err: "ESCAPE expression must be a single character"},
+		{call: "glob", args: []driver.Value{repeat("*", 50001), "a"}, err: "LIKE or GLOB pattern too complex"},
+		{call: "regexp", args: []driver.Value{"(", "a"}, err: "error parsing regexp: missing closing ): `(`"},
+	} {
+		f, err := LookupFunction(test.call, len(test.args))
+		require.NoError(t, err)
+
+		r := &Registers{}
+		args := make([]Register, len(test.args))
+		for i, arg := range test.args {
+			require.NoError(t, r.SetValue(i, arg))
+			args[i] = r.Get(i)
+		}
+		_, err = f.Call(args)
+		require.EqualError(t, err, test.err, test.call)
+	}
+}
+
+func TestLookupFunction(t *testing.T) {
+	f, err := LookupFunction("COALESCE", 5)
+	require.NoError(t, err)
+	require.Equal(t, "coalesce", f.Name)
+
+	_, err = LookupFunction("coalesce", 1)
+	require.EqualError(t, err, "wrong number of arguments to function coalesce()")
+
+	_, err = LookupFunction("Max", 0)
+	require.EqualError(t, err, "wrong number of arguments to function Max()")
+
+	_, err = LookupFunction("nope", 0)
+	require.EqualError(t, err, "no such function: nope")
+}
+
+func TestRandom(t *testing.T) {
+	f, err := LookupFunction("randomblob", 1)
+	require.NoError(t, err)
+
+	for n, want := range map[int]int{-1: 1, 0: 1, 16: 16} {
+		r, err := f.Call([]Register{{typ: RegisterTypeInt, Int: n}})
+		require.NoError(t, err)
+		require.Len(t, r.Blob, want)
+	}
+
+	f, err = LookupFunction("random", 0)
+	require.NoError(t, err)
+	r, err := f.Call(nil)
+	require.NoError(t, err)
+	require.Equal(t, RegisterTypeInt, r.typ)
+}
@@ -0,0 +1,272 @@
+package vm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxPatternLength is the longest LIKE or GLOB pattern, which is SQLite's default
+// SQLITE_MAX_LIKE_PATTERN_LENGTH.
+const maxPatternLength = 50000
+
+// patternInfo describes the wildcards of LIKE or GLOB patterns. A wildcard of 0
+// is disabled.
+type patternInfo struct {
+	// matchAll matches any sequence of characters, and matchOne any single
+	// character.
+	matchAll, matchOne int
+	// matchSet starts a set of characters, like "[a-z]", in GLOB patterns.
+	matchSet int
+	// noCase makes ASCII characters match regardless of their case.
+	noCase bool
+}
+
+var (
+	likeInfo = patternInfo{matchAll: '%', matchOne: '_', noCase: true}
+	globInfo = patternInfo{matchAll: '*', matchOne: '?', matchSet: '['}
+)
+
+// patternResult is the result of patternCompare.
+type patternResult int
+
+const (
+	patternMatch patternResult = iota
+	patternNoMatch
+	// patternNoWildcardMatch means that the pattern does not match any suffix
+	// of the string either, so a preceding wildcard need not try to match more
+	// characters.
+	patternNoWildcardMatch
+)
+
+// like returns the like() or glob() function, which implement the LIKE and GLOB
+// operators: "x LIKE y ESCAPE z" calls like(y, x, z).
+//
+// See: https://www.sqlite.org/lang_expr.html#like
+func like(defaultInfo patternInfo) func(args []Register) (Register, error) {
+	return func(args []Register) (Register, error) {
+		info := defaultInfo
+		if len(textValue(args[0])) > maxPatternLength {
+			return Register{}, errors.New("LIKE or GLOB pattern too complex")
+		}
+
+		// In GLOB patterns, "[" is matched by patternCompare itself, instead of
+		// escaping the next character.
+		escape := info.matchSet
+		if len(args) == 3 {
+			if args[2].typ == RegisterTypeNull {
+				return Register{typ: RegisterTypeNull}, nil
+			}
+			s := cString(args[2])
+			if charLen(s) != 1 {
+				return Register{}, errors.New("ESCAPE expression must be a single character")
+			}
+			escape, _ = utf8Read(s)
+			// The escape character is no longer a wildcard.
+			if escape == info.matchAll {
+				info.matchAll = 0
+			}
+			if escape == info.matchOne {
+				info.matchOne = 0
+			}
+		}
+
+		if args[0].typ == RegisterTypeNull || args[1].typ == RegisterTypeNull {
+			return Register{typ: RegisterTypeNull}, nil
+		}
+
+		return boolean(patternCompare(cString(args[0]), cString(args[1]), info, escape) == patternMatch), nil
+	}
+}
+
+// patternCompare matches s against pattern, where matchOther is the escape
+// character of LIKE patterns, or "[" for GLOB patterns. It is a port of SQLite's
+// patternCompare.
+func patternCompare(pattern, s string, info patternInfo, matchOther int) patternResult {
+	// next returns the character at x[*i] and moves past it, or 0 at the end.
+	next := func(x string, i *int) int {
+		if *i >= len(x) {
+			return 0
+		}
+		c, n := utf8Read(x[*i:])
+		*i += n
+
+		return c
+	}
+
+	p, i := 0, 0
+	// escaped is the position in the pattern just after an escaped character.
+	escaped := -1
+	for c := next(pattern, &p); c != 0; c = next(pattern, &p) {
+		if c == info.matchAll {
+			// Skip over any further wildcards, consuming a character of s for
+			// each matchOne.
+			for {
+				c = next(pattern, &p)
+				if c != info.matchAll && (c != info.matchOne || info.matchOne == 0) {
+					break
+				}
+				if c == info.matchOne && next(s, &i) == 0 {
+					return patternNoWildcardMatch
+				}
+			}
+			if c == 0 {
+				// A trailing matchAll matches the rest of s.
+				return patternMatch
+			}
+			if c == matchOther {
+				if info.matchSet == 0 {
+					c = next(pattern, &p)
+					if c == 0 {
+						return patternNoWildcardMatch
+					}
+				} else {
+					// A set follows the matchAll, which is matched by trying
+					// each suffix of s.
+					for ; i < len(s); i += utf8Len(s[i:]) {
+						if r := patternCompare(pattern[p-1:], s[i:], info, matchOther); r != patternNoMatch {
+							return r
+						}
+					}
+					return patternNoWildcardMatch
+				}
+			}
+
+			// c is the first character after the matchAll. Each occurrence of
+			// it in s is a place where the rest of the pattern may match.
+			if c < 0x80 {
+				stop := string(rune(c))
+				if info.noCase {
+					stop = strings.ToUpper(stop) + strings.ToLower(stop)
+				}
+				for {
+					j := strings.IndexAny(s[i:], stop)
+					if j < 0 {
+						break
+					}
+					i += j + 1
+					if r := patternCompare(pattern[p:], s[i:], info, matchOther); r != patternNoMatch {
+						return r
+					}
+				}
+			} else {
+				for c2 := next(s, &i); c2 != 0; c2 = next(s, &i) {
+					if c2 != c {
+						continue
+					}
+					if r := patternCompare(pattern[p:], s[i:], info, matchOther); r != patternNoMatch {
+						return r
+					}
+				}
+			}
+			return patternNoWildcardMatch
+		}
+
+		if c == matchOther {
+			if info.matchSet == 0 {
+				// The escaped character is matched literally, below.
+				c = next(pattern, &p)
+				if c == 0 {
+					return patternNoMatch
+				}
+				escaped = p
+			} else {
+				// A set of characters, like "[^a-z]".
+				c = next(s, &i)
+				if c == 0 {
+					return patternNoMatch
+				}
+				seen, invert := false, false
+				prior := 0
+				c2 := next(pattern, &p)
+				if c2 == '^' {
+					invert = true
+					c2 = next(pattern, &p)
+				}
+				if c2 == ']' {
+					// A leading "]" is part of the set.
+					seen = c == ']'
+					c2 = next(pattern, &p)
+				}
+				for c2 != 0 && c2 != ']' {
+					if c2 == '-' && p < len(pattern) && pattern[p] != ']' && prior > 0 {
+						c2 = next(pattern, &p)
+						if c >= prior && c <= c2 {
+							seen = true
+						}
+						prior = 0
+					} else {
+						if c == c2 {
+							seen = true
+						}
+						prior = c2
+					}
+					c2 = next(pattern, &p)
+				}
+				if c2 == 0 || seen == invert {
+					return patternNoMatch
+				}
+				continue
+			}
+		}
+
+		c2 := next(s, &i)
+		if c == c2 {
+			continue
+		}
+		if info.noCase && c < 0x80 && c2 < 0x80 && asciiLower(string(rune(c))) == asciiLower(string(rune(c2))) {
+			continue
+		}
+		if c == info.matchOne && p != escaped && c2 != 0 {
+			continue
+		}
+		return patternNoMatch
+	}
+
+	if i == len(s) {
+		return patternMatch
+	}
+
+	return patternNoMatch
+}
+
+// regexps caches compiled REGEXP patterns, as a query usually matches every row
+// against the same pattern.
+var regexps = struct {
+	sync.Mutex
+	cache map[string]*regexp.Regexp
+}{cache: map[string]*regexp.Regexp{}}
+
+// maxCachedRegexps bounds the size of the regexps cache.
+const maxCachedRegexps = 64
+
+// regexpFunction implements the REGEXP operator: "x REGEXP y" calls regexp(y, x),
+// which is true if any part of x matches the regular expression y. SQLite does
+// not define this function itself, so this uses Go's regular expression syntax.
+func regexpFunction(args []Register) (Register, error) {
+	if args[0].typ == RegisterTypeNull || args[1].typ == RegisterTypeNull {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	pattern := textValue(args[0])
+	regexps.Lock()
+	re, ok := regexps.cache[pattern]
+	regexps.Unlock()
+	if !ok {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return Register{}, err
+		}
+
+		regexps.Lock()
+		if len(regexps.cache) >= maxCachedRegexps {
+			regexps.cache = map[string]*regexp.Regexp{}
+		}
+		regexps.cache[pattern] = re
+		regexps.Unlock()
+	}
+
+	return boolean(re.MatchString(textValue(args[1]))), nil
+}
@@ -23,11 +23,84 @@ func _() {
 	_ = x[OpcodeGoto-12]
 	_ = x[OpcodeNext-13]
 	_ = x[OpcodeRewind-14]
+	_ = x[OpcodeVariable-15]
+	_ = x[OpcodeInteger-16]
+	_ = x[OpcodeReal-17]
+	_ = x[OpcodeNull-18]
+	_ = x[OpcodeBlob-19]
+	_ = x[OpcodeCopy-20]
+	_ = x[OpcodeSCopy-21]
+	_ = x[OpcodeAdd-22]
+	_ = x[OpcodeSubtract-23]
+	_ = x[OpcodeMultiply-24]
+	_ = x[OpcodeDivide-25]
+	_ = x[OpcodeRemainder-26]
+	_ = x[OpcodeConcat-27]
+	_ = x[OpcodeBitAnd-28]
+	_ = x[OpcodeBitOr-29]
+	_ = x[OpcodeShiftLeft-30]
+	_ = x[OpcodeShiftRight-31]
+	_ = x[OpcodeEq-32]
+	_ = x[OpcodeNe-33]
+	_ = x[OpcodeLt-34]
+	_ = x[OpcodeLe-35]
+	_ = x[OpcodeGt-36]
+	_ = x[OpcodeGe-37]
+	_ = x[OpcodeZeroOrNull-38]
+	_ = x[OpcodeAnd-39]
+	_ = x[OpcodeOr-40]
+	_ = x[OpcodeNot-41]
+	_ = x[OpcodeIf-42]
+	_ = x[OpcodeIfNot-43]
+	_ = x[OpcodeAffinity-44]
+	_ = x[OpcodeRealAffinity-45]
+	_ = x[OpcodeFunction-46]
+	_ = x[OpcodeRowid-47]
+	_ = x[OpcodeSeekGT-48]
+	_ = x[OpcodeSeekRowid-49]
+	_ = x[OpcodeIdxGE-50]
+	_ = x[OpcodeIdxLT-51]
+	_ = x[OpcodeIdxLE-52]
+	_ = x[OpcodeIfPos-53]
+	_ = x[OpcodeAggStep-54]
+	_ = x[OpcodeAggFinal-55]
+	_ = x[OpcodeCollSeq-56]
+	_ = x[OpcodeCompare-57]
+	_ = x[OpcodeJump-58]
+	_ = x[OpcodeGosub-59]
+	_ = x[OpcodeReturn-60]
+	_ = x[OpcodeSorterOpen-61]
+	_ = x[OpcodeSorterInsert-62]
+	_ = x[OpcodeSorterSort-63]
+	_ = x[OpcodeSorterNext-64]
+	_ = x[OpcodeSorterData-65]
+	_ = x[OpcodeOpenEphemeral-66]
+	_ = x[OpcodeFound-67]
+	_ = x[OpcodeIdxInsert-68]
+	_ = x[OpcodeDecrJumpZero-69]
+	_ = x[OpcodeMustBeInt-70]
+	_ = x[OpcodeOffsetLimit-71]
+	_ = x[OpcodeNullRow-72]
+	_ = x[OpcodeNotFound-73]
+	_ = x[OpcodeOnce-74]
+	_ = x[OpcodeInitCoroutine-75]
+	_ = x[OpcodeYield-76]
+	_ = x[OpcodeEndCoroutine-77]
+	_ = x[OpcodeIdxDelete-78]
+	_ = x[OpcodeOpenDup-79]
+	_ = x[OpcodeSequence-80]
+	_ = x[OpcodeInsert-81]
+	_ = x[OpcodeDelete-82]
+	_ = x[OpcodeAggInverse-83]
+	_ = x[OpcodeAggValue-84]
+	_ = x[OpcodeAddImm-85]
+	_ = x[OpcodeNotNull-86]
+	_ = x[OpcodeBitNot-87]
 }
 
-const _Opcode_name = "OpcodeInitOpcodeOpenReadOpcodeString8OpcodeCastOpcodeIsNullOpcodeSeekGEOpcodeIdxGTOpcodeDeferredSeekOpcodeColumnOpcodeResultRowOpcodeHaltOpcodeTransactionOpcodeGotoOpcodeNextOpcodeRewind"
+const _Opcode_name = "OpcodeInitOpcodeOpenReadOpcodeString8OpcodeCastOpcodeIsNullOpcodeSeekGEOpcodeIdxGTOpcodeDeferredSeekOpcodeColumnOpcodeResultRowOpcodeHaltOpcodeTransactionOpcodeGotoOpcodeNextOpcodeRewindOpcodeVariableOpcodeIntegerOpcodeRealOpcodeNullOpcodeBlobOpcodeCopyOpcodeSCopyOpcodeAddOpcodeSubtractOpcodeMultiplyOpcodeDivideOpcodeRemainderOpcodeConcatOpcodeBitAndOpcodeBitOrOpcodeShiftLeftOpcodeShiftRightOpcodeEqOpcodeNeOpcodeLtOpcodeLeOpcodeGtOpcodeGeOpcodeZeroOrNullOpcodeAndOpcodeOrOpcodeNotOpcodeIfOpcodeIfNotOpcodeAffinityOpcodeRealAffinityOpcodeFunctionOpcodeRowidOpcodeSeekGTOpcodeSeekRowidOpcodeIdxGEOpcodeIdxLTOpcodeIdxLEOpcodeIfPosOpcodeAggStepOpcodeAggFinalOpcodeCollSeqOpcodeCompareOpcodeJumpOpcodeGosubOpcodeReturnOpcodeSorterOpenOpcodeSorterInsertOpcodeSorterSortOpcodeSorterNextOpcodeSorterDataOpcodeOpenEphemeralOpcodeFoundOpcodeIdxInsertOpcodeDecrJumpZeroOpcodeMustBeIntOpcodeOffsetLimitOpcodeNullRowOpcodeNotFoundOpcodeOnceOpcodeInitCoroutineOpcodeYieldOpcodeEndCoroutineOpcodeIdxDeleteOpcodeOpenDupOpcodeSequenceOpcodeInsertOpcodeDeleteOpcodeAggInverseOpcodeAggValueOpcodeAddImmOpcodeNotNullOpcodeBitNot"
 
-var _Opcode_index = [...]uint8{0, 10, 24, 37, 47, 59, 71, 82, 100, 112, 127, 137, 154, 164, 174, 186}
+var _Opcode_index = [...]uint16{0, 10, 24, 37, 47, 59, 71, 82, 100, 112, 127, 137, 154, 164, 174, 186, 200, 213, 223, 233, 243, 253, 264, 273, 287, 301, 313, 328, 340, 352, 363, 378, 394, 402, 410, 418, 426, 434, 442, 458, 467, 475, 484, 492, 503, 517, 535, 549, 560, 572, 587, 598, 609, 620, 631, 644, 658, 671, 684, 694, 705, 717, 733, 751, 767, 783, 799, 818, 829, 844, 862, 877, 894, 907, 921, 931, 950, 961, 979, 994, 1007, 1021, 1033, 1045, 1061, 1075, 1087, 1100, 1112}
 
 func (i Opcode) String() string {
 	if i < 0 || i >= Opcode(len(_Opcode_index)-1) {
@@ -0,0 +1,729 @@
+package vm
+
+import (
+	"math"
+	"strings"
+)
+
+// The printf implementation follows SQLite's sqlite3_str_vappendf, which differs
+// from Go's fmt package in many details, f.e. in how reals are rounded and in
+// its '!' and ',' flags: https://www.sqlite.org/printf.html
+
+// printfVerb describes a conversion of printf.
+type printfVerb struct {
+	class verbClass
+	// base is the radix of integer conversions.
+	base uint64
+	// signed is true if integer conversions are of signed values.
+	signed bool
+	// upper is true if digits and exponents are in upper case.
+	upper bool
+	// prefix is written before non-zero integers with the '#' flag.
+	prefix string
+}
+
+type verbClass int
+
+const (
+	verbDecimal verbClass = iota + 1
+	verbRadix
+	verbOrdinal
+	verbFloat
+	verbExp
+	verbGeneric
+	verbString
+	verbChar
+	verbEscape
+	verbPercent
+	verbSize
+)
+
+var printfVerbs = map[byte]printfVerb{
+	'd': {class: verbDecimal, base: 10, signed: true},
+	'i': {class: verbDecimal, base: 10, signed: true},
+	'u': {class: verbDecimal, base: 10},
+	'x': {class: verbRadix, base: 16, prefix: "0x"},
+	'X': {class: verbRadix, base: 16, upper: true, prefix: "0X"},
+	'p': {class: verbRadix, base: 16, upper: true, prefix: "0x"},
+	'o': {class: verbRadix, base: 8, prefix: "0"},
+	'r': {class: verbOrdinal, base: 10, signed: true},
+	'f': {class: verbFloat},
+	'e': {class: verbExp},
+	'E': {class: verbExp, upper: true},
+	'g': {class: verbGeneric},
+	'G': {class: verbGeneric, upper: true},
+	's': {class: verbString},
+	'z': {class: verbString},
+	'c': {class: verbChar},
+	'q': {class: verbEscape},
+	'Q': {class: verbEscape},
+	'w': {class: verbEscape},
+	'%': {class: verbPercent},
+	'n': {class: verbSize},
+}
+
+// printfSpec holds the flags, width and precision of a conversion.
+type printfSpec struct {
+	leftJustify bool
+	// sign is '+' or ' ' to prefix non-negative numbers with, or 0.
+	sign      byte
+	altForm   bool // '#'
+	altForm2  bool // '!'
+	zeroPad   bool
+	thousands bool // ','
+	width     int
+	// precision is -1 if it is not specified.
+	precision int
+}
+
+// printfArgs hands out the arguments of printf in order. Missing arguments are
+// treated as 0, 0.0 or NULL.
+type printfArgs []Register
+
+func (a *printfArgs) next() (Register, bool) {
+	if len(*a) == 0 {
+		return Register{typ: RegisterTypeNull}, false
+	}
+	r := (*a)[0]
+	*a = (*a)[1:]
+
+	return r, true
+}
+
+func (a *printfArgs) int() int64 {
+	r, _ := a.next()
+	return int64(intValue(r))
+}
+
+func (a *printfArgs) real() float64 {
+	r, _ := a.next()
+	return realValue(r)
+}
+
+// text returns the next argument as text, with ok false if it is NULL.
+func (a *printfArgs) text() (s string, ok bool) {
+	r, _ := a.next()
+	if r.typ == RegisterTypeNull || r.typ == RegisterTypeUnknown {
+		return "", false
+	}
+
+	return textValue(r), true
+}
+
+// sqlPrintf formats args according to format, like SQL's printf function. The
+// output ends at the first invalid conversion. written is false if nothing was
+// written, not even an empty conversion, in which case SQL's printf returns NULL.
+func sqlPrintf(format string, args []Register) (s string, written bool) {
+	var out strings.Builder
+	a := printfArgs(args)
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			written = true
+			j := strings.IndexByte(format[i:], '%')
+			if j < 0 {
+				out.WriteString(format[i:])
+				break
+			}
+			out.WriteString(format[i : i+j])
+			i += j
+		}
+		i++
+		if i >= len(format) {
+			written = true
+			out.WriteByte('%')
+			break
+		}
+
+		spec := printfSpec{precision: -1}
+		c := format[i]
+	flags:
+		for ; ; c = format[i] {
+			switch c {
+			case '-':
+				spec.leftJustify = true
+			case '+', ' ':
+				spec.sign = c
+			case '#':
+				spec.altForm = true
+			case '!':
+				spec.altForm2 = true
+			case '0':
+				spec.zeroPad = true
+			case ',':
+				spec.thousands = true
+			default:
+				break flags
+			}
+			if i++; i >= len(format) {
+				return out.String(), written
+			}
+		}
+
+		// The width.
+		if c == '*' {
+			// Like in C, the argument is truncated to an int.
+			width := int32(a.int())
+			switch {
+			case width == math.MinInt32:
+				spec.leftJustify = true
+			case width < 0:
+				spec.leftJustify = true
+				spec.width = int(-width)
+			default:
+				spec.width = int(width)
+			}
+			i++
+		} else {
+			for ; i < len(format) && isDigit(format[i]); i++ {
+				spec.width = (spec.width*10 + int(format[i]-'0')) & math.MaxInt32
+			}
+		}
+
+		// The precision.
+		if i < len(format) && format[i] == '.' {
+			i++
+			if i < len(format) && format[i] == '*' {
+				precision := int32(a.int())
+				switch {
+				case precision == math.MinInt32:
+					spec.precision = -1
+				case precision < 0:
+					spec.precision = int(-precision)
+				default:
+					spec.precision = int(precision)
+				}
+				i++
+			} else {
+				spec.precision = 0
+				for ; i < len(format) && isDigit(format[i]); i++ {
+					spec.precision = (spec.precision*10 + int(format[i]-'0')) & math.MaxInt32
+				}
+			}
+		}
+
+		// The "l" and "ll" length modifiers are accepted, but have no effect.
+		for n := 0; n < 2 && i < len(format) && format[i] == 'l'; n++ {
+			i++
+		}
+		if i >= len(format) {
+			break
+		}
+
+		verb, ok := printfVerbs[format[i]]
+		if !ok {
+			break
+		}
+		spec.format(&out, format[i], verb, &a)
+		written = true
+	}
+
+	return out.String(), written
+}
+
+// format writes the conversion of the next argument(s) according to verb.
+func (spec printfSpec) format(out *strings.Builder, c byte, verb printfVerb, a *printfArgs) {
+	var s string
+	switch verb.class {
+	case verbDecimal, verbRadix, verbOrdinal:
+		if verb.class != verbDecimal {
+			spec.thousands = false
+		}
+		s = spec.formatInt(a.int(), verb)
+	case verbFloat, verbExp, verbGeneric:
+		s = spec.formatFloat(a.real(), verb)
+	case verbString:
+		s, _ = a.text()
+		s = spec.truncate(s)
+	case verbChar:
+		s = spec.formatChar(out, a)
+	case verbEscape:
+		s = spec.formatEscape(c, a)
+	case verbPercent:
+		s = "%"
+	case verbSize:
+		return
+	}
+
+	if spec.altForm2 && (verb.class == verbString || verb.class == verbChar || verb.class == verbEscape) {
+		// The width counts characters, rather than bytes.
+		for i := 0; i < len(s); i++ {
+			if s[i]&0xc0 == 0x80 {
+				spec.width++
+			}
+		}
+	}
+
+	pad := ""
+	if spec.width > len(s) {
+		pad = strings.Repeat(" ", spec.width-len(s))
+	}
+	if spec.leftJustify {
+		out.WriteString(s + pad)
+	} else {
+		out.WriteString(pad + s)
+	}
+}
+
+func (spec printfSpec) formatInt(v int64, verb printfVerb) string {
+	var u uint64
+	var sign byte
+	switch {
+	case !verb.signed:
+		u = uint64(v)
+	case v < 0:
+		u = -uint64(v)
+		sign = '-'
+	default:
+		u = uint64(v)
+		sign = spec.sign
+	}
+
+	precision := spec.precision
+	if spec.zeroPad && precision < spec.width-boolInt(sign != 0) {
+		precision = spec.width - boolInt(sign != 0)
+	}
+
+	digits := "0123456789abcdef"
+	if verb.upper {
+		digits = "0123456789ABCDEF"
+	}
+	var b []byte
+	if verb.class == verbOrdinal {
+		// The suffix counts towards the precision, so it is added first, in
+		// reverse like the digits.
+		suffix := "ht"
+		if u/10%10 != 1 {
+			switch u % 10 {
+			case 1:
+				suffix = "ts"
+			case 2:
+				suffix = "dn"
+			case 3:
+				suffix = "dr"
+			}
+		}
+		b = append(b, suffix...)
+	}
+	for n := u; ; {
+		b = append(b, digits[n%verb.base])
+		if n /= verb.base; n == 0 {
+			break
+		}
+	}
+	for len(b) < precision {
+		b = append(b, '0')
+	}
+	reverse(b)
+
+	if spec.thousands {
+		var grouped []byte
+		for i, d := range b {
+			if i > 0 && (len(b)-i)%3 == 0 {
+				grouped = append(grouped, ',')
+			}
+			grouped = append(grouped, d)
+		}
+		b = grouped
+	}
+	s := string(b)
+	if sign != 0 {
+		s = string(sign) + s
+	}
+	if spec.altForm && u != 0 {
+		s = verb.prefix + s
+	}
+
+	return s
+}
+
+func (spec printfSpec) formatFloat(f float64, verb printfVerb) string {
+	precision := spec.precision
+	if precision < 0 {
+		precision = 6
+	}
+	var round int
+	switch verb.class {
+	case verbFloat:
+		round = -precision
+	case verbGeneric:
+		if precision == 0 {
+			precision = 1
+		}
+		round = precision
+	default:
+		round = precision + 1
+	}
+	mxRound := 16
+	if spec.altForm2 {
+		mxRound = 26
+	}
+	d := fpDecode(f, round, mxRound)
+
+	switch {
+	case math.IsNaN(f) && spec.zeroPad:
+		return "null"
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 0) && spec.zeroPad:
+		// Infinity is rendered as a number that is too large to be a real, so
+		// that it reads back as infinity.
+		d.digits, d.point = "9", 1000
+	case math.IsInf(f, 0):
+		switch {
+		case d.sign == '-':
+			return "-Inf"
+		case spec.sign != 0:
+			return string(spec.sign) + "Inf"
+		default:
+			return "Inf"
+		}
+	}
+
+	var sign byte
+	if d.sign == '-' {
+		sign = '-'
+	} else {
+		sign = spec.sign
+	}
+
+	class := verb.class
+	exp := d.point - 1
+	var trimZeros bool
+	if class == verbGeneric {
+		precision--
+		trimZeros = !spec.altForm
+		if exp < -4 || exp > precision {
+			class = verbExp
+		} else {
+			precision -= exp
+			class = verbFloat
+		}
+	} else {
+		trimZeros = spec.altForm2
+	}
+	e2 := 0
+	if class != verbExp {
+		e2 = d.point - 1
+	}
+
+	var b []byte
+	if sign != 0 {
+		b = append(b, sign)
+	}
+	j := 0
+	digit := func() byte {
+		if j < len(d.digits) {
+			j++
+			return d.digits[j-1]
+		}
+		return '0'
+	}
+
+	// The digits before the decimal point.
+	if e2 < 0 {
+		b = append(b, '0')
+	} else {
+		for ; e2 >= 0; e2-- {
+			b = append(b, digit())
+			if spec.thousands && e2%3 == 0 && e2 > 1 {
+				b = append(b, ',')
+			}
+		}
+	}
+
+	point := precision > 0 || spec.altForm || spec.altForm2
+	if point {
+		b = append(b, '.')
+	}
+	// Zeros after the decimal point, before the first significant digit.
+	for e2++; e2 < 0 && precision > 0; precision, e2 = precision-1, e2+1 {
+		b = append(b, '0')
+	}
+	for ; precision > 0; precision-- {
+		b = append(b, digit())
+	}
+
+	if trimZeros && point {
+		for b[len(b)-1] == '0' {
+			b = b[:len(b)-1]
+		}
+		if b[len(b)-1] == '.' {
+			if spec.altForm2 {
+				b = append(b, '0')
+			} else {
+				b = b[:len(b)-1]
+			}
+		}
+	}
+
+	if class == verbExp {
+		exp := d.point - 1
+		if verb.upper {
+			b = append(b, 'E')
+		} else {
+			b = append(b, 'e')
+		}
+		if exp < 0 {
+			b = append(b, '-')
+			exp = -exp
+		} else {
+			b = append(b, '+')
+		}
+		if exp >= 100 {
+			b = append(b, byte(exp/100)+'0')
+			exp %= 100
+		}
+		b = append(b, byte(exp/10)+'0', byte(exp%10)+'0')
+	}
+
+	if spec.zeroPad && !spec.leftJustify && len(b) < spec.width {
+		// The zeros go between the sign and the digits.
+		n := boolInt(sign != 0)
+		zeros := strings.Repeat("0", spec.width-len(b))
+		b = append(b[:n], append([]byte(zeros), b[n:]...)...)
+	}
+
+	return string(b)
+}
+
+// truncate shortens s to the precision of spec, which counts characters with the
+// '!' flag, and bytes otherwise. Text ends at its first NUL character.
+func (spec printfSpec) truncate(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	if spec.precision < 0 {
+		return s
+	}
+	if !spec.altForm2 {
+		if spec.precision < len(s) {
+			s = s[:spec.precision]
+		}
+		return s
+	}
+
+	n := 0
+	for i := 0; i < spec.precision && n < len(s); i++ {
+		n += utf8Len(s[n:])
+	}
+
+	return s[:n]
+}
+
+// formatChar returns the first character of the next argument. With a precision,
+// the character is repeated that many times, but the width is only applied to
+// the first repetition, which formatChar writes to out itself.
+func (spec *printfSpec) formatChar(out *strings.Builder, a *printfArgs) string {
+	s, ok := a.text()
+	c := "\x00"
+	if ok && s != "" {
+		n := 1
+		if s[0]&0xc0 == 0xc0 {
+			for n < 4 && n < len(s) && s[n]&0xc0 == 0x80 {
+				n++
+			}
+		}
+		c = s[:n]
+	}
+	spec.altForm2 = true
+
+	if spec.precision <= 1 {
+		return c
+	}
+	spec.width -= spec.precision - 1
+	if spec.width > 1 && !spec.leftJustify {
+		out.WriteString(strings.Repeat(" ", spec.width-1))
+		spec.width = 0
+	}
+	out.WriteString(strings.Repeat(c, spec.precision-1))
+
+	return c
+}
+
+// formatEscape implements %q, which doubles single quotes, %Q, which also
+// encloses the text in single quotes, unless it is NULL, and %w, which doubles
+// double quotes.
+func (spec printfSpec) formatEscape(c byte, a *printfArgs) string {
+	s, ok := a.text()
+	if !ok {
+		s = "(NULL)"
+		if c == 'Q' {
+			s = "NULL"
+		}
+	}
+	s = spec.truncate(s)
+
+	q := "'"
+	if c == 'w' {
+		q = `"`
+	}
+	s = strings.ReplaceAll(s, q, q+q)
+	if ok && c == 'Q' {
+		s = q + s + q
+	}
+
+	return s
+}
+
+// fpDecoded is a real as a sign and a string of decimal digits with the decimal
+// point before digits[point]. Trailing zeros are removed from digits.
+type fpDecoded struct {
+	sign   byte
+	digits string
+	point  int
+}
+
+// fpDecode converts the absolute value of f into decimal digits, like SQLite's
+// sqlite3FpDecode: it extracts between 17 and 19 significant digits and then
+// rounds them to round digits, if round is positive, or to -round digits after
+// the decimal point otherwise, but never to more than mxRound digits. Digits are
+// computed with double-double arithmetic, which is not exact, so that the digits
+// are the same as SQLite's.
+//
+// Infinity and NaN are not decoded, except for their sign.
+func fpDecode(f float64, round, mxRound int) fpDecoded {
+	d := fpDecoded{sign: '+'}
+	switch {
+	case f < 0:
+		d.sign = '-'
+		f = -f
+	case f == 0:
+		return fpDecoded{sign: '+', digits: "0", point: 1}
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return d
+	}
+
+	// Scale f by powers of ten until it is between 1e17 and 1e19, keeping track
+	// of the error of each multiplication, as well as of the constants.
+	exp := 0
+	rr := [2]float64{f, 0}
+	if rr[0] > 9.223372036854774784e+18 {
+		for rr[0] > 9.223372036854774784e+118 {
+			exp += 100
+			dekkerMul2(&rr, 1.0e-100, -1.99918998026028836196e-117)
+		}
+		for rr[0] > 9.223372036854774784e+28 {
+			exp += 10
+			dekkerMul2(&rr, 1.0e-10, -3.6432197315497741579e-27)
+		}
+		for rr[0] > 9.223372036854774784e+18 {
+			exp++
+			dekkerMul2(&rr, 1.0e-01, -5.5511151231257827021e-18)
+		}
+	} else {
+		for rr[0] < 9.223372036854774784e-83 {
+			exp -= 100
+			dekkerMul2(&rr, 1.0e+100, -1.5902891109759918046e+83)
+		}
+		for rr[0] < 9.223372036854774784e+07 {
+			exp -= 10
+			dekkerMul2(&rr, 1.0e+10, 0)
+		}
+		for rr[0] < 9.22337203685477478e+17 {
+			exp--
+			dekkerMul2(&rr, 1.0e+01, 0)
+		}
+	}
+	var v uint64
+	if rr[1] < 0 {
+		v = uint64(rr[0]) - uint64(-rr[1])
+	} else {
+		v = uint64(rr[0]) + uint64(rr[1])
+	}
+
+	var buf [24]byte
+	i := len(buf)
+	for ; v > 0; v /= 10 {
+		i--
+		buf[i] = byte(v%10) + '0'
+	}
+	digits := buf[i:]
+	d.point = len(digits) + exp
+
+	if round <= 0 {
+		round = d.point - round
+		if round == 0 && digits[0] >= '5' {
+			// The number rounds up to the next power of ten.
+			round = 1
+			i--
+			buf[i] = '0'
+			digits = buf[i:]
+			d.point++
+		}
+	}
+	if round > 0 && (round < len(digits) || len(digits) > mxRound) {
+		if round > mxRound {
+			round = mxRound
+		}
+		up := digits[round] >= '5'
+		digits = digits[:round]
+		for j := round - 1; up; j-- {
+			if digits[j]++; digits[j] <= '9' {
+				break
+			}
+			digits[j] = '0'
+			if j == 0 {
+				i--
+				buf[i] = '1'
+				digits = buf[i : i+round+1]
+				d.point++
+				break
+			}
+		}
+	}
+
+	d.digits = strings.TrimRight(string(digits), "0")
+
+	return d
+}
+
+// dekkerMul2 multiplies the double-double x by the double-double (y, yy).
+func dekkerMul2(x *[2]float64, y, yy float64) {
+	// hx and hy are x[0] and y with the lower 26 bits of their mantissas
+	// cleared, so that their products are exact. The explicit float64
+	// conversions prevent the compiler from fusing multiplications and
+	// additions, which would round differently.
+	hx := math.Float64frombits(math.Float64bits(x[0]) & 0xfffffffffc000000)
+	tx := x[0] - hx
+	hy := math.Float64frombits(math.Float64bits(y) & 0xfffffffffc000000)
+	ty := y - hy
+
+	p := float64(hx * hy)
+	q := float64(hx*ty) + float64(tx*hy)
+	c := p + q
+	cc := p - c + q + float64(tx*ty)
+	cc = float64(x[0]*yy) + float64(x[1]*y) + cc
+	x[0] = c + cc
+	x[1] = c - x[0]
+	x[1] += cc
+}
+
+// utf8Len returns the number of bytes in the first UTF-8 character of s, which is
+// not empty. Invalid characters are delimited like SQLite does: by the next byte
+// that is not a continuation byte.
+func utf8Len(s string) int {
+	n := 1
+	if s[0] >= 0xc0 {
+		for n < len(s) && s[n]&0xc0 == 0x80 {
+			n++
+		}
+	}
+
+	return n
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
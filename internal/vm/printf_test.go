@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"database/sql/driver"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The expected values in these tests were produced by sqlite3 3.50.2.
+
+func TestPrintf(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		args   []driver.Value
+		want   string
+	}{
+		{format: "%d|%5d|%-5d|%05d|%+d|% d", args: []driver.Value{int64(-7), int64(42), int64(42), int64(42), int64(42), int64(42)}, want: "-7|   42|42   |00042|+42| 42"},
+		{format: "%x|%X|%#x|%o|%#o|%p", args: []driver.Value{int64(255), int64(255), int64(255), int64(8), int64(8), int64(255)}, want: "ff|FF|0xff|10|010|FF"},
+		{format: "%u|%x", args: []driver.Value{int64(-1), int64(-1)}, want: "18446744073709551615|ffffffffffffffff"},
+		{format: "%,d|%,010d|%.8d", args: []driver.Value{int64(-1234567), int64(1234), int64(42)}, want: "-1,234,567|0,000,001,234|00000042"},
+		{format: "%r %r %r %r %r %05r", args: []driver.Value{int64(1), int64(2), int64(3), int64(11), int64(112), int64(1)}, want: "1st 2nd 3rd 11th 112th 001st"},
+		{format: "%lld %ld %i", args: []driver.Value{int64(5), 6.9, "7x"}, want: "5 6 7"},
+		{format: "%f|%.2f|%.0f|%#.0f|%,.1f", args: []driver.Value{1.5, 2.675, 0.5, 2.0, 1234567.25}, want: "1.500000|2.67|1|2.|1,234,567.3"},
+		{format: "%e|%.1E|%g|%g|%G|%#g", args: []driver.Value{12345.678, 0.0, 0.0001, 1e-5, 1e20, 1.0}, want: "1.234568e+04|0.0E+00|0.0001|1e-05|1E+20|1.00000"},
+		{format: "%!.15g|%!.20e|%!.3f|%!.3g", args: []driver.Value{0.1, 0.1, 1.0, 100.0}, want: "0.1|1.000000000000000055e-01|1.0|100.0"},
+		{format: "%010.2f|%-8.1f|%+.1e", args: []driver.Value{-3.14159, 2.25, 12345.0}, want: "-000003.14|2.3     |+1.2e+04"},
+		{format: "%f|%+f|%05f|%f", args: []driver.Value{math.Inf(1), math.Inf(1), math.Inf(-1), "abc"}, want: "Inf|+Inf|-9" + repeat("0", 999) + ".000000|0.000000"},
+		{format: "%s|%10s|%-10s|%.2s|%!.2s", args: []driver.Value{"a", "b", "c", "héllo", "héllo"}, want: "a|         b|c         |h\xc3|hé"},
+		{format: "%!5s|%5s|%s|%s", args: []driver.Value{"é", "é", nil, []byte("x\x00y")}, want: "    é|   é||x"},
+		{format: "%q|%Q|%Q|%w|%.3q", args: []driver.Value{"it's", "it's", nil, `a"b`, "a'bc"}, want: "it''s|'it''s'|NULL|a\"\"b|a''b"},
+		{format: "%c|%.3c|%5.3c|%-5c|%c", args: []driver.Value{"abc", "x", "y", "é", int64(65)}, want: "a|xxx|  yyy|é    |6"},
+		{format: "%*d|%-*d|%.*f|%*d", args: []driver.Value{int64(4), int64(1), int64(3), int64(2), int64(2), math.Pi, int64(-3), int64(5)}, want: "   1|2  |3.14|5  "},
+		{format: "%5%|%n|%z", args: []driver.Value{"z"}, want: "    %||z"},
+		{format: "%d %s %f", args: []driver.Value{}, want: "0  0.000000"},
+		{format: "abc%", want: "abc%"},
+		{format: "abc%T def", args: []driver.Value{int64(1)}, want: "abc"},
+		{format: "a%5", want: "a"},
+	} {
+		r := &Registers{}
+		args := make([]Register, len(test.args))
+		for i, arg := range test.args {
+			require.NoError(t, r.SetValue(i, arg))
+			args[i] = r.Get(i)
+		}
+
+		s, _ := sqlPrintf(test.format, args)
+		require.Equal(t, test.want, s, "printf(%q, %#v)", test.format, test.args)
+	}
+}
+
+func TestFpDecode(t *testing.T) {
+	for _, test := range []struct {
+		f         float64
+		round, mx int
+		want      fpDecoded
+	}{
+		{f: 0, round: 15, mx: 16, want: fpDecoded{sign: '+', digits: "0", point: 1}},
+		{f: -1.5, round: 15, mx: 16, want: fpDecoded{sign: '-', digits: "15", point: 1}},
+		{f: 1.0 / 3, round: 30, mx: 26, want: fpDecoded{sign: '+', digits: "3333333333333333148", point: 0}},
+		// The digits are computed with double-double arithmetic, like SQLite does,
+		// which drops the last digit of 2^63.
+		{f: 9223372036854775808.0, round: 30, mx: 26, want: fpDecoded{sign: '+', digits: "922337203685477581", point: 19}},
+		{f: 0.96, round: -1, mx: 16, want: fpDecoded{sign: '+', digits: "1", point: 1}},
+		{f: 0.6, round: 0, mx: 16, want: fpDecoded{sign: '+', digits: "1", point: 1}},
+		{f: 0.4, round: 0, mx: 16, want: fpDecoded{sign: '+', digits: "4000000000000000222", point: 0}},
+		{f: 2e-320, round: 15, mx: 16, want: fpDecoded{sign: '+', digits: "199997773436537", point: -319}},
+	} {
+		require.Equal(t, test.want, fpDecode(test.f, test.round, test.mx), "%v", test.f)
+	}
+}
+
+func repeat(s string, n int) string {
+	out := ""
+	for i := 0; i < n; i++ {
+		out += s
+	}
+
+	return out
+}
@@ -1,6 +1,9 @@
 package vm
 
-import "fmt"
+import (
+	"database/sql/driver"
+	"fmt"
+)
 
 type Registers struct {
 	Registers []Register
@@ -27,6 +30,23 @@ type Register struct {
 	Blob   []byte
 }
 
+// Value returns the contents of this register as one of the types allowed by
+// driver.Value: nil, int64, float64, string or []byte.
+func (r Register) Value() driver.Value {
+	switch r.typ {
+	case RegisterTypeInt:
+		return int64(r.Int)
+	case RegisterTypeFloat:
+		return r.Float
+	case RegisterTypeString:
+		return r.String
+	case RegisterTypeBlob:
+		return r.Blob
+	default:
+		return nil
+	}
+}
+
 func (r *Registers) Get(idx int) Register {
 	if idx < cap(r.Registers) {
 		return r.Registers[idx]
@@ -35,129 +55,95 @@ func (r *Registers) Get(idx int) Register {
 	return Register{}
 }
 
+// Set stores reg in the register at idx.
+func (r *Registers) Set(idx int, reg Register) {
+	r.resize(idx)
+	r.Registers[idx] = reg
+}
+
 func (r *Registers) SetInt(idx int, i int) {
 	r.resize(idx)
 	r.Registers[idx].typ = RegisterTypeInt
 	r.Registers[idx].Int = i
 }
 
-func (r *Registers) CastAsInt(idx int) error {
-	to := RegisterTypeInt
-
-	if idx >= cap(r.Registers) {
-		return fmt.Errorf("unknown register at idx=%d, unable to cast as %s", idx, to.String())
-	}
-
-	switch r.Registers[idx].typ {
-	case RegisterTypeNull:
-		// casting a null is a no-op
-	case RegisterTypeFloat:
-		r.Registers[idx].typ = to
-		r.Registers[idx].Int = int(r.Registers[idx].Float)
-	// case RegisterTypeString:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Int = int(r.Registers[idx].String)
-	// case RegisterTypeBlob:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Int = int(r.Registers[idx].Blob)
-	default:
-		return fmt.Errorf("unsupported cast at idx=%d from typ=%s to typ=%s", idx, r.Registers[idx].typ.String(), to.String())
-	}
-
-	return nil
-}
-
 func (r *Registers) SetFloat(idx int, f float64) {
 	r.resize(idx)
 	r.Registers[idx].typ = RegisterTypeFloat
 	r.Registers[idx].Float = f
 }
 
-func (r *Registers) CastAsFloat(idx int) error {
-	to := RegisterTypeFloat
-
-	if idx >= cap(r.Registers) {
-		return fmt.Errorf("unknown register at idx=%d, unable to cast as %s", idx, to.String())
-	}
-
-	switch r.Registers[idx].typ {
-	case RegisterTypeNull:
-		// casting a null is a no-op
-	case RegisterTypeInt:
-		r.Registers[idx].typ = to
-		r.Registers[idx].Float = float64(r.Registers[idx].Int)
-	// case RegisterTypeString:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Float = float64(r.Registers[idx].String)
-	// case RegisterTypeBlob:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Float = float64(r.Registers[idx].Blob)
-	default:
-		return fmt.Errorf("unsupported cast at idx=%d from typ=%s to typ=%s", idx, r.Registers[idx].typ.String(), to.String())
-	}
-
-	return nil
-}
-
 func (r *Registers) SetString(idx int, s string) {
 	r.resize(idx)
 	r.Registers[idx].typ = RegisterTypeString
 	r.Registers[idx].String = s
 }
 
-func (r *Registers) CastAsString(idx int) error {
-	to := RegisterTypeString
-
-	if idx >= cap(r.Registers) {
-		return fmt.Errorf("unknown register at idx=%d, unable to cast as %s", idx, to.String())
-	}
-
-	switch r.Registers[idx].typ {
-	case RegisterTypeNull:
-		// casting a null is a no-op
-	case RegisterTypeInt:
-		r.Registers[idx].typ = to
-		r.Registers[idx].String = fmt.Sprintf("%d", r.Registers[idx].Int)
-	case RegisterTypeFloat:
-		r.Registers[idx].typ = to
-		r.Registers[idx].String = fmt.Sprintf("%f", r.Registers[idx].Float)
-	case RegisterTypeBlob:
-		r.Registers[idx].typ = to
-		r.Registers[idx].String = string(r.Registers[idx].Blob)
-	default:
-		return fmt.Errorf("unsupported cast at idx=%d from typ=%s to typ=%s", idx, r.Registers[idx].typ.String(), to.String())
-	}
-
-	return nil
-}
-
 func (r *Registers) SetBlob(idx int, b []byte) {
 	r.resize(idx)
 	r.Registers[idx].typ = RegisterTypeBlob
 	r.Registers[idx].Blob = b
 }
 
+// CastAsBlob converts the register at idx into a blob, as if by CAST(... AS BLOB).
 func (r *Registers) CastAsBlob(idx int) error {
-	to := RegisterTypeBlob
+	return r.cast(idx, AffinityBlob)
+}
+
+// CastAsString converts the register at idx into text, as if by CAST(... AS TEXT).
+func (r *Registers) CastAsString(idx int) error {
+	return r.cast(idx, AffinityText)
+}
 
+// CastAsNumeric converts the register at idx into a number, as if by
+// CAST(... AS NUMERIC).
+func (r *Registers) CastAsNumeric(idx int) error {
+	return r.cast(idx, AffinityNumeric)
+}
+
+// CastAsInt converts the register at idx into an integer, as if by
+// CAST(... AS INTEGER).
+func (r *Registers) CastAsInt(idx int) error {
+	return r.cast(idx, AffinityInteger)
+}
+
+// CastAsFloat converts the register at idx into a real, as if by CAST(... AS REAL).
+func (r *Registers) CastAsFloat(idx int) error {
+	return r.cast(idx, AffinityReal)
+}
+
+func (r *Registers) cast(idx int, affinity int) error {
 	if idx >= cap(r.Registers) {
-		return fmt.Errorf("unknown register at idx=%d, unable to cast as %s", idx, to.String())
+		return fmt.Errorf("unknown register at idx=%d, unable to cast with affinity %c", idx, affinity)
 	}
+	r.Registers[idx] = cast(r.Registers[idx], affinity)
 
-	switch r.Registers[idx].typ {
-	case RegisterTypeNull:
-		// casting a null is a no-op
-	// case RegisterTypeInt:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Blob = []byte(r.Registers[idx].Int)
-	// case RegisterTypeFloat:
-	// 	r.Registers[idx].typ = to
-	// 	r.Registers[idx].Blob = []byte(r.Registers[idx].Float)
-	case RegisterTypeString:
-		r.Registers[idx].typ = to
-		r.Registers[idx].Blob = []byte(r.Registers[idx].String)
+	return nil
+}
+
+// ApplyAffinity converts the register at idx into the storage class preferred by
+// affinity, if that can be done without losing information.
+func (r *Registers) ApplyAffinity(idx int, affinity int) {
+	r.resize(idx)
+	r.Registers[idx] = applyAffinity(r.Registers[idx], affinity)
+}
+
+// SetValue stores a driver.Value in the register at idx. The value must be nil,
+// int64, float64, string or []byte.
+func (r *Registers) SetValue(idx int, v driver.Value) error {
+	switch vt := v.(type) {
+	case nil:
+		r.SetNull(idx)
+	case int64:
+		r.SetInt(idx, int(vt))
+	case float64:
+		r.SetFloat(idx, vt)
+	case string:
+		r.SetString(idx, vt)
+	case []byte:
+		r.SetBlob(idx, vt)
 	default:
-		return fmt.Errorf("unsupported cast at idx=%d from typ=%s to typ=%s", idx, r.Registers[idx].typ.String(), to.String())
+		return fmt.Errorf("unsupported register value at idx=%d: %T", idx, v)
 	}
 
 	return nil
@@ -0,0 +1,385 @@
+package vm
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"unsafe"
+)
+
+// DefaultSortMemory is the number of bytes that the entries of a sorter may take up
+// in memory before they are spilled to a temporary file, unless overridden with
+// VM.SortMemory.
+const DefaultSortMemory = 16 << 20
+
+// registerSize is the number of bytes that a register takes up in memory, not
+// counting the contents of its string or blob.
+const registerSize = int(unsafe.Sizeof(Register{}))
+
+// sorter sorts the entries of a sorter cursor, such as the ORDER BY terms of each
+// result row followed by the row's columns. Like SQLite's external sorter, it
+// keeps the entries in memory until they take up more than its memory budget, at
+// which point it sorts them and writes them to a temporary file as a run. Once
+// all entries are added, the runs and the entries that are still in memory are
+// merged, so that each entry is only written and read once.
+//
+// If the sorter has a limit, only that many of the smallest entries are returned,
+// so the entries in memory are kept in a heap of at most that many entries, whose
+// root is the largest entry that is kept. Each run then holds the smallest
+// entries of those that were added since the last one, which include those of
+// the smallest entries overall.
+//
+// Entries with equal keys are returned in the order that they were added.
+//
+// See: https://www.sqlite.org/src/file/src/vdbesort.c
+type sorter struct {
+	order keyOrder
+	// memory is the budget of the entries in memory, in bytes.
+	memory int
+	// limit is the maximum number of entries that are returned, or 0 if there
+	// is none.
+	limit int
+
+	// entries are the entries in memory, which size estimates the number of
+	// bytes of. With a limit, they are a max-heap.
+	entries []sorterEntry
+	size    int
+	// seq is the number of entries that were added.
+	seq int
+	// files are the temporary files that runs were written to.
+	files []*os.File
+
+	// runs are the runs that are merged once the entries are sorted, in a heap
+	// that is ordered by their current entries.
+	runs *mergeHeap
+	// returned is the number of entries that were returned.
+	returned int
+}
+
+// sorterEntry is an entry of a sorter, with the number of entries that were added
+// before it, which orders entries with equal keys.
+type sorterEntry struct {
+	key []Register
+	seq int
+}
+
+func newSorter(order keyOrder, memory, limit int) *sorter {
+	if memory <= 0 {
+		memory = DefaultSortMemory
+	}
+
+	return &sorter{order: order, memory: memory, limit: limit}
+}
+
+// less returns true if the entry a sorts before the entry b.
+func (s *sorter) less(a, b sorterEntry) bool {
+	if cmp := s.order.compare(a.key, b.key); cmp != 0 {
+		return cmp < 0
+	}
+
+	return a.seq < b.seq
+}
+
+// insert adds an entry to the sorter, which must not be modified afterwards.
+func (s *sorter) insert(key []Register) error {
+	e := sorterEntry{key: key, seq: s.seq}
+	s.seq++
+
+	if s.limit > 0 && len(s.entries) == s.limit {
+		// The heap is full, so either the new entry or its root is dropped.
+		if !s.less(e, s.entries[0]) {
+			return nil
+		}
+		s.size -= entrySize(s.entries[0].key)
+		s.entries[0] = e
+		s.size += entrySize(key)
+		heap.Fix((*topN)(s), 0)
+	} else if s.limit > 0 {
+		heap.Push((*topN)(s), e)
+		s.size += entrySize(key)
+	} else {
+		s.entries = append(s.entries, e)
+		s.size += entrySize(key)
+	}
+
+	if s.size > s.memory {
+		return s.spill()
+	}
+
+	return nil
+}
+
+// entrySize estimates the number of bytes that an entry takes up in memory.
+func entrySize(key []Register) int {
+	n := 0
+	for _, r := range key {
+		n += registerSize + len(r.String) + len(r.Blob)
+	}
+
+	return n
+}
+
+// spill sorts the entries in memory and writes them to a temporary file as a run.
+func (s *sorter) spill() error {
+	s.sortEntries()
+
+	f, err := ioutil.TempFile("", "go-sqlite3-native-sorter-*")
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, f)
+
+	w := bufio.NewWriter(f)
+	for _, e := range s.entries {
+		writeEntry(w, e)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	s.entries, s.size = nil, 0
+
+	return nil
+}
+
+func (s *sorter) sortEntries() {
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.less(s.entries[i], s.entries[j])
+	})
+}
+
+// sort finishes adding entries and moves to the first entry, returning false if
+// there are none.
+func (s *sorter) sort() (bool, error) {
+	s.sortEntries()
+	s.runs = &mergeHeap{sorter: s}
+	for _, f := range s.files {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		s.runs.runs = append(s.runs.runs, &run{r: bufio.NewReader(f)})
+	}
+	s.runs.runs = append(s.runs.runs, &run{entries: s.entries})
+	s.entries = nil
+
+	// Each run is moved to its first entry, and dropped if it has none.
+	runs := s.runs.runs[:0]
+	for _, r := range s.runs.runs {
+		ok, err := r.next()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			runs = append(runs, r)
+		}
+	}
+	s.runs.runs = runs
+	heap.Init(s.runs)
+	s.returned = 1
+
+	return len(runs) > 0, nil
+}
+
+// next moves to the next entry, returning false if there are no more.
+func (s *sorter) next() (bool, error) {
+	if s.limit > 0 && s.returned >= s.limit {
+		return false, nil
+	}
+
+	r := s.runs.runs[0]
+	ok, err := r.next()
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		heap.Fix(s.runs, 0)
+	} else {
+		heap.Pop(s.runs)
+	}
+	s.returned++
+
+	return len(s.runs.runs) > 0, nil
+}
+
+// data returns the current entry.
+func (s *sorter) data() []Register {
+	return s.runs.runs[0].current.key
+}
+
+// close removes the temporary files of the sorter.
+func (s *sorter) close() error {
+	var err error
+	for _, f := range s.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(f.Name()); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	s.files = nil
+
+	return err
+}
+
+// topN implements heap.Interface for the entries of a sorter with a limit, as a
+// max-heap.
+type topN sorter
+
+func (h *topN) Len() int           { return len(h.entries) }
+func (h *topN) Less(i, j int) bool { return (*sorter)(h).less(h.entries[j], h.entries[i]) }
+func (h *topN) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *topN) Push(x interface{}) { h.entries = append(h.entries, x.(sorterEntry)) }
+func (h *topN) Pop() interface{} {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return e
+}
+
+// run is a sorted sequence of entries, which are read from a temporary file, or
+// are the entries that were kept in memory.
+type run struct {
+	r       *bufio.Reader
+	entries []sorterEntry
+	current sorterEntry
+}
+
+// next moves to the next entry of the run, returning false if there are no more.
+func (r *run) next() (bool, error) {
+	if r.r == nil {
+		if len(r.entries) == 0 {
+			return false, nil
+		}
+		r.current, r.entries = r.entries[0], r.entries[1:]
+		return true, nil
+	}
+
+	e, err := readEntry(r.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.current = e
+
+	return true, nil
+}
+
+// mergeHeap implements heap.Interface for the runs of a sorter, as a min-heap
+// ordered by their current entries.
+type mergeHeap struct {
+	sorter *sorter
+	runs   []*run
+}
+
+func (h *mergeHeap) Len() int { return len(h.runs) }
+func (h *mergeHeap) Less(i, j int) bool {
+	return h.sorter.less(h.runs[i].current, h.runs[j].current)
+}
+func (h *mergeHeap) Swap(i, j int)      { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *mergeHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*run)) }
+func (h *mergeHeap) Pop() interface{} {
+	r := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return r
+}
+
+// writeEntry writes an entry to a run as its sequence number and number of
+// values, followed by the type and contents of each value. Errors are returned by
+// w.Flush.
+func writeEntry(w *bufio.Writer, e sorterEntry) {
+	var buf [binary.MaxVarintLen64]byte
+	putVarint := func(x int) {
+		n := binary.PutVarint(buf[:], int64(x))
+		_, _ = w.Write(buf[:n])
+	}
+
+	putVarint(e.seq)
+	putVarint(len(e.key))
+	for _, r := range e.key {
+		_ = w.WriteByte(byte(r.typ))
+		switch r.typ {
+		case RegisterTypeInt:
+			putVarint(r.Int)
+		case RegisterTypeFloat:
+			binary.BigEndian.PutUint64(buf[:8], math.Float64bits(r.Float))
+			_, _ = w.Write(buf[:8])
+		case RegisterTypeString:
+			putVarint(len(r.String))
+			_, _ = w.WriteString(r.String)
+		case RegisterTypeBlob:
+			putVarint(len(r.Blob))
+			_, _ = w.Write(r.Blob)
+		}
+	}
+}
+
+// readEntry reads an entry that writeEntry wrote, returning io.EOF if the run has
+// no more entries.
+func readEntry(r *bufio.Reader) (sorterEntry, error) {
+	seq, err := binary.ReadVarint(r)
+	if err != nil {
+		return sorterEntry{}, err
+	}
+
+	e := sorterEntry{seq: int(seq)}
+	n, err := binary.ReadVarint(r)
+	if err != nil {
+		return sorterEntry{}, unexpectedEOF(err)
+	}
+	e.key = make([]Register, n)
+	for i := range e.key {
+		typ, err := r.ReadByte()
+		if err != nil {
+			return sorterEntry{}, unexpectedEOF(err)
+		}
+		reg := Register{typ: RegisterType(typ)}
+		switch reg.typ {
+		case RegisterTypeInt:
+			x, err := binary.ReadVarint(r)
+			if err != nil {
+				return sorterEntry{}, unexpectedEOF(err)
+			}
+			reg.Int = int(x)
+		case RegisterTypeFloat:
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return sorterEntry{}, unexpectedEOF(err)
+			}
+			reg.Float = math.Float64frombits(binary.BigEndian.Uint64(b[:]))
+		case RegisterTypeString, RegisterTypeBlob:
+			size, err := binary.ReadVarint(r)
+			if err != nil {
+				return sorterEntry{}, unexpectedEOF(err)
+			}
+			b := make([]byte, size)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return sorterEntry{}, unexpectedEOF(err)
+			}
+			if reg.typ == RegisterTypeString {
+				reg.String = string(b)
+			} else {
+				reg.Blob = b
+			}
+		}
+		e.key[i] = reg
+	}
+
+	return e, nil
+}
+
+// unexpectedEOF reports an entry that ends early as a corrupt run.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return fmt.Errorf("reading sorter run: %w", err)
+}
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSorter(tt *testing.T) {
+	// Each entry is a key in r[0] followed by its position in the input in
+	// r[1], which shows whether entries with equal keys keep their order.
+	values := []Register{
+		{typ: RegisterTypeString, String: "b"},
+		{typ: RegisterTypeNull},
+		{typ: RegisterTypeInt, Int: 2},
+		{typ: RegisterTypeString, String: "B"},
+		{typ: RegisterTypeFloat, Float: 1.5},
+		{typ: RegisterTypeBlob, Blob: []byte("a")},
+		{typ: RegisterTypeString, String: "a"},
+		{typ: RegisterTypeNull},
+		{typ: RegisterTypeInt, Int: 2},
+		{typ: RegisterTypeString, String: "A"},
+	}
+
+	for _, test := range []struct {
+		name    string
+		keyInfo *KeyInfo
+		limit   int
+		// want are the positions of the entries, in the order that they are
+		// returned.
+		want []int
+	}{
+		{
+			name:    "ascending",
+			keyInfo: &KeyInfo{Collations: []string{""}, Desc: []bool{false}},
+			want:    []int{1, 7, 4, 2, 8, 9, 3, 6, 0, 5},
+		},
+		{
+			name:    "descending",
+			keyInfo: &KeyInfo{Collations: []string{""}, Desc: []bool{true}},
+			want:    []int{5, 0, 6, 3, 9, 2, 8, 4, 1, 7},
+		},
+		{
+			name:    "nocase",
+			keyInfo: &KeyInfo{Collations: []string{"NOCASE"}, Desc: []bool{false}},
+			want:    []int{1, 7, 4, 2, 8, 6, 9, 0, 3, 5},
+		},
+		{
+			name:    "nulls last",
+			keyInfo: &KeyInfo{Collations: []string{""}, Desc: []bool{false}, BigNull: []bool{true}},
+			want:    []int{4, 2, 8, 9, 3, 6, 0, 5, 1, 7},
+		},
+		{
+			name:    "descending nulls first",
+			keyInfo: &KeyInfo{Collations: []string{""}, Desc: []bool{true}, BigNull: []bool{true}},
+			want:    []int{1, 7, 5, 0, 6, 3, 9, 2, 8, 4},
+		},
+		{
+			name:    "limit",
+			keyInfo: &KeyInfo{Collations: []string{"NOCASE"}, Desc: []bool{false}},
+			limit:   6,
+			want:    []int{1, 7, 4, 2, 8, 6},
+		},
+	} {
+		// Each test is run with all entries in memory, and with a budget that
+		// spills every few entries.
+		for _, memory := range []int{0, 3 * 2 * registerSize} {
+			tt.Run(fmt.Sprintf("%s/memory=%d", test.name, memory), func(t *testing.T) {
+				require := require.New(t)
+
+				order, err := newKeyOrder(test.keyInfo)
+				require.NoError(err)
+				s := newSorter(order, memory, test.limit)
+				defer func() {
+					require.NoError(s.close())
+				}()
+
+				for i, v := range values {
+					require.NoError(s.insert([]Register{v, {typ: RegisterTypeInt, Int: i}}))
+				}
+				if memory > 0 {
+					require.NotEmpty(s.files)
+				}
+
+				var got []int
+				ok, err := s.sort()
+				for ; ok; ok, err = s.next() {
+					got = append(got, s.data()[1].Int)
+				}
+				require.NoError(err)
+				require.Equal(test.want, got)
+			})
+		}
+	}
+}
+
+func TestSorterSpills(t *testing.T) {
+	require := require.New(t)
+
+	order, err := newKeyOrder(&KeyInfo{Collations: []string{"", ""}, Desc: []bool{false, true}})
+	require.NoError(err)
+	s := newSorter(order, 1000, 0)
+
+	var want [][]Register
+	for i := 0; i < 1000; i++ {
+		entry := []Register{
+			{typ: RegisterTypeInt, Int: rand.Intn(10)},
+			{typ: RegisterTypeString, String: fmt.Sprint(rand.Intn(1000))},
+			{typ: RegisterTypeBlob, Blob: []byte{byte(i)}},
+		}
+		want = append(want, entry)
+		require.NoError(s.insert(entry))
+	}
+	sort.SliceStable(want, func(i, j int) bool {
+		return order.compare(want[i], want[j]) < 0
+	})
+
+	files := s.files
+	require.True(len(files) > 10)
+
+	var got [][]Register
+	ok, err := s.sort()
+	for ; ok; ok, err = s.next() {
+		got = append(got, s.data())
+	}
+	require.NoError(err)
+	require.Equal(want, got)
+
+	// The temporary files are removed once the sorter is closed.
+	require.NoError(s.close())
+	for _, f := range files {
+		_, err := os.Stat(f.Name())
+		require.True(os.IsNotExist(err))
+	}
+}
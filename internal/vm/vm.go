@@ -1,7 +1,10 @@
 package vm
 
 import (
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/colinking/go-sqlite3-native/internal/tree"
 )
@@ -10,8 +13,22 @@ const (
 	BufferSize = 100
 )
 
+// ErrMismatch is returned if a value cannot be used where an integer is required,
+// f.e. by a LIMIT clause.
+var ErrMismatch = errors.New("datatype mismatch")
+
+// stopCheckInterval is the number of instructions that a program runs between
+// checks of whether its execution was closed, so that Close does not wait for
+// one that runs for a long time without returning a row, f.e. while it sorts.
+const stopCheckInterval = 1000
+
 type VM struct {
 	tm *tree.TreeManager
+
+	// SortMemory is the number of bytes that the entries of each sorter may take
+	// up in memory before they are spilled to temporary files. If it is 0,
+	// DefaultSortMemory is used.
+	SortMemory int
 }
 
 func NewVM(tm *tree.TreeManager) *VM {
@@ -24,20 +41,45 @@ func (m *VM) Close() error {
 	return m.tm.Close()
 }
 
+// SchemaCookie returns the DB's current schema cookie. SQLite increments the
+// cookie each time the schema changes, which invalidates compiled programs.
+func (m *VM) SchemaCookie() (int, error) {
+	header, err := m.tm.Header()
+	if err != nil {
+		return 0, err
+	}
+
+	return header.SchemaCookieNumber, nil
+}
+
 type Execution struct {
 	program Program
-	tm      *tree.TreeManager
-	results chan []tree.Column
-	done    chan error
+	// params are the values bound to the program's parameters, where params[i]
+	// is bound to parameter i+1.
+	params     []driver.Value
+	tm         *tree.TreeManager
+	sortMemory int
+	results    chan []driver.Value
+	done       chan error
+	// stop is closed to ask the program to stop before it is finished, and
+	// stopped is closed once it has.
+	stop    chan struct{}
+	stopped chan struct{}
 }
 
-func (m *VM) Execute(program Program) *Execution {
+// Execute begins executing program. Each value in params must be one of the
+// types that a register can hold: nil, int64, float64, string or []byte.
+func (m *VM) Execute(program Program, params []driver.Value) *Execution {
 	e := &Execution{
 		program: program,
-
-		tm:      m.tm,
-		results: make(chan []tree.Column, BufferSize),
-		done:    make(chan error, 1),
+		params:  params,
+
+		tm:         m.tm,
+		sortMemory: m.SortMemory,
+		results:    make(chan []driver.Value, BufferSize),
+		done:       make(chan error, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 
 	// A VM program is executed in a separate goroutine where results are
@@ -48,22 +90,57 @@ func (m *VM) Execute(program Program) *Execution {
 }
 
 func (e *Execution) run() {
-	activeTreeIndex := -1
-	trees := []*tree.Tree{}
-	row := []tree.Column{}
+	cursors := []*cursor{}
 	registers := &Registers{}
+	// aggregates holds the state of each aggregate function, by the register
+	// that OpcodeAggFinal stores its result in.
+	aggregates := map[int]aggregateState{}
+	// compared is the result of the last OpcodeCompare.
+	compared := 0
+	// once holds the addresses of the OpcodeOnces that were run.
+	once := map[int]bool{}
+
+	defer func() {
+		// The temporary files of sorters are removed once the program stops,
+		// whether or not it failed. There is nothing to be done about errors
+		// at this point.
+		for _, c := range cursors {
+			if c != nil {
+				_ = c.close()
+			}
+		}
+		close(e.stopped)
+	}()
+
+	// jump continues execution at the instruction at address p2.
+	pc := 0
+	jump := func(p2 int) {
+		pc = p2
+		pc-- // negate pc++
+	}
 
-	for pc := 0; pc < len(e.program.Instructions); pc++ {
+	for steps := 1; pc < len(e.program.Instructions); pc, steps = pc+1, steps+1 {
 		inst := e.program.Instructions[pc]
+		if steps%stopCheckInterval == 0 {
+			select {
+			case <-e.stop:
+				return
+			default:
+			}
+		}
 
 		// Opcodes are explained in the SQLite docs here: https://www.sqlite.org/opcode.html
 		switch inst.Op {
 		case OpcodeInit: // https://www.sqlite.org/opcode.html#Init
 			if inst.P2 > 0 {
-				pc = inst.P2
-				pc-- // negate pc++
+				jump(inst.P2)
 			}
 		case OpcodeHalt: // https://www.sqlite.org/opcode.html#Halt
+			// If P1 is not 0, the program fails with the error message in P4.
+			if inst.P1 != 0 {
+				e.done <- errors.New(inst.P4.s)
+				return
+			}
 			pc = len(e.program.Instructions)
 		case OpcodeTransaction: // https://www.sqlite.org/opcode.html#Transaction
 			// TODO: issue a Begin query to the pager to acquire the read lock
@@ -87,8 +164,7 @@ func (e *Execution) run() {
 				// TODO: there's some kind of "schema generation counter" to validate here that is not well-defined.
 			}
 		case OpcodeGoto: // https://www.sqlite.org/opcode.html#Goto
-			pc = inst.P2
-			pc-- // negate pc++
+			jump(inst.P2)
 		case OpcodeOpenRead: // https://www.sqlite.org/opcode.html#OpenRead
 			if inst.P3 != 0 {
 				// We don't need temporary tables because we don't support complex JOINs.
@@ -100,7 +176,6 @@ func (e *Execution) run() {
 
 			cursorID := inst.P1
 			rootPageNumber := inst.P2
-			// numColumns := inst.P4
 
 			t, err := e.tm.Open(rootPageNumber)
 			if err != nil {
@@ -108,56 +183,246 @@ func (e *Execution) run() {
 				return
 			}
 
-			if cursorID < cap(trees) {
-				trees[cursorID] = t
-			} else if cursorID == cap(trees) {
-				trees = append(trees, t)
-			} else {
-				e.done <- fmt.Errorf("failed to insert tree (len=%d cap=%d cursorID=%d)", len(trees), cap(trees), cursorID)
+			// Indexes have a KeyInfo in P4 that describes how their entries
+			// are ordered.
+			c, err := newCursor(t, inst.P4.k)
+			if err != nil {
+				e.done <- err
 				return
 			}
 
-			// TODO: assert the opened b-tree has numColumns
+			cursors = setCursor(cursors, cursorID, c)
 
 			// TODO: consider incorporating P5's OPFLAG_SEEKEQ to optimize tree lookups
 
+		case OpcodeSorterOpen, OpcodeOpenEphemeral: // https://www.sqlite.org/opcode.html#SorterOpen
+			// Both open a cursor on an empty index with the KeyInfo in P4, which
+			// is kept in memory, or, for sorters, spilled to temporary files
+			// once it grows too large. OpenEphemeral without a KeyInfo opens
+			// an empty table instead. If the cursor is already open, it is
+			// emptied. Unlike in SQLite, a sorter returns at most r[P3] entries,
+			// if P3 is not 0 and r[P3] is positive.
+			c, err := newCursor(nil, inst.P4.k)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			if inst.Op == OpcodeSorterOpen {
+				limit := 0
+				if r := registers.Get(inst.P3); inst.P3 != 0 && r.typ == RegisterTypeInt && r.Int > 0 {
+					limit = r.Int
+				}
+				c.sorter = newSorter(c.keyOrder, e.sortMemory, limit)
+			}
+			if inst.P1 < len(cursors) && cursors[inst.P1] != nil {
+				if err := cursors[inst.P1].close(); err != nil {
+					e.done <- err
+					return
+				}
+			}
+			cursors = setCursor(cursors, inst.P1, c)
+
+		case OpcodeSorterInsert, OpcodeIdxInsert, OpcodeInsert: // https://www.sqlite.org/opcode.html#SorterInsert
+			// Unlike in SQLite, the entry is the P3 registers starting at P2,
+			// rather than a record made by MakeRecord. Insert appends it to an
+			// ephemeral table, whose entries have no rowids.
+			entry := make([]Register, inst.P3)
+			for i := range entry {
+				entry[i] = registers.Get(inst.P2 + i)
+				if entry[i].typ == RegisterTypeBlob {
+					entry[i].Blob = append([]byte{}, entry[i].Blob...)
+				}
+			}
+			c := cursors[inst.P1]
+			switch inst.Op {
+			case OpcodeIdxInsert:
+				c.insert(entry)
+			case OpcodeInsert:
+				c.entries = append(c.entries, entry)
+			default:
+				if err := c.sorter.insert(entry); err != nil {
+					e.done <- err
+					return
+				}
+			}
+
+		case OpcodeSorterSort: // https://www.sqlite.org/opcode.html#SorterSort
+			ok, err := cursors[inst.P1].sorter.sort()
+			if err != nil {
+				e.done <- err
+				return
+			}
+			if !ok {
+				jump(inst.P2)
+			}
+
+		case OpcodeSorterNext: // https://www.sqlite.org/opcode.html#SorterNext
+			ok, err := cursors[inst.P1].sorter.next()
+			if err != nil {
+				e.done <- err
+				return
+			}
+			if ok {
+				jump(inst.P2)
+			}
+
+		case OpcodeSorterData: // https://www.sqlite.org/opcode.html#SorterData
+			// Unlike in SQLite, the current entry is copied into the P3
+			// registers starting at P2, rather than into a single register.
+			entry := cursors[inst.P1].sorter.data()
+			for i := 0; i < inst.P3; i++ {
+				registers.Set(inst.P2+i, entry[i])
+			}
+
+		case OpcodeIdxDelete: // https://www.sqlite.org/opcode.html#IdxDelete
+			// Unlike in SQLite, the key is the P3 registers starting at P2,
+			// and the entry is removed from an ephemeral index.
+			key := make([]Register, inst.P3)
+			for i := range key {
+				key[i] = registers.Get(inst.P2 + i)
+			}
+			cursors[inst.P1].delete(key)
+
+		case OpcodeDelete: // https://www.sqlite.org/opcode.html#Delete
+			// Unlike in SQLite, only the entries of ephemeral indexes and tables
+			// can be deleted.
+			cursors[inst.P1].deleteCurrent()
+
+		case OpcodeOpenDup: // https://www.sqlite.org/opcode.html#OpenDup
+			// Unlike in SQLite, the new cursor only has the entries that the
+			// ephemeral index or table P2 has now, so it must not be changed
+			// anymore.
+			dup := *cursors[inst.P2]
+			dup.pos, dup.nullRow = 0, false
+			cursors = setCursor(cursors, inst.P1, &dup)
+
+		case OpcodeSequence: // https://www.sqlite.org/opcode.html#Sequence
+			c := cursors[inst.P1]
+			registers.SetInt(inst.P2, c.seq)
+			c.seq++
+
+		case OpcodeFound, OpcodeNotFound: // https://www.sqlite.org/opcode.html#Found
+			// Jumps to P2 if the ephemeral index P1 has an entry that is equal
+			// to the key in the P4 registers starting at P3, or for NotFound,
+			// if it has none.
+			key := make([]Register, inst.P4.i)
+			for i := range key {
+				key[i] = registers.Get(inst.P3 + i)
+			}
+			if _, found := cursors[inst.P1].find(key); found == (inst.Op == OpcodeFound) {
+				jump(inst.P2)
+			}
+
 		case OpcodeRewind: // https://www.sqlite.org/opcode.html#Rewind
-			treeIdx := inst.P1
-			activeTreeIndex = treeIdx // signal which tree to use, to the next call to Column/etc.
-			tree := trees[treeIdx]
+			tree := cursors[inst.P1].tree
+			cursors[inst.P1].nullRow = false
+			if tree == nil {
+				// An ephemeral index is read in the order of its entries.
+				c := cursors[inst.P1]
+				c.pos = 0
+				if len(c.entries) == 0 {
+					jump(inst.P2)
+				}
+				break
+			}
 			tree.ResetCursor()
 
 			if !tree.Next() {
+				if err := tree.Err(); err != nil {
+					e.done <- err
+					return
+				}
 				// If there are _no_ more rows to read, skip to:
-				pc = inst.P2
-				pc-- // negate pc++
+				jump(inst.P2)
 			}
 
 		case OpcodeColumn: // https://www.sqlite.org/opcode.html#Column
-			tree := trees[activeTreeIndex]
+			if cursors[inst.P1].nullRow {
+				registers.SetNull(inst.P3)
+				break
+			}
+			tree := cursors[inst.P1].tree
 			columnIdx := inst.P2
+			if tree == nil {
+				c := cursors[inst.P1]
+				registers.Set(inst.P3, c.entries[c.pos][columnIdx])
+				break
+			}
 			column := tree.Get().GetColumn(columnIdx)
-			row = append(row, column)
+			if err := registers.SetValue(inst.P3, column.Value()); err != nil {
+				e.done <- err
+				return
+			}
+
+		case OpcodeRowid: // https://www.sqlite.org/opcode.html#Rowid
+			// Unlike in SQLite, this also reads the rowid of index entries,
+			// which SQLite has a separate IdxRowid opcode for. The entries of
+			// ephemeral tables are numbered from 1, in the order that they
+			// were inserted, and a cursor past the last one reads the number
+			// that the next one would have.
+			if cursors[inst.P1].nullRow {
+				registers.SetNull(inst.P2)
+				break
+			}
+			if c := cursors[inst.P1]; c.tree == nil {
+				registers.SetInt(inst.P2, c.pos+1)
+				break
+			}
+			registers.SetInt(inst.P2, cursors[inst.P1].tree.Get().Rowid())
 
 		case OpcodeResultRow: // https://www.sqlite.org/opcode.html#ResultRow
-			e.results <- row
+			row := make([]driver.Value, inst.P2)
+			for i := range row {
+				row[i] = registers.Get(inst.P1 + i).Value()
+			}
+			// Wait for the row to be read, unless the execution is closed
+			// first.
+			select {
+			case e.results <- row:
+			case <-e.stop:
+				return
+			}
 
-			// Reset the row
-			row = []tree.Column{}
+		case OpcodeVariable: // https://www.sqlite.org/opcode.html#Variable
+			// Parameters are numbered starting from 1.
+			paramIdx := inst.P1
+			if paramIdx < 1 || paramIdx > len(e.params) {
+				// Unbound parameters are NULL.
+				registers.SetNull(inst.P2)
+			} else if err := registers.SetValue(inst.P2, e.params[paramIdx-1]); err != nil {
+				e.done <- err
+				return
+			}
 
 		case OpcodeNext: // https://www.sqlite.org/opcode.html#Next
-			cursorID := inst.P1
-			tree := trees[cursorID]
+			// A cursor on a NULL row has no next row.
+			tree := cursors[inst.P1].tree
+			if cursors[inst.P1].nullRow {
+				break
+			}
+			if tree == nil {
+				c := cursors[inst.P1]
+				if c.pos++; c.pos < len(c.entries) {
+					jump(inst.P2)
+				}
+				break
+			}
 			if tree.Next() {
 				// If there are _more_ rows to read, skip to:
-				pc = inst.P2
-				pc-- // negate pc++
+				jump(inst.P2)
+			} else if err := tree.Err(); err != nil {
+				e.done <- err
+				return
 			}
 
 		case OpcodeString8: // https://www.sqlite.org/opcode.html#String8
 			s := inst.P4.s
 			idx := inst.P2
 			registers.SetString(idx, s)
+			// If r[P3] equals P5, the string is stored as a blob instead.
+			if inst.P3 != 0 && registers.Get(inst.P3).typ == RegisterTypeInt && registers.Get(inst.P3).Int == inst.P5 {
+				registers.SetBlob(idx, []byte(s))
+			}
 
 		case OpcodeCast: // https://www.sqlite.org/opcode.html#Cast
 			idx := inst.P1
@@ -165,13 +430,15 @@ func (e *Execution) run() {
 
 			var err error
 			switch typ {
-			case 'A': // BLOB
+			case AffinityBlob:
 				err = registers.CastAsBlob(idx)
-			case 'B': // TEXT
+			case AffinityText:
 				err = registers.CastAsString(idx)
-			case 'D': // INTEGER
+			case AffinityNumeric:
+				err = registers.CastAsNumeric(idx)
+			case AffinityInteger:
 				err = registers.CastAsInt(idx)
-			case 'E': // REAL
+			case AffinityReal:
 				err = registers.CastAsFloat(idx)
 			default:
 				e.done <- fmt.Errorf("unknown/unsupported typ=%+v", typ)
@@ -183,34 +450,487 @@ func (e *Execution) run() {
 				return
 			}
 
+		case OpcodeAffinity: // https://www.sqlite.org/opcode.html#Affinity
+			// P4 has an affinity for each of the P2 registers starting at P1.
+			affinities := inst.P4.s
+			if len(affinities) != inst.P2 {
+				e.done <- fmt.Errorf("invalid affinities %q for %d registers", affinities, inst.P2)
+				return
+			}
+			for i := 0; i < inst.P2; i++ {
+				registers.ApplyAffinity(inst.P1+i, int(affinities[i]))
+			}
+
+		case OpcodeRealAffinity: // https://www.sqlite.org/opcode.html#RealAffinity
+			// REAL columns may store reals that have no fractional part as
+			// integers, which this converts back.
+			if r := registers.Get(inst.P1); r.typ == RegisterTypeInt {
+				registers.SetFloat(inst.P1, float64(r.Int))
+			}
+
 		case OpcodeIsNull: // https://www.sqlite.org/opcode.html#IsNull
 			idx := inst.P1
 			r := registers.Get(idx)
 			if r.typ == RegisterTypeNull {
-				pc = inst.P2
-				pc-- // negate pc++
+				jump(inst.P2)
+			}
+
+		case OpcodeNotNull: // https://www.sqlite.org/opcode.html#NotNull
+			if registers.Get(inst.P1).typ != RegisterTypeNull {
+				jump(inst.P2)
+			}
+
+		case OpcodeSeekGE, OpcodeSeekGT: // https://www.sqlite.org/opcode.html#SeekGE
+			// The key is in the P4 registers starting at P3. For tables, it
+			// is a single rowid.
+			c := cursors[inst.P1]
+			c.nullRow = false
+			key := make([]Register, inst.P4.i)
+			for i := range key {
+				key[i] = registers.Get(inst.P3 + i)
+			}
+			if !c.index {
+				key = []Register{numericAffinity(registers.Get(inst.P3))}
+			}
+
+			// No rowid is NULL, but NULLs are the smallest entries of indexes.
+			found := false
+			if c.index || key[0].typ != RegisterTypeNull {
+				found = c.seek(key, inst.Op == OpcodeSeekGT)
+			}
+			if !found {
+				if err := c.tree.Err(); err != nil {
+					e.done <- err
+					return
+				}
+				jump(inst.P2)
+			}
+
+		case OpcodeSeekRowid: // https://www.sqlite.org/opcode.html#SeekRowid
+			// Jump to P2 if the table has no row whose rowid is r[P3].
+			c := cursors[inst.P1]
+			c.nullRow = false
+			rowid, ok := rowidValue(registers.Get(inst.P3))
+			if c.tree == nil {
+				// The rowids of ephemeral tables are read by OpcodeRowid.
+				if !ok || rowid < 1 || rowid > len(c.entries) {
+					jump(inst.P2)
+					break
+				}
+				c.pos = rowid - 1
+				break
+			}
+			if !ok || !c.tree.SeekRowid(rowid) {
+				if err := c.tree.Err(); err != nil {
+					e.done <- err
+					return
+				}
+				jump(inst.P2)
+			}
+
+		case OpcodeIdxGT, OpcodeIdxGE, OpcodeIdxLT, OpcodeIdxLE: // https://www.sqlite.org/opcode.html#IdxGT
+			// These compare the index entry at cursor P1 with the key in the
+			// P4 registers starting at P3, and jump to P2 if the entry is
+			// greater, etc. Only the first P4 columns of the entry are
+			// compared.
+			c := cursors[inst.P1]
+			key := make([]Register, inst.P4.i)
+			for i := range key {
+				key[i] = registers.Get(inst.P3 + i)
+			}
+			cmp := c.compareKey(c.tree.Get(), key)
+
+			var result bool
+			switch inst.Op {
+			case OpcodeIdxGT:
+				result = cmp > 0
+			case OpcodeIdxGE:
+				result = cmp >= 0
+			case OpcodeIdxLT:
+				result = cmp < 0
+			case OpcodeIdxLE:
+				result = cmp <= 0
+			}
+			if result {
+				jump(inst.P2)
+			}
+
+		case OpcodeDeferredSeek: // https://www.sqlite.org/opcode.html#DeferredSeek
+			// Moves the table cursor P3 to the row that the index entry at
+			// cursor P1 refers to. SQLite defers this until a column of the
+			// table is read, but seeking eagerly is simpler.
+			index, table := cursors[inst.P1], cursors[inst.P3]
+			table.nullRow = false
+			if !table.tree.SeekRowid(index.tree.Get().Rowid()) {
+				if err := table.tree.Err(); err != nil {
+					e.done <- err
+					return
+				}
+				e.done <- fmt.Errorf("%w: index entry without a row", tree.ErrCorrupt)
+				return
+			}
+
+		case OpcodeIfPos: // https://www.sqlite.org/opcode.html#IfPos
+			// If r[P1] is positive, it is decremented by P3 and execution
+			// continues at P2.
+			if r := registers.Get(inst.P1); r.typ == RegisterTypeInt && r.Int > 0 {
+				registers.SetInt(inst.P1, r.Int-inst.P3)
+				jump(inst.P2)
+			}
+
+		case OpcodeDecrJumpZero: // https://www.sqlite.org/opcode.html#DecrJumpZero
+			// r[P1] is decremented, and execution continues at P2 if it
+			// became zero.
+			if r := registers.Get(inst.P1); r.typ == RegisterTypeInt && r.Int > math.MinInt64 {
+				registers.SetInt(inst.P1, r.Int-1)
+				if r.Int == 1 {
+					jump(inst.P2)
+				}
+			}
+
+		case OpcodeMustBeInt: // https://www.sqlite.org/opcode.html#MustBeInt
+			// r[P1] is converted to an integer, if it is one without losing
+			// information. Otherwise, execution continues at P2, or fails if
+			// P2 is 0.
+			r := applyAffinity(registers.Get(inst.P1), AffinityInteger)
+			if r.typ != RegisterTypeInt {
+				if inst.P2 == 0 {
+					e.done <- ErrMismatch
+					return
+				}
+				jump(inst.P2)
+				break
+			}
+			registers.Set(inst.P1, r)
+
+		case OpcodeOffsetLimit: // https://www.sqlite.org/opcode.html#OffsetLimit
+			// r[P2] is set to the number of rows that must be computed to
+			// output the r[P1] rows of a LIMIT after skipping the r[P3] rows
+			// of its OFFSET, or to -1 if there is no limit. r[P1] and r[P3]
+			// are integers.
+			limit, offset := registers.Get(inst.P1).Int, registers.Get(inst.P3).Int
+			switch {
+			case limit <= 0:
+				registers.SetInt(inst.P2, -1)
+			case offset > 0 && limit > math.MaxInt64-offset:
+				registers.SetInt(inst.P2, -1)
+			case offset > 0:
+				registers.SetInt(inst.P2, limit+offset)
+			default:
+				registers.SetInt(inst.P2, limit)
 			}
 
-		case OpcodeSeekGE: // https://www.sqlite.org/opcode.html#SeekGE
-			cursorIdx := inst.P1
-			keyIdx := inst.P3
-			// TODO: nKeys := inst.P4
+		case OpcodeNullRow: // https://www.sqlite.org/opcode.html#NullRow
+			// Moves cursor P1 to a row whose columns are all NULL, which
+			// LEFT JOINs output when no row of their right table matches.
+			cursors[inst.P1].nullRow = true
+
+		case OpcodeInteger: // https://www.sqlite.org/opcode.html#Integer
+			registers.SetInt(inst.P2, inst.P1)
+
+		case OpcodeReal: // https://www.sqlite.org/opcode.html#Real
+			registers.SetFloat(inst.P2, inst.P4.f)
+
+		case OpcodeNull: // https://www.sqlite.org/opcode.html#Null
+			// Registers P2 through P3, inclusive, are set to NULL. Like in
+			// SQLite, where the state of an aggregate function is kept in the
+			// register of its accumulator, this resets the states kept for
+			// them.
+			registers.SetNull(inst.P2)
+			delete(aggregates, inst.P2)
+			for idx := inst.P2 + 1; idx <= inst.P3; idx++ {
+				registers.SetNull(idx)
+				delete(aggregates, idx)
+			}
+
+		case OpcodeBlob: // https://www.sqlite.org/opcode.html#Blob
+			// P1 is the length of the blob in P4.
+			registers.SetBlob(inst.P2, []byte(inst.P4.s))
+
+		case OpcodeCopy: // https://www.sqlite.org/opcode.html#Copy
+			// Registers P1 through P1+P3 are copied into P2 through P2+P3.
+			for i := 0; i <= inst.P3; i++ {
+				r := registers.Get(inst.P1 + i)
+				if r.typ == RegisterTypeBlob {
+					r.Blob = append([]byte{}, r.Blob...)
+				}
+				registers.Set(inst.P2+i, r)
+			}
+
+		case OpcodeSCopy: // https://www.sqlite.org/opcode.html#SCopy
+			registers.Set(inst.P2, registers.Get(inst.P1))
+
+		case OpcodeAdd, OpcodeMultiply: // https://www.sqlite.org/opcode.html#Add
+			registers.Set(inst.P3, arithmetic(inst.Op, registers.Get(inst.P1), registers.Get(inst.P2)))
+
+		case OpcodeAddImm: // https://www.sqlite.org/opcode.html#AddImm
+			// r[P1] is converted to an integer, which P2 is added to.
+			registers.SetInt(inst.P1, intValue(registers.Get(inst.P1))+inst.P2)
 
-			cursor := trees[cursorIdx]
-			key := registers.Get(keyIdx).Blob
-			err := cursor.SeekGE(key)
+		case OpcodeSubtract, OpcodeDivide, OpcodeRemainder: // https://www.sqlite.org/opcode.html#Subtract
+			// These compute r[P2] op r[P1].
+			registers.Set(inst.P3, arithmetic(inst.Op, registers.Get(inst.P2), registers.Get(inst.P1)))
+
+		case OpcodeConcat: // https://www.sqlite.org/opcode.html#Concat
+			registers.Set(inst.P3, concat(registers.Get(inst.P2), registers.Get(inst.P1)))
+
+		case OpcodeBitAnd, OpcodeBitOr, OpcodeShiftLeft, OpcodeShiftRight: // https://www.sqlite.org/opcode.html#BitAnd
+			// These compute r[P2] op r[P1].
+			registers.Set(inst.P3, bitwise(inst.Op, registers.Get(inst.P2), registers.Get(inst.P1)))
+
+		case OpcodeBitNot: // https://www.sqlite.org/opcode.html#BitNot
+			if r := registers.Get(inst.P1); r.typ == RegisterTypeNull {
+				registers.SetNull(inst.P2)
+			} else {
+				registers.SetInt(inst.P2, ^intValue(r))
+			}
+
+		case OpcodeEq, OpcodeNe, OpcodeLt, OpcodeLe, OpcodeGt, OpcodeGe: // https://www.sqlite.org/opcode.html#Eq
+			coll, err := lookupCollation(inst.P4.s)
 			if err != nil {
 				e.done <- err
 				return
 			}
 
-		case OpcodeIdxGT: // https://www.sqlite.org/opcode.html#IdxGT
-			e.done <- fmt.Errorf("todo: support IdxGT! %+v", inst)
-			return
+			// These compare r[P3] op r[P1], and jump to P2 if that is true.
+			result, ok := compare(inst.Op, registers.Get(inst.P3), registers.Get(inst.P1), inst.P5, coll)
+			if result || (!ok && inst.P5&JumpIfNull != 0) {
+				jump(inst.P2)
+			}
 
-		case OpcodeDeferredSeek: // https://www.sqlite.org/opcode.html#DeferredSeek
-			e.done <- fmt.Errorf("todo: support DeferredSeek! %+v", inst)
-			return
+		case OpcodeZeroOrNull: // https://www.sqlite.org/opcode.html#ZeroOrNull
+			if registers.Get(inst.P1).typ == RegisterTypeNull || registers.Get(inst.P3).typ == RegisterTypeNull {
+				registers.SetNull(inst.P2)
+			} else {
+				registers.SetInt(inst.P2, 0)
+			}
+
+		case OpcodeAnd, OpcodeOr: // https://www.sqlite.org/opcode.html#And
+			registers.Set(inst.P3, logic(inst.Op, registers.Get(inst.P1), registers.Get(inst.P2)))
+
+		case OpcodeNot: // https://www.sqlite.org/opcode.html#Not
+			if result, ok := truth(registers.Get(inst.P1)); ok {
+				registers.Set(inst.P2, boolean(!result))
+			} else {
+				registers.SetNull(inst.P2)
+			}
+
+		case OpcodeIf, OpcodeIfNot: // https://www.sqlite.org/opcode.html#If
+			// If r[P1] is NULL, then the jump is only taken if P3 is non-zero.
+			result, ok := truth(registers.Get(inst.P1))
+			if inst.Op == OpcodeIfNot {
+				result = !result
+			}
+			if (ok && result) || (!ok && inst.P3 != 0) {
+				jump(inst.P2)
+			}
+
+		case OpcodeFunction: // https://www.sqlite.org/opcode.html#Function
+			// Unlike in SQLite, P4 is the name of the function and P5 the number
+			// of arguments, which are in registers P2 onwards. The result is
+			// stored in P3.
+			f, err := LookupFunction(inst.P4.s, inst.P5)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			args := make([]Register, inst.P5)
+			for i := range args {
+				args[i] = registers.Get(inst.P2 + i)
+			}
+			result, err := f.Call(args)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			registers.Set(inst.P3, result)
+
+		case OpcodeCollSeq: // https://www.sqlite.org/opcode.html#CollSeq
+			// P4 is the collating sequence of the next OpcodeAggStep. If P1 is
+			// not zero, r[P1] is set to 0, and later to 1 if the aggregate
+			// function skips the row.
+			if inst.P1 != 0 {
+				registers.SetInt(inst.P1, 0)
+			}
+
+		case OpcodeAggStep: // https://www.sqlite.org/opcode.html#AggStep
+			// Unlike in SQLite, P4 is the name of the function and P5 the number
+			// of arguments, which are in registers P2 onwards. The state of the
+			// function is kept for the register P3.
+			f, err := LookupAggregate(inst.P4.s, inst.P5)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			state, ok := aggregates[inst.P3]
+			if !ok {
+				state = f.new()
+				aggregates[inst.P3] = state
+			}
+
+			coll := collations["BINARY"]
+			hit := 0
+			if pc > 0 && e.program.Instructions[pc-1].Op == OpcodeCollSeq {
+				prev := e.program.Instructions[pc-1]
+				if coll, err = lookupCollation(prev.P4.s); err != nil {
+					e.done <- err
+					return
+				}
+				hit = prev.P1
+			}
+
+			args := make([]Register, inst.P5)
+			for i := range args {
+				args[i] = registers.Get(inst.P2 + i)
+				if args[i].typ == RegisterTypeUnknown {
+					args[i] = Register{typ: RegisterTypeNull}
+				}
+			}
+			skip, err := state.step(args, coll)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			if skip && hit != 0 {
+				registers.SetInt(hit, 1)
+			}
+
+		case OpcodeAggFinal: // https://www.sqlite.org/opcode.html#AggFinal
+			// The result of the aggregate function whose state is kept for the
+			// register P1 is stored in it, and the state is reset. P2 is the
+			// number of arguments and P4 the name of the function.
+			f, err := LookupAggregate(inst.P4.s, inst.P2)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			state, ok := aggregates[inst.P1]
+			if !ok {
+				state = f.new()
+			}
+			delete(aggregates, inst.P1)
+			result, err := state.final()
+			if err != nil {
+				e.done <- err
+				return
+			}
+			registers.Set(inst.P1, result)
+
+		case OpcodeAggInverse: // https://www.sqlite.org/opcode.html#AggInverse
+			// Removes a row from the state of the aggregate function that is
+			// kept for the register P3, which must be the first row that is
+			// left of those that were added to it. Like for OpcodeAggStep, P4
+			// is the name of the function and P5 the number of arguments,
+			// which are in registers P2 onwards.
+			f, err := LookupAggregate(inst.P4.s, inst.P5)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			state, ok := aggregates[inst.P3]
+			if !ok {
+				state = f.new()
+				aggregates[inst.P3] = state
+			}
+			inv, ok := state.(inverter)
+			if !ok {
+				e.done <- fmt.Errorf("%s() cannot remove rows", f.Name)
+				return
+			}
+
+			args := make([]Register, inst.P5)
+			for i := range args {
+				args[i] = registers.Get(inst.P2 + i)
+				if args[i].typ == RegisterTypeUnknown {
+					args[i] = Register{typ: RegisterTypeNull}
+				}
+			}
+			if err := inv.inverse(args); err != nil {
+				e.done <- err
+				return
+			}
+
+		case OpcodeAggValue: // https://www.sqlite.org/opcode.html#AggValue
+			// The result of the aggregate function whose state is kept for the
+			// register P1 is stored in r[P3]. Unlike OpcodeAggFinal, this keeps
+			// the state, so that rows can still be added to or removed from
+			// it. P2 is the number of arguments and P4 the name of the function.
+			f, err := LookupAggregate(inst.P4.s, inst.P2)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			state, ok := aggregates[inst.P1]
+			if !ok {
+				state = f.new()
+				aggregates[inst.P1] = state
+			}
+			result, err := state.final()
+			if err != nil {
+				e.done <- err
+				return
+			}
+			registers.Set(inst.P3, result)
+
+		case OpcodeCompare: // https://www.sqlite.org/opcode.html#Compare
+			// Compares the P3 registers starting at P1 with those starting at
+			// P2, using the collating sequences of the KeyInfo in P4, for the
+			// next OpcodeJump.
+			order, err := newKeyOrder(inst.P4.k)
+			if err != nil {
+				e.done <- err
+				return
+			}
+			a, b := make([]Register, inst.P3), make([]Register, inst.P3)
+			for i := range a {
+				a[i], b[i] = registers.Get(inst.P1+i), registers.Get(inst.P2+i)
+			}
+			compared = order.compare(a, b)
+
+		case OpcodeJump: // https://www.sqlite.org/opcode.html#Jump
+			switch {
+			case compared < 0:
+				jump(inst.P1)
+			case compared == 0:
+				jump(inst.P2)
+			default:
+				jump(inst.P3)
+			}
+
+		case OpcodeGosub: // https://www.sqlite.org/opcode.html#Gosub
+			registers.SetInt(inst.P1, pc)
+			jump(inst.P2)
+
+		case OpcodeReturn: // https://www.sqlite.org/opcode.html#Return
+			jump(registers.Get(inst.P1).Int + 1)
+
+		case OpcodeOnce: // https://www.sqlite.org/opcode.html#Once
+			// Falls through the first time that it is run, and jumps to P2
+			// after that.
+			if once[pc] {
+				jump(inst.P2)
+			}
+			once[pc] = true
+
+		case OpcodeInitCoroutine: // https://www.sqlite.org/opcode.html#InitCoroutine
+			// Sets up r[P1] so that the first Yield to it starts the
+			// coroutine at P3, and jumps over the coroutine to P2.
+			registers.SetInt(inst.P1, inst.P3-1)
+			if inst.P2 != 0 {
+				jump(inst.P2)
+			}
+
+		case OpcodeYield: // https://www.sqlite.org/opcode.html#Yield
+			// Swaps the address in r[P1] with that of this instruction, and
+			// continues after the swapped-in address.
+			next := registers.Get(inst.P1).Int
+			registers.SetInt(inst.P1, pc)
+			jump(next + 1)
+
+		case OpcodeEndCoroutine: // https://www.sqlite.org/opcode.html#EndCoroutine
+			// Ends the coroutine in r[P1] by jumping to the P2 of the Yield
+			// that last started it.
+			jump(e.program.Instructions[registers.Get(inst.P1).Int].P2)
 
 		default:
 			e.done <- fmt.Errorf("unknown opcode! %+v", inst)
@@ -221,14 +941,24 @@ func (e *Execution) run() {
 	e.done <- nil
 }
 
+// setCursor stores c as the cursor with the given ID, growing cursors as needed.
+func setCursor(cursors []*cursor, id int, c *cursor) []*cursor {
+	for id >= len(cursors) {
+		cursors = append(cursors, nil)
+	}
+	cursors[id] = c
+
+	return cursors
+}
+
 // Next returns the next available tuple produced by executing this VM program.
 //
 // If a nil error and nil tuple are returned, that means that all rows have been
 // read successfully. A non-nil error indicates an error while executing the bytecode.
-func (e *Execution) Next() (*[]tree.Column, error) {
+func (e *Execution) Next() ([]driver.Value, error) {
 	select {
 	case t := <-e.results:
-		return &t, nil
+		return t, nil
 	case err := <-e.done:
 		// If we call Next() when both e.results and e.done are ready, then we'll receive
 		// a result at random. We want to guarantee that if both are ready, we always prioritize
@@ -240,14 +970,18 @@ func (e *Execution) Next() (*[]tree.Column, error) {
 			// will receive it:
 			e.done <- err
 
-			return &t, nil
+			return t, nil
 		default:
 			return nil, err
 		}
 	}
 }
 
+// Close stops the program if it is still running, and waits for it to release
+// its cursors before the execution's channels are closed.
 func (e *Execution) Close() error {
+	close(e.stop)
+	<-e.stopped
 	close(e.results)
 	close(e.done)
 
@@ -0,0 +1,584 @@
+package vm
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariable(t *testing.T) {
+	require := require.New(t)
+
+	// Equivalent to: SELECT ?, ?2, ?1, ?3
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 1, 3, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 3, 4, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 1, 4, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+		NumPlaceholders: 3,
+	}
+
+	e := NewVM(nil).Execute(program, []driver.Value{int64(1), "two", nil})
+	defer e.Close()
+
+	row, err := e.Next()
+	require.NoError(err)
+	require.Equal([]driver.Value{int64(1), "two", int64(1), nil}, row)
+
+	row, err = e.Next()
+	require.NoError(err)
+	require.Nil(row)
+}
+
+func TestClose(t *testing.T) {
+	require := require.New(t)
+
+	// Equivalent to: SELECT 1 FROM an endless table
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeGoto, 0, 1, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	row, err := e.Next()
+	require.NoError(err)
+	require.Equal([]driver.Value{int64(1)}, row)
+
+	// The program is still running, and is stopped by Close.
+	require.NoError(e.Close())
+	select {
+	case <-e.stopped:
+	default:
+		require.Fail("the program is still running")
+	}
+}
+
+func TestCloseWhileSorting(t *testing.T) {
+	require := require.New(t)
+
+	// The sorter's temporary files are created in a directory of the test's own.
+	dir, err := ioutil.TempDir("", "vm-test-")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	tmpdir := os.Getenv("TMPDIR")
+	require.NoError(os.Setenv("TMPDIR", dir))
+	defer os.Setenv("TMPDIR", tmpdir)
+
+	// A program that spills the entries of a sorter to temporary files, and
+	// then runs forever without returning a row.
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstructionKeyInfo(OpcodeSorterOpen, 0, 1, 0, &KeyInfo{Collations: []string{""}, Desc: []bool{false}}, 0),
+			NewInstruction(OpcodeInteger, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeSorterInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeGoto, 0, 6, 0, 0, 0),
+		},
+	}
+
+	m := NewVM(nil)
+	m.SortMemory = 100
+	e := m.Execute(program, nil)
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(err)
+		if len(files) > 0 {
+			break
+		}
+		require.True(time.Now().Before(deadline), "the sorter did not spill")
+		time.Sleep(time.Millisecond)
+	}
+
+	// The program is stopped by Close, although it never reaches a ResultRow,
+	// and its temporary files are removed.
+	closed := make(chan error)
+	go func() { closed <- e.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(err)
+	case <-time.After(10 * time.Second):
+		require.Fail("the program is still running")
+	}
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(err)
+	require.Empty(files)
+}
+
+func TestExpressions(tt *testing.T) {
+	// Each operation is applied to the registers r1 and r2, which are loaded with
+	// the parameters a and b, and stores its result in r3. For example, OpcodeAdd
+	// is equivalent to: SELECT ?1 + ?2
+	binary := func(op Opcode) []Instruction {
+		return []Instruction{NewInstruction(op, 1, 2, 3, 0, 0)}
+	}
+	// reversed applies an operation that computes r[P2] op r[P1].
+	reversed := func(op Opcode) []Instruction {
+		return []Instruction{NewInstruction(op, 2, 1, 3, 0, 0)}
+	}
+	// comparison stores the result of a comparison like SQLite does: 1 if it
+	// jumps, else 0, or NULL if either operand is NULL.
+	comparison := func(op Opcode, coll string, p5 int) []Instruction {
+		return []Instruction{
+			NewInstruction(OpcodeInteger, 1, 3, 0, 0, 0),
+			NewInstructionStr(op, 2, 7, 1, coll, p5),
+			NewInstruction(OpcodeZeroOrNull, 1, 3, 2, 0, 0),
+		}
+	}
+	// jump stores 1 if the jump is taken, else 0.
+	jump := func(op Opcode, p3 int) []Instruction {
+		return []Instruction{
+			NewInstruction(OpcodeInteger, 1, 3, 0, 0, 0),
+			NewInstruction(op, 1, 7, p3, 0, 0),
+			NewInstruction(OpcodeInteger, 0, 3, 0, 0, 0),
+		}
+	}
+
+	for _, test := range []struct {
+		name         string
+		instructions []Instruction
+		a, b         driver.Value
+		want         driver.Value
+	}{
+		{name: "add", instructions: binary(OpcodeAdd), a: int64(1), b: int64(2), want: int64(3)},
+		{name: "add reals", instructions: binary(OpcodeAdd), a: 1.5, b: int64(2), want: 3.5},
+		{name: "add null", instructions: binary(OpcodeAdd), a: nil, b: int64(2), want: nil},
+		{name: "add text", instructions: binary(OpcodeAdd), a: "12abc", b: int64(1), want: int64(13)},
+		{name: "add real text", instructions: binary(OpcodeAdd), a: " 1e3 ", b: int64(0), want: 1000.0},
+		{name: "add non-numeric text", instructions: binary(OpcodeAdd), a: "abc", b: int64(0), want: int64(0)},
+		{name: "add blob", instructions: binary(OpcodeAdd), a: []byte("12"), b: int64(1), want: int64(13)},
+		{name: "add overflow", instructions: binary(OpcodeAdd), a: int64(math.MaxInt64), b: int64(1), want: 9223372036854775808.0},
+		{name: "subtract", instructions: reversed(OpcodeSubtract), a: int64(1), b: int64(3), want: int64(-2)},
+		{name: "subtract overflow", instructions: reversed(OpcodeSubtract), a: int64(math.MinInt64), b: int64(1), want: -9223372036854775808.0},
+		{name: "multiply", instructions: binary(OpcodeMultiply), a: int64(-4), b: int64(3), want: int64(-12)},
+		{name: "multiply overflow", instructions: binary(OpcodeMultiply), a: int64(-1), b: int64(math.MinInt64), want: 9223372036854775808.0},
+		{name: "divide", instructions: reversed(OpcodeDivide), a: int64(5), b: int64(2), want: int64(2)},
+		{name: "divide reals", instructions: reversed(OpcodeDivide), a: int64(5), b: 2.0, want: 2.5},
+		{name: "divide by zero", instructions: reversed(OpcodeDivide), a: int64(5), b: int64(0), want: nil},
+		{name: "divide real by zero", instructions: reversed(OpcodeDivide), a: 1.0, b: 0.0, want: nil},
+		{name: "divide overflow", instructions: reversed(OpcodeDivide), a: int64(math.MinInt64), b: int64(-1), want: 9223372036854775808.0},
+		{name: "remainder", instructions: reversed(OpcodeRemainder), a: int64(-7), b: int64(3), want: int64(-1)},
+		{name: "remainder by zero", instructions: reversed(OpcodeRemainder), a: int64(5), b: int64(0), want: nil},
+		{name: "remainder by -1", instructions: reversed(OpcodeRemainder), a: int64(math.MinInt64), b: int64(-1), want: int64(0)},
+		{name: "remainder of reals", instructions: reversed(OpcodeRemainder), a: 5.5, b: int64(2), want: 1.0},
+		{name: "concat", instructions: reversed(OpcodeConcat), a: "a", b: int64(1), want: "a1"},
+		{name: "concat real and blob", instructions: reversed(OpcodeConcat), a: 1.5, b: []byte("A"), want: "1.5A"},
+		{name: "concat null", instructions: reversed(OpcodeConcat), a: "a", b: nil, want: nil},
+		{name: "bit and", instructions: reversed(OpcodeBitAnd), a: "1e3", b: int64(0xffff), want: int64(1)},
+		{name: "bit or", instructions: reversed(OpcodeBitOr), a: 1e30, b: int64(0), want: int64(math.MaxInt64)},
+		{name: "shift left", instructions: reversed(OpcodeShiftLeft), a: int64(1), b: int64(3), want: int64(8)},
+		{name: "shift left too far", instructions: reversed(OpcodeShiftLeft), a: int64(1), b: int64(64), want: int64(0)},
+		{name: "shift left by negative", instructions: reversed(OpcodeShiftLeft), a: int64(-8), b: int64(-1), want: int64(-4)},
+		{name: "shift right too far", instructions: reversed(OpcodeShiftRight), a: int64(-1), b: int64(70), want: int64(-1)},
+		{name: "eq", instructions: comparison(OpcodeEq, "", 0), a: int64(1), b: 1.0, want: int64(1)},
+		{name: "eq without affinity", instructions: comparison(OpcodeEq, "", 0), a: int64(1), b: "1", want: int64(0)},
+		{name: "eq numeric affinity", instructions: comparison(OpcodeEq, "", AffinityNumeric), a: int64(1), b: " 1.0 ", want: int64(1)},
+		{name: "eq text affinity", instructions: comparison(OpcodeEq, "", AffinityText), a: 1.5, b: "1.5", want: int64(1)},
+		{name: "eq null", instructions: comparison(OpcodeEq, "", 0), a: nil, b: nil, want: nil},
+		{name: "eq null with nulleq", instructions: comparison(OpcodeEq, "", NullEq), a: nil, b: nil, want: int64(1)},
+		{name: "ne null with nulleq", instructions: comparison(OpcodeNe, "", NullEq), a: int64(1), b: nil, want: int64(1)},
+		{name: "eq nocase", instructions: comparison(OpcodeEq, "NOCASE", 0), a: "A", b: "a", want: int64(1)},
+		{name: "eq rtrim", instructions: comparison(OpcodeEq, "rtrim", 0), a: "a ", b: "a", want: int64(1)},
+		{name: "lt", instructions: comparison(OpcodeLt, "", 0), a: int64(1), b: int64(2), want: int64(1)},
+		{name: "lt large int and real", instructions: comparison(OpcodeLt, "", 0), a: int64(math.MaxInt64 - 1), b: 9223372036854775807.0, want: int64(1)},
+		{name: "le", instructions: comparison(OpcodeLe, "", 0), a: 2.0, b: int64(2), want: int64(1)},
+		{name: "gt", instructions: comparison(OpcodeGt, "", 0), a: "a", b: int64(2), want: int64(1)},
+		{name: "ge", instructions: comparison(OpcodeGe, "", 0), a: "a", b: []byte{0}, want: int64(0)},
+		{name: "and", instructions: binary(OpcodeAnd), a: int64(1), b: "0.5", want: int64(1)},
+		{name: "and false null", instructions: binary(OpcodeAnd), a: nil, b: int64(0), want: int64(0)},
+		{name: "and true null", instructions: binary(OpcodeAnd), a: int64(1), b: nil, want: nil},
+		{name: "or true null", instructions: binary(OpcodeOr), a: nil, b: "1x", want: int64(1)},
+		{name: "or false null", instructions: binary(OpcodeOr), a: 0.0, b: nil, want: nil},
+		{name: "not", instructions: []Instruction{NewInstruction(OpcodeNot, 1, 3, 0, 0, 0)}, a: "x", want: int64(1)},
+		{name: "not null", instructions: []Instruction{NewInstruction(OpcodeNot, 1, 3, 0, 0, 0)}, a: nil, want: nil},
+		{name: "if", instructions: jump(OpcodeIf, 0), a: 0.1, want: int64(1)},
+		{name: "if null", instructions: jump(OpcodeIf, 0), a: nil, want: int64(0)},
+		{name: "if null with p3", instructions: jump(OpcodeIf, 1), a: nil, want: int64(1)},
+		{name: "ifnot", instructions: jump(OpcodeIfNot, 0), a: int64(0), want: int64(1)},
+		{name: "ifnot null", instructions: jump(OpcodeIfNot, 0), a: nil, want: int64(0)},
+		{name: "notnull", instructions: jump(OpcodeNotNull, 0), a: int64(0), want: int64(1)},
+		{name: "notnull null", instructions: jump(OpcodeNotNull, 0), a: nil, want: int64(0)},
+		{name: "bitnot", instructions: []Instruction{NewInstruction(OpcodeBitNot, 1, 3, 0, 0, 0)}, a: "5", want: int64(-6)},
+		{name: "bitnot null", instructions: []Instruction{NewInstruction(OpcodeBitNot, 1, 3, 0, 0, 0)}, a: nil, want: nil},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			instructions := []Instruction{
+				NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+				NewInstruction(OpcodeVariable, 1, 1, 0, 0, 0),
+				NewInstruction(OpcodeVariable, 2, 2, 0, 0, 0),
+			}
+			instructions = append(instructions, test.instructions...)
+			// Jumps target the ResultRow, at address 7.
+			for len(instructions) < 7 {
+				instructions = append(instructions, NewInstruction(OpcodeGoto, 0, 7, 0, 0, 0))
+			}
+			instructions = append(instructions,
+				NewInstruction(OpcodeResultRow, 3, 1, 0, 0, 0),
+				NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+			)
+
+			e := NewVM(nil).Execute(Program{Instructions: instructions, NumPlaceholders: 2}, []driver.Value{test.a, test.b})
+			defer e.Close()
+
+			row, err := e.Next()
+			require.NoError(err)
+			require.Equal([]driver.Value{test.want}, row)
+		})
+	}
+}
+
+func TestLoaders(t *testing.T) {
+	require := require.New(t)
+
+	// Equivalent to: SELECT 7, 1.5, NULL, NULL, x'0102', 7, 1.5
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 7, 1, 0, 0, 0),
+			NewInstructionReal(OpcodeReal, 0, 2, 0, 1.5, 0),
+			NewInstruction(OpcodeNull, 0, 3, 4, 0, 0),
+			NewInstructionStr(OpcodeBlob, 2, 5, 0, "\x01\x02", 0),
+			NewInstruction(OpcodeCopy, 1, 6, 1, 0, 0),
+			NewInstruction(OpcodeSCopy, 5, 8, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 1, 8, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	row, err := e.Next()
+	require.NoError(err)
+	require.Equal([]driver.Value{int64(7), 1.5, nil, nil, []byte{1, 2}, int64(7), 1.5, []byte{1, 2}}, row)
+}
+
+func TestCoroutine(t *testing.T) {
+	require := require.New(t)
+
+	// A coroutine yields the rows 1 and 2 in r2. The loop that reads them
+	// outputs whether the Once fell through, which it only does the first time.
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeInitCoroutine, 1, 7, 2, 0, 0),
+			NewInstruction(OpcodeInteger, 1, 2, 0, 0, 0),
+			NewInstruction(OpcodeYield, 1, 0, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeYield, 1, 0, 0, 0, 0),
+			NewInstruction(OpcodeEndCoroutine, 1, 0, 0, 0, 0),
+			NewInstruction(OpcodeYield, 1, 13, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 0, 3, 0, 0, 0),
+			NewInstruction(OpcodeOnce, 0, 11, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 1, 3, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeGoto, 0, 7, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	for _, want := range [][]driver.Value{{int64(1), int64(1)}, {int64(2), int64(0)}, nil} {
+		row, err := e.Next()
+		require.NoError(err)
+		require.Equal(want, row)
+	}
+}
+
+func TestEphemeralIndex(t *testing.T) {
+	require := require.New(t)
+
+	// The values 3, 1, 3 and 2 are added to an ephemeral index, which is read
+	// in order, without the duplicate. Once it is emptied, Rewind jumps over the
+	// loop.
+	keyInfo := &KeyInfo{Collations: []string{""}, Desc: []bool{false}}
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstructionKeyInfo(OpcodeOpenEphemeral, 0, 1, 0, keyInfo, 0),
+			NewInstruction(OpcodeInteger, 3, 1, 0, 0, 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeInteger, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeInteger, 3, 1, 0, 0, 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeInteger, 2, 1, 0, 0, 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeRewind, 0, 14, 0, 0, 0),
+			NewInstruction(OpcodeColumn, 0, 0, 2, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 1, 0, 0, 0),
+			NewInstruction(OpcodeNext, 0, 11, 0, 0, 0),
+			NewInstructionKeyInfo(OpcodeOpenEphemeral, 0, 1, 0, keyInfo, 0),
+			NewInstruction(OpcodeRewind, 0, 17, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 1, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	for _, want := range [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}, nil} {
+		row, err := e.Next()
+		require.NoError(err)
+		require.Equal(want, row)
+	}
+}
+
+func TestIdxDelete(t *testing.T) {
+	require := require.New(t)
+
+	// With NOCASE, "A" replaces the "a" that is equal to it, and deleting "b"
+	// removes "B", which leaves only "A".
+	keyInfo := &KeyInfo{Collations: []string{"NOCASE"}, Desc: []bool{false}}
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstructionKeyInfo(OpcodeOpenEphemeral, 0, 1, 0, keyInfo, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "a", 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "B", 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "A", 0),
+			NewInstruction(OpcodeIdxInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "b", 0),
+			NewInstruction(OpcodeIdxDelete, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeRewind, 0, 14, 0, 0, 0),
+			NewInstruction(OpcodeColumn, 0, 0, 2, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 1, 0, 0, 0),
+			NewInstruction(OpcodeNext, 0, 11, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	for _, want := range [][]driver.Value{{"A"}, nil} {
+		row, err := e.Next()
+		require.NoError(err)
+		require.Equal(want, row)
+	}
+}
+
+func TestEphemeralTable(t *testing.T) {
+	require := require.New(t)
+
+	// The table keeps equal rows in the order that they were inserted. They
+	// are read as a queue, while a duplicate of the cursor still reads all of
+	// them, and numbers them.
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeOpenEphemeral, 0, 1, 0, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "b", 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "a", 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeOpenDup, 1, 0, 0, 0, 0),
+			NewInstruction(OpcodeRewind, 0, 13, 0, 0, 0),
+			NewInstruction(OpcodeColumn, 0, 0, 2, 0, 0),
+			NewInstruction(OpcodeDelete, 0, 0, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 1, 0, 0, 0),
+			NewInstruction(OpcodeGoto, 0, 8, 0, 0, 0),
+			NewInstruction(OpcodeRewind, 1, 18, 0, 0, 0),
+			NewInstruction(OpcodeColumn, 1, 0, 2, 0, 0),
+			NewInstruction(OpcodeSequence, 1, 3, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeNext, 1, 14, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	for _, want := range [][]driver.Value{
+		{"b"}, {"a"}, {"a"},
+		{"b", int64(0)}, {"a", int64(1)}, {"a", int64(2)},
+		nil,
+	} {
+		row, err := e.Next()
+		require.NoError(err)
+		require.Equal(want, row)
+	}
+}
+
+func TestEphemeralTableRowids(t *testing.T) {
+	require := require.New(t)
+
+	// The rows "a", "b" and "c" are numbered from 1, so seeking 3 reads "c",
+	// while there is no row 4, which jumps to the Halt that fails the program.
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeOpenEphemeral, 0, 1, 0, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "a", 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "b", 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstructionStr(OpcodeString8, 0, 1, 0, "c", 0),
+			NewInstruction(OpcodeInsert, 0, 1, 1, 0, 0),
+			NewInstruction(OpcodeRewind, 0, 16, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 3, 2, 0, 0, 0),
+			NewInstruction(OpcodeSeekRowid, 0, 17, 2, 0, 0),
+			NewInstruction(OpcodeColumn, 0, 0, 3, 0, 0),
+			NewInstruction(OpcodeRowid, 0, 4, 0, 0, 0),
+			NewInstruction(OpcodeResultRow, 3, 2, 0, 0, 0),
+			NewInstruction(OpcodeInteger, 4, 2, 0, 0, 0),
+			NewInstruction(OpcodeSeekRowid, 0, 17, 2, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+			NewInstructionStr(OpcodeHalt, 1, 0, 0, "no row 4", 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	row, err := e.Next()
+	require.NoError(err)
+	require.Equal([]driver.Value{"c", int64(3)}, row)
+	_, err = e.Next()
+	require.EqualError(err, "no row 4")
+}
+
+func TestUnknownCollation(t *testing.T) {
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstructionStr(OpcodeEq, 1, 2, 1, "nope", 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+	}
+
+	e := NewVM(nil).Execute(program, nil)
+	defer e.Close()
+
+	_, err := e.Next()
+	require.EqualError(t, err, "no such collation sequence: nope")
+}
+
+func TestFunction(t *testing.T) {
+	// Equivalent to: SELECT substr(?1, ?2), abs(?2)
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 2, 2, 0, 0, 0),
+			NewInstructionStr(OpcodeFunction, 0, 1, 3, "SUBSTR", 2),
+			NewInstructionStr(OpcodeFunction, 0, 2, 4, "abs", 1),
+			NewInstruction(OpcodeResultRow, 3, 2, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+		NumPlaceholders: 2,
+	}
+
+	for _, test := range []struct {
+		a, b driver.Value
+		want []driver.Value
+		err  string
+	}{
+		{a: "héllo", b: int64(-3), want: []driver.Value{"llo", int64(3)}},
+		{a: []byte("héllo"), b: "2", want: []driver.Value{[]byte("\xc3\xa9llo"), 2.0}},
+		{a: nil, b: nil, want: []driver.Value{nil, nil}},
+		{a: "x", b: int64(math.MinInt64), err: "integer overflow"},
+	} {
+		e := NewVM(nil).Execute(program, []driver.Value{test.a, test.b})
+
+		row, err := e.Next()
+		if test.err != "" {
+			require.EqualError(t, err, test.err)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, test.want, row)
+		}
+		e.Close()
+	}
+}
+
+func TestUnknownFunction(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		numArgs int
+		err     string
+	}{
+		{name: "nope", numArgs: 1, err: "no such function: nope"},
+		{name: "substr", numArgs: 1, err: "wrong number of arguments to function substr()"},
+	} {
+		program := Program{
+			Instructions: []Instruction{
+				NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+				NewInstructionStr(OpcodeFunction, 0, 1, 2, test.name, test.numArgs),
+				NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+			},
+		}
+
+		e := NewVM(nil).Execute(program, nil)
+		_, err := e.Next()
+		require.EqualError(t, err, test.err)
+		e.Close()
+	}
+}
+
+func TestOffsetLimit(t *testing.T) {
+	// Computes the LIMIT ?1 and OFFSET ?2 of a query, and the number of rows
+	// that it computes.
+	program := Program{
+		Instructions: []Instruction{
+			NewInstruction(OpcodeInit, 0, 1, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 1, 1, 0, 0, 0),
+			NewInstruction(OpcodeMustBeInt, 1, 0, 0, 0, 0),
+			NewInstruction(OpcodeVariable, 2, 2, 0, 0, 0),
+			NewInstruction(OpcodeMustBeInt, 2, 0, 0, 0, 0),
+			NewInstruction(OpcodeOffsetLimit, 1, 3, 2, 0, 0),
+			NewInstruction(OpcodeResultRow, 1, 3, 0, 0, 0),
+			NewInstruction(OpcodeHalt, 0, 0, 0, 0, 0),
+		},
+		NumPlaceholders: 2,
+	}
+
+	for _, test := range []struct {
+		limit, offset driver.Value
+		want          []driver.Value
+		err           string
+	}{
+		{limit: int64(10), offset: int64(5), want: []driver.Value{int64(10), int64(5), int64(15)}},
+		{limit: "10", offset: 2.0, want: []driver.Value{int64(10), int64(2), int64(12)}},
+		{limit: int64(10), offset: int64(-5), want: []driver.Value{int64(10), int64(-5), int64(10)}},
+		{limit: int64(-1), offset: int64(5), want: []driver.Value{int64(-1), int64(5), int64(-1)}},
+		{limit: int64(math.MaxInt64), offset: int64(1), want: []driver.Value{int64(math.MaxInt64), int64(1), int64(-1)}},
+		{limit: 1.5, offset: int64(0), err: "datatype mismatch"},
+		{limit: int64(1), offset: nil, err: "datatype mismatch"},
+	} {
+		e := NewVM(nil).Execute(program, []driver.Value{test.limit, test.offset})
+
+		row, err := e.Next()
+		if test.err != "" {
+			require.EqualError(t, err, test.err)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, test.want, row)
+		}
+		e.Close()
+	}
+}
@@ -0,0 +1,277 @@
+package vm
+
+import (
+	"errors"
+)
+
+// The built-in window functions are aggregate functions that can only be called
+// as window functions. Like in SQLite, most of them are computed from a frame
+// that is fixed for each function, regardless of the one of their window, and
+// count the calls to final to know which row of the partition is the current
+// one.
+//
+// See: https://www.sqlite.org/windowfunctions.html#builtins
+func init() {
+	registerWindow("row_number", 0, 0, func() aggregateState { return &rowNumberState{} })
+	registerWindow("rank", 0, 0, func() aggregateState { return &rankState{} })
+	registerWindow("dense_rank", 0, 0, func() aggregateState { return &rankState{dense: true} })
+	registerWindow("percent_rank", 0, 0, func() aggregateState { return &distState{} })
+	registerWindow("cume_dist", 0, 0, func() aggregateState { return &distState{cume: true} })
+	registerWindow("ntile", 1, 1, func() aggregateState { return &ntileState{} })
+	registerWindow("lag", 1, 3, func() aggregateState { return &lagState{sign: -1} })
+	registerWindow("lead", 1, 3, func() aggregateState { return &lagState{sign: 1} })
+	registerWindow("first_value", 1, 1, func() aggregateState { return &nthValueState{} })
+	registerWindow("last_value", 1, 1, func() aggregateState { return &lastValueState{} })
+	registerWindow("nth_value", 2, 2, func() aggregateState { return &nthValueState{} })
+}
+
+func registerWindow(name string, minArgs, maxArgs int, new func() aggregateState) {
+	registerAggregate(name, minArgs, maxArgs, new).Window = true
+}
+
+var (
+	errNtileArgument    = errors.New("argument of ntile must be a positive integer")
+	errNthValueArgument = errors.New("second argument to nth_value must be a positive integer")
+)
+
+// rowNumberState numbers the rows of the partition, whose frame ends at the current
+// row.
+type rowNumberState struct {
+	n int
+}
+
+func (s *rowNumberState) step(args []Register, coll collation) (bool, error) {
+	s.n++
+	return false, nil
+}
+
+func (s *rowNumberState) final() (Register, error) {
+	return Register{typ: RegisterTypeInt, Int: s.n}, nil
+}
+
+// rankState ranks the rows of the partition, whose frame ends with the last peer of
+// the current row: a row starts a new rank if the frame grew since the last one.
+// With gaps, the rank is the number of the row, and without, for dense_rank, it is
+// the number of ranks so far.
+type rankState struct {
+	dense bool
+	// n is the number of rows that were added, and last that number at the
+	// last call to final.
+	n, last int
+	// row is the number of the current row.
+	row  int
+	rank int
+}
+
+func (s *rankState) step(args []Register, coll collation) (bool, error) {
+	s.n++
+	return false, nil
+}
+
+func (s *rankState) final() (Register, error) {
+	s.row++
+	if s.n != s.last {
+		s.last = s.n
+		if s.dense {
+			s.rank++
+		} else {
+			s.rank = s.row
+		}
+	}
+
+	return Register{typ: RegisterTypeInt, Int: s.rank}, nil
+}
+
+// distState computes percent_rank, whose frame starts with the current row's first
+// peer, or cume_dist, if cume is set, whose frame starts after its last peer. The
+// rows before the frame are those that rank before, or with, the current one.
+type distState struct {
+	cume bool
+	// n is the number of rows in the partition, which are all added, and
+	// before the number of rows that were removed.
+	n, before int
+}
+
+func (s *distState) step(args []Register, coll collation) (bool, error) {
+	s.n++
+	return false, nil
+}
+
+func (s *distState) inverse(args []Register) error {
+	s.before++
+	return nil
+}
+
+func (s *distState) final() (Register, error) {
+	var r float64
+	switch {
+	case s.cume:
+		r = float64(s.before) / float64(s.n)
+	case s.n > 1:
+		r = float64(s.before) / float64(s.n-1)
+	}
+
+	return Register{typ: RegisterTypeFloat, Float: r}, nil
+}
+
+// ntileState divides the rows of the partition into the number of buckets of its
+// argument, whose frame starts at the current row, so that the rows before it are
+// those that were removed. Like in SQLite, the number of buckets is the argument of
+// the first row, and the first buckets get the rows that are left over.
+type ntileState struct {
+	buckets   int
+	n, before int
+}
+
+func (s *ntileState) step(args []Register, coll collation) (bool, error) {
+	if s.n == 0 {
+		s.buckets = intValue(args[0])
+		if s.buckets <= 0 {
+			return false, errNtileArgument
+		}
+	}
+	s.n++
+
+	return false, nil
+}
+
+func (s *ntileState) inverse(args []Register) error {
+	s.before++
+	return nil
+}
+
+func (s *ntileState) final() (Register, error) {
+	size := s.n / s.buckets
+	if size == 0 {
+		return Register{typ: RegisterTypeInt, Int: s.before + 1}, nil
+	}
+
+	// The first large buckets have one more row than the others.
+	large := s.n - s.buckets*size
+	small := large * (size + 1)
+	if s.before < small {
+		return Register{typ: RegisterTypeInt, Int: 1 + s.before/(size+1)}, nil
+	}
+
+	return Register{typ: RegisterTypeInt, Int: 1 + large + (s.before-small)/size}, nil
+}
+
+// lagState returns the first argument of the row that is the number of rows of the
+// second argument, or 1, before the current one, for lag, if sign is -1, or after
+// it, for lead. Its frame is the whole partition. If there is no such row, the
+// result is the third argument, or NULL.
+type lagState struct {
+	sign int
+	// rows holds the arguments of each row, and row is the number of the
+	// current one.
+	rows [][]Register
+	row  int
+}
+
+func (s *lagState) step(args []Register, coll collation) (bool, error) {
+	row := make([]Register, len(args))
+	for i, arg := range args {
+		if arg.typ == RegisterTypeBlob {
+			arg.Blob = append([]byte{}, arg.Blob...)
+		}
+		row[i] = arg
+	}
+	s.rows = append(s.rows, row)
+
+	return false, nil
+}
+
+func (s *lagState) final() (Register, error) {
+	args := s.rows[s.row]
+	s.row++
+
+	// Like in SQLite, where the offset is added to the rowid of the current
+	// row, it must be an integer once it is a number.
+	offset, ok := 1, true
+	if len(args) > 1 {
+		offset, ok = rowidValue(numericValue(args[1]))
+		ok = ok && args[1].typ != RegisterTypeNull
+	}
+	if ok && offset >= -len(s.rows) && offset <= len(s.rows) {
+		if i := s.row - 1 + s.sign*offset; i >= 0 && i < len(s.rows) {
+			return s.rows[i][0], nil
+		}
+	}
+	if len(args) > 2 {
+		return args[2], nil
+	}
+
+	return Register{typ: RegisterTypeNull}, nil
+}
+
+// nthValueState returns the first argument of the row of the frame whose number is
+// the second argument, or the first row, for first_value. The number is that of the
+// last row that was added, which must be a positive integer.
+type nthValueState struct {
+	// values holds the first argument of the rows of the frame from head on.
+	values []Register
+	head   int
+	n      int
+}
+
+func (s *nthValueState) step(args []Register, coll collation) (bool, error) {
+	s.n = 1
+	if len(args) > 1 {
+		n, ok := rowidValue(args[1])
+		if !ok || n <= 0 {
+			return false, errNthValueArgument
+		}
+		s.n = n
+	}
+
+	arg := args[0]
+	if arg.typ == RegisterTypeBlob {
+		arg.Blob = append([]byte{}, arg.Blob...)
+	}
+	s.values = append(s.values, arg)
+
+	return false, nil
+}
+
+func (s *nthValueState) inverse(args []Register) error {
+	s.head++
+	return nil
+}
+
+func (s *nthValueState) final() (Register, error) {
+	if i := s.head + s.n - 1; i < len(s.values) {
+		return s.values[i], nil
+	}
+
+	return Register{typ: RegisterTypeNull}, nil
+}
+
+// lastValueState returns the first argument of the last row of the frame.
+type lastValueState struct {
+	last Register
+	// n is the number of rows in the frame.
+	n int
+}
+
+func (s *lastValueState) step(args []Register, coll collation) (bool, error) {
+	s.last = args[0]
+	if s.last.typ == RegisterTypeBlob {
+		s.last.Blob = append([]byte{}, s.last.Blob...)
+	}
+	s.n++
+
+	return false, nil
+}
+
+func (s *lastValueState) inverse(args []Register) error {
+	s.n--
+	return nil
+}
+
+func (s *lastValueState) final() (Register, error) {
+	if s.n == 0 {
+		return Register{typ: RegisterTypeNull}, nil
+	}
+
+	return s.last, nil
+}
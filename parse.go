@@ -0,0 +1,19 @@
+package sqlite3native
+
+import (
+	"github.com/colinking/go-sqlite3-native/ast"
+	"github.com/colinking/go-sqlite3-native/internal/parser"
+)
+
+// SyntaxError is returned by Parse when a query is not valid SQL. It reports the
+// position of the offending token and the tokens that were expected instead.
+// Queries that fail to prepare because of one return an Error that wraps it.
+type SyntaxError = parser.SyntaxError
+
+// Parse parses the statements in query into an AST, without preparing them
+// against a database. Use ast.Format to render a statement as canonical SQL.
+//
+// A *SyntaxError is returned if query is not valid SQL.
+func Parse(query string) ([]ast.Statement, error) {
+	return parser.Parse(query)
+}
@@ -0,0 +1,36 @@
+package sqlite3native
+
+import (
+	"database/sql/driver"
+
+	"github.com/colinking/go-sqlite3-native/internal/vm"
+)
+
+// Result is the result of executing a query with Exec.
+//
+// Writes are not supported yet, so no statement can insert or modify a row.
+type Result struct{}
+
+var _ driver.Result = Result{}
+
+// LastInsertId returns the rowid of the most recently inserted row.
+func (r Result) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+// RowsAffected returns the number of rows inserted, updated or deleted.
+func (r Result) RowsAffected() (int64, error) {
+	return 0, nil
+}
+
+// drain runs execution to completion, discarding the rows it produces.
+func drain(execution *vm.Execution) error {
+	defer execution.Close()
+
+	for {
+		row, err := execution.Next()
+		if err != nil || row == nil {
+			return executionError(err)
+		}
+	}
+}
@@ -1,41 +1,198 @@
 package sqlite3native
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/colinking/go-sqlite3-native/internal/vm"
 )
 
 type Rows struct {
+	vm *vm.VM
+	// programs holds the compiled program for each statement in the query, and
+	// params the values bound to each program's parameters.
+	programs []vm.Program
+	params   [][]driver.Value
+	// index is the index of the statement that is currently executing.
+	index int
+
+	// program and execution are the statement whose result set is being read,
+	// execution is nil once every result set has been read.
 	program   vm.Program
 	execution *vm.Execution
+
+	// loc is the location that times are converted into, see the _loc DSN parameter.
+	loc *time.Location
 }
 
 var _ driver.Rows = &Rows{}
+var _ driver.RowsColumnTypeDatabaseTypeName = &Rows{}
+var _ driver.RowsColumnTypeScanType = &Rows{}
+var _ driver.RowsColumnTypeNullable = &Rows{}
+var _ driver.RowsColumnTypeLength = &Rows{}
+var _ driver.RowsNextResultSet = &Rows{}
 
 func (r *Rows) Columns() []string {
 	return r.program.Columns
 }
 
 func (r *Rows) Next(dest []driver.Value) error {
-	t, err := r.execution.Next()
-	if err != nil {
-		return err
+	if r.execution == nil {
+		return io.EOF
 	}
 
-	if t == nil {
+	row, err := r.execution.Next()
+	if err != nil || row == nil {
+		// The execution has finished, so release it.
+		r.execution.Close()
+		r.execution = nil
+
+		if err != nil {
+			return executionError(err)
+		}
 		return io.EOF
 	}
-	columns := *t
 
-	for i := range columns {
-		dest[i] = columns[i].Value()
+	for i := range row {
+		dest[i] = convertValue(row[i], r.metadata(i).DeclType, r.loc)
 	}
 
 	return nil
 }
 
 func (r *Rows) Close() error {
-	return r.execution.Close()
+	if r.execution == nil {
+		return nil
+	}
+	execution := r.execution
+	r.execution = nil
+
+	return execution.Close()
+}
+
+// HasNextResultSet reports whether a later statement in the query produces a
+// result set.
+func (r *Rows) HasNextResultSet() bool {
+	for _, program := range r.programs[r.index+1:] {
+		if len(program.Columns) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextResultSet advances to the result set of the next statement in the query
+// that produces one, such as a SELECT. Any rows left in the current result set are
+// discarded, and statements without a result set are run to completion along the
+// way. io.EOF is returned once there are no more result sets.
+func (r *Rows) NextResultSet() error {
+	if r.execution != nil {
+		execution := r.execution
+		r.execution = nil
+		if err := drain(execution); err != nil {
+			return err
+		}
+	}
+
+	for r.index+1 < len(r.programs) {
+		r.index++
+		program := r.programs[r.index]
+		execution := r.vm.Execute(program, r.params[r.index])
+
+		if len(program.Columns) == 0 {
+			if err := drain(execution); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r.program = program
+		r.execution = execution
+		return nil
+	}
+
+	return io.EOF
+}
+
+// metadata returns the metadata on the result column at index, or the zero value
+// if no metadata is available.
+func (r *Rows) metadata(index int) vm.ColumnMetadata {
+	if index < len(r.program.ColumnMetadata) {
+		return r.program.ColumnMetadata[index]
+	}
+
+	return vm.ColumnMetadata{}
+}
+
+// ColumnTypeDatabaseTypeName returns the declared type of a column, as it was
+// written in the CREATE TABLE statement but in upper case, f.e. "VARCHAR(255)".
+// Like mattn/go-sqlite3, an empty string is returned for columns without a
+// declared type, such as expressions.
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.metadata(index).DeclType)
+}
+
+// ColumnTypeScanType returns the Go type suitable for scanning a column into,
+// based on its declared type. The same types are returned as mattn/go-sqlite3.
+func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
+	switch classifyDeclType(r.metadata(index).DeclType) {
+	case declTypeInteger:
+		return reflect.TypeOf(sql.NullInt64{})
+	case declTypeText:
+		return reflect.TypeOf(sql.NullString{})
+	case declTypeBlob:
+		return reflect.TypeOf(sql.RawBytes{})
+	case declTypeReal, declTypeNumeric:
+		return reflect.TypeOf(sql.NullFloat64{})
+	case declTypeBool:
+		return reflect.TypeOf(sql.NullBool{})
+	case declTypeTime:
+		return reflect.TypeOf(sql.NullTime{})
+	default:
+		return reflect.TypeOf(new(interface{}))
+	}
+}
+
+// ColumnTypeNullable reports whether a column may contain NULL values, based on
+// its table column's NOT NULL constraint. The nullability of expressions is unknown.
+func (r *Rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	m := r.metadata(index)
+	if m.Table == "" {
+		return true, false
+	}
+
+	return !m.NotNull, true
+}
+
+// ColumnTypeLength returns the length of variable-length (text and blob) columns.
+//
+// SQLite does not enforce length limits, so the length is only informational: it
+// is taken from the declared type if one was given (f.e. 255 for "VARCHAR(255)"),
+// otherwise math.MaxInt64 is returned.
+func (r *Rows) ColumnTypeLength(index int) (length int64, ok bool) {
+	declType := r.metadata(index).DeclType
+	switch classifyDeclType(declType) {
+	case declTypeText, declTypeBlob:
+	default:
+		return 0, false
+	}
+
+	if start := strings.IndexByte(declType, '('); start >= 0 {
+		arg := declType[start+1:]
+		if end := strings.IndexAny(arg, ",)"); end >= 0 {
+			arg = arg[:end]
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64); err == nil {
+			return n, true
+		}
+	}
+
+	return math.MaxInt64, true
 }
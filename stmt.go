@@ -3,13 +3,15 @@ package sqlite3native
 import (
 	"context"
 	"database/sql/driver"
+	"io"
 
 	"github.com/colinking/go-sqlite3-native/internal/vm"
 )
 
 type Stmt struct {
-	conn    *Conn
-	program vm.Program
+	conn *Conn
+	// programs holds the compiled program for each statement in the query, in order.
+	programs []vm.Program
 }
 
 var _ driver.Stmt = &Stmt{}
@@ -29,10 +31,23 @@ func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	return &Rows{
-		program:   s.program,
-		execution: s.conn.vm.Execute(s.program),
-	}, nil
+	params, err := s.bind(args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &Rows{
+		vm:       s.conn.vm,
+		programs: s.programs,
+		params:   params,
+		index:    -1,
+		loc:      s.conn.loc,
+	}
+	if err := rows.NextResultSet(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return rows, nil
 }
 
 func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
@@ -47,12 +62,39 @@ func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
 	return s.ExecContext(context.Background(), namedValues)
 }
 
+// ExecContext runs each statement in the query in order, discarding any rows
+// they produce. Execution stops at the first statement that fails.
 func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	panic("ExecContext not implemented")
+	params, err := s.bind(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, program := range s.programs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := drain(s.conn.vm.Execute(program, params[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	return Result{}, nil
 }
 
+// NumInput returns the number of parameters in the query. If the query contains
+// multiple statements, -1 is returned so that database/sql does not validate the
+// number of arguments, since the parameters of each statement are bound separately.
 func (s *Stmt) NumInput() int {
-	return s.program.NumPlaceholders
+	switch len(s.programs) {
+	case 0:
+		return 0
+	case 1:
+		return s.programs[0].NumPlaceholders
+	default:
+		return -1
+	}
 }
 
 func (s *Stmt) Close() error {
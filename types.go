@@ -0,0 +1,120 @@
+package sqlite3native
+
+import (
+	"database/sql/driver"
+	"strings"
+	"time"
+)
+
+// SQLiteTimestampFormats are the timestamp layouts that are parsed from text
+// values in DATE, DATETIME and TIMESTAMP columns, in the order they are tried.
+//
+// These are the same layouts as mattn/go-sqlite3 uses.
+var SQLiteTimestampFormats = []string{
+	// By default, store timestamps with whatever timezone they come with.
+	// When parsed, they will be returned with the same timezone.
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// declTypeClass classifies a column's declared type in the same way as
+// mattn/go-sqlite3, which uses the class to decide how to convert a column's
+// values into Go types.
+type declTypeClass int
+
+const (
+	declTypeNone declTypeClass = iota
+	declTypeInteger
+	declTypeText
+	declTypeBlob
+	declTypeReal
+	declTypeNumeric
+	declTypeTime
+	declTypeBool
+)
+
+// classifyDeclType returns the class of a declared type, f.e. "VARCHAR(255)".
+//
+// Note that these rules are intentionally not the same as SQLite's type affinity
+// rules (https://www.sqlite.org/datatype3.html#determination_of_column_affinity),
+// since they mirror mattn/go-sqlite3's databaseTypeConvSqlite.
+func classifyDeclType(declType string) declTypeClass {
+	t := strings.ToUpper(declType)
+
+	switch {
+	case strings.Contains(t, "INT"):
+		return declTypeInteger
+	case t == "CLOB" || t == "TEXT" || strings.Contains(t, "CHAR"):
+		return declTypeText
+	case t == "BLOB":
+		return declTypeBlob
+	case t == "REAL" || t == "FLOAT" || strings.Contains(t, "DOUBLE"):
+		return declTypeReal
+	case t == "DATE" || t == "DATETIME" || t == "TIMESTAMP":
+		return declTypeTime
+	case t == "NUMERIC" || strings.Contains(t, "DECIMAL"):
+		return declTypeNumeric
+	case t == "BOOLEAN":
+		return declTypeBool
+	default:
+		return declTypeNone
+	}
+}
+
+// convertValue converts a value read from a column with the given declared type
+// into the Go type that mattn/go-sqlite3 would return for it:
+//
+//   - Integers in DATE, DATETIME and TIMESTAMP columns are Unix timestamps, in
+//     seconds or, if they are too large to be reasonable in seconds, milliseconds.
+//   - Text in DATE, DATETIME and TIMESTAMP columns is parsed using
+//     SQLiteTimestampFormats. Text that cannot be parsed is the zero time.
+//   - Integers in BOOLEAN columns are true if they are positive.
+//
+// Times are converted into loc, if it is non-nil. All other values are
+// returned unchanged.
+func convertValue(v driver.Value, declType string, loc *time.Location) driver.Value {
+	switch classifyDeclType(declType) {
+	case declTypeTime:
+		var t time.Time
+		switch vt := v.(type) {
+		case int64:
+			// Assume a millisecond unix timestamp if it's 13 digits -- too
+			// large to be a reasonable timestamp in seconds.
+			if vt > 1e12 || vt < -1e12 {
+				t = time.Unix(0, vt*int64(time.Millisecond))
+			} else {
+				t = time.Unix(vt, 0)
+			}
+			t = t.UTC()
+		case string:
+			s := strings.TrimSuffix(vt, "Z")
+			for _, format := range SQLiteTimestampFormats {
+				if parsed, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+					t = parsed
+					break
+				}
+			}
+		default:
+			return v
+		}
+
+		if loc != nil {
+			t = t.In(loc)
+		}
+
+		return t
+	case declTypeBool:
+		if i, ok := v.(int64); ok {
+			return i > 0
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,130 @@
+package sqlite3native
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertValue(tt *testing.T) {
+	est, err := time.LoadLocation("America/New_York")
+	require.NoError(tt, err)
+	plus2 := time.FixedZone("", 2*60*60)
+
+	for _, test := range []struct {
+		name     string
+		value    driver.Value
+		declType string
+		loc      *time.Location
+		expected driver.Value
+	}{
+		{
+			name:     "untyped columns are unchanged",
+			value:    int64(1),
+			declType: "",
+			expected: int64(1),
+		},
+		{
+			name:     "boolean true",
+			value:    int64(1),
+			declType: "BOOLEAN",
+			expected: true,
+		},
+		{
+			name:     "boolean false",
+			value:    int64(0),
+			declType: "boolean",
+			expected: false,
+		},
+		{
+			name:     "boolean text is unchanged",
+			value:    "true",
+			declType: "BOOLEAN",
+			expected: "true",
+		},
+		{
+			name:     "BOOL is not a boolean",
+			value:    int64(1),
+			declType: "BOOL",
+			expected: int64(1),
+		},
+		{
+			name:     "unix seconds",
+			value:    int64(1600000000),
+			declType: "DATETIME",
+			expected: time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC),
+		},
+		{
+			name:     "unix milliseconds",
+			value:    int64(1600000000123),
+			declType: "timestamp",
+			expected: time.Date(2020, 9, 13, 12, 26, 40, 123000000, time.UTC),
+		},
+		{
+			name:     "unix seconds with loc",
+			value:    int64(1600000000),
+			declType: "DATE",
+			loc:      est,
+			expected: time.Date(2020, 9, 13, 8, 26, 40, 0, est),
+		},
+		{
+			name:     "text written for a bound time.Time",
+			value:    "2020-09-13 14:26:40.5+02:00",
+			declType: "DATETIME",
+			expected: time.Date(2020, 9, 13, 14, 26, 40, 500000000, plus2),
+		},
+		{
+			name:     "text with a T separator and Z suffix",
+			value:    "2020-09-13T12:26:40Z",
+			declType: "TIMESTAMP",
+			expected: time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC),
+		},
+		{
+			name:     "text date",
+			value:    "2020-09-13",
+			declType: "DATE",
+			expected: time.Date(2020, 9, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "text date with loc",
+			value:    "2020-09-13 12:26",
+			declType: "DATE",
+			loc:      est,
+			expected: time.Date(2020, 9, 13, 8, 26, 0, 0, est),
+		},
+		{
+			name:     "unparseable text is the zero time",
+			value:    "yesterday",
+			declType: "DATETIME",
+			expected: time.Time{},
+		},
+		{
+			name:     "reals are unchanged",
+			value:    float64(1.5),
+			declType: "DATETIME",
+			expected: float64(1.5),
+		},
+		{
+			name:     "NULLs are unchanged",
+			value:    nil,
+			declType: "DATETIME",
+			expected: nil,
+		},
+	} {
+		tt.Run(test.name, func(t *testing.T) {
+			v := convertValue(test.value, test.declType, test.loc)
+			if expected, ok := test.expected.(time.Time); ok {
+				require.IsType(t, time.Time{}, v)
+				require.True(t, expected.Equal(v.(time.Time)), "expected %s, got %s", expected, v)
+				require.Equal(t, expected.Location().String(), v.(time.Time).Location().String())
+				_, expectedOffset := expected.Zone()
+				_, offset := v.(time.Time).Zone()
+				require.Equal(t, expectedOffset, offset)
+			} else {
+				require.Equal(t, test.expected, v)
+			}
+		})
+	}
+}